@@ -0,0 +1,215 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAlertIntervalMs = 1000
+	minAlertIntervalMs     = 100
+)
+
+// AnalogAlertConfig raises an alert when an analog reader crosses a threshold, e.g. for
+// overcurrent or overtemperature. At least one condition must be set; the alert is raised
+// while any holds.
+type AnalogAlertConfig struct {
+	// Name identifies the alert in logs and, with Tick, names its digital interrupt.
+	Name string `json:"name"`
+	// Above and Below raise the alert while the calibrated reading is above or below them.
+	Above *float64 `json:"above,omitempty"`
+	Below *float64 `json:"below,omitempty"`
+	// RisePerSec and FallPerSec raise the alert while the reading rises or falls faster
+	// than this many units per second between two samples.
+	RisePerSec *float64 `json:"rise_per_s,omitempty"`
+	FallPerSec *float64 `json:"fall_per_s,omitempty"`
+	// Hysteresis is how far back past Above or Below the reading must go to clear the
+	// alert, so a reading that hovers at the threshold does not flap.
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+	// Tick makes the alert a digital interrupt named Name, which ticks high when it is
+	// raised and low when it clears.
+	Tick bool `json:"tick,omitempty"`
+}
+
+func (cfg *AnalogAlertConfig) validate(path string) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if cfg.Above == nil && cfg.Below == nil && cfg.RisePerSec == nil && cfg.FallPerSec == nil {
+		return fmt.Errorf("%s: set at least one of 'above', 'below', 'rise_per_s' or 'fall_per_s'", path)
+	}
+	if cfg.Above != nil && cfg.Below != nil && *cfg.Below >= *cfg.Above {
+		return fmt.Errorf("%s: 'below' must be less than 'above', or the alert is always raised", path)
+	}
+	for key, rate := range map[string]*float64{"rise_per_s": cfg.RisePerSec, "fall_per_s": cfg.FallPerSec} {
+		if rate != nil && *rate <= 0 {
+			return fmt.Errorf("%s: '%s' must be positive", path, key)
+		}
+	}
+	if cfg.Hysteresis < 0 {
+		return fmt.Errorf("%s: 'hysteresis' must not be negative", path)
+	}
+	if cfg.Above != nil && cfg.Below != nil && 2*cfg.Hysteresis >= *cfg.Above-*cfg.Below {
+		return fmt.Errorf("%s: 'hysteresis' must be less than half the gap between 'below' and 'above', or the alert never clears", path)
+	}
+	return nil
+}
+
+// crossed returns the reasons the alert holds for value and its rate of change in units
+// per second. rateKnown is false for the first sample. Once the alert is active, Above and
+// Below only stop holding past the hysteresis.
+func (cfg *AnalogAlertConfig) crossed(value, rate float64, rateKnown, active bool) []string {
+	margin := 0.0
+	if active {
+		margin = cfg.Hysteresis
+	}
+	var reasons []string
+	if cfg.Above != nil && value > *cfg.Above-margin {
+		reasons = append(reasons, fmt.Sprintf("%.4g above %.4g", value, *cfg.Above))
+	}
+	if cfg.Below != nil && value < *cfg.Below+margin {
+		reasons = append(reasons, fmt.Sprintf("%.4g below %.4g", value, *cfg.Below))
+	}
+	if rateKnown && cfg.RisePerSec != nil && rate > *cfg.RisePerSec {
+		reasons = append(reasons, fmt.Sprintf("rising %.4g/s", rate))
+	}
+	if rateKnown && cfg.FallPerSec != nil && -rate > *cfg.FallPerSec {
+		reasons = append(reasons, fmt.Sprintf("falling %.4g/s", -rate))
+	}
+	return reasons
+}
+
+type analogAlert struct {
+	cfg AnalogAlertConfig
+	// tickPin is the pin number ticks are dispatched on, a negative number no GPIO has,
+	// or 0 without Tick.
+	tickPin int
+	active  bool
+}
+
+// readerAlerts are the alerts of one analog reader and its previous sample.
+type readerAlerts struct {
+	analogName string
+	pinNum     int
+	interval   time.Duration
+	// units, if set, converts the calibrated count the alerts are checked against.
+	units *analogUnits
+
+	mu     sync.Mutex
+	alerts []*analogAlert
+	last   float64
+	lastAt time.Time
+}
+
+// newReaderAlerts returns the alerts of cfg, allocating a tick pin for each alert that
+// ticks from nextTickPin down.
+func newReaderAlerts(cfg *AnalogConfig, pinNum int, nextTickPin *int) *readerAlerts {
+	intervalMs := cfg.AlertIntervalMs
+	if intervalMs == 0 {
+		intervalMs = defaultAlertIntervalMs
+	}
+	r := &readerAlerts{
+		analogName: cfg.Name,
+		pinNum:     pinNum,
+		interval:   time.Duration(intervalMs) * time.Millisecond,
+		units:      newAnalogUnits(cfg.Units),
+	}
+	for _, alertCfg := range cfg.Alerts {
+		alert := &analogAlert{cfg: alertCfg}
+		if alertCfg.Tick {
+			*nextTickPin--
+			alert.tickPin = *nextTickPin
+		}
+		r.alerts = append(r.alerts, alert)
+	}
+	return r
+}
+
+// pollAlerts reads the reader's pin every interval and checks its alerts, until the board
+// is closed.
+func (s *esp32Board) pollAlerts(r *readerAlerts) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(s.cancelCtx, r.interval)
+		read, err := s.readPinNum(ctx, r.pinNum, callOptions{samples: 1, noRetry: true})
+		cancel()
+		if err != nil {
+			if s.cancelCtx.Err() == nil {
+				s.logger.Debugf("failed to read analog %q for its alerts: %v", r.analogName, err)
+			}
+			continue
+		}
+		s.checkAlerts(r, s.states.counts(read.State), time.Now())
+	}
+}
+
+// checkAlerts checks a raw sample of the reader taken at at against its alerts, in the
+// reader's units if it has them, logging and ticking the ones that were raised or cleared.
+func (s *esp32Board) checkAlerts(r *readerAlerts, raw float64, at time.Time) {
+	value := s.calibrated(r.pinNum, raw)
+	if r.units != nil {
+		value = r.units.convert(value)
+	}
+
+	r.mu.Lock()
+	rate, rateKnown := 0.0, false
+	if dt := at.Sub(r.lastAt).Seconds(); !r.lastAt.IsZero() && dt > 0 {
+		rate, rateKnown = (value-r.last)/dt, true
+	}
+	r.last, r.lastAt = value, at
+	type change struct {
+		alert   *analogAlert
+		raised  bool
+		reasons []string
+	}
+	var changes []change
+	for _, alert := range r.alerts {
+		reasons := alert.cfg.crossed(value, rate, rateKnown, alert.active)
+		if active := len(reasons) > 0; active != alert.active {
+			alert.active = active
+			changes = append(changes, change{alert, active, reasons})
+		}
+	}
+	r.mu.Unlock()
+
+	for _, c := range changes {
+		if c.raised {
+			s.logger.Warnf("analog %q alert %q raised: %s", r.analogName, c.alert.cfg.Name, strings.Join(c.reasons, ", "))
+		} else {
+			s.logger.Infof("analog %q alert %q cleared at %.4g", r.analogName, c.alert.cfg.Name, value)
+		}
+		if c.alert.tickPin != 0 {
+			s.dispatchTick(c.alert.tickPin, c.raised, at)
+		}
+	}
+}
+
+// activeAlerts returns the names of the alerts that are raised, sorted.
+func (s *esp32Board) activeAlerts() []interface{} {
+	var names []string
+	for _, r := range s.alerts {
+		r.mu.Lock()
+		for _, alert := range r.alerts {
+			if alert.active {
+				names = append(names, alert.cfg.Name)
+			}
+		}
+		r.mu.Unlock()
+	}
+	sort.Strings(names)
+	active := make([]interface{}, len(names))
+	for i, name := range names {
+		active[i] = name
+	}
+	return active
+}
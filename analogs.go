@@ -0,0 +1,140 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"esp32wifi/esp32client"
+)
+
+// adcRanges are the attenuations of the ESP32 ADC and the largest input in millivolts
+// each reads accurately, smallest first.
+var adcRanges = []struct {
+	attenuationDB float64
+	maxMV         int
+}{
+	{0, 950},
+	{2.5, 1250},
+	{6, 1750},
+	{11, 3100},
+}
+
+// AnalogConfig declares an analog reader, following the RDK board config.
+type AnalogConfig struct {
+	// Name is what AnalogByName looks the reader up by, e.g. "battery".
+	Name string `json:"name"`
+	// Pin is a pin name or a GPIO number.
+	Pin string `json:"pin"`
+	// AverageOverMillis and SamplesPerSecond make the firmware sample the pin in the
+	// background and return the average of the window on each read.
+	AverageOverMillis int `json:"average_over_ms,omitempty"`
+	SamplesPerSecond  int `json:"samples_per_sec,omitempty"`
+	// RangeMV is the largest input in millivolts. It selects the smallest ADC attenuation
+	// that covers it; the firmware default is kept if unset.
+	RangeMV int `json:"range_mv,omitempty"`
+	// Alerts are raised when the reading crosses a threshold. The pin is read every
+	// AlertIntervalMs, 1000 by default, while the reader has alerts.
+	Alerts          []AnalogAlertConfig `json:"alerts,omitempty"`
+	AlertIntervalMs int                 `json:"alert_interval_ms,omitempty"`
+	// Units, if set, converts readings to an engineering unit, e.g. PSI.
+	Units *AnalogUnitsConfig `json:"units,omitempty"`
+}
+
+func (cfg *AnalogConfig) validate(path string, pins *pinTable) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if _, err := strconv.Atoi(cfg.Name); err == nil {
+		return fmt.Errorf("%s: 'name' %q must not be a number", path, cfg.Name)
+	}
+	if cfg.Pin == "" {
+		return fmt.Errorf("%s: missing required field 'pin'", path)
+	}
+	pinNum, err := pins.lookup(cfg.Pin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := pins.checkRead(pinNum); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if pin, ok := pins.pins[pinNum]; ok && pin.Mode != pinModeAnalog {
+		return fmt.Errorf("%s: GPIO%d is configured as %s, not analog", path, pinNum, pin.Mode)
+	}
+	variant, err := lookupChipVariant(pins.variantName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if variant.adc[pinNum] == 0 {
+		return fmt.Errorf("%s: GPIO%d has no ADC channel on the %s", path, pinNum, pins.variantName)
+	}
+	if cfg.AverageOverMillis < 0 || cfg.SamplesPerSecond < 0 {
+		return fmt.Errorf("%s: 'average_over_ms' and 'samples_per_sec' must not be negative", path)
+	}
+	if (cfg.AverageOverMillis == 0) != (cfg.SamplesPerSecond == 0) {
+		return fmt.Errorf("%s: set both 'average_over_ms' and 'samples_per_sec' to average readings", path)
+	}
+	if cfg.RangeMV < 0 || cfg.RangeMV > adcRanges[len(adcRanges)-1].maxMV {
+		return fmt.Errorf("%s: 'range_mv' must be between 0 and %d", path, adcRanges[len(adcRanges)-1].maxMV)
+	}
+	if cfg.Units != nil {
+		if err := cfg.Units.validate(path + ".units"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// analogReader is a configured analog reader.
+type analogReader struct {
+	pinNum int
+	// rangeMV is zero if the range is not configured.
+	rangeMV int
+	// units is nil unless readings are converted to an engineering unit.
+	units *analogUnits
+	setup esp32client.AnalogConfig
+}
+
+// newAnalogReader resolves cfg, which must be valid.
+func newAnalogReader(cfg *AnalogConfig, pins *pinTable) analogReader {
+	pinNum, _ := pins.lookup(cfg.Pin)
+	reader := analogReader{
+		pinNum: pinNum,
+		units:  newAnalogUnits(cfg.Units),
+		setup: esp32client.AnalogConfig{
+			PinNum:            pinNum,
+			AverageOverMillis: cfg.AverageOverMillis,
+			SamplesPerSecond:  cfg.SamplesPerSecond,
+		},
+	}
+	if cfg.RangeMV > 0 {
+		for _, r := range adcRanges {
+			if cfg.RangeMV <= r.maxMV {
+				attenuation := r.attenuationDB
+				reader.setup.AttenuationDB = &attenuation
+				reader.rangeMV = r.maxMV
+				break
+			}
+		}
+	}
+	return reader
+}
+
+// analogPin resolves an analog reader name, falling back to pin names and numbers for
+// readers that are not configured.
+func (s *esp32Board) analogPin(name string) (int, error) {
+	if reader, ok := s.analogs[name]; ok {
+		return reader.pinNum, nil
+	}
+	return s.pins.lookup(name)
+}
+
+// analogSetup configures a reader on the firmware.
+func (s *esp32Board) analogSetup(name string, reader analogReader) deviceSetup {
+	return deviceSetup{
+		what: fmt.Sprintf("analog %q", name),
+		apply: func(ctx context.Context) error {
+			return esp32client.ConfigureAnalog(ctx, s.client, reader.setup)
+		},
+	}
+}
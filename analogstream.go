@@ -0,0 +1,407 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultAnalogStreamHz = 10
+	maxAnalogStreamHz     = 100
+	// analogStreamTimeout bounds a request starting or stopping a stream.
+	analogStreamTimeout = 5 * time.Second
+	// analogStreamCheckInterval is how often streams are checked for samples that stopped
+	// arriving.
+	analogStreamCheckInterval = time.Second
+	// minAnalogStreamStale is the shortest silence after which a stream is asked for
+	// again, however fast it samples.
+	minAnalogStreamStale = 2 * time.Second
+)
+
+// AnalogSample is a sample of a streamed analog pin delivered to SubscribeAnalog
+// callbacks.
+type AnalogSample struct {
+	// Name is the analog reader or pin the subscription named.
+	Name string
+	// Raw is the ADC count and Value the calibrated reading.
+	Raw, Value float64
+	// TimestampNanosec is when the pin was sampled, on the host clock.
+	TimestampNanosec uint64
+}
+
+// AnalogSubscriber is implemented by the boards in this package. On a BLE board the
+// firmware pushes the samples, which is much faster than polling reads over GATT; other
+// transports return ErrNotSupported.
+type AnalogSubscriber interface {
+	// SubscribeAnalog calls fn with every sample of the named analog reader or pin,
+	// streamed rateHz times a second. Subscriptions to the same pin share its stream,
+	// at the rate last asked for. The returned func stops the subscription and waits for
+	// a callback in progress, so it must not be called from fn; subscriptions also stop
+	// when the board is closed.
+	SubscribeAnalog(name string, rateHz float64, fn func(AnalogSample)) (func(), error)
+}
+
+// analogStreamer is implemented by transports the firmware can push analog samples
+// over, such as BLE.
+type analogStreamer interface {
+	StreamAnalog(ctx context.Context, pin int, rateHz float64, fn func(esp32client.AnalogSample)) error
+	StopAnalogStream(ctx context.Context, pin int) error
+}
+
+// analogStreams are the board's streamed analog pins.
+type analogStreams struct {
+	mu      sync.Mutex
+	started bool
+	streams map[int]*analogStream
+	nextID  int
+
+	// callbackMu is held for reading while callbacks run, so an unsubscribe can wait for
+	// them.
+	callbackMu sync.RWMutex
+}
+
+// analogStream is one streamed pin.
+type analogStream struct {
+	rateHz float64
+	// commanded is set while subscribe_analog holds the stream.
+	commanded bool
+	callbacks map[int]analogCallback
+	// since is when the stream was last asked for, and latest and latestAt the last
+	// sample.
+	since    time.Time
+	latest   float64
+	latestAt time.Time
+	samples  int
+}
+
+type analogCallback struct {
+	name string
+	fn   func(AnalogSample)
+}
+
+func newAnalogStreams() *analogStreams {
+	return &analogStreams{streams: map[int]*analogStream{}}
+}
+
+// stale returns how long the stream may go without a sample before it is asked for again.
+func (st *analogStream) stale() time.Duration {
+	stale := time.Duration(5 * float64(time.Second) / st.rateHz)
+	if stale < minAnalogStreamStale {
+		return minAnalogStreamStale
+	}
+	return stale
+}
+
+// streamRead returns the last sample of a streamed pin, if it is no older than three
+// sample periods and opts do not ask for a fresh or averaged reading.
+func (s *esp32Board) streamRead(pinNum int, opts callOptions) (float64, bool) {
+	if opts.fresh || opts.samples > 1 || len(opts.forward) > 0 {
+		return 0, false
+	}
+	a := s.analogStreams
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, ok := a.streams[pinNum]
+	if !ok || st.latestAt.IsZero() || time.Since(st.latestAt) > time.Duration(3*float64(time.Second)/st.rateHz) {
+		return 0, false
+	}
+	return st.latest, true
+}
+
+// cachedRead returns a reading of pinNum the board already has, from its stream or the
+// last wake window.
+func (s *esp32Board) cachedRead(pinNum int, opts callOptions) (float64, bool) {
+	if state, ok := s.streamRead(pinNum, opts); ok {
+		return state, true
+	}
+	if state, ok := s.wakeRead(pinNum, opts); ok {
+		return s.states.counts(state), true
+	}
+	return 0, false
+}
+
+// streamer returns the transport's analog streaming.
+func (s *esp32Board) streamer() (analogStreamer, error) {
+	streamer, ok := transport(s.client).(analogStreamer)
+	if !ok {
+		return nil, fmt.Errorf("analog streaming needs the BLE transport: %w", ErrNotSupported)
+	}
+	return streamer, nil
+}
+
+// analogStreamPin resolves the analog reader or pin name to stream.
+func (s *esp32Board) analogStreamPin(name string) (int, error) {
+	pinNum, err := s.analogPin(name)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.pins.checkRead(pinNum); err != nil {
+		return 0, err
+	}
+	return pinNum, nil
+}
+
+// streamAnalog starts or re-rates the stream of pinNum and applies add to it, starting the
+// "analog_streams" worker on first use.
+func (s *esp32Board) streamAnalog(ctx context.Context, pinNum int, rateHz float64, add func(*analogStream)) error {
+	if rateHz <= 0 || rateHz > maxAnalogStreamHz {
+		return fmt.Errorf("'rate_hz' must be above 0 and at most %d, got %g", maxAnalogStreamHz, rateHz)
+	}
+	streamer, err := s.streamer()
+	if err != nil {
+		return err
+	}
+	a := s.analogStreams
+	a.mu.Lock()
+	if !a.started {
+		if !s.goBackground("analog_streams", s.watchAnalogStreams) {
+			a.mu.Unlock()
+			return errBoardClosed
+		}
+		a.started = true
+	}
+	st, streaming := a.streams[pinNum]
+	a.mu.Unlock()
+
+	if !streaming || st.rateHz != rateHz {
+		ctx, cancel := context.WithTimeout(ctx, analogStreamTimeout)
+		err := streamer.StreamAnalog(ctx, pinNum, rateHz, func(sample esp32client.AnalogSample) {
+			s.handleAnalogSample(pinNum, sample)
+		})
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, streaming = a.streams[pinNum]
+	if !streaming {
+		st = &analogStream{callbacks: map[int]analogCallback{}}
+		a.streams[pinNum] = st
+	}
+	if st.rateHz != rateHz {
+		st.rateHz, st.since = rateHz, time.Now()
+	}
+	add(st)
+	return nil
+}
+
+// releaseAnalog applies drop to the stream of pinNum, and stops the stream if nothing
+// holds it anymore.
+func (s *esp32Board) releaseAnalog(ctx context.Context, pinNum int, drop func(*analogStream)) error {
+	a := s.analogStreams
+	a.mu.Lock()
+	st, ok := a.streams[pinNum]
+	if !ok {
+		a.mu.Unlock()
+		return nil
+	}
+	drop(st)
+	held := st.commanded || len(st.callbacks) > 0
+	if !held {
+		delete(a.streams, pinNum)
+	}
+	a.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	streamer, err := s.streamer()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, analogStreamTimeout)
+	defer cancel()
+	return streamer.StopAnalogStream(ctx, pinNum)
+}
+
+// handleAnalogSample caches a pushed sample for Read and hands it to the subscribers.
+func (s *esp32Board) handleAnalogSample(pinNum int, sample esp32client.AnalogSample) {
+	at := s.tickTime(sample.TimestampUs)
+	raw := s.states.counts(sample.State)
+	a := s.analogStreams
+	a.mu.Lock()
+	st, ok := a.streams[pinNum]
+	var callbacks []analogCallback
+	if ok {
+		st.latest, st.latestAt = raw, time.Now()
+		st.samples++
+		for _, callback := range st.callbacks {
+			callbacks = append(callbacks, callback)
+		}
+	}
+	a.mu.Unlock()
+	if len(callbacks) == 0 {
+		return
+	}
+
+	value := s.calibrated(pinNum, raw)
+	a.callbackMu.RLock()
+	defer a.callbackMu.RUnlock()
+	for _, callback := range callbacks {
+		callback.fn(AnalogSample{Name: callback.name, Raw: raw, Value: value, TimestampNanosec: uint64(at.UnixNano())})
+	}
+}
+
+// watchAnalogStreams asks again for streams whose samples stopped arriving, e.g. because
+// the device rebooted and forgot them, until the board is closed.
+func (s *esp32Board) watchAnalogStreams() {
+	ticker := time.NewTicker(analogStreamCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		streamer, err := s.streamer()
+		if err != nil {
+			return
+		}
+
+		type resend struct {
+			pinNum int
+			rateHz float64
+		}
+		var resends []resend
+		a := s.analogStreams
+		a.mu.Lock()
+		for pinNum, st := range a.streams {
+			last := st.latestAt
+			if last.Before(st.since) {
+				last = st.since
+			}
+			if time.Since(last) > st.stale() {
+				resends = append(resends, resend{pinNum, st.rateHz})
+				st.since = time.Now()
+			}
+		}
+		a.mu.Unlock()
+
+		for _, r := range resends {
+			s.logger.Debugf("no samples from streamed GPIO%d, asking for them again", r.pinNum)
+			ctx, cancel := context.WithTimeout(s.cancelCtx, analogStreamTimeout)
+			pinNum := r.pinNum
+			err := streamer.StreamAnalog(ctx, pinNum, r.rateHz, func(sample esp32client.AnalogSample) {
+				s.handleAnalogSample(pinNum, sample)
+			})
+			cancel()
+			if err != nil && s.cancelCtx.Err() == nil {
+				s.logger.Debugf("failed to stream GPIO%d: %v", pinNum, err)
+			}
+		}
+	}
+}
+
+// SubscribeAnalog calls fn with every sample of the named analog reader or pin.
+func (s *esp32Board) SubscribeAnalog(name string, rateHz float64, fn func(AnalogSample)) (func(), error) {
+	if fn == nil {
+		return nil, fmt.Errorf("subscribe to analog %s: callback must not be nil", name)
+	}
+	pinNum, err := s.analogStreamPin(name)
+	if err != nil {
+		return nil, err
+	}
+	var id int
+	if err := s.streamAnalog(s.cancelCtx, pinNum, rateHz, func(st *analogStream) {
+		s.analogStreams.nextID++
+		id = s.analogStreams.nextID
+		st.callbacks[id] = analogCallback{name: name, fn: fn}
+	}); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			err := s.releaseAnalog(s.cancelCtx, pinNum, func(st *analogStream) { delete(st.callbacks, id) })
+			if err != nil && s.cancelCtx.Err() == nil {
+				s.logger.Debugf("failed to stop streaming %s: %v", name, err)
+			}
+			// Waits for a callback in progress.
+			s.analogStreams.callbackMu.Lock()
+			s.analogStreams.callbackMu.Unlock()
+		})
+	}, nil
+}
+
+// doSubscribeAnalog has the firmware push samples of an analog reader or pin, which Read
+// answers from until unsubscribe_analog, rate_hz (10 by default) times a second.
+//
+//	{"command": "subscribe_analog", "pin": "battery", "rate_hz": 20}
+func (s *esp32Board) doSubscribeAnalog(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, pinNum, err := s.analogStreamArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	rateHz, err := optionalNumberArg(cmd, "rate_hz", defaultAnalogStreamHz)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.streamAnalog(ctx, pinNum, rateHz, func(st *analogStream) { st.commanded = true }); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"pin": name, "pin_num": pinNum, "rate_hz": rateHz}, nil
+}
+
+// doUnsubscribeAnalog stops a stream subscribe_analog started, unless a SubscribeAnalog
+// callback still uses it.
+//
+//	{"command": "unsubscribe_analog", "pin": "battery"}
+func (s *esp32Board) doUnsubscribeAnalog(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, pinNum, err := s.analogStreamArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.releaseAnalog(ctx, pinNum, func(st *analogStream) { st.commanded = false }); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"pin": name, "pin_num": pinNum}, nil
+}
+
+// analogStreamArg resolves the "pin" argument, an analog reader name, pin name or number.
+func (s *esp32Board) analogStreamArg(cmd map[string]interface{}) (string, int, error) {
+	if number, ok := cmd["pin"].(float64); ok {
+		name := strconv.Itoa(int(number))
+		pinNum, err := s.analogStreamPin(name)
+		return name, pinNum, err
+	}
+	name, err := stringArg(cmd, "pin")
+	if err != nil {
+		return "", 0, err
+	}
+	pinNum, err := s.analogStreamPin(name)
+	return name, pinNum, err
+}
+
+// status describes the streamed pins, for status. It returns nil if there are none.
+func (a *analogStreams) status(pins *pinTable) map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.streams) == 0 {
+		return nil
+	}
+	pinNums := make([]int, 0, len(a.streams))
+	for pinNum := range a.streams {
+		pinNums = append(pinNums, pinNum)
+	}
+	sort.Ints(pinNums)
+	status := map[string]interface{}{}
+	for _, pinNum := range pinNums {
+		st := a.streams[pinNum]
+		stream := map[string]interface{}{"rate_hz": st.rateHz, "samples": st.samples}
+		if !st.latestAt.IsZero() {
+			stream["age_ms"] = time.Since(st.latestAt).Milliseconds()
+		}
+		status[pins.name(pinNum)] = stream
+	}
+	return status
+}
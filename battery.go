@@ -0,0 +1,172 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultWakeIntervalMs = 60000
+	minWakeIntervalMs     = 5000
+	defaultWakeWindowMs   = 2000
+	wakeReadTimeout       = 5 * time.Second
+)
+
+// BatteryModeConfig batches the board's background traffic into wake windows, so a
+// battery-powered device in light sleep wakes as rarely as possible.
+type BatteryModeConfig struct {
+	// WakeIntervalMs is the time between two windows, 60000 by default. Windows start
+	// at multiples of it, so boards with the same interval wake together.
+	WakeIntervalMs int `json:"wake_interval_ms,omitempty"`
+	// WindowMs is how long the firmware stays awake in each window, 2000 by default.
+	WindowMs int `json:"window_ms,omitempty"`
+}
+
+func (cfg *BatteryModeConfig) validate(path string) error {
+	if cfg.WakeIntervalMs != 0 && cfg.WakeIntervalMs < minWakeIntervalMs {
+		return fmt.Errorf("%s: 'wake_interval_ms' must be at least %d, got %d", path, minWakeIntervalMs, cfg.WakeIntervalMs)
+	}
+	if cfg.WindowMs < 0 {
+		return fmt.Errorf("%s: 'window_ms' must not be negative", path)
+	}
+	if cfg.window() >= cfg.interval() {
+		return fmt.Errorf("%s: 'window_ms' must be shorter than 'wake_interval_ms'", path)
+	}
+	return nil
+}
+
+func (cfg *BatteryModeConfig) interval() time.Duration {
+	if cfg.WakeIntervalMs == 0 {
+		return defaultWakeIntervalMs * time.Millisecond
+	}
+	return time.Duration(cfg.WakeIntervalMs) * time.Millisecond
+}
+
+func (cfg *BatteryModeConfig) window() time.Duration {
+	if cfg.WindowMs == 0 {
+		return defaultWakeWindowMs * time.Millisecond
+	}
+	return time.Duration(cfg.WindowMs) * time.Millisecond
+}
+
+// nextWake returns the start of the first window after now.
+func nextWake(now time.Time, interval time.Duration) time.Time {
+	return now.Truncate(interval).Add(interval)
+}
+
+// wakeReads holds the analog readings of the last wake window.
+type wakeReads struct {
+	mu    sync.Mutex
+	at    time.Time
+	reads map[int]float64
+}
+
+// get returns the reading of pinNum from a window no older than maxAge.
+func (w *wakeReads) get(pinNum int, maxAge time.Duration) (float64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.reads[pinNum]
+	if !ok || time.Since(w.at) > maxAge {
+		return 0, false
+	}
+	return state, true
+}
+
+func (w *wakeReads) set(reads []esp32client.PinRead, at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.at = at
+	w.reads = make(map[int]float64, len(reads))
+	for _, read := range reads {
+		w.reads[read.PinNum] = read.State
+	}
+}
+
+// wakeRead returns the pin's reading from the last wake window, if battery mode is on and
+// opts do not ask for a fresh or averaged reading.
+func (s *esp32Board) wakeRead(pinNum int, opts callOptions) (float64, bool) {
+	if s.batteryMode == nil || opts.fresh || opts.samples > 1 || len(opts.forward) > 0 {
+		return 0, false
+	}
+	cfg := s.batteryMode
+	return s.wakeReads.get(pinNum, cfg.interval()+cfg.window())
+}
+
+// wakeScheduleSetup tells the firmware when the windows are, so it can sleep in between.
+func (s *esp32Board) wakeScheduleSetup(cfg *BatteryModeConfig) deviceSetup {
+	return deviceSetup{
+		what: "wake schedule",
+		apply: func(ctx context.Context) error {
+			interval := cfg.interval()
+			return esp32client.SetWakeSchedule(ctx, s.client, esp32client.WakeSchedule{
+				IntervalMs: int(interval.Milliseconds()),
+				WindowMs:   int(cfg.window().Milliseconds()),
+				NextWakeMs: int(time.Until(nextWake(time.Now(), interval)).Milliseconds()),
+			})
+		},
+	}
+}
+
+// runWakeWindows does the background work the board otherwise spreads over several
+// loops, clock sync, power monitoring, keep-alives and analog alerts, once per window, and
+// reads every analog pin so Read can answer from the window. A reboot starts a window right away.
+func (s *esp32Board) runWakeWindows(cfg *BatteryModeConfig) {
+	interval := cfg.interval()
+	p, canPing := transport(s.client).(pinger)
+	analogPinNums := s.analogPinNums()
+	syncClock, monitorPower := true, true
+	for {
+		start := time.Now()
+		if len(analogPinNums) > 0 {
+			ctx, cancel := context.WithTimeout(s.cancelCtx, wakeReadTimeout)
+			reads, err := s.client.ReadPins(ctx, analogPinNums)
+			cancel()
+			if err == nil {
+				for i := range reads {
+					// Older firmware does not echo pin_num back.
+					reads[i].PinNum = analogPinNums[i]
+				}
+				s.wakeReads.set(reads, start)
+				for _, alerts := range s.alerts {
+					if state, ok := s.wakeReads.get(alerts.pinNum, interval); ok {
+						s.checkAlerts(alerts, state, start)
+					}
+				}
+			} else if s.cancelCtx.Err() == nil {
+				s.logger.Debugf("failed to read analog pins in wake window: %v", err)
+			}
+		}
+		if syncClock {
+			syncClock = s.sampleClock()
+		}
+		if monitorPower {
+			monitorPower = s.pollPower()
+		}
+		// Any request above kept the connection alive, so only ping in a window that
+		// sent none.
+		if canPing && s.conn.idleFor() > time.Since(start) {
+			ctx, cancel := context.WithTimeout(s.cancelCtx, keepAliveTimeout)
+			pingStart := time.Now()
+			err := p.Ping(ctx)
+			cancel()
+			if err != nil && s.cancelCtx.Err() == nil {
+				s.logger.Debugf("keep-alive failed: %v", err)
+			}
+			s.conn.record(err, time.Since(pingStart))
+		}
+
+		timer := time.NewTimer(time.Until(nextWake(time.Now(), interval)))
+		select {
+		case <-s.cancelCtx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.clockResync:
+			timer.Stop()
+		}
+	}
+}
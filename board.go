@@ -0,0 +1,807 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	pb "go.viam.com/api/component/board/v1"
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+
+	"esp32wifi/esp32client"
+)
+
+// safeStateTimeout bounds how long Close waits to apply safe states.
+const safeStateTimeout = 2 * time.Second
+
+// esp32Board implements board.Board on top of an esp32client.Client. The models in this
+// package embed it and only differ in how they configure and open the client.
+type esp32Board struct {
+	resource.AlwaysRebuild
+
+	name resource.Name
+
+	logger logging.Logger
+	client esp32client.Client
+	pins   *pinTable
+	health deviceHealth
+	conn   connMonitor
+	// pinActivity is what requests to the device did with each pin.
+	pinActivity pinTracker
+
+	capabilities pinCapabilities
+
+	clock       clockSync
+	clockResync chan struct{}
+
+	// interrupts and analogs are the configured digital interrupts and analog readers
+	// by name.
+	interrupts map[string]esp32client.InterruptConfig
+	analogs    map[string]analogReader
+	// differentials are the configured differential analogs.
+	differentials map[string]differentialAnalog
+
+	// alerts are the analog readers with alerts, and alertTicks the pins the ticks of
+	// alerts with tick are dispatched on, by alert name.
+	alerts     []*readerAlerts
+	alertTicks map[string]int
+
+	// setups are applied to the firmware on startup and again on reconfigure.
+	setups      []deviceSetup
+	reconfigure chan struct{}
+
+	interruptMu     sync.Mutex
+	interruptCounts map[int]int64
+	// counters are the interrupts the firmware counts itself.
+	counters    *interruptCounters
+	tickStreams map[*tickStream]struct{}
+	// polls are the interrupts the module reads the pins of.
+	polls *interruptPolls
+
+	schedulesMu sync.Mutex
+	schedules   map[int]esp32client.Schedule
+
+	calibrationMu sync.Mutex
+	calibrations  map[int]*calibration
+
+	filterMu sync.Mutex
+	filters  map[int]*sampleFilter
+
+	macros   map[string][]esp32client.MacroStep
+	selfTest *SelfTestConfig
+
+	// groups holds the writes of each state of each pin group.
+	groups map[string]map[string][]esp32client.PinWrite
+	// relays are the board's relays and their interlocks.
+	relays *relaySet
+
+	// writes skips repeated identical pin writes if suppress_repeat_writes is set.
+	writes *writeCache
+	// ledc assigns PWM pins LEDC timers and channels.
+	ledc *ledcAllocator
+
+	power *powerMonitor
+
+	deviceLogs deviceLogs
+
+	// recovery is nil unless the recovery policy is configured, and resetLine unless a
+	// reset line is.
+	recovery  *recovery
+	resetLine *resetLine
+	// lease is nil unless the board leases the device's outputs.
+	lease *lease
+	// noAckWrites sends the writes that do not wait for the device, which are all of Set
+	// and SetPWM's if noAckDefault is set.
+	noAckWrites  *noAckWriter
+	noAckDefault bool
+	// states is the scale the firmware reads pins as.
+	states *stateFormat
+	// bleDevice is the name of the device on models that reach it over BLE, for its
+	// link metrics.
+	bleDevice string
+	// writeMode is the write_mode of models that reach the device over BLE.
+	writeMode string
+	// features are what the firmware can do.
+	features *featureMap
+	// webhooks post pin changes and interrupt ticks to external systems.
+	webhooks []*webhook
+	// analogStreams are the analog pins the firmware pushes samples of.
+	analogStreams *analogStreams
+	// coreDumpCheck asks watchCoreDumps to check the device for a core dump, and
+	// bootCheck asks watchBoots to read its boot info.
+	coreDumpCheck chan struct{}
+	bootCheck     chan struct{}
+
+	// batteryMode is nil unless background work runs in wake windows. wakeReads holds
+	// the analog readings of the last window.
+	batteryMode *BatteryModeConfig
+	wakeReads   wakeReads
+
+	// safeStates are written on Close, if set.
+	safeStates []esp32client.PinWrite
+
+	stopTracing func(context.Context) error
+	// stopDashboard is nil unless a dashboard is served.
+	stopDashboard func(context.Context) error
+
+	// workers runs every goroutine the board starts. cancelCtx is its context, done once
+	// the board is closed.
+	workers   *workerGroup
+	cancelCtx context.Context
+}
+
+// newEsp32Board wraps client in a board. If cfg has a warm-up it waits for the device,
+// bounded by ctx, before returning. deps holds the resources cfg.dependencies names.
+func newEsp32Board(ctx context.Context, deps resource.Dependencies, name resource.Name, cfg *BoardConfig, client esp32client.Client, logger logging.Logger) (*esp32Board, error) {
+	pins, err := newPinTable(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	macros := map[string][]esp32client.MacroStep{}
+	for _, macro := range cfg.Macros {
+		steps, err := macro.resolve(pins)
+		if err != nil {
+			return nil, err
+		}
+		macros[macro.Name] = steps
+	}
+	groups := map[string]map[string][]esp32client.PinWrite{}
+	for _, group := range cfg.PinGroups {
+		states, err := group.resolve(pins)
+		if err != nil {
+			return nil, err
+		}
+		groups[group.Name] = states
+	}
+
+	var recovery *recovery
+	if cfg.Recovery != nil {
+		if recovery, err = newRecovery(cfg.Recovery, deps); err != nil {
+			return nil, err
+		}
+	}
+	var resetLine *resetLine
+	if cfg.ResetLine != nil {
+		if resetLine, err = newResetLine(cfg.ResetLine, deps); err != nil {
+			return nil, err
+		}
+	}
+
+	tracerProvider, stopTracing, err := newTracerProvider(cfg.Tracing, name.ShortName())
+	if err != nil {
+		return nil, err
+	}
+
+	var recording *os.File
+	if cfg.RecordPath != "" {
+		recording, err = os.OpenFile(cfg.RecordPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			stopTracing(ctx)
+			return nil, fmt.Errorf("failed to open 'record_path': %w", err)
+		}
+		logger.Warnf("recording every exchange with the device to %s", cfg.RecordPath)
+		client = esp32client.NewRecorder(client, recording)
+	}
+
+	states := newStateFormat(cfg, pins)
+	var leased *lease
+	if cfg.Lease != nil {
+		leased = newLease(cfg.Lease)
+		client = &leasedClient{Client: client, lease: leased}
+	}
+	if verify := pins.verifyingPins(); verify != nil {
+		client = &verifiedClient{Client: client, pins: pins, verify: verify, states: states}
+	}
+	features := newFeatureMap(logger)
+	client = &featureClient{Client: client, features: features, learn: transportName(client) != "hybrid"}
+
+	// The pin table already checked the variant exists.
+	variant, _ := lookupChipVariant(pins.variantName)
+	workers := newWorkerGroup()
+	s := &esp32Board{
+		name:            name,
+		logger:          logger,
+		pins:            pins,
+		interruptCounts: map[int]int64{},
+		tickStreams:     map[*tickStream]struct{}{},
+		schedules:       map[int]esp32client.Schedule{},
+		calibrations:    map[int]*calibration{},
+		filters:         map[int]*sampleFilter{},
+		clockResync:     make(chan struct{}, 1),
+		coreDumpCheck:   make(chan struct{}, 1),
+		bootCheck:       make(chan struct{}, 1),
+		macros:          macros,
+		groups:          groups,
+		relays:          newRelaySet(cfg.Relays, cfg.Interlocks, pins),
+		selfTest:        cfg.SelfTest,
+		writes:          newWriteCache(cfg.SuppressRepeatWrites),
+		ledc:            newLEDCAllocator(variant),
+		power:           newPowerMonitor(cfg.Power, pins),
+		interrupts:      map[string]esp32client.InterruptConfig{},
+		analogs:         map[string]analogReader{},
+		differentials:   map[string]differentialAnalog{},
+		alertTicks:      map[string]int{},
+		batteryMode:     cfg.BatteryMode,
+		recovery:        recovery,
+		resetLine:       resetLine,
+		lease:           leased,
+		noAckWrites:     newNoAckWriter(),
+		analogStreams:   newAnalogStreams(),
+		counters:        newInterruptCounters(),
+		polls:           newInterruptPolls(),
+		noAckDefault:    cfg.NoAck,
+		states:          states,
+		features:        features,
+
+		reconfigure: make(chan struct{}, 1),
+		workers:     workers,
+		cancelCtx:   workers.ctx,
+		stopTracing: stopTracing,
+	}
+	s.client = &monitoredClient{
+		Client:  newTracedClient(client, tracerProvider, name.ShortName()),
+		monitor: &s.conn,
+		pins:    &s.pinActivity,
+	}
+	if cfg.ApplySafeStateOnClose {
+		s.safeStates = pins.safeStates()
+	}
+	for _, interruptCfg := range cfg.DigitalInterrupts {
+		interrupt := interruptCfg.firmwareConfig(pins)
+		s.interrupts[interruptCfg.Name] = interrupt
+		if interruptCfg.Type == interruptTypePoll {
+			poll := s.polls.add(interrupt.PinNum, interruptCfg.Name, interruptCfg.pollHz(), interrupt.Edge, false)
+			s.logger.Warnf("interrupt %q is polled at %d Hz: %s", interruptCfg.Name, poll.hz, poll.limits())
+			continue
+		}
+		s.setups = append(s.setups, s.interruptSetup(interruptCfg.Name, interrupt))
+		if interrupt.Counter {
+			s.counters.add(interrupt.PinNum, interruptCfg.syncInterval())
+		}
+		if hz := interruptCfg.pollHz(); hz > 0 {
+			s.polls.add(interrupt.PinNum, interruptCfg.Name, hz, interrupt.Edge, true)
+		}
+	}
+	for _, diffCfg := range cfg.DifferentialAnalogs {
+		s.differentials[diffCfg.Name] = newDifferentialAnalog(&diffCfg, pins)
+	}
+	nextTickPin := 0
+	for _, analogCfg := range cfg.Analogs {
+		reader := newAnalogReader(&analogCfg, pins)
+		s.analogs[analogCfg.Name] = reader
+		if len(analogCfg.Alerts) > 0 {
+			alerts := newReaderAlerts(&analogCfg, reader.pinNum, &nextTickPin)
+			s.alerts = append(s.alerts, alerts)
+			for _, alert := range alerts.alerts {
+				if alert.tickPin != 0 {
+					s.alertTicks[alert.cfg.Name] = alert.tickPin
+				}
+			}
+		}
+		if reader.setup.AttenuationDB != nil || reader.setup.AverageOverMillis != 0 {
+			s.setups = append(s.setups, s.analogSetup(analogCfg.Name, reader))
+		}
+	}
+	s.webhooks = s.newWebhooks(cfg.Webhooks)
+	if cfg.BatteryMode != nil {
+		s.setups = append(s.setups, s.wakeScheduleSetup(cfg.BatteryMode))
+	}
+	if modes := pins.openDrainModes(); len(modes) > 0 {
+		s.setups = append(s.setups, s.pinModeSetup("open-drain pins", modes))
+	}
+	loadFromNVS := false
+	for pinNum, pin := range pins.pins {
+		if pin.Calibration != nil {
+			s.calibrations[pinNum] = newCalibration(pin.Calibration)
+			loadFromNVS = loadFromNVS || pin.Calibration.NVSKey != ""
+		}
+		if pin.Filter != nil {
+			s.filters[pinNum] = newSampleFilter(pin.Filter)
+		}
+	}
+
+	if cfg.WarmUp != nil {
+		if err := s.warmUp(ctx, cfg.WarmUp); err != nil {
+			s.workers.stop()
+			// The caller closes the client, but not the recording wrapped around it.
+			if recording != nil {
+				recording.Close()
+			}
+			if stopErr := s.stopTracing(ctx); stopErr != nil {
+				s.logger.Debugf("failed to flush spans: %v", stopErr)
+			}
+			return nil, err
+		}
+	}
+
+	if cfg.Dashboard != nil {
+		// A dashboard that cannot listen is logged rather than failing the board, it is
+		// only a debugging aid.
+		if s.stopDashboard, err = s.serveDashboard(cfg.Dashboard); err != nil {
+			s.logger.Errorf("%v", err)
+		}
+	}
+
+	if s.lease != nil {
+		// Writes fail until the lease is granted, so the first request is not left to the
+		// renewals.
+		if err := s.acquireLease(ctx); err != nil {
+			s.logger.Debugf("failed to acquire the lease, retrying: %v", err)
+		}
+		s.goBackground("lease", s.renewLease)
+	}
+	s.goBackground("probe", s.probeDevice)
+	s.goBackground("capabilities", s.loadCapabilities)
+	s.goBackground("events", s.watchEvents)
+	s.OnConnect(s.checkCoreDump)
+	s.OnReconnect(s.checkCoreDump)
+	s.goBackground("coredumps", s.watchCoreDumps)
+	s.OnConnect(s.checkBoot)
+	s.OnReconnect(s.checkBoot)
+	s.goBackground("boots", s.watchBoots)
+	if cfg.BatteryMode != nil {
+		s.goBackground("wake_windows", func() { s.runWakeWindows(cfg.BatteryMode) })
+	} else {
+		s.goBackground("clock_sync", s.syncClock)
+		s.goBackground("power", s.monitorPower)
+	}
+	if len(s.setups) > 0 {
+		s.goBackground("configure", s.configureDevice)
+	}
+	if s.counters.tick() > 0 {
+		s.goBackground("interrupt_counters", s.syncInterruptCounters)
+	}
+	if s.polls.tick() > 0 {
+		s.goBackground("interrupt_polls", s.pollInterrupts)
+	}
+	if loadFromNVS {
+		s.goBackground("calibrations", s.loadCalibrations)
+	}
+	if s.recovery != nil {
+		s.goBackground("recovery", s.runRecovery)
+	}
+	// In battery mode the wake windows check the alerts.
+	if cfg.BatteryMode == nil {
+		for _, alerts := range s.alerts {
+			s.goBackground("analog_alerts", func() { s.pollAlerts(alerts) })
+		}
+	}
+	s.startWebhooks()
+	return s, nil
+}
+
+// goBackground runs fn in a goroutine that Close waits for. fn must return once
+// s.cancelCtx is done. It returns false without running fn if the board is closed.
+func (s *esp32Board) goBackground(name string, fn func()) bool {
+	return s.workers.start(name, fn)
+}
+
+func (s *esp32Board) Name() resource.Name {
+	return s.name
+}
+
+// AnalogByName returns a configured analog reader, a differential analog or an analog pin
+// by name.
+func (s *esp32Board) AnalogByName(name string) (board.Analog, error) {
+	var analogRetVal board.Analog
+	if diff, ok := s.differentials[name]; ok {
+		return &differentialAnalogClient{esp32Board: s, analogName: name, diff: diff}, nil
+	}
+	if _, err := s.analogPin(name); err != nil {
+		return analogRetVal, err
+	}
+	analogRetVal = &analogClient{
+		esp32Board: s,
+		boardName:  s.name.ShortName(),
+		analogName: name,
+	}
+
+	return analogRetVal, nil
+}
+
+// DigitalInterruptByName returns a digital interrupt by name. Its value counts the
+// interrupt events the firmware has pushed for the pin, or for a counter interrupt is the
+// firmware's count.
+func (s *esp32Board) DigitalInterruptByName(name string) (board.DigitalInterrupt, error) {
+	var digitalInterruptRetVal board.DigitalInterrupt
+	if _, err := s.interruptPin(name); err != nil {
+		return digitalInterruptRetVal, err
+	}
+	digitalInterruptRetVal = &digitalInterruptClient{
+		esp32Board:           s,
+		boardName:            s.name.ShortName(),
+		digitalInterruptName: name,
+	}
+
+	return digitalInterruptRetVal, nil
+}
+
+// GPIOPinByName returns a GPIOPin by name.
+func (s *esp32Board) GPIOPinByName(name string) (board.GPIOPin, error) {
+	var gPIOPinRetVal board.GPIOPin
+	if _, err := s.pins.lookup(name); err != nil {
+		return gPIOPinRetVal, err
+	}
+	gPIOPinRetVal = &gpioPinClient{
+		esp32Board: s,
+		boardName:  s.name.ShortName(),
+		pinName:    name,
+	}
+
+	return gPIOPinRetVal, nil
+}
+
+// SetPowerMode sets the board to the given power mode. If
+// provided, the board will exit the given power mode after
+// the specified duration.
+func (s *esp32Board) SetPowerMode(ctx context.Context, mode pb.PowerMode, duration *time.Duration, extra map[string]interface{}) error {
+	return fmt.Errorf("SetPowerMode not implemented")
+}
+
+// StreamTicks starts a stream of digital interrupt ticks. Ticks are sent on ch until ctx
+// is done or the board is closed.
+func (s *esp32Board) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt, ch chan board.Tick, extra map[string]interface{}) error {
+	stream := &tickStream{ctx: ctx, ch: ch, pins: map[int]bool{}}
+	for _, i := range interrupts {
+		raw, ok := i.(*digitalInterruptClient)
+		if !ok || raw.esp32Board != s {
+			return errors.New("cannot stream ticks to an interrupt not associated with this board")
+		}
+		pinNum, err := s.interruptPin(raw.digitalInterruptName)
+		if err != nil {
+			return err
+		}
+		stream.pins[pinNum] = true
+	}
+
+	s.interruptMu.Lock()
+	s.tickStreams[stream] = struct{}{}
+	s.interruptMu.Unlock()
+
+	removeStream := func() {
+		s.interruptMu.Lock()
+		delete(s.tickStreams, stream)
+		s.interruptMu.Unlock()
+	}
+	if !s.goBackground("tick_stream", func() {
+		select {
+		case <-ctx.Done():
+		case <-s.cancelCtx.Done():
+		}
+		removeStream()
+	}) {
+		removeStream()
+		return errBoardClosed
+	}
+	return nil
+}
+
+func (s *esp32Board) Close(ctx context.Context) error {
+	if len(s.safeStates) > 0 {
+		s.applySafeStates(ctx)
+	}
+	if s.stopDashboard != nil {
+		if err := s.stopDashboard(ctx); err != nil {
+			s.logger.Debugf("failed to stop the dashboard: %v", err)
+		}
+	}
+	s.workers.stop()
+	if s.lease != nil {
+		s.releaseLease(ctx)
+	}
+	err := s.client.Close()
+	if stopErr := s.stopTracing(ctx); stopErr != nil {
+		s.logger.Debugf("failed to flush spans: %v", stopErr)
+	}
+	return err
+}
+
+// applySafeStates drives pins to their safe states before the board disconnects. A
+// failure is logged rather than returned so the board still closes.
+func (s *esp32Board) applySafeStates(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, safeStateTimeout)
+	defer cancel()
+	ctx = s.withWriteMode(ctx, true, callOptions{})
+	s.dropNoAckWrites(s.safeStates)
+	if err := s.client.WritePins(ctx, s.safeStates); err != nil {
+		s.logger.Errorf("failed to drive %d pins to their safe states on close: %v", len(s.safeStates), err)
+		return
+	}
+	s.logger.Infof("drove %d pins to their safe states", len(s.safeStates))
+}
+
+// readPin reads the state of a single pin.
+func (s *esp32Board) readPin(ctx context.Context, name string, opts callOptions) (esp32client.PinRead, error) {
+	pinNum, err := s.pins.lookup(name)
+	if err != nil {
+		return esp32client.PinRead{}, err
+	}
+	return s.readPinNum(ctx, pinNum, opts)
+}
+
+// readPinNum reads the state of a single pin by number.
+func (s *esp32Board) readPinNum(ctx context.Context, pinNum int, opts callOptions) (esp32client.PinRead, error) {
+	if err := s.pins.checkRead(pinNum); err != nil {
+		return esp32client.PinRead{}, err
+	}
+	var reads []esp32client.PinRead
+	if err := s.call(ctx, opts, func(ctx context.Context) error {
+		var err error
+		reads, err = s.client.ReadPins(ctx, []int{pinNum})
+		return err
+	}); err != nil {
+		return esp32client.PinRead{}, err
+	}
+	// Older firmware does not echo pin_num back.
+	reads[0].PinNum = pinNum
+	return reads[0], nil
+}
+
+// writePin sets the state (0-100) of a single pin.
+func (s *esp32Board) writePin(ctx context.Context, name string, state int, opts callOptions) error {
+	pinNum, err := s.pins.lookup(name)
+	if err != nil {
+		return err
+	}
+	if err := s.pins.checkWrite(pinNum); err != nil {
+		return err
+	}
+	if s.writes.unchanged(pinNum, state) {
+		return nil
+	}
+	write := esp32client.PinWrite{PinNum: pinNum, State: state}
+	if s.noAck(opts) && !s.pins.pins[pinNum].VerifyWrites {
+		return s.writeNoAck(write, opts)
+	}
+	s.dropNoAck(pinNum)
+	ctx = s.withWriteMode(ctx, s.pins.pins[pinNum].SafeState != nil, opts)
+	if err := s.call(ctx, opts, func(ctx context.Context) error {
+		return s.client.WritePins(ctx, []esp32client.PinWrite{write})
+	}); err != nil {
+		s.writes.forget(pinNum)
+		return err
+	}
+	s.writes.record(write)
+	return nil
+}
+
+// withWriteMode returns ctx asking BLE clients to write with response as extra's
+// write_response says, or, if it does not say and no write_mode is configured, when the
+// write is critical, e.g. to a pin with a safe state.
+func (s *esp32Board) withWriteMode(ctx context.Context, critical bool, opts callOptions) context.Context {
+	switch {
+	case opts.writeResponse != nil && *opts.writeResponse:
+		return esp32client.WithRequestWriteMode(ctx, esp32client.WriteReliable)
+	case opts.writeResponse != nil:
+		return esp32client.WithRequestWriteMode(ctx, esp32client.WriteFast)
+	case critical && s.writeMode == "":
+		return esp32client.WithRequestWriteMode(ctx, esp32client.WriteReliable)
+	}
+	return ctx
+}
+
+type analogClient struct {
+	*esp32Board
+	boardName  string
+	analogName string
+}
+
+// Read returns the filtered, calibrated count of the pin, with Min, Max and StepSize
+// converting it to the reader's units if it has them. Pass extra {"raw": true} for the
+// unfiltered, uncalibrated count, or {"samples": n} to average n readings. In battery
+// mode it answers from the last wake window unless extra has {"fresh": true}.
+func (s *analogClient) Read(ctx context.Context, extra map[string]interface{}) (board.AnalogValue, error) {
+	var analogValueRetVal board.AnalogValue
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return analogValueRetVal, err
+	}
+	_, value, err := s.readAnalog(ctx, s.analogName, opts)
+	if err != nil {
+		return analogValueRetVal, err
+	}
+	reader, configured := s.analogs[s.analogName]
+	if configured && reader.units != nil && !opts.raw {
+		return reader.units.analogValue(value), nil
+	}
+	analogValue := board.AnalogValue{
+		Value: int(math.Round(value)),
+	}
+	if configured && reader.rangeMV != 0 {
+		analogValue.Max = float32(reader.rangeMV) / 1000
+		analogValue.StepSize = analogValue.Max / adcFullScale
+	}
+	return analogValue, nil
+}
+
+// readAnalog reads an analog reader or pin, returning the raw count and the filtered,
+// calibrated value, which is the raw count too if opts.raw is set.
+func (s *esp32Board) readAnalog(ctx context.Context, name string, opts callOptions) (float64, float64, error) {
+	pinNum, err := s.analogPin(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	raw, ok := s.cachedRead(pinNum, opts)
+	if !ok {
+		var sum float64
+		for i := 0; i < opts.samples; i++ {
+			read, err := s.readPinNum(ctx, pinNum, opts)
+			if err != nil {
+				return 0, 0, err
+			}
+			sum += s.states.counts(read.State)
+		}
+		raw = sum / float64(opts.samples)
+	}
+
+	value := s.filtered(pinNum, raw)
+	if opts.raw {
+		value = raw
+	} else {
+		value = s.calibrated(pinNum, value)
+	}
+	return raw, value, nil
+}
+
+func (s *analogClient) Write(ctx context.Context, value int, extra map[string]interface{}) error {
+	return fmt.Errorf("Write not implemented")
+}
+
+type digitalInterruptClient struct {
+	*esp32Board
+	boardName            string
+	digitalInterruptName string
+}
+
+func (s *digitalInterruptClient) Name() string {
+	return s.digitalInterruptName
+}
+
+// Value returns the interrupt's count. A counter interrupt's is interpolated from the last
+// read of the firmware's count; pass extra {"fresh": true} to read it first.
+func (s *digitalInterruptClient) Value(ctx context.Context, extra map[string]interface{}) (int64, error) {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return 0, err
+	}
+	pinNum, err := s.interruptPin(s.digitalInterruptName)
+	if err != nil {
+		return 0, err
+	}
+	if opts.fresh {
+		if s.counters.counting(pinNum) {
+			if err := s.call(ctx, opts, func(ctx context.Context) error {
+				return s.syncCounters(ctx, []int{pinNum})
+			}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if count, ok := s.counters.value(pinNum, time.Now()); ok {
+		return count, nil
+	}
+	s.interruptMu.Lock()
+	defer s.interruptMu.Unlock()
+	return s.interruptCounts[pinNum], nil
+}
+
+type gpioPinClient struct {
+	*esp32Board
+	boardName string
+	pinName   string
+}
+
+// Set drives the pin high or low. Pass extra {"mode": "input"} to release it to high
+// impedance instead, ignoring high.
+func (s *gpioPinClient) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return err
+	}
+	if opts.mode == pinModeInput {
+		pinNum, err := s.pins.lookup(s.pinName)
+		if err != nil {
+			return err
+		}
+		return s.releasePin(ctx, pinNum, opts)
+	}
+	state := 0
+	if high {
+		state = 100
+	}
+	return s.writePin(ctx, s.pinName, state, opts)
+}
+
+// Get reads the level of the pin, high at or above get_threshold and, with
+// get_hysteresis, until it drops below the band. Pass extra {"mode": "input"} to release
+// the pin first, to read back a line something else drives after the board wrote it.
+func (s *gpioPinClient) Get(ctx context.Context, extra map[string]interface{}) (bool, error) {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return false, err
+	}
+	pinNum, err := s.pins.lookup(s.pinName)
+	if err != nil {
+		return false, err
+	}
+	if opts.mode == pinModeInput {
+		if err := s.releasePin(ctx, pinNum, opts); err != nil {
+			return false, err
+		}
+	}
+	read, err := s.readPin(ctx, s.pinName, opts)
+	if err != nil {
+		return false, err
+	}
+	return s.states.high(pinNum, read.State), nil
+}
+
+// PWM returns the duty cycle as a fraction between 0 and 1, the same scale SetPWM takes.
+func (s *gpioPinClient) PWM(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return 0, err
+	}
+	read, err := s.readPin(ctx, s.pinName, opts)
+	if err != nil {
+		return 0, err
+	}
+	return s.states.percent(read.State) / 100, nil
+}
+
+// SetPWM sets the duty cycle of the pin. Pass extra {"pulse_width_us": 1500} to drive a
+// pulse that long at the pin's current frequency instead, ignoring dutyCyclePct.
+func (s *gpioPinClient) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[string]interface{}) error {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return err
+	}
+	pinNum, err := s.pins.lookup(s.pinName)
+	if err != nil {
+		return err
+	}
+	if err := s.pins.checkPWM(pinNum); err != nil {
+		return err
+	}
+	if opts.pulseWidthUs > 0 {
+		return s.writePulseWidth(ctx, s.pinName, pinNum, opts.pulseWidthUs, opts)
+	}
+	return s.writePin(ctx, s.pinName, int(dutyCyclePct*100), opts)
+}
+
+func (s *gpioPinClient) PWMFreq(ctx context.Context, extra map[string]interface{}) (uint, error) {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return 0, err
+	}
+	read, err := s.readPin(ctx, s.pinName, opts)
+	if err != nil {
+		return 0, err
+	}
+	if read.Freq == 0 {
+		return 0, fmt.Errorf("firmware did not report a pwm frequency for pin %s: %w", s.pinName, esp32client.ErrNotSupported)
+	}
+	return read.Freq, nil
+}
+
+func (s *gpioPinClient) SetPWMFreq(ctx context.Context, freqHz uint, extra map[string]interface{}) error {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return err
+	}
+	pinNum, err := s.pins.lookup(s.pinName)
+	if err != nil {
+		return err
+	}
+	if err := s.pins.checkWrite(pinNum); err != nil {
+		return err
+	}
+	return s.setPWMFreqs(ctx, opts, []esp32client.PinFreq{{PinNum: pinNum, Freq: freqHz}})
+}
@@ -0,0 +1,88 @@
+// Package boardtest checks that boards from this module leave no goroutines behind, for
+// the tests of Go programs that create them:
+//
+//	func TestBoardLeaks(t *testing.T) {
+//		boardtest.CheckConstruction(t, func(ctx context.Context) (resource.Resource, error) {
+//			return esp32wifi.NewEsp32Wifi(ctx, nil, board.Named("esp32"), conf, logger)
+//		})
+//	}
+//
+// Tests that only need the goroutines the RDK starts on import ignored can pass
+// IgnoreRuntime to goleak directly, e.g. goleak.VerifyTestMain(m, boardtest.IgnoreRuntime()...).
+//
+// CheckConformance runs a table of pin operations against several models, each talking to
+// an in-memory Firmware, and fails where their behavior differs.
+package boardtest
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/goleak"
+	"go.viam.com/rdk/resource"
+)
+
+// Open creates the resource under test, e.g. with esp32wifi.NewEsp32Ble.
+type Open func(ctx context.Context) (resource.Resource, error)
+
+// IgnoreRuntime returns goleak options ignoring goroutines that are not the board's: the
+// ones packages imported by the RDK start in init, and the server side of connections to
+// an httptest server standing in for the firmware.
+func IgnoreRuntime() []goleak.Option {
+	return []goleak.Option{
+		goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"),
+		goleak.IgnoreTopFunction("github.com/desertbit/timer.timerRoutine"),
+		goleak.IgnoreAnyFunction("net/http.(*conn).serve"),
+	}
+}
+
+// VerifyNone fails t if goroutines other than the ignored ones are running. goleak
+// retries for a while first, so goroutines that are exiting are not reported.
+func VerifyNone(t testing.TB, opts ...goleak.Option) {
+	t.Helper()
+	goleak.VerifyNone(t, append(IgnoreRuntime(), opts...)...)
+}
+
+// CheckConstruction opens the resource, closes it, and fails t if opening fails or any
+// goroutine started since CheckConstruction was called is still running.
+func CheckConstruction(t testing.TB, open Open) {
+	t.Helper()
+	before := goleak.IgnoreCurrent()
+	res, err := open(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	if err := res.Close(context.Background()); err != nil {
+		t.Errorf("failed to close: %v", err)
+	}
+	VerifyNone(t, before)
+}
+
+// CheckFailedConstruction fails t if opening the resource does not fail, or if the
+// failure leaves a goroutine behind, e.g. when the device is not found or its warm-up
+// times out.
+func CheckFailedConstruction(t testing.TB, open Open) {
+	t.Helper()
+	before := goleak.IgnoreCurrent()
+	res, err := open(context.Background())
+	if err == nil {
+		res.Close(context.Background())
+		t.Fatal("opened, want an error")
+	}
+	VerifyNone(t, before)
+}
+
+// CheckCanceled opens the resource with a context that is already canceled, closes it if
+// it opened anyway, and fails t if any goroutine is left behind.
+func CheckCanceled(t testing.TB, open Open) {
+	t.Helper()
+	before := goleak.IgnoreCurrent()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res, err := open(ctx); err == nil {
+		if err := res.Close(context.Background()); err != nil {
+			t.Errorf("failed to close: %v", err)
+		}
+	}
+	VerifyNone(t, before)
+}
@@ -0,0 +1,39 @@
+//go:build !nobluetooth
+
+package boardtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+
+	esp32wifi "esp32wifi"
+	"esp32wifi/boardtest"
+	"esp32wifi/esp32client"
+	"esp32wifi/esp32client/bletest"
+)
+
+// openBLE opens an esp32-ble board connected to device through the mock adapter.
+func openBLE(t *testing.T, device *bletest.Device) boardtest.Open {
+	return func(ctx context.Context) (resource.Resource, error) {
+		return esp32wifi.NewEsp32Ble(ctx, nil, board.Named("esp32"),
+			&esp32wifi.BleConfig{BTServerName: device.Name}, logging.NewTestLogger(t),
+			esp32client.WithAdapter(bletest.NewAdapter(device)))
+	}
+}
+
+func TestBLEConstruction(t *testing.T) {
+	boardtest.CheckConstruction(t, openBLE(t, &bletest.Device{Name: "esp32", Handle: boardtest.NewFirmware().HandleBLE}))
+}
+
+func TestBLEFailedConstruction(t *testing.T) {
+	boardtest.CheckFailedConstruction(t, openBLE(t, &bletest.Device{Name: "esp32", ConnectErr: errors.New("connection refused")}))
+}
+
+func TestBLECanceled(t *testing.T) {
+	boardtest.CheckCanceled(t, openBLE(t, &bletest.Device{Name: "esp32", Handle: boardtest.NewFirmware().HandleBLE}))
+}
@@ -0,0 +1,42 @@
+package boardtest_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/goleak"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+
+	esp32wifi "esp32wifi"
+	"esp32wifi/boardtest"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m, boardtest.IgnoreRuntime()...)
+}
+
+// openWifi opens an esp32-wifi board with conf, pointed at a server for fresh firmware.
+func openWifi(t *testing.T, conf esp32wifi.WifiConfig) boardtest.Open {
+	srv := httptest.NewServer(boardtest.NewFirmware())
+	t.Cleanup(srv.Close)
+	conf.Url = srv.URL
+	return func(ctx context.Context) (resource.Resource, error) {
+		return esp32wifi.NewEsp32Wifi(ctx, nil, board.Named("esp32"), &conf, logging.NewTestLogger(t))
+	}
+}
+
+func TestWifiConstruction(t *testing.T) {
+	boardtest.CheckConstruction(t, openWifi(t, esp32wifi.WifiConfig{}))
+}
+
+func TestWifiFailedConstruction(t *testing.T) {
+	// The firmware reports another MAC address.
+	boardtest.CheckFailedConstruction(t, openWifi(t, esp32wifi.WifiConfig{ExpectedDeviceID: "24:0a:c4:00:00:02"}))
+}
+
+func TestWifiCanceled(t *testing.T) {
+	boardtest.CheckCanceled(t, openWifi(t, esp32wifi.WifiConfig{}))
+}
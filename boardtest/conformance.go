@@ -0,0 +1,232 @@
+package boardtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+
+	esp32wifi "esp32wifi"
+	"esp32wifi/esp32client"
+)
+
+// Transport opens a board of one model talking to fw. It fails t if the board cannot be
+// opened, and registers everything else it started, e.g. a server, with t.Cleanup; the
+// board itself is closed by the caller.
+type Transport struct {
+	Name string
+	Open func(t testing.TB, fw *Firmware) board.Board
+}
+
+// WiFi is the esp32-wifi model, talking to the firmware over HTTP.
+func WiFi() Transport {
+	return Transport{Name: "wifi", Open: func(t testing.TB, fw *Firmware) board.Board {
+		t.Helper()
+		srv := httptest.NewServer(fw)
+		t.Cleanup(srv.Close)
+		b, err := esp32wifi.NewEsp32Wifi(context.Background(), nil, board.Named("esp32"),
+			&esp32wifi.WifiConfig{Url: srv.URL}, logging.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("failed to open wifi board: %v", err)
+		}
+		return b
+	}}
+}
+
+// Fake is the esp32-wifi model with its HTTP requests handed to the firmware in memory
+// instead of over a connection, so a difference from WiFi is the network's, e.g. how
+// requests are encoded or timed out.
+func Fake() Transport {
+	return Transport{Name: "fake", Open: func(t testing.TB, fw *Firmware) board.Board {
+		t.Helper()
+		b, err := esp32wifi.NewEsp32Wifi(context.Background(), nil, board.Named("esp32"),
+			&esp32wifi.WifiConfig{Url: "http://esp32.invalid"}, logging.NewTestLogger(t),
+			esp32client.WithDoer(firmwareDoer{fw}))
+		if err != nil {
+			t.Fatalf("failed to open fake board: %v", err)
+		}
+		return b
+	}}
+}
+
+// firmwareDoer answers HTTP requests from the firmware without a server.
+type firmwareDoer struct {
+	fw *Firmware
+}
+
+func (d firmwareDoer) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	d.fw.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// Case is a pin operation that every transport must perform identically: Run must return
+// equal results, fail or succeed together, and leave the firmware's pins in the same
+// state.
+type Case struct {
+	Name string
+	// Setup, if set, prepares the firmware before the board is opened, e.g. the level of
+	// an input.
+	Setup func(fw *Firmware)
+	Run   func(ctx context.Context, b board.Board) (interface{}, error)
+}
+
+// outcome is what a case did on one transport.
+type outcome struct {
+	result interface{}
+	err    error
+	pins   map[int]esp32client.PinRead
+}
+
+// CheckConformance runs every case against every transport, each on fresh firmware, and
+// fails t where a transport behaves differently from the first:
+//
+//	boardtest.CheckConformance(t, boardtest.Cases(), boardtest.WiFi(), boardtest.BLE(), boardtest.Fake())
+func CheckConformance(t *testing.T, cases []Case, transports ...Transport) {
+	t.Helper()
+	if len(transports) < 2 {
+		t.Fatal("conformance needs at least two transports to compare")
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			outcomes := make([]outcome, len(transports))
+			for i, transport := range transports {
+				fw := NewFirmware()
+				if c.Setup != nil {
+					c.Setup(fw)
+				}
+				b := transport.Open(t, fw)
+				result, err := c.Run(context.Background(), b)
+				outcomes[i] = outcome{result: result, err: err, pins: fw.Pins()}
+				// Closed before the next transport opens, since boards of one device
+				// share a connection.
+				if err := b.Close(context.Background()); err != nil {
+					t.Errorf("failed to close %s board: %v", transport.Name, err)
+				}
+			}
+			want, wantName := outcomes[0], transports[0].Name
+			for i, got := range outcomes[1:] {
+				name := transports[i+1].Name
+				if (got.err == nil) != (want.err == nil) {
+					t.Errorf("%s returned error %v, %s returned error %v", name, got.err, wantName, want.err)
+				}
+				if !reflect.DeepEqual(got.result, want.result) {
+					t.Errorf("%s returned %#v, %s returned %#v", name, got.result, wantName, want.result)
+				}
+				for _, pinNum := range sortedPins(mergePins(got.pins, want.pins)) {
+					g, w := got.pins[pinNum], want.pins[pinNum]
+					if g.State != w.State || g.Freq != w.Freq {
+						t.Errorf("%s left GPIO%d at state %v, %dHz; %s left it at state %v, %dHz",
+							name, pinNum, g.State, g.Freq, wantName, w.State, w.Freq)
+					}
+				}
+			}
+		})
+	}
+}
+
+func mergePins(a, b map[int]esp32client.PinRead) map[int]esp32client.PinRead {
+	merged := make(map[int]esp32client.PinRead, len(a)+len(b))
+	for pinNum, read := range a {
+		merged[pinNum] = read
+	}
+	for pinNum, read := range b {
+		merged[pinNum] = read
+	}
+	return merged
+}
+
+// Cases returns the standard table of pin operations, followed by extra, e.g. cases for a
+// feature being added.
+func Cases(extra ...Case) []Case {
+	cases := []Case{
+		{Name: "set high", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			return setThenGet(ctx, b, "26", true)
+		}},
+		{Name: "set low", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			return setThenGet(ctx, b, "26", false)
+		}},
+		{Name: "read input", Setup: func(fw *Firmware) { fw.SetState(27, 100) }, Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			pin, err := b.GPIOPinByName("27")
+			if err != nil {
+				return nil, err
+			}
+			return pin.Get(ctx, nil)
+		}},
+		{Name: "pwm duty cycle", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			pin, err := b.GPIOPinByName("25")
+			if err != nil {
+				return nil, err
+			}
+			if err := pin.SetPWM(ctx, 0.5, nil); err != nil {
+				return nil, err
+			}
+			return pin.PWM(ctx, nil)
+		}},
+		{Name: "pwm frequency", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			pin, err := b.GPIOPinByName("25")
+			if err != nil {
+				return nil, err
+			}
+			if err := pin.SetPWMFreq(ctx, 1000, nil); err != nil {
+				return nil, err
+			}
+			return pin.PWMFreq(ctx, nil)
+		}},
+		{Name: "servo pulse width", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			return b.DoCommand(ctx, map[string]interface{}{"command": "set_servo_us", "pin": "18", "pulse_width_us": 1500.0})
+		}},
+		{Name: "analog read", Setup: func(fw *Firmware) { fw.SetState(34, 1875) }, Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			analog, err := b.AnalogByName("34")
+			if err != nil {
+				return nil, err
+			}
+			return analog.Read(ctx, map[string]interface{}{"raw": true})
+		}},
+		{Name: "transaction", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			return b.DoCommand(ctx, map[string]interface{}{"command": "transaction", "writes": []interface{}{
+				map[string]interface{}{"pin": "26", "high": true},
+				map[string]interface{}{"pin": "27", "duty_cycle": 0.25},
+			}})
+		}},
+		{Name: "set pins", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			return b.DoCommand(ctx, map[string]interface{}{"command": "set_pins", "pins": map[string]interface{}{"26": true, "27": false}})
+		}},
+		{Name: "release pin", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			pin, err := b.GPIOPinByName("21")
+			if err != nil {
+				return nil, err
+			}
+			if err := pin.Set(ctx, true, nil); err != nil {
+				return nil, err
+			}
+			return pin.Get(ctx, map[string]interface{}{"mode": "input"})
+		}},
+		{Name: "pin the chip lacks", Run: func(ctx context.Context, b board.Board) (interface{}, error) {
+			pin, err := b.GPIOPinByName("45")
+			if err != nil {
+				return nil, err
+			}
+			return nil, pin.Set(ctx, true, nil)
+		}},
+	}
+	return append(cases, extra...)
+}
+
+func setThenGet(ctx context.Context, b board.Board, name string, high bool) (interface{}, error) {
+	pin, err := b.GPIOPinByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := pin.Set(ctx, high, nil); err != nil {
+		return nil, err
+	}
+	return pin.Get(ctx, nil)
+}
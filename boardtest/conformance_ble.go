@@ -0,0 +1,30 @@
+//go:build !nobluetooth
+
+package boardtest
+
+import (
+	"context"
+	"testing"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+
+	esp32wifi "esp32wifi"
+	"esp32wifi/esp32client"
+	"esp32wifi/esp32client/bletest"
+)
+
+// BLE is the esp32-ble model, talking to the firmware through an in-memory adapter.
+func BLE() Transport {
+	return Transport{Name: "ble", Open: func(t testing.TB, fw *Firmware) board.Board {
+		t.Helper()
+		device := &bletest.Device{Name: "esp32", Handle: fw.HandleBLE}
+		b, err := esp32wifi.NewEsp32Ble(context.Background(), nil, board.Named("esp32"),
+			&esp32wifi.BleConfig{BTServerName: device.Name}, logging.NewTestLogger(t),
+			esp32client.WithAdapter(bletest.NewAdapter(device)))
+		if err != nil {
+			t.Fatalf("failed to open ble board: %v", err)
+		}
+		return b
+	}}
+}
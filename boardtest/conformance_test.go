@@ -0,0 +1,13 @@
+//go:build !nobluetooth
+
+package boardtest_test
+
+import (
+	"testing"
+
+	"esp32wifi/boardtest"
+)
+
+func TestConformance(t *testing.T) {
+	boardtest.CheckConformance(t, boardtest.Cases(), boardtest.WiFi(), boardtest.BLE(), boardtest.Fake())
+}
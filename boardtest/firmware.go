@@ -0,0 +1,218 @@
+package boardtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"esp32wifi/esp32client"
+)
+
+// firmwarePins is the number of GPIOs the emulated chip has. Requests for other pins are
+// rejected per pin, like real firmware does.
+const firmwarePins = 40
+
+// Firmware is an in-memory ESP32 firmware. It serves the HTTP API as an http.Handler and
+// answers the same requests over BLE through HandleBLE, so one device can stand behind
+// every transport:
+//
+//	fw := boardtest.NewFirmware()
+//	srv := httptest.NewServer(fw)
+//	device := &bletest.Device{Name: "esp32", Handle: fw.HandleBLE}
+//
+// It keeps the state and PWM frequency of each pin and answers pin reads, writes,
+// transactions, pin modes and /info. Every other endpoint is answered with 404, as
+// firmware too old to know it would.
+type Firmware struct {
+	// Info is returned by GET /info.
+	Info esp32client.Info
+
+	mu     sync.Mutex
+	states map[int]float64
+	freqs  map[int]uint
+}
+
+// NewFirmware returns firmware whose pins are all low.
+func NewFirmware() *Firmware {
+	return &Firmware{
+		Info:   esp32client.Info{FirmwareVersion: "boardtest", ChipModel: "ESP32", MAC: "24:0a:c4:00:00:01"},
+		states: map[int]float64{},
+		freqs:  map[int]uint{},
+	}
+}
+
+// SetState sets what reads of pinNum return, e.g. the millivolts of an analog input or
+// 100 for a digital input pulled high.
+func (f *Firmware) SetState(pinNum int, state float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[pinNum] = state
+}
+
+// Pins returns the state and PWM frequency of every pin that was written or set, keyed
+// by pin number.
+func (f *Firmware) Pins() map[int]esp32client.PinRead {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pins := map[int]esp32client.PinRead{}
+	for pinNum, state := range f.states {
+		pins[pinNum] = esp32client.PinRead{PinNum: pinNum, State: state, Freq: f.freqs[pinNum]}
+	}
+	for pinNum, freq := range f.freqs {
+		pins[pinNum] = esp32client.PinRead{PinNum: pinNum, State: f.states[pinNum], Freq: freq}
+	}
+	return pins
+}
+
+// ServeHTTP answers a request to the HTTP API.
+func (f *Firmware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	status, response := f.handle(r.Method, r.URL.Path, body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if response != nil {
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// bleRequest is a request written to the BLE write characteristic: either an endpoint
+// call or one of the pin requests at the top level.
+type bleRequest struct {
+	ID     uint32          `json:"id"`
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body"`
+
+	PinReads    json.RawMessage `json:"pin_reads"`
+	PinWrites   json.RawMessage `json:"pin_writes"`
+	PinFreqs    json.RawMessage `json:"pin_freqs"`
+	Transaction json.RawMessage `json:"transaction"`
+	Ping        bool            `json:"ping"`
+}
+
+// HandleBLE answers a write to the BLE write characteristic, for bletest.Device.Handle.
+// Responses echo the request's id, as firmware that notifies responses does.
+func (f *Firmware) HandleBLE(request []byte) []byte {
+	var req bleRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return bleAnswer(req.ID, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	}
+	var status int
+	var response interface{}
+	switch {
+	case req.Path != "":
+		status, response = f.handle(req.Method, req.Path, req.Body)
+		if status == http.StatusNotFound {
+			return bleAnswer(req.ID, status, map[string]interface{}{"error": fmt.Sprintf("no endpoint %s %s", req.Method, req.Path)})
+		}
+		return bleAnswer(req.ID, status, map[string]interface{}{"body": response})
+	case req.PinReads != nil:
+		status, response = f.handle(http.MethodPost, "/read-pins", request)
+	case req.PinWrites != nil, req.PinFreqs != nil:
+		status, response = f.handle(http.MethodPost, "/write-pins", request)
+	case req.Transaction != nil:
+		status, response = f.handle(http.MethodPost, "/transaction", json.RawMessage(`{"pin_writes":`+string(req.Transaction)+`}`))
+	case req.Ping:
+		status = http.StatusOK
+	default:
+		status = http.StatusNotFound
+	}
+	fields := map[string]interface{}{}
+	if raw, err := json.Marshal(response); err == nil && response != nil {
+		json.Unmarshal(raw, &fields)
+	}
+	if status == http.StatusNotFound {
+		fields["error"] = "unknown request"
+	}
+	return bleAnswer(req.ID, status, fields)
+}
+
+func bleAnswer(id uint32, status int, fields map[string]interface{}) []byte {
+	fields["id"] = id
+	fields["status"] = status
+	answer, _ := json.Marshal(fields)
+	return answer
+}
+
+// handle answers a request for the endpoint at path with a status and the response body.
+func (f *Firmware) handle(method, path string, body json.RawMessage) (int, interface{}) {
+	var req struct {
+		PinReads  []int                  `json:"pin_reads"`
+		PinWrites []esp32client.PinWrite `json:"pin_writes"`
+		PinFreqs  []esp32client.PinFreq  `json:"pin_freqs"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return http.StatusBadRequest, map[string]interface{}{"error": err.Error()}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case method == http.MethodGet && path == "/info":
+		return http.StatusOK, f.Info
+	case method == http.MethodPost && path == "/read-pins":
+		reads := make([]esp32client.PinRead, len(req.PinReads))
+		for i, pinNum := range req.PinReads {
+			reads[i] = esp32client.PinRead{PinNum: pinNum, State: f.states[pinNum], Freq: f.freqs[pinNum]}
+		}
+		return http.StatusOK, map[string]interface{}{"pin_reads": reads}
+	case method == http.MethodPost && path == "/write-pins":
+		var results []esp32client.PinResult
+		for _, w := range req.PinWrites {
+			results = append(results, f.apply(w.PinNum, func() { f.states[w.PinNum] = float64(w.State) }))
+		}
+		for _, freq := range req.PinFreqs {
+			results = append(results, f.apply(freq.PinNum, func() { f.freqs[freq.PinNum] = freq.Freq }))
+		}
+		status := http.StatusOK
+		for _, result := range results {
+			if !result.OK {
+				status = http.StatusMultiStatus
+			}
+		}
+		return status, map[string]interface{}{"results": results}
+	case method == http.MethodPost && path == "/pin-modes":
+		// A released pin reads whatever drives the line, which is its last state here.
+		return http.StatusOK, nil
+	case method == http.MethodPost && path == "/transaction":
+		for _, w := range req.PinWrites {
+			if w.PinNum < 0 || w.PinNum >= firmwarePins {
+				return http.StatusBadRequest, map[string]interface{}{"error": fmt.Sprintf("no GPIO%d", w.PinNum)}
+			}
+		}
+		for _, w := range req.PinWrites {
+			f.states[w.PinNum] = float64(w.State)
+		}
+		return http.StatusOK, nil
+	}
+	return http.StatusNotFound, nil
+}
+
+// apply runs fn if pinNum exists on the chip. f.mu must be held.
+func (f *Firmware) apply(pinNum int, fn func()) esp32client.PinResult {
+	if pinNum < 0 || pinNum >= firmwarePins {
+		return esp32client.PinResult{PinNum: pinNum, Error: fmt.Sprintf("no GPIO%d", pinNum)}
+	}
+	fn()
+	return esp32client.PinResult{PinNum: pinNum, OK: true}
+}
+
+// sortedPins returns the pin numbers of pins in order.
+func sortedPins(pins map[int]esp32client.PinRead) []int {
+	nums := make([]int, 0, len(pins))
+	for pinNum := range pins {
+		nums = append(nums, pinNum)
+	}
+	sort.Ints(nums)
+	return nums
+}
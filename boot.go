@@ -0,0 +1,86 @@
+package esp32wifi
+
+import (
+	"context"
+	"time"
+)
+
+// bootInfo is what /info last reported about the device's boots.
+type bootInfo struct {
+	// known is false until firmware reporting boots answered.
+	known       bool
+	resetReason string
+	bootCount   int
+	uptime      time.Duration
+	// readAt is when uptime was read.
+	readAt time.Time
+}
+
+// uptimeNow extrapolates the uptime to now.
+func (b bootInfo) uptimeNow() time.Duration {
+	return b.uptime + time.Since(b.readAt)
+}
+
+// watchBoots reads the device's reset reason, boot count and uptime whenever it connects,
+// reconnects or reboots, and logs them, warning when the device rebooted since it was last
+// seen. It stops if the firmware does not report them.
+func (s *esp32Board) watchBoots() {
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-s.bootCheck:
+		}
+		ctx, cancel := context.WithTimeout(s.cancelCtx, infoTimeout)
+		info, err := s.client.Info(ctx)
+		cancel()
+		if err != nil {
+			if s.cancelCtx.Err() == nil {
+				s.logger.Debugf("failed to read boot info: %v", err)
+			}
+			continue
+		}
+		if info.ResetReason == "" && info.BootCount == 0 && info.UptimeMs == 0 {
+			s.logger.Debugf("firmware does not report its reset reason, boot count or uptime")
+			return
+		}
+
+		current := bootInfo{
+			known:       true,
+			resetReason: info.ResetReason,
+			bootCount:   info.BootCount,
+			uptime:      time.Duration(info.UptimeMs) * time.Millisecond,
+			readAt:      time.Now(),
+		}
+		s.health.mu.Lock()
+		previous := s.health.boot
+		s.health.boot = current
+		s.health.mu.Unlock()
+
+		reason := current.resetReason
+		if reason == "" {
+			reason = "unknown"
+		}
+		switch {
+		case !previous.known:
+			s.logger.Infof("device boot %d, reset reason %s, up %s", current.bootCount, reason, current.uptime.Round(time.Second))
+		case current.bootCount > previous.bootCount:
+			s.logger.Warnf("device rebooted %d times since it was last seen, reset reason %s, up %s",
+				current.bootCount-previous.bootCount, reason, current.uptime.Round(time.Second))
+		case current.uptime < previous.uptime:
+			// Firmware without a persistent boot count still restarts its uptime.
+			s.logger.Warnf("device rebooted since it was last seen, reset reason %s, up %s", reason, current.uptime.Round(time.Second))
+		default:
+			s.logger.Infof("device reachable again without rebooting, up %s", current.uptime.Round(time.Second))
+		}
+	}
+}
+
+// checkBoot asks watchBoots to read the boot info. It does not block, so it can run from
+// connection hooks.
+func (s *esp32Board) checkBoot() {
+	select {
+	case s.bootCheck <- struct{}{}:
+	default:
+	}
+}
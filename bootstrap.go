@@ -0,0 +1,190 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	// defaultAPURL is where firmware in AP mode serves its API until it has joined a
+	// network: the address ESP-IDF gives the ESP32 on its own soft AP.
+	defaultAPURL              = "http://192.168.4.1:80"
+	defaultBootstrapTimeoutMs = 60000
+	bootstrapPollInterval     = time.Second
+	// bootstrapRequestTimeout bounds each request while bootstrapping, since a device
+	// that is leaving its AP drops requests rather than failing them.
+	bootstrapRequestTimeout = 3 * time.Second
+)
+
+// newBootstrapClient returns a client for the firmware at baseURL, with no circuit
+// breaker so every poll reaches the device.
+func (s *esp32Board) newBootstrapClient(baseURL string) *esp32client.HTTPClient {
+	return esp32client.NewHTTPClient(baseURL,
+		esp32client.WithLogger(s.logger),
+		esp32client.WithRequestTimeout(bootstrapRequestTimeout),
+		esp32client.WithCircuitBreaker(0))
+}
+
+// urlArg returns the base URL at key, or def if it is not set.
+func urlArg(cmd map[string]interface{}, key, def string) (string, error) {
+	if _, ok := cmd[key]; !ok {
+		return def, nil
+	}
+	raw, err := stringArg(cmd, key)
+	if err != nil {
+		return "", err
+	}
+	if _, ok, _ := parseRemoteURL(raw); ok {
+		return "", fmt.Errorf("argument %q must be an http or https url", key)
+	}
+	baseURL, err := parseBaseURL(raw)
+	if err != nil {
+		return "", fmt.Errorf("argument %q: %w", key, err)
+	}
+	return baseURL, nil
+}
+
+// doBootstrapDetect reports whether a device in AP mode answers at the default AP
+// address, or at ap_url, which the module's host must be able to reach, e.g. over a
+// second WiFi interface joined to the device's AP.
+//
+//	{"command": "bootstrap_detect"}
+func (s *esp32Board) doBootstrapDetect(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	apURL, err := urlArg(cmd, "ap_url", defaultAPURL)
+	if err != nil {
+		return nil, err
+	}
+	ap := s.newBootstrapClient(apURL)
+	defer ap.Close()
+
+	result := map[string]interface{}{"ap_url": apURL, "ap_mode": false}
+	if httpClient, ok := transport(s.client).(*esp32client.HTTPClient); ok {
+		result["targeted"] = httpClient.URL() == apURL
+	}
+	info, err := ap.Info(ctx)
+	if err != nil {
+		result["reason"] = err.Error()
+		return result, nil
+	}
+	result["ap_mode"] = true
+	result["mac"] = info.MAC
+	result["firmware_version"] = info.FirmwareVersion
+	if status, err := esp32client.ReadWiFiStatus(ctx, ap); err == nil {
+		result["connected"] = status.Connected
+	}
+	return result, nil
+}
+
+// doBootstrap onboards a device in AP mode: it sends the WiFi credentials to the
+// device's AP address, waits for the device to answer on the network it joins, at url
+// or else at the address its WiFi status reports, and sends the board's requests there
+// from then on. The board's url attribute still has to be updated to keep the address
+// across restarts.
+//
+//	{"command": "bootstrap", "ssid": "lab", "password": "secret"}
+func (s *esp32Board) doBootstrap(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	httpClient, ok := transport(s.client).(*esp32client.HTTPClient)
+	if !ok {
+		return nil, fmt.Errorf("bootstrap is only supported on esp32-wifi boards: %w", esp32client.ErrNotSupported)
+	}
+	ssid, err := stringArg(cmd, "ssid")
+	if err != nil {
+		return nil, err
+	}
+	var password string
+	if _, ok := cmd["password"]; ok {
+		if password, err = stringArg(cmd, "password"); err != nil {
+			return nil, err
+		}
+	}
+	apURL, err := urlArg(cmd, "ap_url", defaultAPURL)
+	if err != nil {
+		return nil, err
+	}
+	lanURL, err := urlArg(cmd, "url", "")
+	if err != nil {
+		return nil, err
+	}
+	timeoutMs, err := optionalNumberArg(cmd, "timeout_ms", defaultBootstrapTimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	if timeoutMs <= 0 {
+		return nil, fmt.Errorf("'timeout_ms' must be positive, got %v", timeoutMs)
+	}
+
+	ap := s.newBootstrapClient(apURL)
+	defer ap.Close()
+	info, err := ap.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no device in AP mode at %s: %w", apURL, err)
+	}
+	if err := esp32client.ConnectWiFi(ctx, ap, ssid, password); err != nil {
+		// The device may leave its AP before it answers.
+		if !errors.Is(err, esp32client.ErrDeviceUnreachable) && !errors.Is(err, esp32client.ErrTimeout) {
+			return nil, err
+		}
+		s.logger.Debugf("device did not confirm the credentials, waiting for it anyway: %v", err)
+	}
+	s.logger.Infof("sent credentials for %q to the device at %s, waiting for it to join", ssid, apURL)
+
+	u, err := url.Parse(apURL)
+	if err != nil {
+		return nil, err
+	}
+	joinedURL, err := s.awaitJoin(ctx, ap, info, u.Port(), lanURL, time.Duration(timeoutMs)*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.SetURL(joinedURL)
+	s.logger.Warnf("device joined %q at %s, set the board's url to it to keep it across restarts", ssid, joinedURL)
+	return map[string]interface{}{"ssid": ssid, "url": joinedURL}, nil
+}
+
+// awaitJoin polls until the device that answered at its AP with apInfo answers on the
+// network it joined, at lanURL if set, otherwise at the address the AP reports, and
+// returns its URL.
+func (s *esp32Board) awaitJoin(ctx context.Context, ap esp32client.Client, apInfo esp32client.Info, port, lanURL string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(bootstrapPollInterval)
+	defer ticker.Stop()
+	for {
+		candidate := lanURL
+		if candidate == "" {
+			status, err := esp32client.ReadWiFiStatus(ctx, ap)
+			if err == nil && status.Connected && status.IP != "" {
+				candidate = "http://" + net.JoinHostPort(status.IP, port)
+			}
+		}
+		if candidate != "" {
+			err := s.sameDevice(ctx, candidate, apInfo)
+			if err == nil {
+				return candidate, nil
+			}
+			s.logger.Debugf("device not on the network yet at %s: %v", candidate, err)
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("device did not join the network within %s: %w", timeout, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// sameDevice checks that the device at baseURL is the one that reported apInfo.
+func (s *esp32Board) sameDevice(ctx context.Context, baseURL string, apInfo esp32client.Info) error {
+	client := s.newBootstrapClient(baseURL)
+	defer client.Close()
+	if apInfo.MAC == "" {
+		_, err := client.Info(ctx)
+		return err
+	}
+	return verifyDeviceID(ctx, client, apInfo.MAC)
+}
@@ -0,0 +1,260 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultCalibrationSamples = 16
+	nvsLoadTimeout            = 5 * time.Second
+)
+
+// CalibrationConfig maps raw ADC counts to calibrated values, either linearly
+// (raw*scale + offset) or through a lookup table interpolated between points.
+type CalibrationConfig struct {
+	Offset float64  `json:"offset,omitempty"`
+	Scale  *float64 `json:"scale,omitempty"`
+	// Table, if set, replaces scale and offset. Points need not be sorted.
+	Table []CalibrationPoint `json:"table,omitempty"`
+	// NVSKey, if set, loads {"offset", "scale"} from the device's NVS on startup,
+	// overriding Offset and Scale. The calibrate command can save to it.
+	NVSKey string `json:"nvs_key,omitempty"`
+}
+
+// CalibrationPoint is one raw reading and the value it corresponds to.
+type CalibrationPoint struct {
+	Raw   float64 `json:"raw"`
+	Value float64 `json:"value"`
+}
+
+func (cfg *CalibrationConfig) validate(path string) error {
+	if cfg.Scale != nil && *cfg.Scale == 0 {
+		return fmt.Errorf("%s: 'scale' must not be zero", path)
+	}
+	if len(cfg.Table) == 1 {
+		return fmt.Errorf("%s: 'table' needs at least two points", path)
+	}
+	if len(cfg.Table) > 0 && (cfg.Scale != nil || cfg.Offset != 0) {
+		return fmt.Errorf("%s: use either 'table' or 'offset'/'scale', not both", path)
+	}
+	seen := map[float64]bool{}
+	for _, point := range cfg.Table {
+		if seen[point.Raw] {
+			return fmt.Errorf("%s: 'table' has more than one point for raw value %v", path, point.Raw)
+		}
+		seen[point.Raw] = true
+	}
+	if len(cfg.NVSKey) > esp32client.MaxNVSKeyLength {
+		return fmt.Errorf("%s: 'nvs_key' must be at most %d characters", path, esp32client.MaxNVSKeyLength)
+	}
+	return nil
+}
+
+// calibration is the calibration in effect for one analog pin.
+type calibration struct {
+	offset float64
+	scale  float64
+	table  []CalibrationPoint
+	nvsKey string
+
+	// points are reference samples collected by the calibrate command.
+	points []CalibrationPoint
+}
+
+func newCalibration(cfg *CalibrationConfig) *calibration {
+	c := &calibration{scale: 1}
+	if cfg == nil {
+		return c
+	}
+	c.offset = cfg.Offset
+	if cfg.Scale != nil {
+		c.scale = *cfg.Scale
+	}
+	c.table = append([]CalibrationPoint(nil), cfg.Table...)
+	sort.Slice(c.table, func(i, j int) bool { return c.table[i].Raw < c.table[j].Raw })
+	c.nvsKey = cfg.NVSKey
+	return c
+}
+
+// apply converts a raw reading into a calibrated value.
+func (c *calibration) apply(raw float64) float64 {
+	if len(c.table) < 2 {
+		return raw*c.scale + c.offset
+	}
+	// Find the segment containing raw, extending the end segments past the table.
+	i := sort.Search(len(c.table), func(i int) bool { return c.table[i].Raw >= raw })
+	switch {
+	case i == 0:
+		i = 1
+	case i == len(c.table):
+		i = len(c.table) - 1
+	}
+	lo, hi := c.table[i-1], c.table[i]
+	return lo.Value + (raw-lo.Raw)*(hi.Value-lo.Value)/(hi.Raw-lo.Raw)
+}
+
+// fit recomputes offset and scale from the collected points: one point corrects the
+// offset only, two or more are fit by least squares.
+func (c *calibration) fit() {
+	switch n := float64(len(c.points)); {
+	case n == 0:
+		return
+	case n == 1:
+		c.offset = c.points[0].Value - c.points[0].Raw*c.scale
+	default:
+		var sumX, sumY, sumXY, sumXX float64
+		for _, p := range c.points {
+			sumX += p.Raw
+			sumY += p.Value
+			sumXY += p.Raw * p.Value
+			sumXX += p.Raw * p.Raw
+		}
+		denom := n*sumXX - sumX*sumX
+		if denom == 0 {
+			return
+		}
+		c.scale = (n*sumXY - sumX*sumY) / denom
+		c.offset = (sumY - c.scale*sumX) / n
+	}
+	c.table = nil
+}
+
+// calibrationFor returns the calibration of pinNum, creating an identity calibration if
+// none is configured. s.calibrationMu must be held.
+func (s *esp32Board) calibrationFor(pinNum int) *calibration {
+	c, ok := s.calibrations[pinNum]
+	if !ok {
+		c = newCalibration(nil)
+		s.calibrations[pinNum] = c
+	}
+	return c
+}
+
+// calibrated applies the pin's calibration to a raw reading.
+func (s *esp32Board) calibrated(pinNum int, raw float64) float64 {
+	s.calibrationMu.Lock()
+	defer s.calibrationMu.Unlock()
+	c, ok := s.calibrations[pinNum]
+	if !ok {
+		return raw
+	}
+	return c.apply(raw)
+}
+
+// loadCalibrations replaces configured coefficients with ones stored in NVS.
+func (s *esp32Board) loadCalibrations() {
+	s.calibrationMu.Lock()
+	keys := map[int]string{}
+	for pinNum, c := range s.calibrations {
+		if c.nvsKey != "" {
+			keys[pinNum] = c.nvsKey
+		}
+	}
+	s.calibrationMu.Unlock()
+
+	for pinNum, key := range keys {
+		ctx, cancel := context.WithTimeout(s.cancelCtx, nvsLoadTimeout)
+		value, err := esp32client.NVSGet(ctx, s.client, "", key)
+		cancel()
+		if errors.Is(err, esp32client.ErrNotFound) {
+			s.logger.Debugf("no calibration for pin %s in nvs key %q yet, using configured values", s.pins.name(pinNum), key)
+			continue
+		}
+		if err != nil {
+			s.logger.Warnf("failed to load calibration for pin %s from nvs key %q, using configured values: %v",
+				s.pins.name(pinNum), key, err)
+			continue
+		}
+		stored, ok := value.(map[string]interface{})
+		offset, offsetOK := stored["offset"].(float64)
+		scale, scaleOK := stored["scale"].(float64)
+		if !ok || !offsetOK || !scaleOK || scale == 0 {
+			s.logger.Warnf("ignoring malformed calibration in nvs key %q: %v", key, value)
+			continue
+		}
+
+		s.calibrationMu.Lock()
+		c := s.calibrationFor(pinNum)
+		c.offset, c.scale, c.table = offset, scale, nil
+		s.calibrationMu.Unlock()
+		s.logger.Infof("loaded calibration for pin %s from nvs: offset=%v scale=%v", s.pins.name(pinNum), offset, scale)
+	}
+}
+
+// doCalibrate samples an analog pin while it measures a known reference and refits the
+// pin's linear calibration from every reference sampled so far.
+//
+//	{"command": "calibrate", "pin": "34", "reference": 1650, "samples": 16, "save": true}
+//	{"command": "calibrate", "pin": "34", "reset": true}
+func (s *esp32Board) doCalibrate(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	pinNum, err := s.pinArg(cmd, "pin")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pins.checkRead(pinNum); err != nil {
+		return nil, err
+	}
+	if reset, _ := cmd["reset"].(bool); reset {
+		s.calibrationMu.Lock()
+		s.calibrations[pinNum] = newCalibration(nil)
+		s.calibrationMu.Unlock()
+		return map[string]interface{}{"offset": 0.0, "scale": 1.0}, nil
+	}
+
+	reference, err := numberArg(cmd, "reference")
+	if err != nil {
+		return nil, err
+	}
+	samples, err := optionalNumberArg(cmd, "samples", defaultCalibrationSamples)
+	if err != nil {
+		return nil, err
+	}
+	if samples < 1 {
+		return nil, fmt.Errorf("samples must be at least 1, got %v", samples)
+	}
+
+	var sum float64
+	for i := 0; i < int(samples); i++ {
+		reads, err := s.client.ReadPins(ctx, []int{pinNum})
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample pin %s: %w", s.pins.name(pinNum), err)
+		}
+		sum += s.states.counts(reads[0].State)
+	}
+	rawMean := sum / math.Floor(samples)
+
+	s.calibrationMu.Lock()
+	c := s.calibrationFor(pinNum)
+	c.points = append(c.points, CalibrationPoint{Raw: rawMean, Value: reference})
+	c.fit()
+	offset, scale, nvsKey, points := c.offset, c.scale, c.nvsKey, len(c.points)
+	s.calibrationMu.Unlock()
+
+	result := map[string]interface{}{
+		"raw_mean": rawMean,
+		"offset":   offset,
+		"scale":    scale,
+		"points":   points,
+	}
+	if save, _ := cmd["save"].(bool); save {
+		if key, ok := cmd["nvs_key"].(string); ok {
+			nvsKey = key
+		}
+		if nvsKey == "" {
+			return result, fmt.Errorf("cannot save calibration: pin %s has no 'nvs_key' configured or given", s.pins.name(pinNum))
+		}
+		stored := map[string]interface{}{"offset": offset, "scale": scale}
+		if err := esp32client.NVSSet(ctx, s.client, "", nvsKey, stored); err != nil {
+			return result, err
+		}
+		result["nvs_key"] = nvsKey
+	}
+	return result, nil
+}
@@ -0,0 +1,151 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	clockSyncInterval = 30 * time.Second
+	// clockSyncSamples is how many recent samples the offset and drift are fit to.
+	clockSyncSamples = 16
+)
+
+// clockSample pairs a device clock reading with the host time it was taken at,
+// relative to the oldest sample kept.
+type clockSample struct {
+	deviceUs float64
+	hostNs   float64
+}
+
+// clockSync maps the device's microsecond clock onto host time by fitting
+// host = offset + rate*device to recent samples, so that the rate absorbs drift between
+// the two clocks.
+type clockSync struct {
+	mu         sync.Mutex
+	baseDevice uint64
+	baseHost   time.Time
+	samples    []clockSample
+	offsetNs   float64
+	nsPerUs    float64
+}
+
+// add records that the device clock read deviceUs at host time host.
+func (c *clockSync) add(deviceUs uint64, host time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.samples) == 0 {
+		c.baseDevice, c.baseHost = deviceUs, host
+	}
+	c.samples = append(c.samples, clockSample{
+		deviceUs: float64(int64(deviceUs - c.baseDevice)),
+		hostNs:   float64(host.Sub(c.baseHost)),
+	})
+	if len(c.samples) > clockSyncSamples {
+		c.samples = c.samples[len(c.samples)-clockSyncSamples:]
+		c.rebase()
+	}
+	c.fit()
+}
+
+// rebase makes the samples relative to the oldest one, so they stay small enough for the
+// fit to keep its precision however long the device is up. c.mu must be held.
+func (c *clockSync) rebase() {
+	oldest := c.samples[0]
+	c.baseDevice += uint64(int64(oldest.deviceUs))
+	c.baseHost = c.baseHost.Add(time.Duration(oldest.hostNs))
+	for i := range c.samples {
+		c.samples[i].deviceUs -= oldest.deviceUs
+		c.samples[i].hostNs -= oldest.hostNs
+	}
+}
+
+// fit recomputes the offset and rate by least squares. c.mu must be held.
+func (c *clockSync) fit() {
+	n := float64(len(c.samples))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range c.samples {
+		sumX += s.deviceUs
+		sumY += s.hostNs
+		sumXY += s.deviceUs * s.hostNs
+		sumXX += s.deviceUs * s.deviceUs
+	}
+	c.nsPerUs = float64(time.Microsecond)
+	if denom := n*sumXX - sumX*sumX; n > 1 && denom != 0 {
+		c.nsPerUs = (n*sumXY - sumX*sumY) / denom
+	}
+	c.offsetNs = (sumY - c.nsPerUs*sumX) / n
+}
+
+// hostTime converts a device timestamp to host time. It returns false if the clocks have
+// not been synced since the device last booted.
+func (c *clockSync) hostTime(deviceUs uint64) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.samples) == 0 {
+		return time.Time{}, false
+	}
+	x := float64(int64(deviceUs - c.baseDevice))
+	return c.baseHost.Add(time.Duration(c.offsetNs + c.nsPerUs*x)), true
+}
+
+// reset forgets every sample, for when the device clock restarts on reboot.
+func (c *clockSync) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samples = nil
+}
+
+// syncClock samples the device clock until the board is closed or the firmware turns
+// out not to expose it. Each sample is taken at the midpoint of the request's round trip.
+func (s *esp32Board) syncClock() {
+	ticker := time.NewTicker(clockSyncInterval)
+	defer ticker.Stop()
+	for {
+		if !s.sampleClock() {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.clockResync:
+		case <-s.cancelCtx.Done():
+			return
+		}
+	}
+}
+
+// sampleClock takes one sample of the device clock. It returns false if the firmware
+// does not expose its clock.
+func (s *esp32Board) sampleClock() bool {
+	ctx, cancel := context.WithTimeout(s.cancelCtx, infoTimeout)
+	defer cancel()
+	start := time.Now()
+	deviceUs, err := esp32client.ReadDeviceTime(ctx, s.client)
+	end := time.Now()
+	switch {
+	case errors.Is(err, esp32client.ErrNotSupported):
+		s.logger.Debugf("device has no clock endpoint, ticks are stamped with receive time: %v", err)
+		return false
+	case err != nil:
+		s.logger.Debugf("failed to sync device clock: %v", err)
+	default:
+		s.clock.add(deviceUs, start.Add(end.Sub(start)/2))
+	}
+	return true
+}
+
+// tickTime returns the host time of an event the device stamped with deviceUs, falling
+// back to now if the event has no timestamp or the clocks are not synced.
+func (s *esp32Board) tickTime(deviceUs uint64) time.Time {
+	if deviceUs != 0 {
+		if t, ok := s.clock.hostTime(deviceUs); ok {
+			return t
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,271 @@
+// Command decode pretty-prints the pin operations in payloads captured between the module
+// and the firmware: a recording made with the record_path attribute, HTTP bodies or BLE
+// characteristic values pasted as JSON, or, with -hex, a hex dump such as tcpdump -X or
+// a BLE sniffer prints. JSON objects are picked out of whatever surrounds them, e.g. the
+// headers of an HTTP capture, so a firmware interoperability issue can be read off the
+// wire.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+func main() {
+	hexDump := flag.Bool("hex", false, "the input is a hex dump rather than text")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-hex] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var input []byte
+	if flag.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		input = data
+	}
+	for _, name := range flag.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		input = append(input, data...)
+	}
+	if *hexDump {
+		input = parseHexDump(input)
+	}
+
+	found := 0
+	for _, object := range jsonObjects(input) {
+		found++
+		if err := printObject(os.Stdout, object); err != nil {
+			fmt.Printf("? %s: %v\n", object, err)
+		}
+	}
+	if found == 0 {
+		log.Fatal("no JSON payloads found in the input")
+	}
+}
+
+// jsonObjects returns the JSON objects in data, skipping whatever is between them.
+func jsonObjects(data []byte) []json.RawMessage {
+	var objects []json.RawMessage
+	for {
+		start := bytes.IndexByte(data, '{')
+		if start < 0 {
+			return objects
+		}
+		dec := json.NewDecoder(bytes.NewReader(data[start:]))
+		var object json.RawMessage
+		if err := dec.Decode(&object); err != nil {
+			// Not JSON, or cut short: look for an object inside it.
+			data = data[start+1:]
+			continue
+		}
+		objects = append(objects, object)
+		data = data[start+int(dec.InputOffset()):]
+	}
+}
+
+// parseHexDump returns the bytes of a hex dump: tcpdump -X or -xx output, hexdump -C
+// output, or a bare hex stream. An offset leading a line is skipped, and so is the
+// ASCII column that may end it: the first field that is not hex, or that is longer than
+// the line's first group.
+func parseHexDump(dump []byte) []byte {
+	var data []byte
+	for _, line := range strings.Split(string(dump), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && (strings.HasSuffix(fields[0], ":") || strings.HasPrefix(fields[0], "0x") || len(fields[0]) == 8) {
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			b, err := hex.DecodeString(field)
+			if err != nil || len(field) > len(fields[0]) {
+				break
+			}
+			data = append(data, b...)
+		}
+	}
+	return data
+}
+
+// printObject prints a recorded exchange, with its request marked > and its response <,
+// or a bare payload.
+func printObject(w io.Writer, object json.RawMessage) error {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(object, &probe); err != nil {
+		return err
+	}
+	_, hasTime := probe["time"]
+	_, hasDuration := probe["duration_ms"]
+	if !hasTime || !hasDuration {
+		payload, err := esp32client.DecodePayload(object)
+		if err != nil {
+			return err
+		}
+		printPayload(w, "", payload)
+		return nil
+	}
+
+	var exchange esp32client.Exchange
+	if err := json.Unmarshal(object, &exchange); err != nil {
+		return err
+	}
+	request, response, err := esp32client.DecodeExchange(exchange)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s %s %s (%.1fms)\n", exchange.Time.Format(time.RFC3339Nano), exchange.Method, exchange.Path, exchange.DurationMs)
+	printPayload(w, "  > ", request)
+	if exchange.Error != "" {
+		fmt.Fprintf(w, "  < failed (%s): %s\n", exchange.ErrorKind, exchange.Error)
+		return nil
+	}
+	printPayload(w, "  < ", response)
+	return nil
+}
+
+// printPayload prints a line per operation in p, each led by prefix.
+func printPayload(w io.Writer, prefix string, p esp32client.Payload) {
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, prefix+format+"\n", args...)
+	}
+	if p.ID != 0 {
+		line("request %d", p.ID)
+	}
+	if p.Method != "" || p.Path != "" {
+		line("call %s %s%s", p.Method, p.Path, compact(p.Body))
+	} else if len(p.Body) > 0 {
+		line("body%s", compact(p.Body))
+	}
+	if p.Ping {
+		line("ping")
+	}
+	switch {
+	case p.Error != "":
+		line("status %d: %s", p.Status, p.Error)
+	case p.Status != 0:
+		line("status %d", p.Status)
+	}
+
+	legacy := ""
+	if p.Legacy {
+		legacy = " (legacy protocol)"
+	}
+	if p.ReadPins != nil {
+		line("read pins %s%s", joinInts(p.ReadPins), legacy)
+	}
+	for _, read := range p.Reads {
+		if read.Freq != 0 {
+			line("pin %d = %v at %d Hz", read.PinNum, read.State, read.Freq)
+		} else {
+			line("pin %d = %v", read.PinNum, read.State)
+		}
+	}
+	for i, value := range p.Values {
+		line("value %d = %s%s", i, value, legacy)
+	}
+	for _, write := range p.Writes {
+		line("write %s", formatWrite(write))
+	}
+	for _, write := range p.Transaction {
+		line("transaction write %s", formatWrite(write))
+	}
+	for _, freq := range p.Freqs {
+		ledc := ""
+		if freq.LEDCTimer != nil {
+			ledc += fmt.Sprintf(" on LEDC timer %d", *freq.LEDCTimer)
+		}
+		if freq.LEDCChannel != nil {
+			ledc += fmt.Sprintf(" channel %d", *freq.LEDCChannel)
+		}
+		line("pwm pin %d at %d Hz%s", freq.PinNum, freq.Freq, ledc)
+	}
+	for _, result := range p.Results {
+		if result.OK {
+			line("pin %d ok", result.PinNum)
+		} else {
+			line("pin %d rejected: %s", result.PinNum, result.Error)
+		}
+	}
+	for _, sample := range p.Samples {
+		if sample.TimestampUs != 0 {
+			line("sample pin %d = %v at %dus", sample.PinNum, sample.State, sample.TimestampUs)
+		} else {
+			line("sample pin %d = %v", sample.PinNum, sample.State)
+		}
+	}
+	for _, event := range p.Events {
+		line("%s", formatEvent(event))
+	}
+	names := make([]string, 0, len(p.Other))
+	for name := range p.Other {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		line("%s:%s", name, compact(p.Other[name]))
+	}
+}
+
+func formatWrite(write esp32client.PinWrite) string {
+	if write.Duty != nil {
+		return fmt.Sprintf("pin %d = %d (duty %v)", write.PinNum, write.State, *write.Duty)
+	}
+	return fmt.Sprintf("pin %d = %d", write.PinNum, write.State)
+}
+
+func formatEvent(event esp32client.Event) string {
+	switch event.Type {
+	case esp32client.EventInterrupt:
+		level := "low"
+		if event.High {
+			level = "high"
+		}
+		return fmt.Sprintf("interrupt pin %d %s at %dus", event.PinNum, level, event.TimestampUs)
+	case esp32client.EventReboot:
+		return fmt.Sprintf("reboot: %s", event.Reason)
+	case esp32client.EventWiFi:
+		if event.WiFi == nil || !event.WiFi.Connected {
+			return "wifi disconnected"
+		}
+		return fmt.Sprintf("wifi connected to %q as %s (rssi %d, channel %d)",
+			event.WiFi.SSID, event.WiFi.IP, event.WiFi.RSSI, event.WiFi.Channel)
+	default:
+		return fmt.Sprintf("%s event", event.Type)
+	}
+}
+
+// compact returns data as compact JSON led by a space, or "" if there is none.
+func compact(data json.RawMessage) string {
+	if len(data) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return " " + string(data)
+	}
+	return " " + buf.String()
+}
+
+func joinInts(values []int) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = fmt.Sprint(v)
+	}
+	return strings.Join(strs, ", ")
+}
@@ -4,11 +4,28 @@ import (
 	"esp32wifi"
 
 	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/input"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/components/powersensor"
+	"go.viam.com/rdk/components/sensor"
+	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/module"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
 )
 
 func main() {
 	// ModularMain can take multiple APIModel arguments, if your module implements multiple models.
-	module.ModularMain(resource.APIModel{board.API, esp32wifi.Esp32Wifi}, resource.APIModel{board.API, esp32wifi.Esp32Ble})
+	module.ModularMain(
+		resource.APIModel{API: board.API, Model: esp32wifi.Esp32Wifi},
+		resource.APIModel{API: board.API, Model: esp32wifi.Esp32Ble},
+		resource.APIModel{API: board.API, Model: esp32wifi.Esp32Hybrid},
+		resource.APIModel{API: sensor.API, Model: esp32wifi.Esp32Power},
+		resource.APIModel{API: sensor.API, Model: esp32wifi.Esp32Beacon},
+		resource.APIModel{API: movementsensor.API, Model: esp32wifi.Esp32MPU6050},
+		resource.APIModel{API: powersensor.API, Model: esp32wifi.Esp32INA219},
+		resource.APIModel{API: toggleswitch.API, Model: esp32wifi.Esp32Relay},
+		resource.APIModel{API: input.API, Model: esp32wifi.Esp32Button},
+		resource.APIModel{API: generic.API, Model: esp32wifi.Esp32Rules},
+	)
 }
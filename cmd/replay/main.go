@@ -0,0 +1,37 @@
+// Command replay serves a recording made with the record_path attribute as if it were the
+// firmware, so a bug report can be reproduced without the device. Point an esp32-wifi
+// board's url at the listen address.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"esp32wifi/esp32client"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8080", "address to serve the recording on")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatalf("usage: %s [-listen addr] recording.jsonl", os.Args[0])
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	replay, err := esp32client.NewReplayClient(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("replaying %d requests on http://%s", replay.Remaining(), *listen)
+	log.Fatal(http.ListenAndServe(*listen, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL.Path)
+		replay.ServeHTTP(w, r)
+	})))
+}
@@ -0,0 +1,50 @@
+// Command schema writes the JSON schema of each model's attributes to <dir>/<model>.json,
+// for form-based configuration of the module in the Viam app.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"esp32wifi"
+
+	"go.viam.com/rdk/resource"
+)
+
+func main() {
+	dir := flag.String("out", "schemas", "directory to write the schemas to")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	for _, model := range []resource.Model{
+		esp32wifi.Esp32Wifi,
+		esp32wifi.Esp32Ble,
+		esp32wifi.Esp32Hybrid,
+		esp32wifi.Esp32Power,
+		esp32wifi.Esp32Beacon,
+		esp32wifi.Esp32MPU6050,
+		esp32wifi.Esp32INA219,
+		esp32wifi.Esp32Relay,
+		esp32wifi.Esp32Button,
+		esp32wifi.Esp32Rules,
+	} {
+		schema, err := esp32wifi.ConfigSchema(model)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		path := filepath.Join(*dir, model.Name+".json")
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("wrote %s", path)
+	}
+}
@@ -0,0 +1,401 @@
+package esp32wifi
+
+import "context"
+
+// Argument types of commandArg, the JSON schema types plus argPin for a pin name or GPIO
+// number and argAny for any JSON value.
+const (
+	argString  = "string"
+	argNumber  = "number"
+	argBoolean = "boolean"
+	argObject  = "object"
+	argArray   = "array"
+	argPin     = "pin"
+	argAny     = ""
+)
+
+// commandSpec is a DoCommand: its handler and what help says about it.
+type commandSpec struct {
+	summary string
+	args    []commandArg
+	// extra is set for commands that also take the keys of a call's extra.
+	extra bool
+	run   func(s *esp32Board, ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error)
+}
+
+// commandArg is an argument of a command.
+type commandArg struct {
+	name        string
+	typ         string
+	required    bool
+	description string
+}
+
+func requiredArg(name, typ, description string) commandArg {
+	return commandArg{name: name, typ: typ, required: true, description: description}
+}
+
+func optionalArg(name, typ, description string) commandArg {
+	return commandArg{name: name, typ: typ, description: description}
+}
+
+// commands are the DoCommands of every board model, by name. help is answered by
+// DoCommand itself, since it lists this table.
+var commands = map[string]commandSpec{
+	"transaction": {
+		summary: "Applies pin writes atomically, in one GPIO write cycle.",
+		args:    []commandArg{requiredArg("writes", argArray, `Writes, each {"pin", "high"} or {"pin", "duty_cycle"}.`)},
+		run:     (*esp32Board).doTransaction,
+	},
+	"schedule": {
+		summary: "Starts a firmware-side pulse or repeating schedule on a pin.",
+		args: []commandArg{
+			requiredArg("pin", argPin, "Pin to drive."),
+			optionalArg("high", argBoolean, "Level to drive, unless duty_cycle is given."),
+			optionalArg("duty_cycle", argNumber, "Duty cycle to drive, 0-1."),
+			optionalArg("duration_ms", argNumber, "Restores the pin after this long."),
+			optionalArg("delay_ms", argNumber, "Waits this long before driving the pin."),
+			optionalArg("interval_ms", argNumber, "Repeats the schedule this often."),
+		},
+		run: (*esp32Board).doSchedule,
+	},
+	"cancel_schedule": {
+		summary: "Cancels a schedule, or all of them.",
+		args: []commandArg{
+			optionalArg("id", argNumber, "Schedule to cancel, required unless all is set."),
+			optionalArg("all", argBoolean, "Cancels every schedule the board started that is still running."),
+		},
+		run: (*esp32Board).doCancelSchedule,
+	},
+	"list_schedules": {
+		summary: "Lists every schedule on the device, marking the ones the board started.",
+		run:     (*esp32Board).doListSchedules,
+	},
+	"wifi_scan": {
+		summary: "Lists the WiFi networks the device sees.",
+		run:     (*esp32Board).doWiFiScan,
+	},
+	"wifi_connect": {
+		summary: "Moves the device to another WiFi network.",
+		args: []commandArg{
+			requiredArg("ssid", argString, "Network to join."),
+			optionalArg("password", argString, "Network password."),
+		},
+		run: (*esp32Board).doWiFiConnect,
+	},
+	"wifi_status": {
+		summary: "Returns the device's WiFi connection.",
+		run:     (*esp32Board).doWiFiStatus,
+	},
+	"bootstrap_detect": {
+		summary: "Reports whether a device in AP mode answers at its AP address.",
+		args: []commandArg{
+			optionalArg("ap_url", argString, "Address of the device's AP, http://192.168.4.1 by default."),
+		},
+		run: (*esp32Board).doBootstrapDetect,
+	},
+	"bootstrap": {
+		summary: "Sends WiFi credentials to a device in AP mode and switches to its address on the network it joins.",
+		args: []commandArg{
+			requiredArg("ssid", argString, "Network to join."),
+			optionalArg("password", argString, "Network password."),
+			optionalArg("ap_url", argString, "Address of the device's AP, http://192.168.4.1 by default."),
+			optionalArg("url", argString, "Address the device will have on the network, instead of the one it reports."),
+			optionalArg("timeout_ms", argNumber, "How long to wait for the device to join, 60000 by default."),
+		},
+		run: (*esp32Board).doBootstrap,
+	},
+	"nvs_get": {
+		summary: "Reads a value from the device's NVS.",
+		args:    nvsCommandArgs(),
+		run:     (*esp32Board).doNVSGet,
+	},
+	"nvs_set": {
+		summary: "Stores a value in the device's NVS.",
+		args:    append(nvsCommandArgs(), requiredArg("value", argAny, "Value to store.")),
+		run:     (*esp32Board).doNVSSet,
+	},
+	"nvs_erase": {
+		summary: "Erases a value from the device's NVS.",
+		args:    nvsCommandArgs(),
+		run:     (*esp32Board).doNVSErase,
+	},
+	"calibrate": {
+		summary: "Samples an analog pin at a known reference and refits its calibration.",
+		args: []commandArg{
+			requiredArg("pin", argPin, "Analog pin to calibrate."),
+			optionalArg("reference", argNumber, "Value the pin measures, required unless reset is set."),
+			optionalArg("samples", argNumber, "Readings to average, 16 by default."),
+			optionalArg("save", argBoolean, "Stores the calibration in the device's NVS."),
+			optionalArg("nvs_key", argString, "NVS key to store it under."),
+			optionalArg("reset", argBoolean, "Drops the pin's calibration."),
+		},
+		run: (*esp32Board).doCalibrate,
+	},
+	"snapshot": {
+		summary: "Reads every configured pin in one request.",
+		run:     (*esp32Board).doSnapshot,
+	},
+	"run_macro": {
+		summary: "Starts a configured macro on the firmware.",
+		args: []commandArg{
+			requiredArg("name", argString, "Macro to run."),
+			optionalArg("wait", argBoolean, "Waits for the macro to finish."),
+		},
+		run: (*esp32Board).doRunMacro,
+	},
+	"macro_status": {
+		summary: "Returns the progress of a running macro.",
+		args:    []commandArg{requiredArg("id", argNumber, "Run returned by run_macro.")},
+		run:     (*esp32Board).doMacroStatus,
+	},
+	"ramp_pwm": {
+		summary: "Ramps a PWM pin's duty cycle on the firmware.",
+		args: []commandArg{
+			requiredArg("pin", argPin, "PWM pin to ramp."),
+			requiredArg("from", argNumber, "Starting duty cycle, 0-1."),
+			requiredArg("to", argNumber, "Final duty cycle, 0-1."),
+			requiredArg("duration_ms", argNumber, "Length of the ramp."),
+			optionalArg("steps", argNumber, "Steps of the ramp."),
+			optionalArg("wait", argBoolean, "Waits for the ramp to finish."),
+		},
+		run: (*esp32Board).doRampPWM,
+	},
+	"describe": {
+		summary: "Describes the board's profile, pins and macros.",
+		run:     (*esp32Board).doDescribe,
+	},
+	"http": {
+		summary: "Sends a request to any firmware endpoint.",
+		args: []commandArg{
+			requiredArg("method", argString, "HTTP method."),
+			requiredArg("path", argString, "Endpoint path, e.g. /custom."),
+			optionalArg("body", argAny, "JSON body."),
+			optionalArg("forwarded", argBoolean, "Returns the firmware's status with its response, for boards on other parts."),
+		},
+		run: (*esp32Board).doHTTP,
+	},
+	"i2c": {
+		summary: "Writes to and reads from a device on the ESP32's I2C bus.",
+		args: []commandArg{
+			requiredArg("address", argNumber, "7-bit device address."),
+			optionalArg("write", argArray, "Bytes to write, e.g. a register address."),
+			optionalArg("read", argNumber, "Bytes to read after the write."),
+			optionalArg("bus", argNumber, "Firmware I2C bus, 0 by default."),
+		},
+		run: (*esp32Board).doI2C,
+	},
+	"ble_write": {
+		summary: "Writes to the BLE write characteristic without waiting for a response.",
+		args:    []commandArg{requiredArg("data", argAny, "A string to write as is, or a value to write as JSON.")},
+		run:     (*esp32Board).doBLEWrite,
+	},
+	"self_test": {
+		summary: "Checks a loopback pair of pins, the latency and PWM.",
+		args: []commandArg{
+			optionalArg("output_pin", argString, "Loopback output, the self_test attribute's by default."),
+			optionalArg("input_pin", argString, "Loopback input, the self_test attribute's by default."),
+			optionalArg("pwm_freq_hz", argNumber, "Frequency of the PWM check."),
+			optionalArg("max_latency_ms", argNumber, "Latency above which the check fails."),
+		},
+		run: (*esp32Board).doSelfTest,
+	},
+	"status": {
+		summary: "Returns the connection, health and power of the device.",
+		run:     (*esp32Board).doStatus,
+	},
+	"reset_peripherals": {
+		summary: "Resets the firmware's peripheral drivers.",
+		args:    []commandArg{optionalArg("peripherals", argArray, "Any of ledc, pcnt and rmt, all by default.")},
+		run:     (*esp32Board).doResetPeripherals,
+	},
+	"write_mask": {
+		summary: "Writes the pins of a bit mask in one request.",
+		args: []commandArg{
+			requiredArg("mask", argAny, "Pins to write, a number or hex string."),
+			requiredArg("values", argAny, "Levels of the masked pins, a number or hex string."),
+		},
+		run: (*esp32Board).doWriteMask,
+	},
+	"set_pins": {
+		summary: "Switches several pins in one request.",
+		args:    []commandArg{requiredArg("pins", argObject, "Levels by pin name.")},
+		run:     (*esp32Board).doSetPins,
+	},
+	"set_group_state": {
+		summary: "Switches a pin group to one of its states.",
+		args: []commandArg{
+			requiredArg("group", argString, "Configured pin group."),
+			requiredArg("state", argString, "State of the group."),
+		},
+		run: (*esp32Board).doSetGroupState,
+	},
+	"set_relay": {
+		summary: "Energizes or releases a relay, subject to its interlocks and dwell times.",
+		args: []commandArg{
+			requiredArg("relay", argString, "Configured relay."),
+			requiredArg("on", argBoolean, "Whether to energize the relay."),
+		},
+		run: (*esp32Board).doSetRelay,
+	},
+	"get_relay": {
+		summary: "Reports whether a relay is energized.",
+		args:    []commandArg{requiredArg("relay", argString, "Configured relay.")},
+		run:     (*esp32Board).doGetRelay,
+	},
+	"export_config": {
+		summary: "Returns the setup stored on the device.",
+		run:     (*esp32Board).doExportConfig,
+	},
+	"import_config": {
+		summary: "Replaces the setup stored on the device with an exported one.",
+		args:    []commandArg{requiredArg("config", argObject, "Setup returned by export_config.")},
+		run:     (*esp32Board).doImportConfig,
+	},
+	"get_logs": {
+		summary: "Returns the firmware's new log entries, or tails them into the module's log.",
+		args: []commandArg{
+			optionalArg("tail", argBoolean, "Starts or stops tailing."),
+			optionalArg("interval_ms", argNumber, "How often to tail."),
+		},
+		run: (*esp32Board).doGetLogs,
+	},
+	"coredump_info": {
+		summary: "Describes the core dump stored on the device.",
+		run:     (*esp32Board).doCoreDumpInfo,
+	},
+	"coredump_download": {
+		summary: "Returns the stored core dump, or writes it to a file.",
+		args:    []commandArg{optionalArg("path", argString, "File to write it to.")},
+		run:     (*esp32Board).doCoreDumpDownload,
+	},
+	"coredump_erase": {
+		summary: "Erases the stored core dump.",
+		run:     (*esp32Board).doCoreDumpErase,
+	},
+	"hard_reset": {
+		summary: "Resets the chip with the reset line.",
+		run:     (*esp32Board).doHardReset,
+	},
+	"inspect_pin": {
+		summary: "Returns what the board last commanded, observed and failed to do with a pin.",
+		args: []commandArg{
+			requiredArg("pin", argString, "Pin to inspect."),
+			optionalArg("at", argString, "RFC 3339 time to inspect the pin as of."),
+		},
+		run: (*esp32Board).doInspectPin,
+	},
+	"set_servo_us": {
+		summary: "Drives a servo pulse of an exact width.",
+		args: []commandArg{
+			requiredArg("pin", argPin, "PWM pin."),
+			requiredArg("pulse_width_us", argNumber, "Pulse width."),
+			optionalArg("freq_hz", argNumber, "PWM frequency, 50 by default."),
+		},
+		run: (*esp32Board).doSetServoUs,
+	},
+	"release_pin": {
+		summary: "Releases a pin to a high-impedance input.",
+		args:    []commandArg{requiredArg("pin", argPin, "Pin to release.")},
+		run:     (*esp32Board).doReleasePin,
+	},
+	"set_open_drain": {
+		summary: "Switches an output pin to or from open-drain.",
+		args: []commandArg{
+			requiredArg("pin", argPin, "Output pin."),
+			requiredArg("enabled", argBoolean, "Open-drain, or push-pull if false."),
+			optionalArg("pull_up", argString, "external or internal."),
+		},
+		run: (*esp32Board).doSetOpenDrain,
+	},
+	"read_analog": {
+		summary: "Reads an analog with its raw count and units.",
+		args:    []commandArg{requiredArg("analog", argString, "Analog reader or pin.")},
+		extra:   true,
+		run:     (*esp32Board).doReadAnalog,
+	},
+	"subscribe_analog": {
+		summary: "Has the firmware push samples of an analog over BLE.",
+		args: []commandArg{
+			requiredArg("pin", argPin, "Analog reader or pin."),
+			optionalArg("rate_hz", argNumber, "Samples a second, 10 by default."),
+		},
+		run: (*esp32Board).doSubscribeAnalog,
+	},
+	"unsubscribe_analog": {
+		summary: "Stops a stream subscribe_analog started.",
+		args:    []commandArg{requiredArg("pin", argPin, "Analog reader or pin.")},
+		run:     (*esp32Board).doUnsubscribeAnalog,
+	},
+	"config_schema": {
+		summary: "Returns the JSON schema of a model's attributes.",
+		args:    []commandArg{optionalArg("model", argString, "Model name, e.g. esp32-ble, every model by default.")},
+		run:     (*esp32Board).doConfigSchema,
+	},
+}
+
+func nvsCommandArgs() []commandArg {
+	return []commandArg{
+		requiredArg("key", argString, "NVS key."),
+		optionalArg("namespace", argString, "NVS namespace, the firmware's by default."),
+	}
+}
+
+// schema returns the JSON schema of the command's arguments.
+func (spec commandSpec) schema() map[string]interface{} {
+	properties := map[string]interface{}{
+		"command": map[string]interface{}{"type": argString},
+	}
+	requiredArgs := []interface{}{"command"}
+	for _, arg := range spec.args {
+		property := map[string]interface{}{"description": arg.description}
+		switch arg.typ {
+		case argAny:
+		case argPin:
+			property["type"] = []interface{}{argString, argNumber}
+		default:
+			property["type"] = arg.typ
+		}
+		properties[arg.name] = property
+		if arg.required {
+			requiredArgs = append(requiredArgs, arg.name)
+		}
+	}
+	return map[string]interface{}{
+		"type":                 argObject,
+		"properties":           properties,
+		"required":             requiredArgs,
+		"additionalProperties": spec.extra,
+	}
+}
+
+// doHelp lists every command with its summary and the JSON schema of its arguments, or
+// describes the one named.
+//
+//	{"command": "help"}
+//	{"command": "help", "name": "set_pins"}
+func doHelp(cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd["name"]; ok {
+		name, err := stringArg(cmd, "name")
+		if err != nil {
+			return nil, err
+		}
+		spec, ok := commands[name]
+		if !ok {
+			return nil, unknownCommand(name)
+		}
+		return map[string]interface{}{"name": name, "summary": spec.summary, "args": spec.schema()}, nil
+	}
+
+	list := make(map[string]interface{}, len(commands)+1)
+	for name, spec := range commands {
+		list[name] = map[string]interface{}{"summary": spec.summary, "args": spec.schema()}
+	}
+	list["help"] = map[string]interface{}{
+		"summary": "Lists the commands and their arguments.",
+		"args": commandSpec{args: []commandArg{
+			optionalArg("name", argString, "Command to describe, every command by default."),
+		}}.schema(),
+	}
+	return map[string]interface{}{"commands": list}, nil
+}
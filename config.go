@@ -0,0 +1,507 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"strconv"
+
+	"esp32wifi/esp32client"
+)
+
+// Pin modes accepted in PinConfig.Mode.
+const (
+	pinModeInput  = "input"
+	pinModeOutput = "output"
+	pinModePWM    = "pwm"
+	pinModeAnalog = "analog"
+	pinModeDAC    = "dac"
+)
+
+// Pull-ups accepted in PinConfig.PullUp.
+const (
+	pullUpExternal = "external"
+	pullUpInternal = "internal"
+)
+
+// BoardConfig holds the attributes shared by every model in this module. It is embedded
+// in each model's config.
+type BoardConfig struct {
+	// Profile presets pin names, reserved pins and safe states for a known board:
+	// devkit-v1, relay-4ch or esp32-cam.
+	Profile string `json:"profile,omitempty"`
+	// ChipVariant is one of esp32 (default), esp32s2, esp32s3 or esp32c3.
+	ChipVariant string      `json:"chip_variant,omitempty"`
+	Pins        []PinConfig `json:"pins,omitempty"`
+	// Analogs are analog readers looked up by name and set up on the firmware at startup.
+	Analogs []AnalogConfig `json:"analogs,omitempty"`
+	// DifferentialAnalogs are virtual analogs reading one pin minus another, looked up by
+	// name like Analogs.
+	DifferentialAnalogs []DifferentialAnalogConfig `json:"differential_analogs,omitempty"`
+	// DigitalInterrupts are set up on the firmware at startup and looked up by name.
+	DigitalInterrupts []DigitalInterruptConfig `json:"digital_interrupts,omitempty"`
+	// Macros are sequences of pin writes that can be started with the run_macro command.
+	Macros []MacroConfig `json:"macros,omitempty"`
+	// PinGroups are output pins switched between named states with set_group_state.
+	PinGroups []PinGroupConfig `json:"pin_groups,omitempty"`
+	// Relays are output pins switched only through set_relay and esp32-relay switches,
+	// subject to Interlocks and their dwell times.
+	Relays     []RelayConfig     `json:"relays,omitempty"`
+	Interlocks []InterlockConfig `json:"interlocks,omitempty"`
+	// ApplySafeStateOnClose drives every pin with a safe state to it when the board is
+	// closed, e.g. on shutdown or reconfiguration.
+	ApplySafeStateOnClose bool `json:"apply_safe_state_on_close,omitempty"`
+	// Tracing exports a span for every request to the device.
+	Tracing *TracingConfig `json:"tracing,omitempty"`
+	// SuppressRepeatWrites skips Set and SetPWM calls that would write the state last
+	// written to the pin.
+	SuppressRepeatWrites bool `json:"suppress_repeat_writes,omitempty"`
+	// Power configures supply voltage telemetry.
+	Power *PowerConfig `json:"power,omitempty"`
+	// SelfTest is the loopback wiring checked by the self_test command.
+	SelfTest *SelfTestConfig `json:"self_test,omitempty"`
+	// WarmUp, if set, waits for the device to be ready before the board starts.
+	WarmUp *WarmUpConfig `json:"warm_up,omitempty"`
+	// ProtocolVersion is the firmware's pin read payload format: 1 for legacy firmware
+	// that reads {"pins": [...]}, 2 for the current format. Unset, it is detected.
+	ProtocolVersion int `json:"protocol_version,omitempty"`
+	// StateFormat is the scale the firmware reads pins as: percent (0-100) on older
+	// firmware, raw (12-bit ADC counts) on newer. Unset, the firmware's capabilities tell.
+	StateFormat string `json:"state_format,omitempty"`
+	// GetThreshold is the fraction of full scale at or above which Get reads a pin high,
+	// 1 by default, or 0.5 for firmware in the raw format.
+	GetThreshold *float64 `json:"get_threshold,omitempty"`
+	// GetHysteresis, a fraction of full scale, keeps a pin that read high reading high
+	// until its state drops below GetThreshold minus it.
+	GetHysteresis float64 `json:"get_hysteresis,omitempty"`
+	// Dashboard, if set, serves a debug dashboard over HTTP.
+	Dashboard *DashboardConfig `json:"dashboard,omitempty"`
+	// RecordPath, if set, is a JSONL file every exchange with the device is appended to,
+	// for bug reports. cmd/replay serves a recording as firmware.
+	RecordPath string `json:"record_path,omitempty"`
+	// BatteryMode, if set, batches background traffic into wake windows.
+	BatteryMode *BatteryModeConfig `json:"battery_mode,omitempty"`
+	// Recovery, if set, restarts or power cycles a device that stopped working.
+	Recovery *RecoveryConfig `json:"recovery,omitempty"`
+	// ResetLine, if set, is a GPIO on another board wired to the ESP32's EN pin, pulsed by
+	// the hard_reset command.
+	ResetLine *ResetLineConfig `json:"reset_line,omitempty"`
+	// Lease, if set, takes exclusive control of the device's outputs, failing writes with
+	// ErrBoardBusy while another host holds it.
+	Lease *LeaseConfig `json:"lease,omitempty"`
+	// NoAck makes Set and SetPWM return without waiting for the device to answer, for
+	// outputs such as status LEDs where latency matters more than knowing the write
+	// landed. Failures are counted in status. Extra {"no_ack": false} waits anyway.
+	NoAck bool `json:"no_ack,omitempty"`
+	// Webhooks post pin changes and interrupt ticks to external systems.
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+}
+
+// PinConfig declares how a pin is used so it can be checked against the chip's GPIO
+// matrix before the board is built.
+type PinConfig struct {
+	// Name is an optional alias the pin can be looked up by instead of its number.
+	Name string `json:"name,omitempty"`
+	Pin  int    `json:"pin"`
+	// Mode is one of input, output, pwm, analog or dac.
+	Mode string `json:"mode"`
+	// SafeState is the level the pin should be driven to when the board is not in use.
+	SafeState *bool `json:"safe_state,omitempty"`
+	// Calibration is applied to raw readings of analog pins.
+	Calibration *CalibrationConfig `json:"calibration,omitempty"`
+	// Filter smooths readings of analog pins before calibration.
+	Filter *FilterConfig `json:"filter,omitempty"`
+	// ReadOnly rejects writes to the pin, e.g. to protect a strapping pin.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// WriteOnly rejects reads of the pin.
+	WriteOnly bool `json:"write_only,omitempty"`
+	// OpenDrain makes an output pin pull the line low or release it instead of driving it
+	// high, e.g. to bit-bang I2C on pins the firmware's bus is not wired to.
+	OpenDrain bool `json:"open_drain,omitempty"`
+	// PullUp is what raises a released open-drain line: external (default), a resistor on
+	// the line, or internal, the chip's weak pull-up, which only suits slow lines.
+	PullUp string `json:"pull_up,omitempty"`
+	// VerifyWrites reads an output or pwm pin back after every write to it and fails the
+	// write if the device does not hold the state written.
+	VerifyWrites bool `json:"verify_writes,omitempty"`
+	// GetThreshold and GetHysteresis override the board's for the pin, e.g. for a PWM
+	// pin that should read high from half duty.
+	GetThreshold  *float64 `json:"get_threshold,omitempty"`
+	GetHysteresis *float64 `json:"get_hysteresis,omitempty"`
+}
+
+// validate checks the shared attributes. Errors are prefixed with path.
+func (cfg *BoardConfig) validate(path string) error {
+	profile, err := lookupBoardProfile(cfg.Profile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	variantName := cfg.ChipVariant
+	if profile.chipVariant != "" {
+		if variantName != "" && variantName != profile.chipVariant {
+			return fmt.Errorf("%s: 'chip_variant' %q does not match the %s profile, which uses %q",
+				path, variantName, cfg.Profile, profile.chipVariant)
+		}
+		variantName = profile.chipVariant
+	}
+	if variantName == "" {
+		variantName = defaultChipVariant
+	}
+	variant, err := lookupChipVariant(variantName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	seen := map[int]bool{}
+	for i, pin := range cfg.Pins {
+		pinPath := fmt.Sprintf("%s.pins.%d", path, i)
+		if seen[pin.Pin] {
+			return fmt.Errorf("%s: pin %d is configured more than once", pinPath, pin.Pin)
+		}
+		seen[pin.Pin] = true
+
+		if _, err := strconv.Atoi(pin.Name); err == nil {
+			return fmt.Errorf("%s: 'name' %q must not be a number, pins can already be looked up by number", pinPath, pin.Name)
+		}
+		if use, ok := profile.reserved[pin.Pin]; ok {
+			return fmt.Errorf("%s: GPIO%d is reserved for %s on the %s profile", pinPath, pin.Pin, use, cfg.Profile)
+		}
+		if pin.Mode == pinModePWM && profile.pwmPins != nil && !profile.pwmPins[pin.Pin] {
+			return fmt.Errorf("%s: GPIO%d does not support PWM on the %s profile", pinPath, pin.Pin, cfg.Profile)
+		}
+		if pin.SafeState != nil && pin.Mode != pinModeOutput && pin.Mode != pinModePWM {
+			return fmt.Errorf("%s: 'safe_state' can only be set on output or pwm pins", pinPath)
+		}
+		if pin.ReadOnly && pin.WriteOnly {
+			return fmt.Errorf("%s: a pin cannot be both 'read_only' and 'write_only'", pinPath)
+		}
+		if pin.ReadOnly && pin.SafeState != nil {
+			return fmt.Errorf("%s: 'safe_state' cannot be set on a read_only pin", pinPath)
+		}
+		if pin.WriteOnly && (pin.Mode == pinModeInput || pin.Mode == pinModeAnalog) {
+			return fmt.Errorf("%s: %s pins cannot be write_only", pinPath, pin.Mode)
+		}
+		if pin.OpenDrain && pin.Mode != pinModeOutput {
+			return fmt.Errorf("%s: 'open_drain' can only be set on output pins", pinPath)
+		}
+		if pin.VerifyWrites {
+			switch {
+			case pin.Mode != pinModeOutput && pin.Mode != pinModePWM:
+				return fmt.Errorf("%s: 'verify_writes' can only be set on output or pwm pins", pinPath)
+			case pin.WriteOnly:
+				return fmt.Errorf("%s: 'verify_writes' cannot be set on a write_only pin", pinPath)
+			case pin.OpenDrain:
+				// A released open-drain line reads whatever else drives it.
+				return fmt.Errorf("%s: 'verify_writes' cannot be set on an open_drain pin", pinPath)
+			}
+		}
+		if pin.PullUp != "" {
+			if !pin.OpenDrain {
+				return fmt.Errorf("%s: 'pull_up' can only be set on open_drain pins", pinPath)
+			}
+			if pin.PullUp != pullUpExternal && pin.PullUp != pullUpInternal {
+				return fmt.Errorf("%s: invalid 'pull_up' %q, must be %s or %s", pinPath, pin.PullUp, pullUpExternal, pullUpInternal)
+			}
+		}
+		if pin.GetThreshold != nil || pin.GetHysteresis != nil {
+			if pin.Mode == pinModeAnalog || pin.Mode == pinModeDAC || pin.WriteOnly {
+				return fmt.Errorf("%s: 'get_threshold' and 'get_hysteresis' can only be set on pins Get reads", pinPath)
+			}
+			threshold, hysteresis := pin.GetThreshold, pin.GetHysteresis
+			if threshold == nil {
+				threshold = cfg.GetThreshold
+			}
+			if hysteresis == nil {
+				hysteresis = &cfg.GetHysteresis
+			}
+			if err := validateLogicLevel(threshold, hysteresis); err != nil {
+				return fmt.Errorf("%s: %w", pinPath, err)
+			}
+		}
+		if pin.Calibration != nil {
+			if pin.Mode != pinModeAnalog {
+				return fmt.Errorf("%s: 'calibration' can only be set on analog pins", pinPath)
+			}
+			if err := pin.Calibration.validate(pinPath + ".calibration"); err != nil {
+				return err
+			}
+		}
+		if pin.Filter != nil {
+			if pin.Mode != pinModeAnalog {
+				return fmt.Errorf("%s: 'filter' can only be set on analog pins", pinPath)
+			}
+			if err := pin.Filter.validate(pinPath + ".filter"); err != nil {
+				return err
+			}
+		}
+
+		if !variant.gpios[pin.Pin] {
+			return fmt.Errorf("%s: GPIO%d is not a usable pin on the %s (it does not exist or is wired to flash)",
+				pinPath, pin.Pin, variantName)
+		}
+		switch pin.Mode {
+		case pinModeInput:
+		case pinModeOutput, pinModePWM:
+			if variant.inputOnly[pin.Pin] {
+				return fmt.Errorf("%s: GPIO%d is input-only on the %s and cannot be used as %s, pick a pin without the input-only restriction",
+					pinPath, pin.Pin, variantName, pin.Mode)
+			}
+		case pinModeAnalog:
+			if variant.adc[pin.Pin] == 0 {
+				return fmt.Errorf("%s: GPIO%d has no ADC channel on the %s", pinPath, pin.Pin, variantName)
+			}
+		case pinModeDAC:
+			if len(variant.dac) == 0 {
+				return fmt.Errorf("%s: the %s has no DAC, use a pwm pin with an RC filter instead", pinPath, variantName)
+			}
+			if !variant.dac[pin.Pin] {
+				return fmt.Errorf("%s: GPIO%d has no DAC channel on the %s", pinPath, pin.Pin, variantName)
+			}
+		default:
+			return fmt.Errorf("%s: invalid 'mode' %q, must be one of %s, %s, %s, %s or %s",
+				pinPath, pin.Mode, pinModeInput, pinModeOutput, pinModePWM, pinModeAnalog, pinModeDAC)
+		}
+	}
+
+	pins, err := newPinTable(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	analogNames := map[string]bool{}
+	for i, analog := range cfg.Analogs {
+		analogPath := fmt.Sprintf("%s.analogs.%d", path, i)
+		if err := analog.validate(analogPath, pins); err != nil {
+			return err
+		}
+		if analogNames[analog.Name] {
+			return fmt.Errorf("%s: analog name %q is used more than once", analogPath, analog.Name)
+		}
+		analogNames[analog.Name] = true
+		pinNum, _ := pins.lookup(analog.Pin)
+		if other, ok := pins.byName[analog.Name]; ok && other != pinNum {
+			return fmt.Errorf("%s: analog name %q is already the name of GPIO%d", analogPath, analog.Name, other)
+		}
+	}
+	for i, diff := range cfg.DifferentialAnalogs {
+		diffPath := fmt.Sprintf("%s.differential_analogs.%d", path, i)
+		if err := diff.validate(diffPath, pins); err != nil {
+			return err
+		}
+		if analogNames[diff.Name] {
+			return fmt.Errorf("%s: analog name %q is used more than once", diffPath, diff.Name)
+		}
+		analogNames[diff.Name] = true
+		if other, ok := pins.byName[diff.Name]; ok {
+			return fmt.Errorf("%s: analog name %q is already the name of GPIO%d", diffPath, diff.Name, other)
+		}
+	}
+
+	interruptNames := map[string]bool{}
+	interruptPins := map[int]bool{}
+	for i, interrupt := range cfg.DigitalInterrupts {
+		interruptPath := fmt.Sprintf("%s.digital_interrupts.%d", path, i)
+		if err := interrupt.validate(interruptPath, pins); err != nil {
+			return err
+		}
+		if interruptNames[interrupt.Name] {
+			return fmt.Errorf("%s: interrupt name %q is used more than once", interruptPath, interrupt.Name)
+		}
+		interruptNames[interrupt.Name] = true
+		pinNum, _ := pins.lookup(interrupt.Pin)
+		if other, ok := pins.byName[interrupt.Name]; ok && other != pinNum {
+			return fmt.Errorf("%s: interrupt name %q is already the name of GPIO%d", interruptPath, interrupt.Name, other)
+		}
+		if interruptPins[pinNum] {
+			return fmt.Errorf("%s: GPIO%d has more than one interrupt", interruptPath, pinNum)
+		}
+		interruptPins[pinNum] = true
+	}
+
+	alertNames := map[string]bool{}
+	tickNames := map[string]bool{}
+	for name := range interruptNames {
+		tickNames[name] = true
+	}
+	for i, analog := range cfg.Analogs {
+		analogPath := fmt.Sprintf("%s.analogs.%d", path, i)
+		if analog.AlertIntervalMs != 0 && analog.AlertIntervalMs < minAlertIntervalMs {
+			return fmt.Errorf("%s: 'alert_interval_ms' must be at least %d, got %d", analogPath, minAlertIntervalMs, analog.AlertIntervalMs)
+		}
+		for j, alert := range analog.Alerts {
+			alertPath := fmt.Sprintf("%s.alerts.%d", analogPath, j)
+			if err := alert.validate(alertPath); err != nil {
+				return err
+			}
+			if alertNames[alert.Name] {
+				return fmt.Errorf("%s: alert name %q is used more than once", alertPath, alert.Name)
+			}
+			alertNames[alert.Name] = true
+			if !alert.Tick {
+				continue
+			}
+			tickNames[alert.Name] = true
+			if interruptNames[alert.Name] {
+				return fmt.Errorf("%s: alert name %q is already the name of an interrupt", alertPath, alert.Name)
+			}
+			if other, ok := pins.byName[alert.Name]; ok {
+				return fmt.Errorf("%s: alert name %q is already the name of GPIO%d", alertPath, alert.Name, other)
+			}
+			if _, err := strconv.Atoi(alert.Name); err == nil {
+				return fmt.Errorf("%s: 'name' %q of an alert with 'tick' must not be a number", alertPath, alert.Name)
+			}
+		}
+	}
+
+	macroNames := map[string]bool{}
+	for i, macro := range cfg.Macros {
+		macroPath := fmt.Sprintf("%s.macros.%d", path, i)
+		if macro.Name == "" {
+			return fmt.Errorf("%s: missing required field 'name'", macroPath)
+		}
+		if macroNames[macro.Name] {
+			return fmt.Errorf("%s: macro name %q is used more than once", macroPath, macro.Name)
+		}
+		macroNames[macro.Name] = true
+		if _, err := macro.resolve(pins); err != nil {
+			return fmt.Errorf("%s: %w", macroPath, err)
+		}
+	}
+	webhookNames := map[string]bool{}
+	for i, webhook := range cfg.Webhooks {
+		webhookPath := fmt.Sprintf("%s.webhooks.%d", path, i)
+		if err := webhook.validate(webhookPath, pins, tickNames); err != nil {
+			return err
+		}
+		if webhookNames[webhook.Name] {
+			return fmt.Errorf("%s: webhook name %q is used more than once", webhookPath, webhook.Name)
+		}
+		webhookNames[webhook.Name] = true
+	}
+	if err := validateRelays(path, cfg.Relays, cfg.Interlocks, pins); err != nil {
+		return err
+	}
+	groupNames := map[string]bool{}
+	for i, group := range cfg.PinGroups {
+		groupPath := fmt.Sprintf("%s.pin_groups.%d", path, i)
+		if group.Name == "" {
+			return fmt.Errorf("%s: missing required field 'name'", groupPath)
+		}
+		if groupNames[group.Name] {
+			return fmt.Errorf("%s: pin group name %q is used more than once", groupPath, group.Name)
+		}
+		groupNames[group.Name] = true
+		if _, err := group.resolve(pins); err != nil {
+			return fmt.Errorf("%s: %w", groupPath, err)
+		}
+	}
+	if cfg.Power != nil {
+		if err := cfg.Power.validate(path+".power", pins); err != nil {
+			return err
+		}
+	}
+	if cfg.SelfTest != nil {
+		if err := cfg.SelfTest.validate(path+".self_test", pins); err != nil {
+			return err
+		}
+	}
+	if cfg.WarmUp != nil {
+		if err := cfg.WarmUp.validate(path + ".warm_up"); err != nil {
+			return err
+		}
+	}
+	if cfg.BatteryMode != nil {
+		if err := cfg.BatteryMode.validate(path + ".battery_mode"); err != nil {
+			return err
+		}
+	}
+	if cfg.Recovery != nil {
+		if err := cfg.Recovery.validate(path + ".recovery"); err != nil {
+			return err
+		}
+	}
+	if cfg.Lease != nil {
+		if err := cfg.Lease.validate(path + ".lease"); err != nil {
+			return err
+		}
+	}
+	if cfg.ResetLine != nil {
+		if err := cfg.ResetLine.validate(path + ".reset_line"); err != nil {
+			return err
+		}
+	}
+	if cfg.Dashboard != nil {
+		if err := cfg.Dashboard.validate(path + ".dashboard"); err != nil {
+			return err
+		}
+	}
+	switch cfg.ProtocolVersion {
+	case esp32client.ProtocolAuto, esp32client.ProtocolLegacy, esp32client.ProtocolCurrent:
+	default:
+		return fmt.Errorf("%s: invalid 'protocol_version' %d, must be %d (legacy) or %d (current), or unset to detect it",
+			path, cfg.ProtocolVersion, esp32client.ProtocolLegacy, esp32client.ProtocolCurrent)
+	}
+	if err := validateStateFormat(cfg.StateFormat); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := validateLogicLevel(cfg.GetThreshold, &cfg.GetHysteresis); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// protocolOptions returns the esp32client options for the configured protocol version.
+func (cfg *BoardConfig) protocolOptions() []esp32client.Option {
+	if cfg.ProtocolVersion == esp32client.ProtocolAuto {
+		return nil
+	}
+	return []esp32client.Option{esp32client.WithProtocolVersion(cfg.ProtocolVersion)}
+}
+
+// dependencies returns the resources the board needs: the boards of its power_cycle and
+// reset_line pins.
+func (cfg *BoardConfig) dependencies() []string {
+	var deps []string
+	if cfg.Recovery != nil && cfg.Recovery.PowerCycle != nil {
+		deps = append(deps, cfg.Recovery.PowerCycle.Board)
+	}
+	if cfg.ResetLine != nil {
+		deps = append(deps, cfg.ResetLine.Board)
+	}
+	return deps
+}
+
+// validateWiFiADC rejects analog pins on ADC2 for models that keep WiFi active, on chips
+// where WiFi and ADC2 cannot be used together.
+func (cfg *BoardConfig) validateWiFiADC(path string) error {
+	pins, err := newPinTable(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	variant, err := lookupChipVariant(pins.variantName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if !variant.adc2WiFiConflict {
+		return nil
+	}
+	adc2Error := func(path string, pinNum int) error {
+		return fmt.Errorf("%s: GPIO%d is on ADC2, which cannot be read while WiFi is active on the %s; "+
+			"use an ADC1 pin (GPIO %s) or the esp32-ble model", path, pinNum, pins.variantName, variant.adc1Pins())
+	}
+	for i, pin := range cfg.Pins {
+		if pin.Mode == pinModeAnalog && variant.adc[pin.Pin] == 2 {
+			return adc2Error(fmt.Sprintf("%s.pins.%d", path, i), pin.Pin)
+		}
+	}
+	for i, analog := range cfg.Analogs {
+		if pinNum, err := pins.lookup(analog.Pin); err == nil && variant.adc[pinNum] == 2 {
+			return adc2Error(fmt.Sprintf("%s.analogs.%d", path, i), pinNum)
+		}
+	}
+	for i, diff := range cfg.DifferentialAnalogs {
+		for _, pin := range []string{diff.PinA, diff.PinB} {
+			if pinNum, err := pins.lookup(pin); err == nil && variant.adc[pinNum] == 2 {
+				return adc2Error(fmt.Sprintf("%s.differential_analogs.%d", path, i), pinNum)
+			}
+		}
+	}
+	return nil
+}
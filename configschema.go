@@ -0,0 +1,318 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.viam.com/rdk/resource"
+
+	"esp32wifi/esp32client"
+)
+
+// configSchemaDraft is the JSON schema dialect ConfigSchema returns.
+const configSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// configModels are the config types of the module's models.
+var configModels = []struct {
+	model resource.Model
+	cfg   interface{}
+	// hints override configHints for attributes the model uses differently.
+	hints map[string]fieldHint
+}{
+	{Esp32Wifi, WifiConfig{}, nil},
+	{Esp32Ble, BleConfig{}, nil},
+	{Esp32Hybrid, HybridConfig{}, nil},
+	{Esp32Beacon, BeaconConfig{}, nil},
+	{Esp32Power, PowerSensorConfig{}, nil},
+	{Esp32MPU6050, IMUConfig{}, map[string]fieldHint{
+		"chip":    {def: imuChipMPU6050, enum: enumOf(imuChipMPU6050, imuChipICM20948)},
+		"address": {description: "7-bit I2C address, 0x68 for the mpu6050 and 0x69 for the icm20948 by default."},
+	}},
+	{Esp32INA219, INAConfig{}, map[string]fieldHint{
+		"chip":    {def: inaChipINA219, enum: enumOf(inaChipINA219, inaChipINA226)},
+		"address": {description: "7-bit I2C address.", def: defaultINAAddress},
+	}},
+	{Esp32Relay, RelaySwitchConfig{}, map[string]fieldHint{
+		"relay": {description: "Name of a relay in the board's relays."},
+	}},
+	{Esp32Button, ButtonControllerConfig{}, map[string]fieldHint{
+		"buttons.control":         {description: "Input control the button's events are sent for, e.g. ButtonSouth."},
+		"buttons.interrupt":       {description: "Name of a digital interrupt on the board reporting both edges."},
+		"buttons.debounce_ms":     {def: defaultButtonDebounceMs},
+		"buttons.long_press_ms":   {description: "How long the button is held before ButtonHold is sent.", def: defaultButtonLongPressMs},
+		"buttons.double_press_ms": {description: "How soon after a release a press is also sent as ButtonDoublePress.", def: defaultButtonDoublePressMs},
+	}},
+	{Esp32Rules, RulesConfig{}, map[string]fieldHint{
+		"interval_ms":      {description: "How often the rules are evaluated.", def: defaultRuleIntervalMs},
+		"rules.analog":     {description: "Name of the board's analog reader the rule watches."},
+		"rules.above":      {description: "The rule holds while the reading is above this value."},
+		"rules.below":      {description: "The rule holds while the reading is below this value."},
+		"rules.hysteresis": {description: "How far back past 'above' or 'below' the reading must go before the rule stops holding."},
+		"rules.pin":        {description: "Output pin driven high while the rule holds and low otherwise."},
+		"rules.relay":      {description: "Name of a relay in the board's relays, energized while the rule holds."},
+	}},
+}
+
+// fieldHint is what the schema says about an attribute beyond its type, which the Go
+// type alone does not tell.
+type fieldHint struct {
+	description string
+	def         interface{}
+	enum        []interface{}
+}
+
+// configHints are keyed by attribute path, the json names from the top of the config
+// with arrays and maps left out, e.g. "digital_interrupts.edge".
+var configHints = map[string]fieldHint{
+	"profile":                       {description: "Presets pin names, reserved pins and safe states for a known board.", enum: boardProfileNames()},
+	"chip_variant":                  {description: "The chip the pins are checked against.", def: defaultChipVariant, enum: chipVariantNames()},
+	"pins":                          {description: "How each pin is used, checked against the chip before the board starts."},
+	"pins.name":                     {description: "Alias the pin can be looked up by."},
+	"pins.mode":                     {enum: enumOf(pinModeInput, pinModeOutput, pinModePWM, pinModeAnalog, pinModeDAC)},
+	"pins.safe_state":               {description: "Level the pin is driven to when the board is not in use."},
+	"pins.pull_up":                  {description: "What raises a released open-drain line.", def: pullUpExternal, enum: enumOf(pullUpExternal, pullUpInternal)},
+	"pins.calibration.scale":        {def: 1},
+	"pins.filter.type":              {enum: enumOf(filterMean, filterMedian, filterEMA)},
+	"analogs":                       {description: "Analog readers looked up by name."},
+	"analogs.pin":                   {description: "Pin name or GPIO number."},
+	"analogs.alert_interval_ms":     {def: defaultAlertIntervalMs},
+	"analogs.units.scale":           {def: 1},
+	"differential_analogs":          {description: "Virtual analogs reading pin_a minus pin_b."},
+	"differential_analogs.gain":     {def: 1},
+	"digital_interrupts":            {description: "Interrupts looked up by name."},
+	"digital_interrupts.pin":        {description: "Pin name or GPIO number."},
+	"digital_interrupts.type":       {description: "basic counts the edges the firmware pushes, counter has the firmware count them, poll has the module read the pin.", def: interruptTypeBasic, enum: enumOf(interruptTypeBasic, interruptTypeCounter, interruptTypePoll)},
+	"digital_interrupts.sync_ms":    {def: defaultCounterSyncMs},
+	"digital_interrupts.poll_hz":    {description: "How often a poll interrupt is read; on a basic interrupt, polls it if the firmware cannot push it."},
+	"digital_interrupts.edge":       {def: esp32client.EdgeBoth, enum: enumOf(esp32client.EdgeRising, esp32client.EdgeFalling, esp32client.EdgeBoth)},
+	"digital_interrupts.pull":       {def: esp32client.PullNone, enum: enumOf(esp32client.PullNone, esp32client.PullUp, esp32client.PullDown)},
+	"macros":                        {description: "Sequences of pin writes started with run_macro."},
+	"pin_groups":                    {description: "Output pins switched between named states with set_group_state."},
+	"relays":                        {description: "Output pins switched only through set_relay and esp32-relay switches."},
+	"relays.pin":                    {description: "Pin name or GPIO number."},
+	"interlocks":                    {description: "Relays that are never energized together."},
+	"apply_safe_state_on_close":     {description: "Drives every pin with a safe state to it when the board is closed."},
+	"tracing":                       {description: "Exports a span for every request to the device."},
+	"suppress_repeat_writes":        {description: "Skips writes of the state last written to a pin."},
+	"power":                         {description: "Supply voltage telemetry."},
+	"power.divider_ratio":           {def: defaultDividerRatio},
+	"power.warn_below_v":            {def: defaultWarnBelowV},
+	"webhooks":                      {description: "Targets posted a JSON payload when a watched pin changes or an interrupt ticks."},
+	"webhooks.retries":              {def: defaultWebhookRetries},
+	"webhooks.timeout_ms":           {def: defaultWebhookTimeoutMs},
+	"self_test":                     {description: "Loopback wiring checked by self_test."},
+	"self_test.pwm_freq_hz":         {def: defaultSelfTestPWMFreqHz},
+	"warm_up":                       {description: "Waits for the device to be ready before the board starts."},
+	"warm_up.timeout_ms":            {def: defaultWarmUpTimeoutMs},
+	"protocol_version":              {description: "Pin read payload format of the firmware, detected if unset.", enum: []interface{}{1, 2}},
+	"state_format":                  {description: "Scale the firmware reads pins as, detected if unset.", enum: enumOf(esp32client.StateFormatPercent, esp32client.StateFormatRaw)},
+	"get_threshold":                 {description: "Fraction of full scale at or above which Get reads high, 1 by default or 0.5 for raw firmware."},
+	"get_hysteresis":                {description: "Fraction of full scale a high pin must drop below get_threshold to read low."},
+	"pins.get_threshold":            {description: "Overrides the board's get_threshold."},
+	"pins.get_hysteresis":           {description: "Overrides the board's get_hysteresis."},
+	"dashboard":                     {description: "Serves a debug dashboard over HTTP."},
+	"record_path":                   {description: "JSONL file every exchange with the device is appended to."},
+	"battery_mode":                  {description: "Batches background traffic into wake windows."},
+	"battery_mode.wake_interval_ms": {def: defaultWakeIntervalMs},
+	"battery_mode.window_ms":        {def: defaultWakeWindowMs},
+	"recovery":                      {description: "Restarts or power cycles a device that stopped working."},
+	"recovery.check_interval_ms":    {def: defaultRecoveryCheckIntervalMs},
+	"recovery.power_cycle.off_ms":   {def: defaultPowerOffMs},
+	"recovery.power_cycle.after_s":  {def: defaultPowerCycleAfterS},
+	"reset_line":                    {description: "GPIO on another board wired to the ESP32's EN pin."},
+	"reset_line.pulse_ms":           {def: defaultResetPulseMs},
+	"lease":                         {description: "Takes exclusive control of the device's outputs."},
+	"lease.duration_ms":             {def: defaultLeaseDurationMs},
+	"no_ack":                        {description: "Returns from writes without waiting for the device to answer."},
+
+	"url":                       {description: `Firmware address, e.g. "http://192.168.1.50", or "resource://<board>" to forward through another board.`},
+	"host":                      {description: "Firmware host, instead of url."},
+	"urls":                      {description: "Firmware addresses tried in order, instead of url, e.g. a LAN and an AP-mode address."},
+	"port":                      {def: defaultPort},
+	"expected_device_id":        {description: "MAC address or chip ID the device must report."},
+	"proxy":                     {description: "http, https or socks5 proxy to reach the device through."},
+	"timeout_ms":                {description: "Bounds each request to the device."},
+	"adaptive_timeout":          {description: "Bounds each request by the latency of its endpoint."},
+	"adaptive_timeout.multiple": {def: defaultAdaptiveMultiple},
+	"adaptive_timeout.min_ms":   {def: defaultAdaptiveMinMs},
+	"adaptive_timeout.max_ms":   {def: defaultAdaptiveMaxMs},
+
+	"bt_server_name": {description: "BLE name the firmware advertises."},
+	"security":       {def: string(esp32client.SecurityNone), enum: enumOf(string(esp32client.SecurityNone), string(esp32client.SecurityBond), string(esp32client.SecurityPasskey))},
+	"passkey":        {description: "Six digit passkey, required when security is passkey."},
+	"keep_alive_ms":  {description: "Idle time before the board pings the device, 0 disables it.", def: defaultKeepAliveMs},
+	"write_mode":     {description: "reliable writes every BLE request with response, fast none; unset, only safe state pins and relays.", enum: enumOf(writeModeFast, writeModeReliable)},
+	"stale_after_ms": {def: defaultBeaconStaleAfterMs},
+	"board":          {description: "Name of the esp32 board the sensor reads through."},
+	"bus":            {description: "Firmware I2C bus the chip is on.", def: 0},
+	"accel_range_g":  {def: defaultAccelRangeG, enum: []interface{}{2, 4, 8, 16}},
+	"gyro_range_dps": {def: defaultGyroRangeDps, enum: []interface{}{250, 500, 1000, 2000}},
+	"shunt_ohms":     {def: defaultShuntOhms},
+}
+
+func enumOf(values ...string) []interface{} {
+	enum := make([]interface{}, len(values))
+	for i, value := range values {
+		enum[i] = value
+	}
+	return enum
+}
+
+func boardProfileNames() []interface{} {
+	names := make([]string, 0, len(boardProfiles))
+	for name := range boardProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return enumOf(names...)
+}
+
+func chipVariantNames() []interface{} {
+	names := make([]string, 0, len(chipVariants))
+	for name := range chipVariants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return enumOf(names...)
+}
+
+// ConfigSchema returns the JSON schema of a model's attributes, with their types,
+// defaults and allowed values, for building a configuration form.
+func ConfigSchema(model resource.Model) (map[string]interface{}, error) {
+	for _, m := range configModels {
+		if m.model == model {
+			hints := configHints
+			if m.hints != nil {
+				hints = make(map[string]fieldHint, len(configHints)+len(m.hints))
+				for path, hint := range configHints {
+					hints[path] = hint
+				}
+				for path, hint := range m.hints {
+					hints[path] = hint
+				}
+			}
+			schema := typeSchema(reflect.TypeOf(m.cfg), "", hints)
+			schema["$schema"] = configSchemaDraft
+			schema["title"] = model.String()
+			return schema, nil
+		}
+	}
+	return nil, fmt.Errorf("no config schema for model %s", model)
+}
+
+// typeSchema returns the schema of t, the type of the attribute at path, described by
+// hints.
+func typeSchema(t reflect.Type, path string, hints map[string]fieldHint) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	schema := map[string]interface{}{}
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []interface{}
+		addFields(t, path, hints, properties, &required)
+		schema["type"] = "object"
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	case reflect.Slice, reflect.Array:
+		schema["type"] = "array"
+		schema["items"] = typeSchema(t.Elem(), path, hints)
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = typeSchema(t.Elem(), path, hints)
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema["type"] = "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	}
+	return schema
+}
+
+// addFields adds the attributes of struct t to properties, inlining squashed fields.
+// Fields without omitempty are required.
+func addFields(t reflect.Type, path string, hints map[string]fieldHint, properties map[string]interface{}, required *[]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("json")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if strings.Contains(options, "squash") {
+			addFields(field.Type, path, hints, properties, required)
+			continue
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		schema := typeSchema(field.Type, fieldPath, hints)
+		if hint, ok := hints[fieldPath]; ok {
+			if hint.description != "" {
+				schema["description"] = hint.description
+			}
+			if hint.def != nil {
+				schema["default"] = hint.def
+			}
+			if hint.enum != nil {
+				schema["enum"] = hint.enum
+			}
+		}
+		properties[name] = schema
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// doConfigSchema returns the JSON schema of the named model's attributes, or of every
+// model's by model name.
+//
+//	{"command": "config_schema", "model": "esp32-wifi"}
+func (s *esp32Board) doConfigSchema(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd["model"]; ok {
+		name, err := stringArg(cmd, "model")
+		if err != nil {
+			return nil, err
+		}
+		model, err := configModel(name)
+		if err != nil {
+			return nil, err
+		}
+		return ConfigSchema(model)
+	}
+	schemas := make(map[string]interface{}, len(configModels))
+	for _, m := range configModels {
+		schema, err := ConfigSchema(m.model)
+		if err != nil {
+			return nil, err
+		}
+		schemas[m.model.Name] = schema
+	}
+	return map[string]interface{}{"schemas": schemas}, nil
+}
+
+// configModel resolves a model by its name, e.g. "esp32-ble", or its full triplet.
+func configModel(name string) (resource.Model, error) {
+	names := make([]string, len(configModels))
+	for i, m := range configModels {
+		if m.model.Name == name || m.model.String() == name {
+			return m.model, nil
+		}
+		names[i] = m.model.Name
+	}
+	return resource.Model{}, fmt.Errorf("unknown 'model' %q, must be one of %s", name, strings.Join(names, ", "))
+}
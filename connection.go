@@ -0,0 +1,228 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const infoTimeout = 5 * time.Second
+
+// Status describes the board's connection to the device.
+type Status struct {
+	// Connected is true if the most recent request reached the device.
+	Connected bool
+	// LastError is the error of the most recent failed request, even if later requests
+	// succeeded, and LastErrorTime is when it happened.
+	LastError     error
+	LastErrorTime time.Time
+	// Latency is the round trip time of the most recent successful request.
+	Latency time.Duration
+	// FirmwareVersion is reported by firmware with an /info endpoint and empty otherwise.
+	FirmwareVersion string
+}
+
+// ConnectionObserver is implemented by the boards in this package. Go programs that
+// embed a board can type assert to it to reflect device connectivity.
+type ConnectionObserver interface {
+	// Status returns the current connection status.
+	Status() Status
+	// OnConnect calls fn the first time a request reaches the device.
+	OnConnect(fn func())
+	// OnDisconnect calls fn with the error of the first request to fail after the device
+	// was reachable.
+	OnDisconnect(fn func(err error))
+	// OnReconnect calls fn when a request reaches the device after it was disconnected.
+	OnReconnect(fn func())
+}
+
+// connMonitor tracks connectivity from the outcome of every request. Hooks run on the
+// goroutine that made the request and must not block.
+type connMonitor struct {
+	mu         sync.Mutex
+	status     Status
+	everOnline bool
+	// lastRequest is when the most recent request that reached or failed to reach the
+	// device finished.
+	lastRequest time.Time
+	// latencies are the most recent requests, oldest first, for the dashboard.
+	latencies    []latencySample
+	onConnect    []func()
+	onDisconnect []func(error)
+	onReconnect  []func()
+}
+
+// record updates the status with the outcome of a request that took latency and runs
+// the hooks of any resulting transition.
+func (m *connMonitor) record(err error, latency time.Duration) {
+	// ErrNotSupported and ErrBoardBusy may be returned without contacting the device, and
+	// cancellation is up to the caller, so none says anything about the connection.
+	if errors.Is(err, esp32client.ErrNotSupported) || errors.Is(err, esp32client.ErrBoardBusy) ||
+		errors.Is(err, context.Canceled) {
+		return
+	}
+	// The device answered a partially failed write, so it is reachable.
+	if errors.As(err, new(*esp32client.PartialWriteError)) {
+		err = nil
+	}
+
+	m.mu.Lock()
+	m.lastRequest = time.Now()
+	if len(m.latencies) == latencyHistorySize {
+		m.latencies = append(m.latencies[:0], m.latencies[1:]...)
+	}
+	m.latencies = append(m.latencies, latencySample{
+		At:        m.lastRequest,
+		LatencyMs: float64(latency) / float64(time.Millisecond),
+		OK:        err == nil,
+	})
+	wasConnected, everOnline := m.status.Connected, m.everOnline
+	var hooks []func()
+	var disconnectHooks []func(error)
+	if err != nil {
+		m.status.Connected = false
+		m.status.LastError, m.status.LastErrorTime = err, time.Now()
+		if wasConnected {
+			disconnectHooks = append(disconnectHooks, m.onDisconnect...)
+		}
+	} else {
+		m.status.Connected, m.everOnline = true, true
+		m.status.Latency = latency
+		switch {
+		case !everOnline:
+			hooks = append(hooks, m.onConnect...)
+		case !wasConnected:
+			hooks = append(hooks, m.onReconnect...)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+	for _, fn := range disconnectHooks {
+		fn(err)
+	}
+}
+
+// idleFor returns how long ago the most recent request finished, or forever if there was
+// none yet.
+func (m *connMonitor) idleFor() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lastRequest.IsZero() {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Since(m.lastRequest)
+}
+
+// recentLatencies returns a copy of the latency history.
+func (m *connMonitor) recentLatencies() []latencySample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]latencySample(nil), m.latencies...)
+}
+
+// monitoredClient records the outcome of every request on the wrapped client, and the
+// pin events of pin requests.
+type monitoredClient struct {
+	esp32client.Client
+	monitor *connMonitor
+	// pins records what every request did with each pin.
+	pins *pinTracker
+}
+
+func (c *monitoredClient) unwrap() esp32client.Client {
+	return c.Client
+}
+
+// observe records the outcome of a request that started at start. It is deferred so
+// err must be a pointer to the named result.
+func (c *monitoredClient) observe(start time.Time, err *error) {
+	c.monitor.record(*err, time.Since(start))
+}
+
+func (c *monitoredClient) ReadPins(ctx context.Context, pins []int) (reads []esp32client.PinRead, err error) {
+	defer c.observe(time.Now(), &err)
+	reads, err = c.Client.ReadPins(ctx, pins)
+	c.pins.reads(pins, reads, err)
+	return reads, err
+}
+
+func (c *monitoredClient) WritePins(ctx context.Context, writes []esp32client.PinWrite) (err error) {
+	defer c.observe(time.Now(), &err)
+	err = c.Client.WritePins(ctx, writes)
+	c.pins.writes(writes, err)
+	return err
+}
+
+func (c *monitoredClient) Transaction(ctx context.Context, writes []esp32client.PinWrite) (err error) {
+	defer c.observe(time.Now(), &err)
+	err = c.Client.Transaction(ctx, writes)
+	c.pins.writes(writes, err)
+	return err
+}
+
+func (c *monitoredClient) SetPWMFreqs(ctx context.Context, freqs []esp32client.PinFreq) (err error) {
+	defer c.observe(time.Now(), &err)
+	err = c.Client.SetPWMFreqs(ctx, freqs)
+	c.pins.freqs(freqs, err)
+	return err
+}
+
+func (c *monitoredClient) Info(ctx context.Context) (info esp32client.Info, err error) {
+	defer c.observe(time.Now(), &err)
+	return c.Client.Info(ctx)
+}
+
+func (c *monitoredClient) Call(ctx context.Context, method, path string, body, out interface{}) (err error) {
+	defer c.observe(time.Now(), &err)
+	return c.Client.Call(ctx, method, path, body, out)
+}
+
+// Status returns the current connection status.
+func (s *esp32Board) Status() Status {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	return s.conn.status
+}
+
+// OnConnect calls fn the first time a request reaches the device.
+func (s *esp32Board) OnConnect(fn func()) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	s.conn.onConnect = append(s.conn.onConnect, fn)
+}
+
+// OnDisconnect calls fn with the error of the first request to fail after the device was
+// reachable.
+func (s *esp32Board) OnDisconnect(fn func(err error)) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	s.conn.onDisconnect = append(s.conn.onDisconnect, fn)
+}
+
+// OnReconnect calls fn when a request reaches the device after it was disconnected.
+func (s *esp32Board) OnReconnect(fn func()) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	s.conn.onReconnect = append(s.conn.onReconnect, fn)
+}
+
+// probeDevice reads the firmware version, which also establishes the initial status.
+func (s *esp32Board) probeDevice() {
+	ctx, cancel := context.WithTimeout(s.cancelCtx, infoTimeout)
+	defer cancel()
+	info, err := s.client.Info(ctx)
+	if err != nil {
+		s.logger.Debugf("could not read device info: %v", err)
+		return
+	}
+	s.conn.mu.Lock()
+	s.conn.status.FirmwareVersion = info.FirmwareVersion
+	s.conn.mu.Unlock()
+}
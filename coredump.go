@@ -0,0 +1,97 @@
+package esp32wifi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"esp32wifi/esp32client"
+)
+
+// watchCoreDumps warns when the device has a core dump stored, checking whenever it
+// connects, reconnects or reboots. It stops if the firmware does not store core dumps.
+func (s *esp32Board) watchCoreDumps() {
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-s.coreDumpCheck:
+		}
+		ctx, cancel := context.WithTimeout(s.cancelCtx, infoTimeout)
+		info, err := esp32client.ReadCoreDumpInfo(ctx, s.client)
+		cancel()
+		switch {
+		case errors.Is(err, esp32client.ErrNotSupported):
+			s.logger.Debugf("firmware does not report core dumps: %v", err)
+			return
+		case err != nil:
+			if s.cancelCtx.Err() == nil {
+				s.logger.Debugf("failed to check for a core dump: %v", err)
+			}
+		case info.Present:
+			reason := info.PanicReason
+			if reason == "" {
+				reason = "unknown reason"
+			}
+			s.logger.Warnf("device crashed (%s) and stored a %d byte core dump, "+
+				"download it with the coredump_download command and clear it with coredump_erase", reason, info.Size)
+		}
+	}
+}
+
+// checkCoreDump asks watchCoreDumps to check for a core dump. It does not block, so it
+// can run from connection hooks.
+func (s *esp32Board) checkCoreDump() {
+	select {
+	case s.coreDumpCheck <- struct{}{}:
+	default:
+	}
+}
+
+func (s *esp32Board) doCoreDumpInfo(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	info, err := esp32client.ReadCoreDumpInfo(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"present": info.Present, "size": info.Size}
+	if info.PanicReason != "" {
+		result["panic_reason"] = info.PanicReason
+	}
+	if info.Task != "" {
+		result["task"] = info.Task
+	}
+	return result, nil
+}
+
+// doCoreDumpDownload returns the stored core dump base64 encoded, or writes it to path.
+//
+//	{"command": "coredump_download", "path": "/tmp/esp32-core.elf"}
+func (s *esp32Board) doCoreDumpDownload(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	var path string
+	if _, ok := cmd["path"]; ok {
+		var err error
+		if path, err = stringArg(cmd, "path"); err != nil {
+			return nil, err
+		}
+	}
+	dump, err := esp32client.ReadCoreDump(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return map[string]interface{}{"size": len(dump), "data": base64.StdEncoding.EncodeToString(dump)}, nil
+	}
+	if err := os.WriteFile(path, dump, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write core dump: %w", err)
+	}
+	return map[string]interface{}{"size": len(dump), "path": path}, nil
+}
+
+func (s *esp32Board) doCoreDumpErase(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if err := esp32client.EraseCoreDump(ctx, s.client); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"erased": true}, nil
+}
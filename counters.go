@@ -0,0 +1,192 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+// counterSyncTimeout bounds a read of the counters.
+const counterSyncTimeout = 5 * time.Second
+
+// interruptCounters are the counter interrupts, which the firmware counts itself so no
+// edge is lost while the device is out of reach. The board reads the counts every sync
+// interval and interpolates between reads.
+type interruptCounters struct {
+	mu       sync.Mutex
+	counters map[int]*interruptCounter
+	// unsupported is set once the firmware turned out not to count, so Value falls back
+	// to counting events.
+	unsupported bool
+}
+
+type interruptCounter struct {
+	interval time.Duration
+	due      time.Time
+
+	// count is the last count read and syncedAt when it was taken. offset adds the counts
+	// of the device's earlier boots, since its count restarts when it reboots.
+	synced   bool
+	count    int64
+	offset   int64
+	syncedAt time.Time
+	// rate is counts per second between the last two reads.
+	rate float64
+	// reported is the highest value Value returned, which it never goes below.
+	reported int64
+	// rebooted is set when the device rebooted since the last read.
+	rebooted bool
+}
+
+func newInterruptCounters() *interruptCounters {
+	return &interruptCounters{counters: map[int]*interruptCounter{}}
+}
+
+func (c *interruptCounters) add(pinNum int, interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[pinNum] = &interruptCounter{interval: interval}
+}
+
+// counting reports whether the firmware counts pinNum.
+func (c *interruptCounters) counting(pinNum int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.counters[pinNum]
+	return ok && !c.unsupported
+}
+
+// value returns the count of pinNum interpolated to now, and false if pinNum is not a
+// counter or was never read.
+func (c *interruptCounters) value(pinNum int, now time.Time) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.counters[pinNum]
+	if !ok || !counter.synced || c.unsupported {
+		return 0, false
+	}
+	elapsed := now.Sub(counter.syncedAt)
+	switch {
+	case elapsed < 0:
+		elapsed = 0
+	case elapsed > counter.interval:
+		// A missed read must not make the count run away.
+		elapsed = counter.interval
+	}
+	value := counter.offset + counter.count + int64(counter.rate*elapsed.Seconds())
+	if value < counter.reported {
+		value = counter.reported
+	}
+	counter.reported = value
+	return value, true
+}
+
+// record applies counts read at their device times.
+func (c *interruptCounters) record(counts []esp32client.InterruptCount, times []time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, read := range counts {
+		counter, ok := c.counters[read.PinNum]
+		if !ok {
+			continue
+		}
+		if counter.synced && (counter.rebooted || read.Count < counter.count) {
+			// The count restarted with the device. Edges between the last read and the
+			// reboot are lost.
+			counter.offset += counter.count
+			counter.rate = 0
+		} else if counter.synced {
+			if dt := times[i].Sub(counter.syncedAt).Seconds(); dt > 0 {
+				counter.rate = float64(read.Count-counter.count) / dt
+			}
+		}
+		counter.synced, counter.rebooted = true, false
+		counter.count, counter.syncedAt = read.Count, times[i]
+	}
+}
+
+// rebooted marks every counter as restarted.
+func (c *interruptCounters) rebooted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, counter := range c.counters {
+		counter.rebooted = true
+	}
+}
+
+// due returns the counters due for a read at now, and moves them to their next read.
+func (c *interruptCounters) due(now time.Time) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var pinNums []int
+	for pinNum, counter := range c.counters {
+		if !now.Before(counter.due) {
+			pinNums = append(pinNums, pinNum)
+			counter.due = now.Add(counter.interval)
+		}
+	}
+	sort.Ints(pinNums)
+	return pinNums
+}
+
+// tick returns the shortest sync interval.
+func (c *interruptCounters) tick() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var tick time.Duration
+	for _, counter := range c.counters {
+		if tick == 0 || counter.interval < tick {
+			tick = counter.interval
+		}
+	}
+	return tick
+}
+
+// syncCounters reads the counts of pinNums in one request.
+func (s *esp32Board) syncCounters(ctx context.Context, pinNums []int) error {
+	counts, err := esp32client.ReadInterruptCounts(ctx, s.client, pinNums)
+	if err != nil {
+		return err
+	}
+	times := make([]time.Time, len(counts))
+	for i, count := range counts {
+		times[i] = s.tickTime(count.TimestampUs)
+	}
+	s.counters.record(counts, times)
+	return nil
+}
+
+// syncInterruptCounters reads every counter at its sync interval until the board is
+// closed. A failed read is retried at the next one, and the firmware keeps counting in
+// the meantime. It stops if the firmware does not count.
+func (s *esp32Board) syncInterruptCounters() {
+	ticker := time.NewTicker(s.counters.tick())
+	defer ticker.Stop()
+	for {
+		if pinNums := s.counters.due(time.Now()); len(pinNums) > 0 {
+			ctx, cancel := context.WithTimeout(s.cancelCtx, counterSyncTimeout)
+			err := s.syncCounters(ctx, pinNums)
+			cancel()
+			switch {
+			case errors.Is(err, esp32client.ErrNotSupported):
+				s.logger.Warnf("firmware does not count interrupts, counting their events instead: %v", err)
+				s.counters.mu.Lock()
+				s.counters.unsupported = true
+				s.counters.mu.Unlock()
+				return
+			case err != nil && s.cancelCtx.Err() == nil:
+				s.logger.Debugf("failed to sync interrupt counters: %v", err)
+			}
+		}
+
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
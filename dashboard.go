@@ -0,0 +1,240 @@
+package esp32wifi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	dashboardShutdownTimeout = 2 * time.Second
+	dashboardRequestTimeout  = 5 * time.Second
+	// latencyHistorySize is how many request latencies the dashboard charts.
+	latencyHistorySize = 120
+)
+
+// DashboardConfig serves a debug dashboard for commissioning hardware without the Viam
+// app. It has no authentication, so it should only listen where the network is trusted.
+type DashboardConfig struct {
+	// Listen is the address to serve on, e.g. "127.0.0.1:8090" or ":8090".
+	Listen string `json:"listen"`
+}
+
+func (cfg *DashboardConfig) validate(path string) error {
+	if cfg.Listen == "" {
+		return fmt.Errorf("%s: missing required field 'listen'", path)
+	}
+	if _, _, err := net.SplitHostPort(cfg.Listen); err != nil {
+		return fmt.Errorf("%s: invalid 'listen' %q: %w", path, cfg.Listen, err)
+	}
+	return nil
+}
+
+// latencySample is one request latency charted by the dashboard.
+type latencySample struct {
+	At        time.Time `json:"at"`
+	LatencyMs float64   `json:"latency_ms"`
+	OK        bool      `json:"ok"`
+}
+
+// serveDashboard starts the dashboard and returns a func that stops it.
+func (s *esp32Board) serveDashboard(cfg *DashboardConfig) (func(ctx context.Context) error, error) {
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve the dashboard on %s: %w", cfg.Listen, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, dashboardHTML)
+	})
+	mux.HandleFunc("GET /api/state", s.handleDashboardState)
+	mux.HandleFunc("POST /api/set", s.handleDashboardSet)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: dashboardRequestTimeout}
+
+	s.goBackground("dashboard", func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("dashboard stopped: %v", err)
+		}
+	})
+	s.logger.Infof("serving the debug dashboard on http://%s", listener.Addr())
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, dashboardShutdownTimeout)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}, nil
+}
+
+// handleDashboardState reports the status command, the latency history and a snapshot of
+// the configured pins. A failed snapshot is reported next to the rest.
+func (s *esp32Board) handleDashboardState(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), dashboardRequestTimeout)
+	defer cancel()
+	status, err := s.doStatus(ctx, nil)
+	if err != nil {
+		writeDashboardError(w, http.StatusInternalServerError, err)
+		return
+	}
+	state := map[string]interface{}{
+		"name":      s.name.ShortName(),
+		"status":    status,
+		"latencies": s.conn.recentLatencies(),
+	}
+	outputs := map[string]bool{}
+	for pinNum, pin := range s.pins.pins {
+		if pin.Mode == pinModeOutput && !pin.ReadOnly {
+			outputs[s.pins.name(pinNum)] = true
+		}
+	}
+	state["outputs"] = outputs
+	if snapshot, err := s.doSnapshot(ctx, nil); err != nil {
+		state["pins_error"] = err.Error()
+	} else {
+		state["pins"] = snapshot["pins"]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		s.logger.Debugf("failed to write dashboard state: %v", err)
+	}
+}
+
+// handleDashboardSet sets a pin from {"pin": "26", "high": true}.
+func (s *esp32Board) handleDashboardSet(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Pin  string `json:"pin"`
+		High bool   `json:"high"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeDashboardError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), dashboardRequestTimeout)
+	defer cancel()
+	state := 0
+	if request.High {
+		state = 100
+	}
+	if err := s.writePin(ctx, request.Pin, state, callOptions{samples: 1}); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrInvalidPin) {
+			status = http.StatusBadRequest
+		}
+		writeDashboardError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeDashboardError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// dashboardHTML polls /api/state and renders it without any external assets, so it works
+// on isolated networks.
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>esp32 dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; text-align: left; }
+.ok { color: #1a7f37; } .bad { color: #cf222e; }
+svg { border: 1px solid #ddd; background: #fafafa; }
+</style>
+</head>
+<body>
+<h1 id="name">esp32</h1>
+<table id="status"></table>
+<h2>Latency</h2>
+<svg id="chart" width="600" height="150"></svg>
+<h2>Pins</h2>
+<p id="pins-error" class="bad"></p>
+<table id="pins"></table>
+<script>
+function cell(row, text, cls) {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (cls) td.className = cls;
+  return td;
+}
+
+function renderStatus(status) {
+  const table = document.getElementById("status");
+  table.innerHTML = "";
+  for (const [key, value] of Object.entries(status)) {
+    const row = table.insertRow();
+    cell(row, key);
+    const text = typeof value === "object" ? JSON.stringify(value) : String(value);
+    cell(row, text, key === "connected" ? (value ? "ok" : "bad") : "");
+  }
+}
+
+function renderChart(latencies) {
+  const svg = document.getElementById("chart");
+  const width = svg.width.baseVal.value, height = svg.height.baseVal.value;
+  const max = Math.max(10, ...latencies.map(l => l.latency_ms));
+  const x = i => latencies.length < 2 ? 0 : i * width / (latencies.length - 1);
+  const y = ms => height - ms * (height - 10) / max;
+  const points = latencies.map((l, i) => x(i) + "," + y(l.latency_ms)).join(" ");
+  const failures = latencies.map((l, i) => l.ok ? "" :
+    '<circle cx="' + x(i) + '" cy="' + y(l.latency_ms) + '" r="3" fill="#cf222e"/>').join("");
+  svg.innerHTML = '<polyline fill="none" stroke="#0969da" points="' + points + '"/>' + failures +
+    '<text x="4" y="12" font-size="11">' + max.toFixed(0) + ' ms</text>';
+}
+
+function renderPins(pins, outputs) {
+  const table = document.getElementById("pins");
+  table.innerHTML = "<tr><th>Pin</th><th>GPIO</th><th>Mode</th><th>Reading</th><th></th></tr>";
+  for (const name of Object.keys(pins || {}).sort()) {
+    const pin = pins[name];
+    const row = table.insertRow();
+    cell(row, name);
+    cell(row, pin.pin);
+    cell(row, pin.mode);
+    const reading = pin.high !== undefined ? (pin.high ? "high" : "low") :
+      pin.duty_cycle !== undefined ? (pin.duty_cycle * 100).toFixed(0) + "%" : pin.value;
+    cell(row, reading);
+    const action = row.insertCell();
+    if (outputs[name]) {
+      const button = document.createElement("button");
+      button.textContent = pin.high ? "Set low" : "Set high";
+      button.onclick = () => setPin(name, !pin.high);
+      action.appendChild(button);
+    }
+  }
+}
+
+async function setPin(name, high) {
+  const resp = await fetch("api/set", {method: "POST", body: JSON.stringify({pin: name, high: high})});
+  if (!resp.ok) alert((await resp.json()).error);
+  refresh();
+}
+
+async function refresh() {
+  try {
+    const state = await (await fetch("api/state")).json();
+    document.getElementById("name").textContent = state.name;
+    renderStatus(state.status || {});
+    renderChart(state.latencies || []);
+    renderPins(state.pins, state.outputs || {});
+    document.getElementById("pins-error").textContent = state.pins_error || "";
+  } catch (err) {
+    document.getElementById("pins-error").textContent = "dashboard unreachable: " + err;
+  }
+}
+
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
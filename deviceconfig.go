@@ -0,0 +1,94 @@
+package esp32wifi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"esp32wifi/esp32client"
+)
+
+// doExportConfig returns the setup stored on the device, in the form import_config takes.
+//
+//	{"command": "export_config"}
+func (s *esp32Board) doExportConfig(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	config, err := esp32client.ReadDeviceConfig(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+	value, err := toJSONValue(config)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"config": value}, nil
+}
+
+// doImportConfig replaces the setup stored on the device with an exported one, e.g. to
+// clone a commissioned board onto replacement hardware. The setup is checked against this
+// board's chip and pin restrictions first. Imported calibrations take effect on the board
+// at once.
+//
+//	{"command": "import_config", "config": {"pins": [{"pin_num": 26, "mode": "output", "safe_state": false}]}}
+func (s *esp32Board) doImportConfig(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	raw, ok := cmd["config"]
+	if !ok {
+		return nil, fmt.Errorf("missing required argument %q", "config")
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var config esp32client.DeviceConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("argument %q is not an exported config: %w", "config", err)
+	}
+	if err := s.checkDeviceConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := esp32client.WriteDeviceConfig(ctx, s.client, config); err != nil {
+		return nil, err
+	}
+	// The firmware drives the imported pins to their new setup.
+	s.writes.reset()
+	s.relays.forget()
+	calibrated := 0
+	s.calibrationMu.Lock()
+	for _, pin := range config.Pins {
+		if pin.Calibration != nil {
+			c := s.calibrationFor(pin.PinNum)
+			c.offset, c.scale, c.table = pin.Calibration.Offset, pin.Calibration.Scale, nil
+			calibrated++
+		}
+	}
+	s.calibrationMu.Unlock()
+	return map[string]interface{}{"pins": len(config.Pins), "calibrations": calibrated}, nil
+}
+
+// checkDeviceConfig applies the checks of the pins attribute to an imported setup, so a
+// config exported from another chip variant cannot drive a pin this one lacks.
+func (s *esp32Board) checkDeviceConfig(config esp32client.DeviceConfig) error {
+	check := BoardConfig{ChipVariant: s.pins.variantName}
+	for i, pin := range config.Pins {
+		pinPath := fmt.Sprintf("config.pins.%d", i)
+		if err := s.pins.checkAvailable(pin.PinNum); err != nil {
+			return fmt.Errorf("%s: %w", pinPath, err)
+		}
+		if pin.PWMFreqHz < 0 {
+			return fmt.Errorf("%s: 'pwm_freq_hz' must not be negative", pinPath)
+		}
+		if pin.PWMFreqHz > 0 && pin.Mode != pinModePWM {
+			return fmt.Errorf("%s: 'pwm_freq_hz' can only be set on pwm pins", pinPath)
+		}
+		pinCfg := PinConfig{Pin: pin.PinNum, Mode: pin.Mode, SafeState: pin.SafeState}
+		if pin.Calibration != nil {
+			scale := pin.Calibration.Scale
+			pinCfg.Calibration = &CalibrationConfig{Offset: pin.Calibration.Offset, Scale: &scale}
+		}
+		check.Pins = append(check.Pins, pinCfg)
+	}
+	return check.validate("config")
+}
@@ -0,0 +1,178 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultLogTailIntervalMs = 5000
+	minLogTailIntervalMs     = 500
+	logsTimeout              = 5 * time.Second
+)
+
+// deviceLogs copies the firmware's log into the module's, remembering how far it got so
+// each entry is logged once.
+type deviceLogs struct {
+	// fetchMu serializes fetches, so get_logs and the tail never log an entry twice.
+	fetchMu sync.Mutex
+	next    uint64
+
+	mu sync.Mutex
+	// stopTail stops the tail and waits for it, nil unless tailing.
+	stopTail func()
+}
+
+// resetCursor starts over at the first entry, after the device rebooted and its sequence
+// numbers restarted.
+func (l *deviceLogs) resetCursor() {
+	l.fetchMu.Lock()
+	defer l.fetchMu.Unlock()
+	l.next = 0
+}
+
+// fetchLogs reads the entries logged since the last fetch and writes them to the module's
+// log under a "device" sublogger.
+func (s *esp32Board) fetchLogs(ctx context.Context) (esp32client.Logs, error) {
+	s.deviceLogs.fetchMu.Lock()
+	defer s.deviceLogs.fetchMu.Unlock()
+	logs, err := esp32client.ReadLogs(ctx, s.client, s.deviceLogs.next)
+	if err != nil {
+		return esp32client.Logs{}, err
+	}
+	logger := s.logger.Sublogger("device")
+	if logs.Dropped > 0 {
+		logger.Warnf("%d device log entries were overwritten before they were read", logs.Dropped)
+	}
+	for _, entry := range logs.Entries {
+		logDeviceEntry(logger, entry)
+	}
+	s.deviceLogs.next = logs.NextSeq
+	return logs, nil
+}
+
+// logDeviceEntry writes entry at its ESP-IDF level, prefixed with the device's uptime.
+func logDeviceEntry(logger logging.Logger, entry esp32client.LogEntry) {
+	uptime := (time.Duration(entry.TimestampUs) * time.Microsecond).Round(time.Millisecond)
+	line := fmt.Sprintf("(%s) %s: %s", uptime, entry.Tag, entry.Message)
+	switch strings.ToUpper(entry.Level) {
+	case "E", "ERROR":
+		logger.Error(line)
+	case "W", "WARN", "WARNING":
+		logger.Warn(line)
+	case "I", "INFO":
+		logger.Info(line)
+	default:
+		logger.Debug(line)
+	}
+}
+
+// doGetLogs copies the firmware log entries that are new since the last call into the
+// module's log and returns them. With tail it instead starts or stops copying them every
+// interval_ms.
+//
+//	{"command": "get_logs"}
+//	{"command": "get_logs", "tail": true, "interval_ms": 2000}
+//	{"command": "get_logs", "tail": false}
+func (s *esp32Board) doGetLogs(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd["tail"]; ok {
+		tail, err := boolArg(cmd, "tail")
+		if err != nil {
+			return nil, err
+		}
+		intervalMs, err := optionalNumberArg(cmd, "interval_ms", defaultLogTailIntervalMs)
+		if err != nil {
+			return nil, err
+		}
+		if intervalMs < minLogTailIntervalMs {
+			return nil, fmt.Errorf("interval_ms must be at least %d, got %v", minLogTailIntervalMs, intervalMs)
+		}
+		if !tail {
+			s.stopLogTail()
+			return map[string]interface{}{"tailing": false}, nil
+		}
+		if err := s.startLogTail(time.Duration(intervalMs) * time.Millisecond); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"tailing": true}, nil
+	}
+
+	logs, err := s.fetchLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := toJSONValue(logs.Entries)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"entries": entries, "dropped": logs.Dropped}, nil
+}
+
+// startLogTail fetches the firmware's log every interval until stopLogTail or Close,
+// replacing a tail that is already running.
+func (s *esp32Board) startLogTail(interval time.Duration) error {
+	s.deviceLogs.mu.Lock()
+	defer s.deviceLogs.mu.Unlock()
+	if s.deviceLogs.stopTail != nil {
+		s.deviceLogs.stopTail()
+		s.deviceLogs.stopTail = nil
+	}
+
+	ctx, cancel := context.WithCancel(s.cancelCtx)
+	done := make(chan struct{})
+	if !s.goBackground("log_tail", func() {
+		defer close(done)
+		s.tailLogs(ctx, interval)
+	}) {
+		cancel()
+		return errBoardClosed
+	}
+	s.deviceLogs.stopTail = func() {
+		cancel()
+		<-done
+	}
+	return nil
+}
+
+// stopLogTail stops the tail, if one is running.
+func (s *esp32Board) stopLogTail() {
+	s.deviceLogs.mu.Lock()
+	defer s.deviceLogs.mu.Unlock()
+	if s.deviceLogs.stopTail != nil {
+		s.deviceLogs.stopTail()
+		s.deviceLogs.stopTail = nil
+	}
+}
+
+// tailLogs fetches the log every interval until ctx is done. It stops if the firmware
+// keeps no log.
+func (s *esp32Board) tailLogs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, logsTimeout)
+		_, err := s.fetchLogs(fetchCtx)
+		cancel()
+		switch {
+		case errors.Is(err, esp32client.ErrNotSupported):
+			s.logger.Warnf("firmware keeps no log to tail: %v", err)
+			return
+		case err != nil && ctx.Err() == nil:
+			s.logger.Debugf("failed to tail device log: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"go.viam.com/rdk/components/board"
+)
+
+// DifferentialAnalogConfig declares a virtual analog reading the difference between two
+// ADC inputs, e.g. the two outputs of a bridge sensor.
+type DifferentialAnalogConfig struct {
+	// Name is what AnalogByName looks the reader up by, e.g. "load_cell".
+	Name string `json:"name"`
+	// PinA and PinB are pin names or GPIO numbers. The reading is PinA minus PinB.
+	PinA string `json:"pin_a"`
+	PinB string `json:"pin_b"`
+	// Gain scales the difference, 1 by default, e.g. to read a small bridge output in
+	// tenths of a millivolt.
+	Gain float64 `json:"gain,omitempty"`
+}
+
+func (cfg *DifferentialAnalogConfig) validate(path string, pins *pinTable) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if _, err := strconv.Atoi(cfg.Name); err == nil {
+		return fmt.Errorf("%s: 'name' %q must not be a number", path, cfg.Name)
+	}
+	variant, err := lookupChipVariant(pins.variantName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var pinNums [2]int
+	for i, field := range []struct{ key, pin string }{{"pin_a", cfg.PinA}, {"pin_b", cfg.PinB}} {
+		if field.pin == "" {
+			return fmt.Errorf("%s: missing required field '%s'", path, field.key)
+		}
+		pinNum, err := pins.lookup(field.pin)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", path, field.key, err)
+		}
+		if err := pins.checkRead(pinNum); err != nil {
+			return fmt.Errorf("%s.%s: %w", path, field.key, err)
+		}
+		if pin, ok := pins.pins[pinNum]; ok && pin.Mode != pinModeAnalog {
+			return fmt.Errorf("%s.%s: GPIO%d is configured as %s, not analog", path, field.key, pinNum, pin.Mode)
+		}
+		if variant.adc[pinNum] == 0 {
+			return fmt.Errorf("%s.%s: GPIO%d has no ADC channel on the %s", path, field.key, pinNum, pins.variantName)
+		}
+		pinNums[i] = pinNum
+	}
+	if pinNums[0] == pinNums[1] {
+		return fmt.Errorf("%s: 'pin_a' and 'pin_b' are both GPIO%d", path, pinNums[0])
+	}
+	if math.IsNaN(cfg.Gain) || math.IsInf(cfg.Gain, 0) {
+		return fmt.Errorf("%s: 'gain' must be a finite number", path)
+	}
+	return nil
+}
+
+// differentialAnalog is a configured differential analog.
+type differentialAnalog struct {
+	pinA, pinB int
+	gain       float64
+}
+
+// newDifferentialAnalog resolves cfg, which must be valid.
+func newDifferentialAnalog(cfg *DifferentialAnalogConfig, pins *pinTable) differentialAnalog {
+	pinA, _ := pins.lookup(cfg.PinA)
+	pinB, _ := pins.lookup(cfg.PinB)
+	gain := cfg.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	return differentialAnalog{pinA: pinA, pinB: pinB, gain: gain}
+}
+
+type differentialAnalogClient struct {
+	*esp32Board
+	analogName string
+	diff       differentialAnalog
+}
+
+// Read returns the gain times the difference of the calibrated readings of both pins,
+// which are read in one request. Pass extra {"raw": true} for the difference of the
+// uncalibrated readings without gain, or {"samples": n} to average n readings. Readings
+// are not filtered, since a filter smooths one pin's readings and not their difference.
+func (s *differentialAnalogClient) Read(ctx context.Context, extra map[string]interface{}) (board.AnalogValue, error) {
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return board.AnalogValue{}, err
+	}
+	rawA, okA := s.cachedRead(s.diff.pinA, opts)
+	rawB, okB := s.cachedRead(s.diff.pinB, opts)
+	if !okA || !okB {
+		var sumA, sumB float64
+		for i := 0; i < opts.samples; i++ {
+			var a, b float64
+			if err := s.call(ctx, opts, func(ctx context.Context) error {
+				reads, err := s.client.ReadPins(ctx, []int{s.diff.pinA, s.diff.pinB})
+				if err != nil {
+					return err
+				}
+				if len(reads) != 2 {
+					return fmt.Errorf("firmware returned %d readings for 2 pins", len(reads))
+				}
+				a, b = s.states.counts(reads[0].State), s.states.counts(reads[1].State)
+				return nil
+			}); err != nil {
+				return board.AnalogValue{}, err
+			}
+			sumA += a
+			sumB += b
+		}
+		rawA, rawB = sumA/float64(opts.samples), sumB/float64(opts.samples)
+	}
+
+	value := rawA - rawB
+	if !opts.raw {
+		value = s.diff.gain * (s.calibrated(s.diff.pinA, rawA) - s.calibrated(s.diff.pinB, rawB))
+	}
+	return board.AnalogValue{Value: int(math.Round(value))}, nil
+}
+
+func (s *differentialAnalogClient) Write(ctx context.Context, value int, extra map[string]interface{}) error {
+	return fmt.Errorf("cannot write differential analog %q", s.analogName)
+}
@@ -0,0 +1,244 @@
+package esp32wifi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"esp32wifi/esp32client"
+)
+
+// DoCommand runs the command named by cmd["command"], looking it up in commands:
+//
+//	{"command": "help"}
+//	{"command": "transaction", "writes": [{"pin": "26", "high": true}, {"pin": "27", "duty_cycle": 0.5}]}
+//	{"command": "schedule", "pin": "26", "high": true, "duration_ms": 500}
+//	{"command": "cancel_schedule", "id": 3}
+//	{"command": "list_schedules"}
+//	{"command": "wifi_scan"}
+//	{"command": "wifi_connect", "ssid": "shop-floor", "password": "..."}
+//	{"command": "wifi_status"}
+//	{"command": "bootstrap", "ssid": "shop-floor", "password": "..."}
+//	{"command": "nvs_get", "key": "cal_offset"}
+//	{"command": "nvs_set", "key": "cal_offset", "value": 12.5}
+//	{"command": "nvs_erase", "key": "cal_offset"}
+//	{"command": "calibrate", "pin": "34", "reference": 1650}
+//	{"command": "snapshot"}
+//	{"command": "run_macro", "name": "startup", "wait": true}
+//	{"command": "macro_status", "id": 4}
+//	{"command": "ramp_pwm", "pin": "27", "from": 0, "to": 0.8, "duration_ms": 2000}
+//	{"command": "describe"}
+//	{"command": "http", "method": "POST", "path": "/custom", "body": {"speed": 3}}
+//	{"command": "ble_write", "data": {"custom": true}}
+//	{"command": "i2c", "address": 104, "write": [117], "read": 1}
+//	{"command": "self_test"}
+//	{"command": "status"}
+//	{"command": "reset_peripherals", "peripherals": ["ledc", "pcnt", "rmt"]}
+//	{"command": "write_mask", "mask": "0xff000", "values": "0x5a000"}
+//	{"command": "set_pins", "pins": {"relay1": true, "relay2": false}}
+//	{"command": "set_group_state", "group": "traffic_light", "state": "stop"}
+//	{"command": "set_relay", "relay": "pump", "on": true}
+//	{"command": "get_relay", "relay": "pump"}
+//	{"command": "export_config"}
+//	{"command": "import_config", "config": {"pins": [{"pin_num": 26, "mode": "output"}]}}
+//	{"command": "get_logs", "tail": true}
+//	{"command": "coredump_info"}
+//	{"command": "coredump_download", "path": "/tmp/esp32-core.elf"}
+//	{"command": "coredump_erase"}
+//	{"command": "hard_reset"}
+//	{"command": "inspect_pin", "pin": "26", "at": "2026-10-14T14:02:00Z"}
+//	{"command": "set_servo_us", "pin": "18", "pulse_width_us": 1500}
+//	{"command": "release_pin", "pin": "21"}
+//	{"command": "set_open_drain", "pin": "21", "enabled": true}
+//	{"command": "read_analog", "analog": "pressure"}
+//	{"command": "subscribe_analog", "pin": "battery", "rate_hz": 20}
+//	{"command": "unsubscribe_analog", "pin": "battery"}
+//	{"command": "config_schema", "model": "esp32-wifi"}
+func (s *esp32Board) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, err := stringArg(cmd, "command")
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "help" {
+		return doHelp(cmd)
+	}
+	spec, ok := commands[name]
+	if !ok {
+		return nil, unknownCommand(name)
+	}
+	return spec.run(s, ctx, cmd)
+}
+
+// unknownCommand is the error for a command name that is not in commands.
+func unknownCommand(name string) error {
+	return fmt.Errorf("unknown command %q, \"help\" lists the commands", name)
+}
+
+// doTransaction applies a set of pin writes atomically, e.g. both inputs of an H-bridge,
+// so the outputs never pass through an intermediate state.
+func (s *esp32Board) doTransaction(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	writes, err := s.pinWritesArg(cmd, "writes")
+	if err != nil {
+		return nil, err
+	}
+	s.dropNoAckWrites(writes)
+	if err := s.client.Transaction(ctx, writes); err != nil {
+		for _, w := range writes {
+			s.writes.forget(w.PinNum)
+		}
+		return nil, err
+	}
+	s.writes.record(writes...)
+	return map[string]interface{}{"written": len(writes)}, nil
+}
+
+func stringArg(cmd map[string]interface{}, key string) (string, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return "", fmt.Errorf("missing required argument %q", key)
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string, got %T", key, raw)
+	}
+	return value, nil
+}
+
+func numberArg(cmd map[string]interface{}, key string) (float64, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return 0, fmt.Errorf("missing required argument %q", key)
+	}
+	value, ok := raw.(float64)
+	if !ok {
+		return 0, fmt.Errorf("argument %q must be a number, got %T", key, raw)
+	}
+	return value, nil
+}
+
+// optionalNumberArg returns the number at key, or def if it is not set.
+func optionalNumberArg(cmd map[string]interface{}, key string, def float64) (float64, error) {
+	if _, ok := cmd[key]; !ok {
+		return def, nil
+	}
+	return numberArg(cmd, key)
+}
+
+func boolArg(cmd map[string]interface{}, key string) (bool, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return false, fmt.Errorf("missing required argument %q", key)
+	}
+	value, ok := raw.(bool)
+	if !ok {
+		return false, fmt.Errorf("argument %q must be a boolean, got %T", key, raw)
+	}
+	return value, nil
+}
+
+// pinArg accepts a pin either as a name ("relay1", "26") or a number (26).
+func (s *esp32Board) pinArg(cmd map[string]interface{}, key string) (int, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return 0, fmt.Errorf("missing required argument %q", key)
+	}
+	switch value := raw.(type) {
+	case string:
+		return s.pins.lookup(value)
+	case float64:
+		return int(value), s.pins.checkAvailable(int(value))
+	default:
+		return 0, fmt.Errorf("argument %q must be a pin name or number, got %T", key, raw)
+	}
+}
+
+func listArg(cmd map[string]interface{}, key string) ([]map[string]interface{}, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return nil, fmt.Errorf("missing required argument %q", key)
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument %q must be a list, got %T", key, raw)
+	}
+	list := make([]map[string]interface{}, 0, len(items))
+	for i, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s[%d] must be an object, got %T", key, i, item)
+		}
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+// pinWriteArg parses {"pin": ..., "high": bool} or {"pin": ..., "duty_cycle": 0-1}.
+func (s *esp32Board) pinWriteArg(entry map[string]interface{}) (esp32client.PinWrite, error) {
+	pinNum, err := s.pinArg(entry, "pin")
+	if err != nil {
+		return esp32client.PinWrite{}, err
+	}
+	if err := s.pins.checkWrite(pinNum); err != nil {
+		return esp32client.PinWrite{}, err
+	}
+	if _, ok := entry["high"]; ok {
+		high, err := boolArg(entry, "high")
+		if err != nil {
+			return esp32client.PinWrite{}, err
+		}
+		state := 0
+		if high {
+			state = 100
+		}
+		return esp32client.PinWrite{PinNum: pinNum, State: state}, nil
+	}
+	duty, err := numberArg(entry, "duty_cycle")
+	if err != nil {
+		return esp32client.PinWrite{}, fmt.Errorf("pin %d: needs either \"high\" or \"duty_cycle\": %w", pinNum, err)
+	}
+	if duty < 0 || duty > 1 {
+		return esp32client.PinWrite{}, fmt.Errorf("pin %d: duty_cycle must be between 0 and 1, got %v", pinNum, duty)
+	}
+	if err := s.pins.checkPWM(pinNum); err != nil {
+		return esp32client.PinWrite{}, err
+	}
+	return esp32client.PinWrite{PinNum: pinNum, State: int(duty * 100)}, nil
+}
+
+func (s *esp32Board) pinWritesArg(cmd map[string]interface{}, key string) ([]esp32client.PinWrite, error) {
+	entries, err := listArg(cmd, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("argument %q must not be empty", key)
+	}
+	writes := make([]esp32client.PinWrite, 0, len(entries))
+	seen := map[int]bool{}
+	for i, entry := range entries {
+		write, err := s.pinWriteArg(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+		if seen[write.PinNum] {
+			return nil, fmt.Errorf("%s[%d]: pin %d is written more than once", key, i, write.PinNum)
+		}
+		seen[write.PinNum] = true
+		writes = append(writes, write)
+	}
+	return writes, nil
+}
+
+// toJSONValue converts v into the maps, slices and scalars DoCommand results are made of.
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,48 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+// Environment variables that override the config of every board in the module process,
+// so one fragment can be pushed to many machines that differ only in these.
+const (
+	// envURL replaces 'url', 'host' and 'port' of esp32-wifi and esp32-hybrid boards.
+	envURL = "ESP32WIFI_URL"
+	// envTimeoutMs replaces 'timeout_ms' of esp32-wifi and esp32-hybrid boards.
+	envTimeoutMs = "ESP32WIFI_TIMEOUT_MS"
+	// envLogLevel sets the log level of every board, e.g. debug or warn.
+	envLogLevel = "ESP32WIFI_LOG_LEVEL"
+)
+
+// envTimeout returns the timeout set by envTimeoutMs, if any.
+func envTimeout() (time.Duration, bool, error) {
+	raw := os.Getenv(envTimeoutMs)
+	if raw == "" {
+		return 0, false, nil
+	}
+	ms, err := strconv.Atoi(raw)
+	if err != nil || ms < 0 {
+		return 0, false, fmt.Errorf("invalid %s %q: must be a non-negative number of milliseconds", envTimeoutMs, raw)
+	}
+	return time.Duration(ms) * time.Millisecond, true, nil
+}
+
+// applyEnvLogLevel sets logger's level from envLogLevel, if set.
+func applyEnvLogLevel(logger logging.Logger) error {
+	raw := os.Getenv(envLogLevel)
+	if raw == "" {
+		return nil
+	}
+	level, err := logging.LevelFromString(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", envLogLevel, err)
+	}
+	logger.SetLevel(level)
+	return nil
+}
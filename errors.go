@@ -0,0 +1,20 @@
+package esp32wifi
+
+import "esp32wifi/esp32client"
+
+// The errors boards return, for use with errors.Is. They are the esp32client errors, so
+// either package's can be checked.
+var (
+	ErrDeviceUnreachable = esp32client.ErrDeviceUnreachable
+	ErrTimeout           = esp32client.ErrTimeout
+	ErrInvalidPin        = esp32client.ErrInvalidPin
+	ErrNotSupported      = esp32client.ErrNotSupported
+	ErrFirmwareTooOld    = esp32client.ErrFirmwareTooOld
+	ErrNotFound          = esp32client.ErrNotFound
+	ErrBoardBusy         = esp32client.ErrBoardBusy
+	// ErrBluetoothUnavailable is returned by BLE boards whose host cannot use bluetooth.
+	ErrBluetoothUnavailable = esp32client.ErrBluetoothUnavailable
+	// ErrWriteNotVerified is returned when a verify_writes pin does not read back the state
+	// written.
+	ErrWriteNotVerified = esp32client.ErrWriteNotVerified
+)
@@ -0,0 +1,167 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+
+	"esp32wifi/esp32client"
+)
+
+var (
+	Esp32Beacon = resource.NewModel("mattmacf", "esp32-wifi", "esp32-beacon")
+)
+
+const (
+	defaultBeaconStaleAfterMs = 60000
+	// beaconRescanDelay is how long the sensor waits before scanning again after a scan
+	// failed, e.g. because the adapter was powered off.
+	beaconRescanDelay = 5 * time.Second
+)
+
+func init() {
+	resource.RegisterComponent(sensor.API, Esp32Beacon,
+		resource.Registration[sensor.Sensor, *BeaconConfig]{
+			Constructor: newEsp32Beacon,
+		},
+	)
+}
+
+// BeaconConfig names the device whose advertisements the sensor reads.
+type BeaconConfig struct {
+	BTServerName string `json:"bt_server_name"`
+	// StaleAfterMs fails readings once no advertisement was seen for this long, 60000 by
+	// default.
+	StaleAfterMs int `json:"stale_after_ms,omitempty"`
+}
+
+// Validate requires the device name.
+func (cfg *BeaconConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.BTServerName == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'bt_server_name'", path)
+	}
+	if cfg.StaleAfterMs < 0 {
+		return nil, nil, fmt.Errorf("%s: 'stale_after_ms' must not be negative", path)
+	}
+	return nil, nil, nil
+}
+
+type esp32Beacon struct {
+	resource.Named
+	resource.AlwaysRebuild
+
+	staleAfter time.Duration
+	workers    *workerGroup
+
+	mu      sync.Mutex
+	last    esp32client.Beacon
+	scanErr error
+}
+
+func newEsp32Beacon(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (sensor.Sensor, error) {
+	conf, err := resource.NativeConfig[*BeaconConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	return NewEsp32Beacon(ctx, deps, rawConf.ResourceName(), conf, logger)
+}
+
+// NewEsp32Beacon returns a sensor reporting the telemetry conf.BTServerName advertises,
+// without connecting to it. opts are passed to esp32client.WatchBeacons, e.g.
+// esp32client.WithAdapter to use another bluetooth adapter.
+func NewEsp32Beacon(ctx context.Context, deps resource.Dependencies, name resource.Name, conf *BeaconConfig, logger logging.Logger, opts ...esp32client.Option) (sensor.Sensor, error) {
+	if err := applyEnvLogLevel(logger); err != nil {
+		return nil, err
+	}
+	staleAfterMs := conf.StaleAfterMs
+	if staleAfterMs == 0 {
+		staleAfterMs = defaultBeaconStaleAfterMs
+	}
+	s := &esp32Beacon{
+		Named:      name.AsNamed(),
+		staleAfter: time.Duration(staleAfterMs) * time.Millisecond,
+		workers:    newWorkerGroup(),
+	}
+	opts = append([]esp32client.Option{esp32client.WithLogger(logger)}, opts...)
+	s.workers.start("beacons", func() { s.watch(conf.BTServerName, logger, opts) })
+	return s, nil
+}
+
+// watch scans for beacons until the sensor is closed, scanning again after a failure.
+func (s *esp32Beacon) watch(serverName string, logger logging.Logger, opts []esp32client.Option) {
+	ctx := s.workers.ctx
+	for {
+		err := esp32client.WatchBeacons(ctx, serverName, func(beacon esp32client.Beacon) {
+			s.mu.Lock()
+			s.last, s.scanErr = beacon, nil
+			s.mu.Unlock()
+		}, opts...)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Debugf("failed to scan for beacons from %q: %v", serverName, err)
+		s.mu.Lock()
+		s.scanErr = err
+		s.mu.Unlock()
+		// Builds without bluetooth will never scan.
+		if errors.Is(err, esp32client.ErrNotSupported) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(beaconRescanDelay):
+		}
+	}
+}
+
+// Readings returns the last advertised telemetry: uptime_s, outputs (the GPIOs last
+// driven high), rssi, age_s, and battery_percent if the firmware measures it.
+func (s *esp32Beacon) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	s.mu.Lock()
+	last, scanErr := s.last, s.scanErr
+	s.mu.Unlock()
+	if last.Seen.IsZero() {
+		if scanErr != nil {
+			return nil, fmt.Errorf("no beacon seen: %w", scanErr)
+		}
+		return nil, errors.New("no beacon seen yet")
+	}
+	age := time.Since(last.Seen)
+	if age > s.staleAfter {
+		return nil, fmt.Errorf("last beacon was seen %s ago", age.Round(time.Second))
+	}
+
+	outputs := []interface{}{}
+	for pinNum := 0; pinNum < esp32client.GPIOBankSize; pinNum++ {
+		if last.Outputs&(1<<pinNum) != 0 {
+			outputs = append(outputs, pinNum)
+		}
+	}
+	readings := map[string]interface{}{
+		"uptime_s": last.Uptime.Seconds(),
+		"outputs":  outputs,
+		"rssi":     int(last.RSSI),
+		"age_s":    age.Seconds(),
+	}
+	if last.BatteryPercent >= 0 {
+		readings["battery_percent"] = last.BatteryPercent
+	}
+	return readings, nil
+}
+
+func (s *esp32Beacon) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+// Close stops scanning.
+func (s *esp32Beacon) Close(ctx context.Context) error {
+	s.workers.stop()
+	return nil
+}
@@ -2,24 +2,17 @@ package esp32wifi
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"strconv"
-	"strings"
-	"time"
 
-	pb "go.viam.com/api/component/board/v1"
 	board "go.viam.com/rdk/components/board"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
-	"tinygo.org/x/bluetooth"
+
+	"esp32wifi/esp32client"
 )
 
 var (
-	Esp32Ble         = resource.NewModel("mattmacf", "esp32-wifi", "esp32-ble")
-	errUnimplemented = errors.New("unimplemented")
-	adapter          = bluetooth.DefaultAdapter
+	Esp32Ble = resource.NewModel("mattmacf", "esp32-wifi", "esp32-ble")
 )
 
 func init() {
@@ -31,7 +24,20 @@ func init() {
 }
 
 type BleConfig struct {
+	BoardConfig `json:",squash"`
+
 	BTServerName string `json:"bt_server_name"`
+	// Security is "none" (default), "bond" or "passkey".
+	Security string `json:"security,omitempty"`
+	// Passkey is the static six digit passkey the firmware expects when Security is "passkey".
+	Passkey *uint32 `json:"passkey,omitempty"`
+	// KeepAliveMs is how long the connection may idle before the board pings the device,
+	// 10000 by default. 0 disables the keep-alive.
+	KeepAliveMs *int `json:"keep_alive_ms,omitempty"`
+	// WriteMode is "fast" to write every request without response, "reliable" to write
+	// every request with response, or empty to write with response only to the pins with
+	// a safe state and the relays.
+	WriteMode string `json:"write_mode,omitempty"`
 }
 
 // Validate ensures all parts of the config are valid and important fields exist.
@@ -48,266 +54,136 @@ func (cfg *BleConfig) Validate(path string) ([]string, []string, error) {
 	if cfg.BTServerName == "" {
 		return nil, nil, fmt.Errorf("%s: missing required field 'bt_server_name'", path)
 	}
-	return nil, nil, nil
-}
-
-type esp32BleEsp32Ble struct {
-	resource.AlwaysRebuild
-
-	name resource.Name
-
-	logger       logging.Logger
-	cfg          *BleConfig
-	btServerName string
-	device       *bluetooth.Device
-
-	cancelCtx  context.Context
-	cancelFunc func()
-}
-
-func newEsp32BleEsp32Ble(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (board.Board, error) {
-	conf, err := resource.NativeConfig[*BleConfig](rawConf)
-	if err != nil {
-		return nil, err
+	if err := validateBLESecurity(cfg.Security, cfg.Passkey); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
 	}
-
-	return NewEsp32Ble(ctx, deps, rawConf.ResourceName(), conf, logger)
-
-}
-
-func NewEsp32Ble(ctx context.Context, deps resource.Dependencies, name resource.Name, conf *BleConfig, logger logging.Logger) (board.Board, error) {
-
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
-
-	err := adapter.Enable()
-	if err != nil {
-		logger.Errorf("Failed to enable Bluetooth adapter: %v", err)
-		cancelFunc()
-		return nil, err
+	if err := validateWriteMode(cfg.WriteMode); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
 	}
-
-	deviceFound := make(chan bluetooth.ScanResult, 1)
-	timeout := time.After(10 * time.Second)
-
-	// Start scanning
-	go func() error {
-		err := adapter.Scan(func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
-			deviceName := result.LocalName()
-
-			// Print all discovered devices for visibility
-			if deviceName != "" {
-				logger.Infof("Found: %s (Address: %s, RSSI: %d dBm)",
-					deviceName, result.Address.String(), result.RSSI)
-			}
-
-			// Check if this is the device we're looking for (case-insensitive)
-			if strings.EqualFold(deviceName, conf.BTServerName) {
-				select {
-				case deviceFound <- result:
-					adapter.StopScan()
-				default:
-				}
-			}
-		})
-
-		if err != nil {
-			logger.Errorf("Scan error: %v", err)
-			cancelFunc()
-			return err
-		}
-		return nil
-	}()
-
-	var device *bluetooth.Device
-
-	// Wait for device to be found or timeout
-	select {
-	case result := <-deviceFound:
-		logger.Infof("Found target device: %s", result.LocalName())
-		logger.Infof("Address: %s", result.Address.String())
-		logger.Infof("Signal strength: %d dBm", result.RSSI)
-
-		// Connect to the device
-		logger.Infof("Connecting...")
-
-		dev, err := adapter.Connect(result.Address, bluetooth.ConnectionParams{})
-		if err != nil {
-			logger.Errorf("Failed to connect: %v", err)
-			cancelFunc()
-			return nil, err
-		}
-		device = &dev
-	case <-timeout:
-		logger.Errorf("Timeout waiting for device")
-		cancelFunc()
-		return nil, errors.New("timeout waiting for device")
+	if err := validateKeepAlive(cfg.KeepAliveMs); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
 	}
-
-	s := &esp32BleEsp32Ble{
-		name:         name,
-		logger:       logger,
-		cfg:          conf,
-		btServerName: conf.BTServerName,
-		device:       device,
-		cancelCtx:    cancelCtx,
-		cancelFunc:   cancelFunc,
+	if cfg.KeepAliveMs != nil && *cfg.KeepAliveMs != 0 && cfg.BatteryMode != nil {
+		return nil, nil, fmt.Errorf("%s: 'keep_alive_ms' cannot be used with 'battery_mode', whose wake windows keep the connection alive", path)
 	}
-	//TODO: disconnect device when closing module
-	return s, nil
-}
-
-func (s *esp32BleEsp32Ble) Name() resource.Name {
-	return s.name
-}
-
-// AnalogByName returns an analog pin by name.
-func (s *esp32BleEsp32Ble) AnalogByName(name string) (board.Analog, error) {
-	var analogRetVal board.Analog
-
-	return analogRetVal, fmt.Errorf("not implemented")
+	if err := cfg.BoardConfig.validate(path); err != nil {
+		return nil, nil, err
+	}
+	return cfg.BoardConfig.dependencies(), nil, nil
 }
 
-// DigitalInterruptByName returns a digital interrupt by name.
-func (s *esp32BleEsp32Ble) DigitalInterruptByName(name string) (board.DigitalInterrupt, error) {
-	var digitalInterruptRetVal board.DigitalInterrupt
-
-	return digitalInterruptRetVal, fmt.Errorf("not implemented")
+// clientOptions returns the esp32client options for the configured security, write mode
+// and protocol version.
+func (cfg *BleConfig) clientOptions() []esp32client.Option {
+	opts := append(bleClientOptions(cfg.Security, cfg.Passkey), writeModeOptions(cfg.WriteMode)...)
+	return append(opts, cfg.protocolOptions()...)
 }
 
-// GPIOPinByName returns a GPIOPin by name.
-func (s *esp32BleEsp32Ble) GPIOPinByName(name string) (board.GPIOPin, error) {
-	var gPIOPinRetVal board.GPIOPin
-	gPIOPinRetVal = &bleGPIOPinClient{
-		esp32BleEsp32Ble: s,
-		boardName:        s.name.ShortName(),
-		pinName:          name,
+// validateBLESecurity checks a security level and the passkey it may need.
+func validateBLESecurity(security string, passkey *uint32) error {
+	switch esp32client.SecurityLevel(security) {
+	case "", esp32client.SecurityNone, esp32client.SecurityBond:
+		if passkey != nil {
+			return fmt.Errorf("'passkey' requires 'security' to be %q", esp32client.SecurityPasskey)
+		}
+	case esp32client.SecurityPasskey:
+		if passkey == nil {
+			return fmt.Errorf("missing required field 'passkey' for security %q", esp32client.SecurityPasskey)
+		}
+		if *passkey > esp32client.MaxPasskey {
+			return fmt.Errorf("'passkey' must be at most six digits, got %d", *passkey)
+		}
+	default:
+		return fmt.Errorf("invalid 'security' %q, must be one of %q, %q or %q",
+			security, esp32client.SecurityNone, esp32client.SecurityBond, esp32client.SecurityPasskey)
 	}
-
-	return gPIOPinRetVal, nil
+	return nil
 }
 
-// SetPowerMode sets the board to the given power mode. If
-// provided, the board will exit the given power mode after
-// the specified duration.
-func (s *esp32BleEsp32Ble) SetPowerMode(ctx context.Context, mode pb.PowerMode, duration *time.Duration, extra map[string]interface{}) error {
-	return fmt.Errorf("not implemented")
+// bleClientOptions returns the esp32client options for a security level.
+func bleClientOptions(security string, passkey *uint32) []esp32client.Option {
+	if security == "" {
+		return nil
+	}
+	var key uint32
+	if passkey != nil {
+		key = *passkey
+	}
+	return []esp32client.Option{esp32client.WithSecurity(esp32client.SecurityLevel(security), key)}
 }
 
-func (s *esp32BleEsp32Ble) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
+// Write modes of the write_mode attribute; the empty mode writes fast except to the pins
+// with a safe state and the relays.
+const (
+	writeModeFast     = "fast"
+	writeModeReliable = "reliable"
+)
 
-type bleAnalogClient struct {
-	*esp32BleEsp32Ble
-	boardName  string
-	analogName string
+// validateWriteMode checks a write_mode attribute.
+func validateWriteMode(mode string) error {
+	switch mode {
+	case "", writeModeFast, writeModeReliable:
+		return nil
+	}
+	return fmt.Errorf("invalid 'write_mode' %q, must be %q or %q", mode, writeModeFast, writeModeReliable)
 }
 
-func (s *bleAnalogClient) Read(ctx context.Context, extra map[string]interface{}) (board.AnalogValue, error) {
-	var analogValueRetVal board.AnalogValue
-
-	return analogValueRetVal, fmt.Errorf("not implemented")
+// writeModeOptions returns the esp32client options for a write mode.
+func writeModeOptions(mode string) []esp32client.Option {
+	if mode != writeModeReliable {
+		return nil
+	}
+	return []esp32client.Option{esp32client.WithWriteMode(esp32client.WriteReliable)}
 }
 
-func (s *bleAnalogClient) Write(ctx context.Context, value int, extra map[string]interface{}) error {
-	return fmt.Errorf("not implemented")
-}
+type esp32BleEsp32Ble struct {
+	*esp32Board
 
-type bleDigitalInterruptClient struct {
-	*esp32BleEsp32Ble
-	boardName            string
-	digitalInterruptName string
+	cfg          *BleConfig
+	btServerName string
 }
 
-func (s *bleDigitalInterruptClient) Value(ctx context.Context, extra map[string]interface{}) (int64, error) {
-	return 0, fmt.Errorf("not implemented")
-}
+func newEsp32BleEsp32Ble(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (board.Board, error) {
+	conf, err := resource.NativeConfig[*BleConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
 
-// StreamTicks starts a stream of digital interrupt ticks.
-func (s *esp32BleEsp32Ble) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt, ch chan board.Tick, extra map[string]interface{}) error {
-	return fmt.Errorf("not implemented")
-}
+	return NewEsp32Ble(ctx, deps, rawConf.ResourceName(), conf, logger)
 
-type bleGPIOPinClient struct {
-	*esp32BleEsp32Ble
-	boardName string
-	pinName   string
 }
 
-func (s *bleGPIOPinClient) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
-	var targetChar bluetooth.DeviceCharacteristic
-	var found bool
-
-	// TODO: make so we dont neecd to do this every time
-	services, err := s.device.DiscoverServices(nil)
-	if err != nil {
-		s.logger.Errorf("Failed to discover services: %v", err)
-		return err
+// NewEsp32Ble returns a board connected to the device advertising conf.BTServerName.
+// opts are applied after the options derived from conf, e.g. esp32client.WithAdapter to
+// use another bluetooth adapter. ESP32WIFI_LOG_LEVEL, if set, overrides the log level.
+func NewEsp32Ble(ctx context.Context, deps resource.Dependencies, name resource.Name, conf *BleConfig, logger logging.Logger, opts ...esp32client.Option) (board.Board, error) {
+	if err := applyEnvLogLevel(logger); err != nil {
+		return nil, err
 	}
-
-	targetUUID, err := bluetooth.ParseUUID("c79b2ca7-f39d-4060-8168-816fa26737b7")
+	client, err := devices.acquire(bleDeviceKey(conf.BTServerName), func() (esp32client.Client, error) {
+		clientOpts := append([]esp32client.Option{esp32client.WithLogger(logger)}, conf.clientOptions()...)
+		return esp32client.DialBLE(ctx, conf.BTServerName, append(clientOpts, opts...)...)
+	})
 	if err != nil {
-		s.logger.Errorf("Failed to parse UUID: %v", err)
-		return err
+		return nil, err
 	}
-	for _, service := range services {
-		chars, err := service.DiscoverCharacteristics([]bluetooth.UUID{targetUUID})
-		if err != nil {
-			continue
-		}
 
-		if len(chars) > 0 {
-			targetChar = chars[0]
-			found = true
-			break
-		}
-	}
-	if !found {
-		s.logger.Errorf("Failed to find characteristic")
-		return errors.New("failed to find characteristic")
+	b, err := newEsp32Board(ctx, deps, name, &conf.BoardConfig, client, logger)
+	if err != nil {
+		client.Close()
+		return nil, err
 	}
 
-	state := 0
-	if high {
-		state = 100
+	b.bleDevice = conf.BTServerName
+	b.writeMode = conf.WriteMode
+	s := &esp32BleEsp32Ble{
+		esp32Board:   b,
+		cfg:          conf,
+		btServerName: conf.BTServerName,
 	}
-	pinNum, err := strconv.Atoi(s.pinName)
-	body := map[string]interface{}{
-		"pin_writes": []map[string]interface{}{
-			{
-				"pin_num": pinNum,
-				"state":   state,
-			},
-		},
+	// In battery mode the wake windows keep the connection alive.
+	if interval := keepAliveInterval(conf.KeepAliveMs); interval > 0 && conf.BatteryMode == nil {
+		b.goBackground("keep_alive", func() { b.keepAlive(interval) })
 	}
-	body_string, err := json.Marshal(body)
-
-	writeCharacteristic(targetChar, body_string)
-	return nil
-}
-
-func (s *bleGPIOPinClient) Get(ctx context.Context, extra map[string]interface{}) (bool, error) {
-	return false, fmt.Errorf("not implemented")
-}
-
-func (s *bleGPIOPinClient) PWM(ctx context.Context, extra map[string]interface{}) (float64, error) {
-	return 0, fmt.Errorf("not implemented")
-}
-
-func (s *bleGPIOPinClient) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[string]interface{}) error {
-	return fmt.Errorf("not implemented")
-}
-
-func (s *bleGPIOPinClient) PWMFreq(ctx context.Context, extra map[string]interface{}) (uint, error) {
-	return 0, fmt.Errorf("not implemented")
-}
-
-func (s *bleGPIOPinClient) SetPWMFreq(ctx context.Context, freqHz uint, extra map[string]interface{}) error {
-	return fmt.Errorf("not implemented")
-}
-
-func (s *esp32BleEsp32Ble) Close(context.Context) error {
-	// Put close code here
-	s.cancelFunc()
-	return nil
+	return s, nil
 }
@@ -0,0 +1,326 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/input"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var (
+	Esp32Button = resource.NewModel("mattmacf", "esp32-wifi", "esp32-button")
+)
+
+// ButtonDoublePress is the event an esp32-button sends when a button is pressed again
+// within double_press_ms of being released.
+const ButtonDoublePress input.EventType = "ButtonDoublePress"
+
+const (
+	defaultButtonDebounceMs    = 20
+	defaultButtonLongPressMs   = 800
+	defaultButtonDoublePressMs = 300
+	// buttonRestreamDelay is how long a button waits to stream its interrupt's ticks again
+	// after the board refused.
+	buttonRestreamDelay = 5 * time.Second
+)
+
+func init() {
+	resource.RegisterComponent(input.API, Esp32Button,
+		resource.Registration[input.Controller, *ButtonControllerConfig]{
+			Constructor: newEsp32Button,
+		},
+	)
+}
+
+// ButtonControllerConfig names the esp32 board whose digital interrupts the buttons are
+// wired to.
+type ButtonControllerConfig struct {
+	Board   string         `json:"board"`
+	Buttons []ButtonConfig `json:"buttons"`
+}
+
+// ButtonConfig is a push button on one of the board's digital interrupts, which must
+// report both edges.
+type ButtonConfig struct {
+	// Control is the input control the button's events are sent for, e.g. ButtonSouth.
+	Control string `json:"control"`
+	// Interrupt is the name of the board's digital interrupt the button is wired to.
+	Interrupt string `json:"interrupt"`
+	// ActiveLow reads the button as pressed while its pin is low, as when it pulls the pin
+	// to ground.
+	ActiveLow bool `json:"active_low,omitempty"`
+	// DebounceMs is how long the pin must hold a level before the button is read as
+	// pressed or released, 20 by default.
+	DebounceMs int `json:"debounce_ms,omitempty"`
+	// LongPressMs is how long the button is held before ButtonHold is sent, 800 by
+	// default.
+	LongPressMs int `json:"long_press_ms,omitempty"`
+	// DoublePressMs is how soon after a release a press is sent as ButtonDoublePress, 300
+	// by default.
+	DoublePressMs int `json:"double_press_ms,omitempty"`
+}
+
+// Validate requires the board as a dependency.
+func (cfg *ButtonControllerConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Board == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'board'", path)
+	}
+	if len(cfg.Buttons) == 0 {
+		return nil, nil, fmt.Errorf("%s: missing required field 'buttons'", path)
+	}
+	controls := map[string]bool{}
+	for i, button := range cfg.Buttons {
+		buttonPath := fmt.Sprintf("%s.buttons.%d", path, i)
+		if button.Control == "" {
+			return nil, nil, fmt.Errorf("%s: missing required field 'control'", buttonPath)
+		}
+		if button.Interrupt == "" {
+			return nil, nil, fmt.Errorf("%s: missing required field 'interrupt'", buttonPath)
+		}
+		if controls[button.Control] {
+			return nil, nil, fmt.Errorf("%s: control %q is used more than once", buttonPath, button.Control)
+		}
+		controls[button.Control] = true
+		if button.DebounceMs < 0 || button.LongPressMs < 0 || button.DoublePressMs < 0 {
+			return nil, nil, fmt.Errorf("%s: 'debounce_ms', 'long_press_ms' and 'double_press_ms' must not be negative", buttonPath)
+		}
+	}
+	return []string{cfg.Board}, nil, nil
+}
+
+type esp32Button struct {
+	resource.Named
+	resource.AlwaysRebuild
+
+	logger  logging.Logger
+	workers *workerGroup
+	buttons []*button
+
+	mu        sync.Mutex
+	callbacks map[input.Control]map[input.EventType]input.ControlFunction
+	events    map[input.Control]input.Event
+}
+
+// button is a configured button and the state of its pin.
+type button struct {
+	control     input.Control
+	interrupt   board.DigitalInterrupt
+	activeLow   bool
+	debounce    time.Duration
+	longPress   time.Duration
+	doublePress time.Duration
+
+	// Only touched by the button's worker.
+	pressed bool
+	// raw is the pin's last level, read as pressed or not, and rawAt when it changed.
+	raw   bool
+	rawAt time.Time
+	// releasedAt is when the button was last released, zero once a press within
+	// doublePress of it was sent as a double press.
+	releasedAt time.Time
+}
+
+func newEsp32Button(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (input.Controller, error) {
+	conf, err := resource.NativeConfig[*ButtonControllerConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := board.FromProvider(deps, conf.Board)
+	if err != nil {
+		return nil, err
+	}
+	s := &esp32Button{
+		Named:     rawConf.ResourceName().AsNamed(),
+		logger:    logger,
+		workers:   newWorkerGroup(),
+		callbacks: map[input.Control]map[input.EventType]input.ControlFunction{},
+		events:    map[input.Control]input.Event{},
+	}
+	now := time.Now()
+	for _, cfg := range conf.Buttons {
+		interrupt, err := b.DigitalInterruptByName(cfg.Interrupt)
+		if err != nil {
+			return nil, fmt.Errorf("button %q: %w", cfg.Control, err)
+		}
+		btn := &button{
+			control:     input.Control(cfg.Control),
+			interrupt:   interrupt,
+			activeLow:   cfg.ActiveLow,
+			debounce:    msOrDefault(cfg.DebounceMs, defaultButtonDebounceMs),
+			longPress:   msOrDefault(cfg.LongPressMs, defaultButtonLongPressMs),
+			doublePress: msOrDefault(cfg.DoublePressMs, defaultButtonDoublePressMs),
+		}
+		s.buttons = append(s.buttons, btn)
+		s.events[btn.control] = input.Event{Time: now, Event: input.Connect, Control: btn.control}
+	}
+	for _, btn := range s.buttons {
+		s.workers.start("button "+string(btn.control), func() { s.watch(b, btn) })
+	}
+	return s, nil
+}
+
+// msOrDefault returns ms milliseconds, or def milliseconds if ms is 0.
+func msOrDefault(ms, def int) time.Duration {
+	if ms == 0 {
+		ms = def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// watch streams the ticks of btn's interrupt until the controller is closed, streaming
+// them again if the board refuses.
+func (s *esp32Button) watch(b board.Board, btn *button) {
+	ctx := s.workers.ctx
+	for {
+		err := s.stream(ctx, b, btn)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warnf("failed to stream ticks of interrupt %q for button %q: %v", btn.interrupt.Name(), btn.control, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(buttonRestreamDelay):
+		}
+	}
+}
+
+// stream turns the ticks of btn's interrupt into button events until ctx is done. The
+// pin must hold a level for btn.debounce before it counts, so the events are sent that
+// much after the last edge, but carry its time.
+func (s *esp32Button) stream(ctx context.Context, b board.Board, btn *button) error {
+	// Buffered so callbacks do not hold up the board's other tick streams.
+	ticks := make(chan board.Tick, 64)
+	if err := b.StreamTicks(ctx, []board.DigitalInterrupt{btn.interrupt}, ticks, nil); err != nil {
+		return err
+	}
+	// Stopped until the first tick; stopping or resetting a timer drops a pending fire.
+	settle := time.NewTimer(time.Hour)
+	settle.Stop()
+	hold := time.NewTimer(time.Hour)
+	hold.Stop()
+	defer settle.Stop()
+	defer hold.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case tick := <-ticks:
+			btn.raw = tick.High != btn.activeLow
+			btn.rawAt = time.Unix(0, int64(tick.TimestampNanosec))
+			settle.Reset(btn.debounce)
+		case <-settle.C:
+			if btn.raw == btn.pressed {
+				continue
+			}
+			btn.pressed = btn.raw
+			if !btn.pressed {
+				hold.Stop()
+				btn.releasedAt = btn.rawAt
+				s.send(ctx, input.Event{Time: btn.rawAt, Event: input.ButtonRelease, Control: btn.control})
+				continue
+			}
+			hold.Reset(btn.longPress - time.Since(btn.rawAt))
+			s.send(ctx, input.Event{Time: btn.rawAt, Event: input.ButtonPress, Control: btn.control, Value: 1})
+			if !btn.releasedAt.IsZero() && btn.rawAt.Sub(btn.releasedAt) <= btn.doublePress {
+				// A third press starts over rather than making another double press.
+				btn.releasedAt = time.Time{}
+				s.send(ctx, input.Event{Time: btn.rawAt, Event: ButtonDoublePress, Control: btn.control, Value: 1})
+			}
+		case <-hold.C:
+			if btn.pressed {
+				s.send(ctx, input.Event{Time: time.Now(), Event: input.ButtonHold, Control: btn.control, Value: 1})
+			}
+		}
+	}
+}
+
+// send records ev as its control's latest event and calls the callbacks registered for
+// it, on the button's worker.
+func (s *esp32Button) send(ctx context.Context, ev input.Event) {
+	s.mu.Lock()
+	// A double press follows the press it was detected on, which stays the button's
+	// state.
+	if ev.Event != ButtonDoublePress {
+		s.events[ev.Control] = ev
+	}
+	callback := s.callbacks[ev.Control][ev.Event]
+	all := s.callbacks[ev.Control][input.AllEvents]
+	s.mu.Unlock()
+	if callback != nil {
+		callback(ctx, ev)
+	}
+	if all != nil {
+		all(ctx, ev)
+	}
+}
+
+func (s *esp32Button) Controls(ctx context.Context, extra map[string]interface{}) ([]input.Control, error) {
+	controls := make([]input.Control, len(s.buttons))
+	for i, btn := range s.buttons {
+		controls[i] = btn.control
+	}
+	return controls, nil
+}
+
+// Events returns each button's latest press, release or hold, or its Connect event if it
+// has not been pressed.
+func (s *esp32Button) Events(ctx context.Context, extra map[string]interface{}) (map[input.Control]input.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make(map[input.Control]input.Event, len(s.events))
+	for control, ev := range s.events {
+		events[control] = ev
+	}
+	return events, nil
+}
+
+// RegisterControlCallback calls ctrlFunc on the triggers for control, replacing the
+// callback registered for any of them. ButtonChange registers both ButtonPress and
+// ButtonRelease, and a nil ctrlFunc removes the callbacks.
+func (s *esp32Button) RegisterControlCallback(
+	ctx context.Context,
+	control input.Control,
+	triggers []input.EventType,
+	ctrlFunc input.ControlFunction,
+	extra map[string]interface{},
+) error {
+	s.mu.Lock()
+	if _, ok := s.events[control]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("unknown control %q", control)
+	}
+	defer s.mu.Unlock()
+	if s.callbacks[control] == nil {
+		s.callbacks[control] = map[input.EventType]input.ControlFunction{}
+	}
+	for _, trigger := range triggers {
+		events := []input.EventType{trigger}
+		if trigger == input.ButtonChange {
+			events = []input.EventType{input.ButtonPress, input.ButtonRelease}
+		}
+		for _, ev := range events {
+			if ctrlFunc == nil {
+				delete(s.callbacks[control], ev)
+			} else {
+				s.callbacks[control][ev] = ctrlFunc
+			}
+		}
+	}
+	return nil
+}
+
+func (s *esp32Button) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+// Close stops streaming ticks.
+func (s *esp32Button) Close(ctx context.Context) error {
+	s.workers.stop()
+	return nil
+}
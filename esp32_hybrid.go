@@ -0,0 +1,138 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+
+	"esp32wifi/esp32client"
+)
+
+var (
+	Esp32Hybrid = resource.NewModel("mattmacf", "esp32-wifi", "esp32-hybrid")
+)
+
+func init() {
+	resource.RegisterComponent(board.API, Esp32Hybrid,
+		resource.Registration[board.Board, *HybridConfig]{
+			Constructor: newEsp32Hybrid,
+		},
+	)
+}
+
+// HybridConfig configures a board reached over WiFi that falls back to BLE while WiFi is
+// down.
+type HybridConfig struct {
+	WifiConfig `json:",squash"`
+
+	BTServerName string `json:"bt_server_name"`
+	// Security is "none" (default), "bond" or "passkey".
+	Security string `json:"security,omitempty"`
+	// Passkey is the static six digit passkey the firmware expects when Security is "passkey".
+	Passkey *uint32 `json:"passkey,omitempty"`
+	// WriteMode is how requests are written over BLE, as for esp32-ble.
+	WriteMode string `json:"write_mode,omitempty"`
+}
+
+// Validate ensures all parts of the config are valid and important fields exist.
+// Returns three values:
+//  1. Required dependencies: other resources that must exist for this resource to work.
+//  2. Optional dependencies: other resources that may exist but are not required.
+//  3. An error if any Config fields are missing or invalid.
+//
+// The `path` parameter indicates
+// where this resource appears in the machine's JSON configuration
+// (for example, "components.0"). You can use it in error messages
+// to indicate which resource has a problem.
+func (cfg *HybridConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.BTServerName == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'bt_server_name'", path)
+	}
+	if err := validateBLESecurity(cfg.Security, cfg.Passkey); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := validateWriteMode(cfg.WriteMode); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// A device this host reaches over BLE is close enough to be reached over WiFi directly.
+	if remote, err := cfg.remoteBoard(); err == nil && remote != "" {
+		return nil, nil, fmt.Errorf("%s: esp32-hybrid cannot forward through a %q url, use esp32-wifi", path, remoteScheme)
+	}
+	return cfg.WifiConfig.Validate(path)
+}
+
+func hybridDeviceKey(baseURL, proxy, serverName string) string {
+	return "hybrid:" + httpDeviceKey(baseURL, proxy) + "," + strings.ToLower(serverName)
+}
+
+type esp32Hybrid struct {
+	*esp32Board
+
+	cfg *HybridConfig
+	url string
+}
+
+func newEsp32Hybrid(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (board.Board, error) {
+	conf, err := resource.NativeConfig[*HybridConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEsp32Hybrid(ctx, deps, rawConf.ResourceName(), conf, logger)
+}
+
+// NewEsp32Hybrid returns a board that talks to the device over WiFi and switches to BLE
+// while the device is unreachable over WiFi. BLE is only connected while it is in use.
+// opts are applied to both the HTTP and BLE clients. The ESP32WIFI_* environment variables
+// override conf.
+func NewEsp32Hybrid(ctx context.Context, deps resource.Dependencies, name resource.Name, conf *HybridConfig, logger logging.Logger, opts ...esp32client.Option) (board.Board, error) {
+	if err := applyEnvLogLevel(logger); err != nil {
+		return nil, err
+	}
+	baseURL, err := conf.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	httpOpts, err := conf.httpClientOptions(logger)
+	if err != nil {
+		return nil, err
+	}
+	client, err := devices.acquire(hybridDeviceKey(baseURL, conf.Proxy, conf.BTServerName), func() (esp32client.Client, error) {
+		primary := esp32client.NewHTTPClient(baseURL, append(httpOpts, opts...)...)
+		dialBLE := func(ctx context.Context) (esp32client.Client, error) {
+			bleOpts := append([]esp32client.Option{esp32client.WithLogger(logger)}, bleClientOptions(conf.Security, conf.Passkey)...)
+			bleOpts = append(bleOpts, writeModeOptions(conf.WriteMode)...)
+			bleOpts = append(bleOpts, conf.protocolOptions()...)
+			return esp32client.DialBLE(ctx, conf.BTServerName, append(bleOpts, opts...)...)
+		}
+		return esp32client.NewFailoverClient(primary, dialBLE, esp32client.WithLogger(logger)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if conf.ExpectedDeviceID != "" {
+		if err := verifyDeviceID(ctx, client, conf.ExpectedDeviceID); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("refusing to use the device at %s: %w", baseURL, err)
+		}
+	}
+
+	b, err := newEsp32Board(ctx, deps, name, &conf.BoardConfig, client, logger)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	b.bleDevice = conf.BTServerName
+	b.writeMode = conf.WriteMode
+	s := &esp32Hybrid{
+		esp32Board: b,
+		cfg:        conf,
+		url:        baseURL,
+	}
+	return s, nil
+}
@@ -0,0 +1,231 @@
+package esp32wifi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/powersensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var (
+	Esp32INA219 = resource.NewModel("mattmacf", "esp32-wifi", "esp32-ina219")
+)
+
+// Chips the esp32-ina219 model reads.
+const (
+	inaChipINA219 = "ina219"
+	inaChipINA226 = "ina226"
+)
+
+const (
+	defaultINAAddress = 0x40
+	defaultShuntOhms  = 0.1
+)
+
+// Registers of the INA219 and INA226, which share the first three.
+const (
+	inaRegConfig  = 0x00
+	inaRegShunt   = 0x01
+	inaRegBus     = 0x02
+	ina226RegMfr  = 0xfe
+	ina226TIMfrID = 0x5449
+	// ina219Config is the INA219's power-on configuration: a 32V bus range, a ±320mV shunt
+	// range and continuous 12-bit conversions of both.
+	ina219Config = 0x399f
+)
+
+func init() {
+	resource.RegisterComponent(powersensor.API, Esp32INA219,
+		resource.Registration[powersensor.PowerSensor, *INAConfig]{
+			Constructor: newEsp32INA219,
+		},
+	)
+}
+
+// INAConfig names the esp32 board whose I2C bus the current monitor is wired to.
+type INAConfig struct {
+	Board string `json:"board"`
+	// Chip is ina219 or ina226, ina219 by default.
+	Chip string `json:"chip,omitempty"`
+	// Address is the chip's I2C address, 0x40 by default.
+	Address int `json:"address,omitempty"`
+	// Bus is the firmware's I2C bus, 0 by default.
+	Bus int `json:"bus,omitempty"`
+	// ShuntOhms is the resistance of the shunt the current flows through, 0.1 by default
+	// as on most breakouts.
+	ShuntOhms float64 `json:"shunt_ohms,omitempty"`
+}
+
+// Validate requires the board as a dependency.
+func (cfg *INAConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Board == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'board'", path)
+	}
+	switch cfg.Chip {
+	case "", inaChipINA219, inaChipINA226:
+	default:
+		return nil, nil, fmt.Errorf("%s: unknown chip %q, must be %s or %s", path, cfg.Chip, inaChipINA219, inaChipINA226)
+	}
+	if cfg.Address != 0 && (cfg.Address < 0x08 || cfg.Address > 0x77) {
+		return nil, nil, fmt.Errorf("%s: 'address' must be a 7-bit I2C address, got %d", path, cfg.Address)
+	}
+	if cfg.Bus < 0 {
+		return nil, nil, fmt.Errorf("%s: 'bus' must not be negative", path)
+	}
+	if cfg.ShuntOhms < 0 {
+		return nil, nil, fmt.Errorf("%s: 'shunt_ohms' must be positive", path)
+	}
+	return []string{cfg.Board}, nil, nil
+}
+
+// inaSample is one reading of the chip.
+type inaSample struct {
+	volts, amps float64
+}
+
+type esp32INA219 struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	logger    logging.Logger
+	dev       i2cDevice
+	chip      string
+	shuntOhms float64
+	// shuntLSB and busLSB are the volts per count of the shunt and bus voltages.
+	shuntLSB, busLSB float64
+
+	mu sync.Mutex
+	// ready is set once the chip is identified and configured, and cleared when a read
+	// fails, since the chip may have lost power and its configuration.
+	ready bool
+}
+
+func newEsp32INA219(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (powersensor.PowerSensor, error) {
+	conf, err := resource.NativeConfig[*INAConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := board.FromProvider(deps, conf.Board)
+	if err != nil {
+		return nil, err
+	}
+	s := &esp32INA219{
+		Named:     rawConf.ResourceName().AsNamed(),
+		logger:    logger,
+		dev:       i2cDevice{bus: i2cBusOf(b), busNum: conf.Bus, address: conf.Address},
+		chip:      conf.Chip,
+		shuntOhms: conf.ShuntOhms,
+		shuntLSB:  10e-6,
+		busLSB:    4e-3,
+	}
+	if s.chip == "" {
+		s.chip = inaChipINA219
+	}
+	if s.chip == inaChipINA226 {
+		s.shuntLSB, s.busLSB = 2.5e-6, 1.25e-3
+	}
+	if s.dev.address == 0 {
+		s.dev.address = defaultINAAddress
+	}
+	if s.shuntOhms == 0 {
+		s.shuntOhms = defaultShuntOhms
+	}
+	return s, nil
+}
+
+// setUp checks that the chip at the address is an INA226 if one is configured, and
+// restores an INA219's configuration, which the module relies on. s.mu must be held.
+func (s *esp32INA219) setUp(ctx context.Context) error {
+	if s.chip == inaChipINA226 {
+		id, err := s.dev.read(ctx, ina226RegMfr, 2)
+		if err != nil {
+			return err
+		}
+		if mfr := binary.BigEndian.Uint16(id); mfr != ina226TIMfrID {
+			return fmt.Errorf("device at i2c address 0x%02x reports manufacturer ID 0x%04x, not an %s", s.dev.address, mfr, s.chip)
+		}
+		return nil
+	}
+	// The INA219 has no ID register; writing its configuration at least fails if nothing
+	// answers at the address.
+	return s.dev.write(ctx, []byte{inaRegConfig, ina219Config >> 8, ina219Config & 0xff})
+}
+
+// read sets the chip up if needed and reads the bus voltage and the current.
+func (s *esp32INA219) read(ctx context.Context) (inaSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ready {
+		if err := s.setUp(ctx); err != nil {
+			return inaSample{}, fmt.Errorf("failed to set up the %s: %w", s.chip, err)
+		}
+		s.ready = true
+	}
+	shunt, err := s.dev.read(ctx, inaRegShunt, 2)
+	if err != nil {
+		s.ready = false
+		return inaSample{}, fmt.Errorf("failed to read the %s: %w", s.chip, err)
+	}
+	bus, err := s.dev.read(ctx, inaRegBus, 2)
+	if err != nil {
+		s.ready = false
+		return inaSample{}, fmt.Errorf("failed to read the %s: %w", s.chip, err)
+	}
+	busCounts := binary.BigEndian.Uint16(bus)
+	if s.chip == inaChipINA219 {
+		// The low three bits are conversion flags.
+		busCounts >>= 3
+	}
+	shuntVolts := float64(int16(binary.BigEndian.Uint16(shunt))) * s.shuntLSB
+	return inaSample{volts: float64(busCounts) * s.busLSB, amps: shuntVolts / s.shuntOhms}, nil
+}
+
+// Voltage returns the bus voltage, on the load side of the shunt.
+func (s *esp32INA219) Voltage(ctx context.Context, extra map[string]interface{}) (float64, bool, error) {
+	sample, err := s.read(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return sample.volts, false, nil
+}
+
+// Current returns the current through the shunt, negative if it flows backwards.
+func (s *esp32INA219) Current(ctx context.Context, extra map[string]interface{}) (float64, bool, error) {
+	sample, err := s.read(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return sample.amps, false, nil
+}
+
+func (s *esp32INA219) Power(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	sample, err := s.read(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return sample.volts * sample.amps, nil
+}
+
+// Readings returns volts, amps and watts from a single sample.
+func (s *esp32INA219) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	sample, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"volts": sample.volts,
+		"amps":  sample.amps,
+		"watts": sample.volts * sample.amps,
+		"is_ac": false,
+	}, nil
+}
+
+func (s *esp32INA219) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
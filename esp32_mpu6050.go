@@ -0,0 +1,341 @@
+package esp32wifi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+)
+
+var (
+	Esp32MPU6050 = resource.NewModel("mattmacf", "esp32-wifi", "esp32-mpu6050")
+)
+
+// Chips the esp32-mpu6050 model reads.
+const (
+	imuChipMPU6050  = "mpu6050"
+	imuChipICM20948 = "icm20948"
+)
+
+const (
+	defaultAccelRangeG   = 2
+	defaultGyroRangeDps  = 250
+	standardGravityMPerS = 9.80665
+)
+
+func init() {
+	resource.RegisterComponent(movementsensor.API, Esp32MPU6050,
+		resource.Registration[movementsensor.MovementSensor, *IMUConfig]{
+			Constructor: newEsp32MPU6050,
+		},
+	)
+}
+
+// IMUConfig names the esp32 board whose I2C bus the IMU is wired to.
+type IMUConfig struct {
+	Board string `json:"board"`
+	// Chip is mpu6050, also covering the register compatible MPU-6500 and MPU-9250, or
+	// icm20948. mpu6050 by default.
+	Chip string `json:"chip,omitempty"`
+	// Address is the chip's I2C address, 0x68 by default for the mpu6050 and 0x69, the
+	// usual breakout wiring, for the icm20948.
+	Address int `json:"address,omitempty"`
+	// Bus is the firmware's I2C bus, 0 by default.
+	Bus int `json:"bus,omitempty"`
+	// AccelRangeG is the full scale of the accelerometer: 2, 4, 8 or 16 g, 2 by default.
+	AccelRangeG int `json:"accel_range_g,omitempty"`
+	// GyroRangeDps is the full scale of the gyroscope: 250, 500, 1000 or 2000 degrees per
+	// second, 250 by default.
+	GyroRangeDps int `json:"gyro_range_dps,omitempty"`
+}
+
+// Validate requires the board as a dependency.
+func (cfg *IMUConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Board == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'board'", path)
+	}
+	switch cfg.Chip {
+	case "", imuChipMPU6050, imuChipICM20948:
+	default:
+		return nil, nil, fmt.Errorf("%s: unknown chip %q, must be %s or %s", path, cfg.Chip, imuChipMPU6050, imuChipICM20948)
+	}
+	if cfg.Address != 0 && (cfg.Address < 0x08 || cfg.Address > 0x77) {
+		return nil, nil, fmt.Errorf("%s: 'address' must be a 7-bit I2C address, got %d", path, cfg.Address)
+	}
+	if cfg.Bus < 0 {
+		return nil, nil, fmt.Errorf("%s: 'bus' must not be negative", path)
+	}
+	if cfg.AccelRangeG != 0 && fullScaleSel(cfg.AccelRangeG, defaultAccelRangeG) < 0 {
+		return nil, nil, fmt.Errorf("%s: 'accel_range_g' must be 2, 4, 8 or 16, got %d", path, cfg.AccelRangeG)
+	}
+	if cfg.GyroRangeDps != 0 && fullScaleSel(cfg.GyroRangeDps, defaultGyroRangeDps) < 0 {
+		return nil, nil, fmt.Errorf("%s: 'gyro_range_dps' must be 250, 500, 1000 or 2000, got %d", path, cfg.GyroRangeDps)
+	}
+	return []string{cfg.Board}, nil, nil
+}
+
+// fullScaleSel returns the FS_SEL bits for a full scale of rangeValue, which the chips
+// double from smallest for each step, or -1 if they do not offer it.
+func fullScaleSel(rangeValue, smallest int) int {
+	for sel := 0; sel < 4; sel++ {
+		if rangeValue == smallest<<sel {
+			return sel
+		}
+	}
+	return -1
+}
+
+// imuRegisters are where a chip keeps what the model reads and writes.
+type imuRegisters struct {
+	// selectBank are the register writes, if any, that select the bank WHO_AM_I, wake
+	// and sample are in.
+	selectBank [][]byte
+	whoAmI     byte
+	// ids are the WHO_AM_I values of the chips the registers fit.
+	ids []byte
+	// wake are the register writes that take the chip out of sleep, in order.
+	wake [][]byte
+	// fullScale returns the register writes that set the full scales, in order.
+	fullScale func(accelSel, gyroSel int) [][]byte
+	// sample is the first of the registers read in one burst, and the offsets of the
+	// accelerometer, gyroscope and temperature in it.
+	sample               byte
+	sampleLen            int
+	accelAt, gyroAt, tAt int
+	// celsius converts the raw temperature.
+	celsius func(raw int16) float64
+}
+
+var imuChips = map[string]imuRegisters{
+	imuChipMPU6050: {
+		whoAmI: 0x75,
+		ids:    []byte{0x68, 0x70, 0x71, 0x73},
+		// PWR_MGMT_1: clear SLEEP and clock from the X gyro's PLL.
+		wake: [][]byte{{0x6b, 0x01}},
+		fullScale: func(accelSel, gyroSel int) [][]byte {
+			// GYRO_CONFIG and ACCEL_CONFIG, which follow it.
+			return [][]byte{{0x1b, byte(gyroSel << 3), byte(accelSel << 3)}}
+		},
+		sample:    0x3b,
+		sampleLen: 14,
+		accelAt:   0,
+		tAt:       6,
+		gyroAt:    8,
+		celsius:   func(raw int16) float64 { return float64(raw)/340 + 36.53 },
+	},
+	imuChipICM20948: {
+		// REG_BANK_SEL bank 0.
+		selectBank: [][]byte{{0x7f, 0x00}},
+		whoAmI:     0x00,
+		ids:        []byte{0xea},
+		// PWR_MGMT_1: clear SLEEP and select the best clock.
+		wake: [][]byte{{0x06, 0x01}},
+		fullScale: func(accelSel, gyroSel int) [][]byte {
+			// In bank 2, GYRO_CONFIG_1 and ACCEL_CONFIG with the low pass filter on, then
+			// back to bank 0 for the samples.
+			return [][]byte{{0x7f, 0x20}, {0x01, byte(gyroSel<<1 | 1)}, {0x14, byte(accelSel<<1 | 1)}, {0x7f, 0x00}}
+		},
+		sample:    0x2d,
+		sampleLen: 14,
+		accelAt:   0,
+		gyroAt:    6,
+		tAt:       12,
+		celsius:   func(raw int16) float64 { return float64(raw-21)/333.87 + 21 },
+	},
+}
+
+// imuSample is one burst read of the chip, in SI units where the movement sensor API
+// has them.
+type imuSample struct {
+	angularVelocity    spatialmath.AngularVelocity
+	linearAcceleration r3.Vector
+	temperatureC       float64
+}
+
+type esp32MPU6050 struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	logger logging.Logger
+	dev    i2cDevice
+	chip   string
+	regs   imuRegisters
+	// accelSel and gyroSel are the full scales' FS_SEL bits, and accelLSB and gyroLSB the
+	// counts per g and per degree per second they give.
+	accelSel, gyroSel int
+	accelLSB, gyroLSB float64
+
+	mu sync.Mutex
+	// ready is set once the chip is awake and configured, and cleared when a read fails,
+	// since the chip may have lost power and gone back to sleep.
+	ready bool
+}
+
+func newEsp32MPU6050(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (movementsensor.MovementSensor, error) {
+	conf, err := resource.NativeConfig[*IMUConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := board.FromProvider(deps, conf.Board)
+	if err != nil {
+		return nil, err
+	}
+	s := &esp32MPU6050{
+		Named:  rawConf.ResourceName().AsNamed(),
+		logger: logger,
+		dev:    i2cDevice{bus: i2cBusOf(b), busNum: conf.Bus, address: conf.Address},
+		chip:   conf.Chip,
+	}
+	if s.chip == "" {
+		s.chip = imuChipMPU6050
+	}
+	if s.dev.address == 0 {
+		s.dev.address = 0x68
+		if s.chip == imuChipICM20948 {
+			s.dev.address = 0x69
+		}
+	}
+	s.regs = imuChips[s.chip]
+	accelRange, gyroRange := conf.AccelRangeG, conf.GyroRangeDps
+	if accelRange == 0 {
+		accelRange = defaultAccelRangeG
+	}
+	if gyroRange == 0 {
+		gyroRange = defaultGyroRangeDps
+	}
+	// Checked by Validate.
+	s.accelSel = fullScaleSel(accelRange, defaultAccelRangeG)
+	s.gyroSel = fullScaleSel(gyroRange, defaultGyroRangeDps)
+	s.accelLSB = 32768 / float64(accelRange)
+	s.gyroLSB = 32768 / float64(gyroRange)
+	return s, nil
+}
+
+// setUp checks that the chip at the address is the configured one, wakes it and sets its
+// full scales. s.mu must be held.
+func (s *esp32MPU6050) setUp(ctx context.Context) error {
+	if err := s.dev.write(ctx, s.regs.selectBank...); err != nil {
+		return err
+	}
+	id, err := s.dev.read(ctx, s.regs.whoAmI, 1)
+	if err != nil {
+		return err
+	}
+	known := false
+	for _, want := range s.regs.ids {
+		known = known || id[0] == want
+	}
+	if !known {
+		return fmt.Errorf("device at i2c address 0x%02x reports WHO_AM_I 0x%02x, not an %s", s.dev.address, id[0], s.chip)
+	}
+	if err := s.dev.write(ctx, s.regs.wake...); err != nil {
+		return err
+	}
+	if err := s.dev.write(ctx, s.regs.fullScale(s.accelSel, s.gyroSel)...); err != nil {
+		return err
+	}
+	s.logger.Debugf("%s at i2c address 0x%02x is set up", s.chip, s.dev.address)
+	return nil
+}
+
+// read sets the chip up if needed and reads a sample in one burst.
+func (s *esp32MPU6050) read(ctx context.Context) (imuSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ready {
+		if err := s.setUp(ctx); err != nil {
+			return imuSample{}, fmt.Errorf("failed to set up the %s: %w", s.chip, err)
+		}
+		s.ready = true
+	}
+	data, err := s.dev.read(ctx, s.regs.sample, s.regs.sampleLen)
+	if err != nil {
+		s.ready = false
+		return imuSample{}, fmt.Errorf("failed to read the %s: %w", s.chip, err)
+	}
+	axis := func(at, i int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(data[at+2*i:])))
+	}
+	var sample imuSample
+	sample.linearAcceleration = r3.Vector{
+		X: axis(s.regs.accelAt, 0) / s.accelLSB * standardGravityMPerS,
+		Y: axis(s.regs.accelAt, 1) / s.accelLSB * standardGravityMPerS,
+		Z: axis(s.regs.accelAt, 2) / s.accelLSB * standardGravityMPerS,
+	}
+	sample.angularVelocity = spatialmath.AngularVelocity{
+		X: axis(s.regs.gyroAt, 0) / s.gyroLSB,
+		Y: axis(s.regs.gyroAt, 1) / s.gyroLSB,
+		Z: axis(s.regs.gyroAt, 2) / s.gyroLSB,
+	}
+	sample.temperatureC = s.regs.celsius(int16(binary.BigEndian.Uint16(data[s.regs.tAt:])))
+	return sample, nil
+}
+
+// AngularVelocity returns the gyroscope's reading in degrees per second.
+func (s *esp32MPU6050) AngularVelocity(ctx context.Context, extra map[string]interface{}) (spatialmath.AngularVelocity, error) {
+	sample, err := s.read(ctx)
+	if err != nil {
+		return spatialmath.AngularVelocity{}, err
+	}
+	return sample.angularVelocity, nil
+}
+
+// LinearAcceleration returns the accelerometer's reading in m/s², gravity included.
+func (s *esp32MPU6050) LinearAcceleration(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	sample, err := s.read(ctx)
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	return sample.linearAcceleration, nil
+}
+
+// Readings returns angular_velocity and linear_acceleration from a single sample, and the
+// chip's temperature_c.
+func (s *esp32MPU6050) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	sample, err := s.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"angular_velocity":    sample.angularVelocity,
+		"linear_acceleration": sample.linearAcceleration,
+		"temperature_c":       sample.temperatureC,
+	}, nil
+}
+
+func (s *esp32MPU6050) Properties(ctx context.Context, extra map[string]interface{}) (*movementsensor.Properties, error) {
+	return &movementsensor.Properties{AngularVelocitySupported: true, LinearAccelerationSupported: true}, nil
+}
+
+func (s *esp32MPU6050) Position(ctx context.Context, extra map[string]interface{}) (*geo.Point, float64, error) {
+	return nil, 0, movementsensor.ErrMethodUnimplementedPosition
+}
+
+func (s *esp32MPU6050) LinearVelocity(ctx context.Context, extra map[string]interface{}) (r3.Vector, error) {
+	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearVelocity
+}
+
+func (s *esp32MPU6050) CompassHeading(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+}
+
+func (s *esp32MPU6050) Orientation(ctx context.Context, extra map[string]interface{}) (spatialmath.Orientation, error) {
+	return nil, movementsensor.ErrMethodUnimplementedOrientation
+}
+
+func (s *esp32MPU6050) Accuracy(ctx context.Context, extra map[string]interface{}) (*movementsensor.Accuracy, error) {
+	return nil, movementsensor.ErrMethodUnimplementedAccuracy
+}
+
+func (s *esp32MPU6050) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
@@ -0,0 +1,119 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var (
+	Esp32Power = resource.NewModel("mattmacf", "esp32-wifi", "esp32-power")
+)
+
+func init() {
+	resource.RegisterComponent(sensor.API, Esp32Power,
+		resource.Registration[sensor.Sensor, *PowerSensorConfig]{
+			Constructor: newEsp32Power,
+		},
+	)
+}
+
+// PowerSensorConfig names the esp32 board whose supply the sensor reports. The board's
+// 'power' attribute configures how the voltage is measured.
+type PowerSensorConfig struct {
+	Board string `json:"board"`
+}
+
+// Validate requires the board as a dependency.
+func (cfg *PowerSensorConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Board == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'board'", path)
+	}
+	return []string{cfg.Board}, nil, nil
+}
+
+type esp32Power struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	monitor PowerMonitor
+}
+
+func newEsp32Power(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (sensor.Sensor, error) {
+	conf, err := resource.NativeConfig[*PowerSensorConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := board.FromProvider(deps, conf.Board)
+	if err != nil {
+		return nil, err
+	}
+	// Modules usually get their dependencies as gRPC clients, which only offer the
+	// board's status command.
+	monitor, ok := b.(PowerMonitor)
+	if !ok {
+		monitor = statusPowerMonitor{b}
+	}
+	return &esp32Power{Named: rawConf.ResourceName().AsNamed(), monitor: monitor}, nil
+}
+
+// Readings returns brownouts, supply_voltage (volts) and supply_sagging when the voltage
+// is measured, and boot_count, reset_reason and uptime_s when the firmware reports them.
+func (s *esp32Power) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	power, err := s.monitor.Power(ctx)
+	if err != nil {
+		return nil, err
+	}
+	readings := map[string]interface{}{"brownouts": power.Brownouts}
+	if power.SupplyVoltage != 0 {
+		readings["supply_voltage"] = power.SupplyVoltage
+		readings["supply_sagging"] = power.Sagging
+	}
+	if power.BootCount != 0 {
+		readings["boot_count"] = power.BootCount
+		readings["reset_reason"] = power.ResetReason
+		readings["uptime_s"] = power.Uptime.Seconds()
+	}
+	return readings, nil
+}
+
+func (s *esp32Power) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+// statusPowerMonitor reads power through the status command of a board it cannot type
+// assert.
+type statusPowerMonitor struct {
+	board board.Board
+}
+
+func (m statusPowerMonitor) Power(ctx context.Context) (PowerStatus, error) {
+	status, err := m.board.DoCommand(ctx, map[string]interface{}{"command": "status"})
+	if err != nil {
+		return PowerStatus{}, err
+	}
+	if msg, ok := status["power_error"].(string); ok {
+		return PowerStatus{}, errors.New(msg)
+	}
+	brownouts, ok := status["brownouts"].(float64)
+	if !ok {
+		return PowerStatus{}, fmt.Errorf("board %q did not report power telemetry, is it an esp32 board from this module?", m.board.Name().ShortName())
+	}
+	power := PowerStatus{Brownouts: int(brownouts)}
+	power.SupplyVoltage, _ = status["supply_voltage"].(float64)
+	power.Sagging, _ = status["supply_sagging"].(bool)
+	if bootCount, ok := status["boot_count"].(float64); ok {
+		power.BootCount = int(bootCount)
+		power.ResetReason, _ = status["reset_reason"].(string)
+		uptime, _ := status["uptime_s"].(float64)
+		power.Uptime = time.Duration(uptime * float64(time.Second))
+	}
+	return power, nil
+}
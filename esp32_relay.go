@@ -0,0 +1,131 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+
+	board "go.viam.com/rdk/components/board"
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var (
+	Esp32Relay = resource.NewModel("mattmacf", "esp32-wifi", "esp32-relay")
+)
+
+func init() {
+	resource.RegisterComponent(toggleswitch.API, Esp32Relay,
+		resource.Registration[toggleswitch.Switch, *RelaySwitchConfig]{
+			Constructor: newEsp32Relay,
+		},
+	)
+}
+
+// RelaySwitchConfig names a relay configured on an esp32 board.
+type RelaySwitchConfig struct {
+	Board string `json:"board"`
+	Relay string `json:"relay"`
+}
+
+// Validate requires the board as a dependency.
+func (cfg *RelaySwitchConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Board == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'board'", path)
+	}
+	if cfg.Relay == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'relay'", path)
+	}
+	return []string{cfg.Board}, nil, nil
+}
+
+// RelayController is implemented by the boards in this package to switch their relays
+// under the configured interlocks and dwell times. The esp32-relay switch switches boards
+// through it.
+type RelayController interface {
+	SetRelay(ctx context.Context, name string, energize bool) error
+	Relay(ctx context.Context, name string) (bool, error)
+}
+
+// Positions of an esp32-relay switch.
+const (
+	relayPositionOff = iota
+	relayPositionOn
+)
+
+type esp32Relay struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	relays RelayController
+	relay  string
+}
+
+func newEsp32Relay(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (toggleswitch.Switch, error) {
+	conf, err := resource.NativeConfig[*RelaySwitchConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := board.FromProvider(deps, conf.Board)
+	if err != nil {
+		return nil, err
+	}
+	// Modules usually get their dependencies as gRPC clients, which only offer the
+	// board's relay commands.
+	relays, ok := b.(RelayController)
+	if !ok {
+		relays = commandRelayController{b}
+	}
+	return &esp32Relay{Named: rawConf.ResourceName().AsNamed(), relays: relays, relay: conf.Relay}, nil
+}
+
+// SetPosition releases the relay at position 0 and energizes it at 1.
+func (s *esp32Relay) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
+	if position > relayPositionOn {
+		return fmt.Errorf("relay %q has positions 0 (off) and 1 (on), got %d", s.relay, position)
+	}
+	return s.relays.SetRelay(ctx, s.relay, position == relayPositionOn)
+}
+
+func (s *esp32Relay) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
+	on, err := s.relays.Relay(ctx, s.relay)
+	if err != nil {
+		return 0, err
+	}
+	if on {
+		return relayPositionOn, nil
+	}
+	return relayPositionOff, nil
+}
+
+func (s *esp32Relay) GetNumberOfPositions(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
+	return 2, []string{"off", "on"}, nil
+}
+
+func (s *esp32Relay) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+// commandRelayController switches relays through the commands of a board it cannot type
+// assert.
+type commandRelayController struct {
+	board board.Board
+}
+
+func (c commandRelayController) SetRelay(ctx context.Context, name string, energize bool) error {
+	_, err := c.board.DoCommand(ctx, map[string]interface{}{"command": "set_relay", "relay": name, "on": energize})
+	return err
+}
+
+func (c commandRelayController) Relay(ctx context.Context, name string) (bool, error) {
+	resp, err := c.board.DoCommand(ctx, map[string]interface{}{"command": "get_relay", "relay": name})
+	if err != nil {
+		return false, err
+	}
+	on, ok := resp["on"].(bool)
+	if !ok {
+		return false, fmt.Errorf("board %q did not report relay %q, is it an esp32 board from this module?", c.board.Name().ShortName(), name)
+	}
+	return on, nil
+}
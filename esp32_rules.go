@@ -0,0 +1,327 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
+)
+
+var (
+	Esp32Rules = resource.NewModel("mattmacf", "esp32-wifi", "esp32-rules")
+)
+
+const (
+	defaultRuleIntervalMs = 1000
+	minRuleIntervalMs     = 100
+)
+
+func init() {
+	resource.RegisterService(generic.API, Esp32Rules,
+		resource.Registration[resource.Resource, *RulesConfig]{
+			Constructor: newEsp32Rules,
+		},
+	)
+}
+
+// RulesConfig names the esp32 board whose analog readers the rules watch and whose pins
+// and relays they drive.
+type RulesConfig struct {
+	Board string `json:"board"`
+	// IntervalMs is how often the rules are evaluated, 1000 by default.
+	IntervalMs int          `json:"interval_ms,omitempty"`
+	Rules      []RuleConfig `json:"rules"`
+}
+
+// RuleConfig drives an output pin or a relay while an analog reading crosses a threshold,
+// e.g. a fan while a temperature is above 30. At least one of Above and Below must be
+// set; the rule holds while either does.
+type RuleConfig struct {
+	Name string `json:"name"`
+	// Analog is the name of the board's analog reader the rule watches.
+	Analog string `json:"analog"`
+	// Above and Below hold the rule while the reading is above or below them.
+	Above *float64 `json:"above,omitempty"`
+	Below *float64 `json:"below,omitempty"`
+	// Hysteresis is how far back past Above or Below the reading must go before the rule
+	// stops holding, so a reading that hovers at the threshold does not flap the output.
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+	// Pin is driven high while the rule holds and low otherwise. Exactly one of Pin and
+	// Relay must be set.
+	Pin string `json:"pin,omitempty"`
+	// ActiveLow drives Pin low while the rule holds instead.
+	ActiveLow bool `json:"active_low,omitempty"`
+	// Relay is the name of a relay on the board, energized while the rule holds.
+	Relay string `json:"relay,omitempty"`
+}
+
+// Validate requires the board as a dependency.
+func (cfg *RulesConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Board == "" {
+		return nil, nil, fmt.Errorf("%s: missing required field 'board'", path)
+	}
+	if cfg.IntervalMs != 0 && cfg.IntervalMs < minRuleIntervalMs {
+		return nil, nil, fmt.Errorf("%s: 'interval_ms' must be at least %d", path, minRuleIntervalMs)
+	}
+	if len(cfg.Rules) == 0 {
+		return nil, nil, fmt.Errorf("%s: missing required field 'rules'", path)
+	}
+	names := map[string]bool{}
+	targets := map[string]string{}
+	for i, rule := range cfg.Rules {
+		rulePath := fmt.Sprintf("%s.rules.%d", path, i)
+		if err := rule.validate(rulePath); err != nil {
+			return nil, nil, err
+		}
+		if names[rule.Name] {
+			return nil, nil, fmt.Errorf("%s: rule name %q is used more than once", rulePath, rule.Name)
+		}
+		names[rule.Name] = true
+		target := rule.target()
+		if other, ok := targets[target]; ok {
+			return nil, nil, fmt.Errorf("%s: %s is also driven by rule %q", rulePath, target, other)
+		}
+		targets[target] = rule.Name
+	}
+	return []string{cfg.Board}, nil, nil
+}
+
+func (cfg *RuleConfig) validate(path string) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if cfg.Analog == "" {
+		return fmt.Errorf("%s: missing required field 'analog'", path)
+	}
+	if cfg.Above == nil && cfg.Below == nil {
+		return fmt.Errorf("%s: set at least one of 'above' or 'below'", path)
+	}
+	if cfg.Above != nil && cfg.Below != nil && *cfg.Below >= *cfg.Above {
+		return fmt.Errorf("%s: 'below' must be less than 'above', or the rule always holds", path)
+	}
+	if cfg.Hysteresis < 0 {
+		return fmt.Errorf("%s: 'hysteresis' must not be negative", path)
+	}
+	if cfg.Above != nil && cfg.Below != nil && 2*cfg.Hysteresis >= *cfg.Above-*cfg.Below {
+		return fmt.Errorf("%s: 'hysteresis' must be less than half the gap between 'below' and 'above', or the rule never releases", path)
+	}
+	if (cfg.Pin == "") == (cfg.Relay == "") {
+		return fmt.Errorf("%s: set exactly one of 'pin' or 'relay'", path)
+	}
+	if cfg.ActiveLow && cfg.Relay != "" {
+		return fmt.Errorf("%s: 'active_low' applies to 'pin'; configure it on the relay instead", path)
+	}
+	return nil
+}
+
+// target describes what the rule drives, for messages.
+func (cfg *RuleConfig) target() string {
+	if cfg.Relay != "" {
+		return fmt.Sprintf("relay %q", cfg.Relay)
+	}
+	return fmt.Sprintf("pin %q", cfg.Pin)
+}
+
+// holds reports whether the rule holds for value. Once it holds, Above and Below only stop
+// holding past the hysteresis.
+func (cfg *RuleConfig) holds(value float64, holding bool) bool {
+	margin := 0.0
+	if holding {
+		margin = cfg.Hysteresis
+	}
+	return (cfg.Above != nil && value > *cfg.Above-margin) || (cfg.Below != nil && value < *cfg.Below+margin)
+}
+
+// rule is a configured rule and what it last saw and did.
+type rule struct {
+	cfg RuleConfig
+	pin board.GPIOPin
+
+	// Guarded by esp32Rules.mu.
+	holding bool
+	// applied is set once the output was driven for holding, and cleared when driving it
+	// failed so the next evaluation tries again.
+	applied bool
+	value   float64
+	err     error
+}
+
+type esp32Rules struct {
+	resource.Named
+	resource.AlwaysRebuild
+
+	logger   logging.Logger
+	workers  *workerGroup
+	interval time.Duration
+	analogs  analogValueReader
+	relays   RelayController
+
+	mu    sync.Mutex
+	rules []*rule
+	// evaluatedAt is when the rules were last evaluated.
+	evaluatedAt time.Time
+}
+
+func newEsp32Rules(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (resource.Resource, error) {
+	conf, err := resource.NativeConfig[*RulesConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+	b, err := board.FromProvider(deps, conf.Board)
+	if err != nil {
+		return nil, err
+	}
+	intervalMs := conf.IntervalMs
+	if intervalMs == 0 {
+		intervalMs = defaultRuleIntervalMs
+	}
+	s := &esp32Rules{
+		Named:    rawConf.ResourceName().AsNamed(),
+		logger:   logger,
+		workers:  newWorkerGroup(),
+		interval: time.Duration(intervalMs) * time.Millisecond,
+	}
+	for _, cfg := range conf.Rules {
+		r := &rule{cfg: cfg}
+		if _, err = b.AnalogByName(cfg.Analog); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", cfg.Name, err)
+		}
+		if cfg.Pin != "" {
+			if r.pin, err = b.GPIOPinByName(cfg.Pin); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", cfg.Name, err)
+			}
+		}
+		s.rules = append(s.rules, r)
+	}
+	// As for the esp32-relay switch, a board client only offers the relay commands.
+	relays, ok := b.(RelayController)
+	if !ok {
+		relays = commandRelayController{b}
+	}
+	s.relays = relays
+	// The thresholds are compared with the unrounded value in the analog's units, which
+	// Read does not return.
+	analogs, ok := b.(analogValueReader)
+	if !ok {
+		analogs = commandAnalogValueReader{b}
+	}
+	s.analogs = analogs
+	s.workers.start("rules", s.run)
+	return s, nil
+}
+
+// run evaluates the rules every interval until the service is closed.
+func (s *esp32Rules) run() {
+	ctx := s.workers.ctx
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.evaluate(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluate reads each rule's analog, once per reader, and drives the outputs of the rules
+// that started or stopped holding, or whose output was not driven yet. A rule whose
+// reading fails leaves its output as it is.
+func (s *esp32Rules) evaluate(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.interval)
+	defer cancel()
+	type reading struct {
+		value float64
+		err   error
+	}
+	readings := map[string]reading{}
+	for _, r := range s.rules {
+		if _, ok := readings[r.cfg.Analog]; ok {
+			continue
+		}
+		value, err := s.analogs.readAnalogValue(ctx, r.cfg.Analog)
+		readings[r.cfg.Analog] = reading{value, err}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evaluatedAt = time.Now()
+	for _, r := range s.rules {
+		read := readings[r.cfg.Analog]
+		if read.err != nil {
+			if r.err == nil && ctx.Err() == nil {
+				s.logger.Warnf("rule %q failed to read analog %q, leaving %s as it is: %v", r.cfg.Name, r.cfg.Analog, r.cfg.target(), read.err)
+			}
+			r.err = read.err
+			continue
+		}
+		r.value = read.value
+		holding := r.cfg.holds(read.value, r.holding)
+		if holding == r.holding && r.applied {
+			r.err = nil
+			continue
+		}
+		if err := s.drive(ctx, r, holding); err != nil {
+			if r.err == nil && ctx.Err() == nil {
+				s.logger.Warnf("rule %q failed to drive %s: %v", r.cfg.Name, r.cfg.target(), err)
+			}
+			r.err, r.applied = err, false
+			continue
+		}
+		if holding {
+			s.logger.Infof("rule %q holds at %.4g, driving %s", r.cfg.Name, read.value, r.cfg.target())
+		} else {
+			s.logger.Infof("rule %q released at %.4g, releasing %s", r.cfg.Name, read.value, r.cfg.target())
+		}
+		r.holding, r.applied, r.err = holding, true, nil
+	}
+}
+
+// drive sets the rule's output for holding.
+func (s *esp32Rules) drive(ctx context.Context, r *rule, holding bool) error {
+	if r.cfg.Relay != "" {
+		return s.relays.SetRelay(ctx, r.cfg.Relay, holding)
+	}
+	return r.pin.Set(ctx, holding != r.cfg.ActiveLow, nil)
+}
+
+// DoCommand answers status with each rule's last reading and whether it holds.
+//
+//	{"command": "status"}
+func (s *esp32Rules) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["command"] != "status" {
+		return nil, fmt.Errorf("unknown command %v", cmd)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := make([]interface{}, len(s.rules))
+	for i, r := range s.rules {
+		status := map[string]interface{}{
+			"name":    r.cfg.Name,
+			"holding": r.holding,
+			"applied": r.applied,
+			"value":   r.value,
+		}
+		if r.err != nil {
+			status["error"] = r.err.Error()
+		}
+		rules[i] = status
+	}
+	result := map[string]interface{}{"rules": rules}
+	if !s.evaluatedAt.IsZero() {
+		result["evaluated_at"] = s.evaluatedAt.Format(time.RFC3339Nano)
+	}
+	return result, nil
+}
+
+// Close stops evaluating the rules, leaving their outputs as they are.
+func (s *esp32Rules) Close(ctx context.Context) error {
+	s.workers.stop()
+	return nil
+}
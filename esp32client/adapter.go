@@ -0,0 +1,163 @@
+//go:build !nobluetooth
+
+package esp32client
+
+import (
+	"fmt"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// Adapter is the part of a bluetooth adapter DialBLE uses. DefaultAdapter wraps the
+// system adapter; tests can pass their own with WithAdapter.
+type Adapter interface {
+	Enable() error
+	// Scan calls fn for every advertisement until StopScan is called.
+	Scan(fn func(ScanResult)) error
+	StopScan() error
+	Connect(address bluetooth.Address) (Peripheral, error)
+}
+
+// ScanResult is one advertisement seen during a scan.
+type ScanResult struct {
+	Address   bluetooth.Address
+	LocalName string
+	RSSI      int16
+	// ManufacturerData is the manufacturer specific data of the advertisement, by
+	// company ID.
+	ManufacturerData map[uint16][]byte
+}
+
+// Peripheral is a connected BLE device.
+type Peripheral interface {
+	// Characteristic returns the characteristic with the given UUID on any service.
+	Characteristic(uuid string) (Characteristic, error)
+	Disconnect() error
+}
+
+// Characteristic is a GATT characteristic of a connected device.
+type Characteristic interface {
+	Read(p []byte) (int, error)
+	// Write writes p the way the platform's firmware link expects, without a response
+	// where the platform supports it.
+	Write(p []byte) (int, error)
+	// WriteWithResponse writes p and waits for the device to acknowledge it, failing if
+	// the device did not.
+	WriteWithResponse(p []byte) (int, error)
+	EnableNotifications(fn func(buf []byte)) error
+}
+
+// WithAdapter sets the bluetooth adapter BLE clients scan and connect with.
+func WithAdapter(adapter Adapter) Option {
+	return func(o *options) {
+		o.adapter = adapter
+	}
+}
+
+// DefaultAdapter is the system's bluetooth adapter.
+var DefaultAdapter Adapter = systemAdapter{bluetooth.DefaultAdapter}
+
+type systemAdapter struct {
+	adapter *bluetooth.Adapter
+}
+
+// Enable checks that the bluetooth stack is there before enabling the adapter, so hosts
+// without BlueZ get ErrBluetoothUnavailable.
+func (a systemAdapter) Enable() (err error) {
+	if err := checkBluetoothStack(); err != nil {
+		return err
+	}
+	defer recoverBluetooth("enable", &err)
+	if err := a.adapter.Enable(); err != nil {
+		return fmt.Errorf("failed to enable the adapter: %w: %w", ErrBluetoothUnavailable, err)
+	}
+	return nil
+}
+
+// recoverBluetooth turns a panic in the bluetooth stack, e.g. on a half-initialized
+// adapter, into an error instead of taking down the module.
+func recoverBluetooth(op string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("bluetooth %s panicked: %v: %w", op, r, ErrBluetoothUnavailable)
+	}
+}
+
+func (a systemAdapter) Scan(fn func(ScanResult)) (err error) {
+	defer recoverBluetooth("scan", &err)
+	return a.adapter.Scan(func(_ *bluetooth.Adapter, result bluetooth.ScanResult) {
+		scan := ScanResult{Address: result.Address, LocalName: result.LocalName(), RSSI: result.RSSI}
+		if elements := result.ManufacturerData(); len(elements) > 0 {
+			scan.ManufacturerData = make(map[uint16][]byte, len(elements))
+			for _, element := range elements {
+				scan.ManufacturerData[element.CompanyID] = element.Data
+			}
+		}
+		fn(scan)
+	})
+}
+
+func (a systemAdapter) StopScan() (err error) {
+	defer recoverBluetooth("stop scan", &err)
+	return a.adapter.StopScan()
+}
+
+func (a systemAdapter) Connect(address bluetooth.Address) (_ Peripheral, err error) {
+	defer recoverBluetooth("connect", &err)
+	device, err := a.adapter.Connect(address, bluetooth.ConnectionParams{})
+	if err != nil {
+		return nil, err
+	}
+	return systemPeripheral{device}, nil
+}
+
+type systemPeripheral struct {
+	device bluetooth.Device
+}
+
+func (p systemPeripheral) Characteristic(uuid string) (Characteristic, error) {
+	targetUUID, err := bluetooth.ParseUUID(uuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse UUID: %w", err)
+	}
+
+	services, err := p.device.DiscoverServices(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services: %w", err)
+	}
+	for _, service := range services {
+		chars, err := service.DiscoverCharacteristics([]bluetooth.UUID{targetUUID})
+		if err != nil {
+			continue
+		}
+		if len(chars) > 0 {
+			return &systemCharacteristic{char: chars[0], address: p.device.Address}, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find characteristic %s", uuid)
+}
+
+func (p systemPeripheral) Disconnect() error {
+	return p.device.Disconnect()
+}
+
+type systemCharacteristic struct {
+	char bluetooth.DeviceCharacteristic
+	// address is the device's, which BlueZ needs to find the characteristic by.
+	address bluetooth.Address
+}
+
+func (c *systemCharacteristic) Read(p []byte) (int, error) {
+	return c.char.Read(p)
+}
+
+func (c *systemCharacteristic) Write(p []byte) (int, error) {
+	return writeCharacteristic(c.char, p)
+}
+
+func (c *systemCharacteristic) WriteWithResponse(p []byte) (int, error) {
+	return writeCharacteristicWithResponse(c.char, c.address, p)
+}
+
+func (c *systemCharacteristic) EnableNotifications(fn func(buf []byte)) error {
+	return c.char.EnableNotifications(fn)
+}
@@ -0,0 +1,90 @@
+package esp32client
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyAlpha is the weight of each new sample in an endpoint's latency average.
+const latencyAlpha = 0.2
+
+// WithAdaptiveTimeout bounds each HTTP request to multiple times the moving average of
+// its endpoint's latency, kept within min and max, instead of a fixed timeout. Endpoints
+// without a sample yet get max. A request that times out counts as a sample of its
+// deadline, so the deadline of a device that got slower grows back instead of failing
+// every request. It replaces WithRequestTimeout for HTTP requests.
+func WithAdaptiveTimeout(multiple float64, minTimeout, maxTimeout time.Duration) Option {
+	return func(o *options) {
+		o.adaptive = &adaptiveTimeout{multiple: multiple, min: minTimeout, max: maxTimeout, averages: map[string]time.Duration{}}
+	}
+}
+
+// adaptiveTimeout tracks the latency average of each endpoint.
+type adaptiveTimeout struct {
+	multiple float64
+	min, max time.Duration
+
+	mu       sync.Mutex
+	averages map[string]time.Duration
+}
+
+// endpointKey is the method and path of a request without its query, e.g. "GET /info".
+func endpointKey(method, path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	return method + " " + path
+}
+
+// timeout returns the deadline for the next request to endpoint.
+func (a *adaptiveTimeout) timeout(endpoint string) time.Duration {
+	a.mu.Lock()
+	average, ok := a.averages[endpoint]
+	a.mu.Unlock()
+	if !ok {
+		return a.max
+	}
+	return a.bound(time.Duration(float64(average) * a.multiple))
+}
+
+func (a *adaptiveTimeout) bound(timeout time.Duration) time.Duration {
+	return min(max(timeout, a.min), a.max)
+}
+
+// observe adds a latency sample for endpoint.
+func (a *adaptiveTimeout) observe(endpoint string, latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	average, ok := a.averages[endpoint]
+	if !ok {
+		a.averages[endpoint] = latency
+		return
+	}
+	a.averages[endpoint] = time.Duration(latencyAlpha*float64(latency) + (1-latencyAlpha)*float64(average))
+}
+
+// EndpointLatency is the latency average of an endpoint and the deadline it gives its
+// requests.
+type EndpointLatency struct {
+	Endpoint string
+	Average  time.Duration
+	Timeout  time.Duration
+}
+
+// AdaptiveTimeouts returns the endpoints the client has latency samples for, sorted, and
+// nil unless it was created with WithAdaptiveTimeout.
+func (c *HTTPClient) AdaptiveTimeouts() []EndpointLatency {
+	a := c.opts.adaptive
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	latencies := make([]EndpointLatency, 0, len(a.averages))
+	for endpoint, average := range a.averages {
+		latencies = append(latencies, EndpointLatency{Endpoint: endpoint, Average: average,
+			Timeout: a.bound(time.Duration(float64(average) * a.multiple))})
+	}
+	a.mu.Unlock()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i].Endpoint < latencies[j].Endpoint })
+	return latencies
+}
@@ -0,0 +1,43 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// AnalogConfig sets up how the firmware samples an analog pin.
+type AnalogConfig struct {
+	PinNum int `json:"pin_num"`
+	// AttenuationDB selects the ADC input range: 0, 2.5, 6 or 11 dB. Nil keeps the
+	// firmware default.
+	AttenuationDB *float64 `json:"attenuation_db,omitempty"`
+	// AverageOverMillis and SamplesPerSecond, if set, make the firmware sample the pin in
+	// the background and answer reads with the average of the window.
+	AverageOverMillis int `json:"average_over_ms,omitempty"`
+	SamplesPerSecond  int `json:"samples_per_sec,omitempty"`
+}
+
+// ConfigureAnalog applies cfg to an analog pin, replacing its previous configuration.
+func ConfigureAnalog(ctx context.Context, c Client, cfg AnalogConfig) error {
+	if err := c.Call(ctx, http.MethodPost, "/analogs", cfg, nil); err != nil {
+		return fmt.Errorf("failed to configure analog pin %d: %w", cfg.PinNum, err)
+	}
+	return nil
+}
+
+// AnalogStream asks the firmware to push samples of an analog pin RateHz times a second.
+// A RateHz of zero stops the stream.
+type AnalogStream struct {
+	PinNum int     `json:"pin_num"`
+	RateHz float64 `json:"rate_hz"`
+}
+
+// AnalogSample is a reading the firmware pushed for a streamed analog pin.
+type AnalogSample struct {
+	PinNum int     `json:"pin_num"`
+	State  float64 `json:"state"`
+	// TimestampUs is the device clock when the pin was sampled, zero on firmware without
+	// one.
+	TimestampUs uint64 `json:"timestamp_us,omitempty"`
+}
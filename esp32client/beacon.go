@@ -0,0 +1,61 @@
+package esp32client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// EspressifCompanyID is the Bluetooth SIG company ID the firmware advertises its beacon
+// payload under, in the manufacturer specific data of its advertisements.
+const EspressifCompanyID = 0x02E5
+
+const (
+	// beaconVersion is the payload layout ParseBeacon reads. Later versions append
+	// fields, so their payloads still parse.
+	beaconVersion = 1
+	// beaconSize is a version 1 payload: version, battery percent, uptime in seconds
+	// and output levels, little-endian.
+	beaconSize = 10
+	// beaconBatteryUnknown is sent by firmware that does not measure its battery.
+	beaconBatteryUnknown = 0xff
+)
+
+// Beacon is the telemetry the firmware broadcasts in its advertisements, which can be
+// read without connecting to the device.
+type Beacon struct {
+	// BatteryPercent is -1 if the firmware does not measure its battery.
+	BatteryPercent int
+	Uptime         time.Duration
+	// Outputs has bit n set if GPIOn was last driven high, for GPIO0-31.
+	Outputs uint32
+	// RSSI and Seen describe the advertisement the beacon was read from.
+	RSSI int16
+	Seen time.Time
+}
+
+// ParseBeacon decodes the manufacturer data the firmware advertises under
+// EspressifCompanyID.
+func ParseBeacon(data []byte) (Beacon, error) {
+	if len(data) == 0 {
+		return Beacon{}, fmt.Errorf("empty beacon payload")
+	}
+	if data[0] < beaconVersion {
+		return Beacon{}, fmt.Errorf("unknown beacon version %d", data[0])
+	}
+	if len(data) < beaconSize {
+		return Beacon{}, fmt.Errorf("beacon payload is %d bytes, want at least %d", len(data), beaconSize)
+	}
+	beacon := Beacon{
+		BatteryPercent: int(data[1]),
+		Uptime:         time.Duration(binary.LittleEndian.Uint32(data[2:6])) * time.Second,
+		Outputs:        binary.LittleEndian.Uint32(data[6:10]),
+	}
+	switch {
+	case data[1] == beaconBatteryUnknown:
+		beacon.BatteryPercent = -1
+	case data[1] > 100:
+		return Beacon{}, fmt.Errorf("beacon battery percent %d is over 100", data[1])
+	}
+	return beacon, nil
+}
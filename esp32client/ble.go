@@ -0,0 +1,373 @@
+//go:build !nobluetooth
+
+package esp32client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriteCharacteristicUUID is the characteristic the firmware accepts pin writes on.
+const WriteCharacteristicUUID = "c79b2ca7-f39d-4060-8168-816fa26737b7"
+
+// ReadCharacteristicUUID is the characteristic the firmware returns responses on, either
+// notified with the ID of the request they answer or, on older firmware, as the value of
+// the last pin_reads request. The oldest firmware does not expose it.
+const ReadCharacteristicUUID = "c79b2ca7-f39d-4060-8168-816fa26737b8"
+
+// maxReadSize bounds a single characteristic read, the largest attribute value BLE allows.
+const maxReadSize = 512
+
+// scanTimeout is how long DialBLE scans for the named device before giving up.
+const scanTimeout = 10 * time.Second
+
+// stopScanRetryInterval is how often StopScan is retried until the scan has ended.
+const stopScanRetryInterval = 100 * time.Millisecond
+
+// BLEClient talks to the firmware over a BLE GATT connection.
+type BLEClient struct {
+	device    Peripheral
+	writeChar Characteristic
+	readChar  Characteristic
+	opts      options
+	protocol  *protocol
+
+	// mu serializes characteristic writes, and reads without notifications.
+	mu sync.Mutex
+
+	// pending is nil unless the firmware notifies responses.
+	pendingMu sync.Mutex
+	pending   map[uint32]chan []byte
+	nextID    uint32
+
+	// streams are the callbacks of streamed analog pins.
+	streamMu sync.Mutex
+	streams  map[int]func(AnalogSample)
+
+	metrics *bleMetrics
+}
+
+// DialBLE scans for a device advertising serverName (case-insensitive), connects to it,
+// and discovers the characteristics used by the firmware.
+func DialBLE(ctx context.Context, serverName string, opts ...Option) (*BLEClient, error) {
+	o := newOptions(opts)
+	metrics := bleMetricsFor(serverName)
+	client, err := dialBLE(ctx, serverName, o, metrics)
+	if err != nil {
+		metrics.dialFailed()
+		return nil, err
+	}
+	return client, nil
+}
+
+func dialBLE(ctx context.Context, serverName string, o options, metrics *bleMetrics) (*BLEClient, error) {
+	logger := o.logger
+
+	adapter := o.adapter
+	err := adapter.Enable()
+	if err != nil {
+		logger.Errorf("Failed to enable Bluetooth adapter: %v", err)
+		return nil, err
+	}
+
+	start := time.Now()
+	result, err := scan(ctx, adapter, serverName, logger)
+	if err != nil {
+		logger.Errorf("Failed to find device: %v", err)
+		return nil, err
+	}
+	scanned := time.Now()
+	logger.Infof("Found target device: %s", result.LocalName)
+	logger.Infof("Address: %s", result.Address.String())
+	logger.Infof("Signal strength: %d dBm", result.RSSI)
+
+	// Connect to the device
+	logger.Infof("Connecting...")
+
+	device, err := managerFor(adapter).connect(result.Address)
+	if err != nil {
+		logger.Errorf("Failed to connect: %v", err)
+		return nil, err
+	}
+	connected := time.Now()
+
+	if o.security != SecurityNone {
+		if err := pairDevice(result.Address, o.security, o.passkey, logger); err != nil {
+			logger.Errorf("Failed to pair: %v", err)
+			if disconnectErr := device.Disconnect(); disconnectErr != nil {
+				logger.Errorf("Failed to disconnect: %v", disconnectErr)
+			}
+			return nil, err
+		}
+	}
+
+	writeChar, err := device.Characteristic(WriteCharacteristicUUID)
+	if err != nil {
+		logger.Errorf("Failed to find characteristic: %v", err)
+		if disconnectErr := device.Disconnect(); disconnectErr != nil {
+			logger.Errorf("Failed to disconnect: %v", disconnectErr)
+		}
+		return nil, err
+	}
+
+	client := &BLEClient{
+		device:    device,
+		writeChar: writeChar,
+		opts:      o,
+		protocol:  newProtocol(o),
+		metrics:   metrics,
+	}
+	if readChar, err := device.Characteristic(ReadCharacteristicUUID); err == nil {
+		client.readChar = readChar
+		if err := client.enableResponses(); err != nil {
+			client.pending = nil
+			logger.Infof("Firmware does not notify responses, requests cannot be confirmed: %v", err)
+		}
+	} else {
+		logger.Infof("Firmware does not expose a read characteristic, pin reads are unavailable: %v", err)
+	}
+	metrics.dialed(scanned.Sub(start), connected.Sub(scanned), time.Since(connected))
+	return client, nil
+}
+
+// scan returns the first device advertising serverName (case-insensitive). It gives up
+// after scanTimeout or when ctx is done. The scan is shared with every other client and
+// beacon watcher on the adapter, and is stopped by the time scan returns if no one else
+// needs it, so the adapter is free for the next attempt.
+func scan(ctx context.Context, adapter Adapter, serverName string, logger Logger) (ScanResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	found := make(chan ScanResult, 1)
+	watcher := managerFor(adapter).watch(func(result ScanResult) {
+		deviceName := result.LocalName
+
+		// Print all discovered devices for visibility
+		if deviceName != "" {
+			logger.Infof("Found: %s (Address: %s, RSSI: %d dBm)",
+				deviceName, result.Address.String(), result.RSSI)
+		}
+
+		if strings.EqualFold(deviceName, serverName) {
+			select {
+			case found <- result:
+			default:
+			}
+		}
+	}, logger)
+	defer watcher.stop()
+
+	select {
+	case result := <-found:
+		return result, nil
+	case err := <-watcher.ended:
+		// The scan may have found the device just before it ended.
+		select {
+		case result := <-found:
+			return result, nil
+		default:
+		}
+		return ScanResult{}, fmt.Errorf("scan failed: %w", err)
+	case <-ctx.Done():
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ScanResult{}, fmt.Errorf("timeout waiting for device %q", serverName)
+	}
+	return ScanResult{}, ctx.Err()
+}
+
+// ReadPins writes a pin_reads request and reads the response back from the read
+// characteristic, using the same JSON payloads as the HTTP API.
+func (c *BLEClient) ReadPins(ctx context.Context, pins []int) ([]PinRead, error) {
+	if c.readChar == nil {
+		return nil, fmt.Errorf("read pins over BLE: %w", ErrFirmwareTooOld)
+	}
+	reads, err := c.protocol.readPins(pins, func(body map[string]interface{}, response *readsResponse) error {
+		body = addExtra(ctx, body)
+		if c.pending != nil {
+			return c.request(ctx, body, response)
+		}
+		return c.readUnconfirmed(ctx, body, response)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pins: %w", err)
+	}
+	return reads, nil
+}
+
+// readUnconfirmed writes body and reads the response from the read characteristic, for
+// firmware that does not notify responses.
+func (c *BLEClient) readUnconfirmed(ctx context.Context, body map[string]interface{}, response *readsResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.write(ctx, body); err != nil {
+		return err
+	}
+
+	buf := make([]byte, maxReadSize)
+	n, err := c.readChar.Read(buf)
+	c.metrics.read(err)
+	if err != nil {
+		return fmt.Errorf("failed to read characteristic: %w", transportError(err))
+	}
+	if err := json.Unmarshal(buf[:n], response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	c.opts.logger.Debugf("response: %+v", response)
+	return nil
+}
+
+// WritePins applies all writes in a single characteristic write. Firmware that notifies
+// responses reports rejected pins as a *PartialWriteError.
+func (c *BLEClient) WritePins(ctx context.Context, writes []PinWrite) error {
+	body := addExtra(ctx, map[string]interface{}{
+		"pin_writes": writes,
+	})
+	if err := c.send(ctx, body); err != nil {
+		return fmt.Errorf("failed to write pins: %w", err)
+	}
+	return nil
+}
+
+// Transaction applies all writes atomically in one GPIO write cycle. The writes are sent
+// under their own key so firmware without transaction support ignores them instead of
+// applying them one by one.
+func (c *BLEClient) Transaction(ctx context.Context, writes []PinWrite) error {
+	body := addExtra(ctx, map[string]interface{}{
+		"transaction": writes,
+	})
+	if err := c.send(ctx, body); err != nil {
+		return fmt.Errorf("failed to apply transaction: %w", err)
+	}
+	return nil
+}
+
+// SetPWMFreqs applies all PWM frequencies in a single characteristic write.
+func (c *BLEClient) SetPWMFreqs(ctx context.Context, freqs []PinFreq) error {
+	body := addExtra(ctx, map[string]interface{}{
+		"pin_freqs": freqs,
+	})
+	if err := c.send(ctx, body); err != nil {
+		return fmt.Errorf("failed to set pwm frequencies: %w", err)
+	}
+	return nil
+}
+
+// WriteRaw writes data to the write characteristic as is, for firmware commands this
+// package does not know. It does not wait for the firmware to answer.
+func (c *BLEClient) WriteRaw(ctx context.Context, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.logger.Debugf("raw write: %s", data)
+	err := c.writeCharacteristic(ctx, data)
+	c.metrics.wrote(err)
+	if err != nil {
+		return fmt.Errorf("failed to write characteristic: %w", transportError(err))
+	}
+	return nil
+}
+
+// send writes body and, if the firmware notifies responses, waits for it to confirm the
+// request was applied.
+func (c *BLEClient) send(ctx context.Context, body map[string]interface{}) error {
+	if c.pending != nil {
+		var response batchResponse
+		if err := c.request(ctx, body, &response); err != nil {
+			return err
+		}
+		return response.err()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.write(ctx, body)
+}
+
+// write marshals body and writes it to the write characteristic, in the write mode ctx
+// asks for. c.mu must be held.
+func (c *BLEClient) write(ctx context.Context, body map[string]interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal body: %w", err)
+	}
+	if r, ok := body["body"].(redactor); ok {
+		c.opts.logger.Debugf("jsonBody: {\"id\":%v,\"method\":%q,\"path\":%q,\"body\":%s}",
+			body["id"], body["method"], body["path"], r.redacted())
+	} else {
+		c.opts.logger.Debugf("jsonBody: %s", string(jsonBody))
+	}
+
+	err = c.writeCharacteristic(ctx, jsonBody)
+	c.metrics.wrote(err)
+	if err != nil {
+		return fmt.Errorf("failed to write characteristic: %w", transportError(err))
+	}
+	return nil
+}
+
+// writeCharacteristic writes data with response if ctx or the client asks for reliable
+// writes.
+func (c *BLEClient) writeCharacteristic(ctx context.Context, data []byte) error {
+	var err error
+	if writeModeFor(ctx, c.opts.writeMode) == WriteReliable {
+		_, err = c.writeChar.WriteWithResponse(data)
+	} else {
+		_, err = c.writeChar.Write(data)
+	}
+	return err
+}
+
+// Ping exchanges the smallest request the firmware allows to keep the connection from
+// idling out: a ping it answers if it notifies responses, otherwise a read of the read
+// characteristic, or a ping write that it ignores on the oldest firmware. An error means
+// the connection is most likely gone.
+func (c *BLEClient) Ping(ctx context.Context) error {
+	body := map[string]interface{}{"ping": true}
+	if c.pending != nil {
+		if err := c.request(ctx, body, nil); err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.readChar != nil {
+		buf := make([]byte, maxReadSize)
+		_, err := c.readChar.Read(buf)
+		c.metrics.read(err)
+		if err != nil {
+			return fmt.Errorf("ping: failed to read characteristic: %w", transportError(err))
+		}
+		return nil
+	}
+	if err := c.write(ctx, body); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	return nil
+}
+
+// Subscribe calls fn every time the state of pin changes, until ctx is done or the
+// returned func is called.
+func (c *BLEClient) Subscribe(ctx context.Context, pin int, fn func(PinRead)) (func(), error) {
+	return pollSubscribe(ctx, c, c.opts.logger, c.opts.subscribeInterval, pin, fn)
+}
+
+// Metrics returns the health counters of the link to the device, across every
+// connection to it.
+func (c *BLEClient) Metrics() BLEMetrics {
+	return c.metrics.snapshot()
+}
+
+// Close disconnects from the device.
+func (c *BLEClient) Close() error {
+	return c.device.Disconnect()
+}
+
+// Events is not supported over BLE.
+func (c *BLEClient) Events(ctx context.Context, fn func(Event)) error {
+	return fmt.Errorf("events over BLE: %w", ErrNotSupported)
+}
@@ -0,0 +1,47 @@
+//go:build !nobluetooth
+
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WatchBeacons scans for advertisements from serverName (case-insensitive) and calls fn
+// with the beacon each one carries, until ctx is done. Advertisements without a valid
+// beacon payload are skipped. It never connects, so the device can stay in its low power
+// advertising mode. The scan is shared with DialBLE and other watchers on the adapter.
+func WatchBeacons(ctx context.Context, serverName string, fn func(Beacon), opts ...Option) error {
+	o := newOptions(opts)
+	logger := o.logger
+	if err := o.adapter.Enable(); err != nil {
+		return fmt.Errorf("failed to enable bluetooth adapter: %w", err)
+	}
+
+	watcher := managerFor(o.adapter).watch(func(result ScanResult) {
+		if !strings.EqualFold(result.LocalName, serverName) {
+			return
+		}
+		data, ok := result.ManufacturerData[EspressifCompanyID]
+		if !ok {
+			return
+		}
+		beacon, err := ParseBeacon(data)
+		if err != nil {
+			logger.Debugf("ignoring advertisement from %s: %v", result.Address.String(), err)
+			return
+		}
+		beacon.RSSI, beacon.Seen = result.RSSI, time.Now()
+		fn(beacon)
+	}, logger)
+	defer watcher.stop()
+
+	select {
+	case err := <-watcher.ended:
+		return fmt.Errorf("scan failed: %w", err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,38 @@
+//go:build nobluetooth
+
+package esp32client
+
+import (
+	"context"
+	"fmt"
+)
+
+// ErrBluetoothDisabled is returned by DialBLE in builds with the nobluetooth tag.
+var ErrBluetoothDisabled = fmt.Errorf("built with the nobluetooth tag: %w", ErrNotSupported)
+
+// Adapter is a placeholder in builds without bluetooth.
+type Adapter interface{}
+
+// DefaultAdapter is nil in builds without bluetooth.
+var DefaultAdapter Adapter
+
+// BLEClient cannot be created in builds without bluetooth. It exists so code that checks
+// for a BLE transport still compiles.
+type BLEClient struct {
+	Client
+}
+
+// DialBLE always fails in builds without bluetooth.
+func DialBLE(ctx context.Context, serverName string, opts ...Option) (*BLEClient, error) {
+	return nil, fmt.Errorf("connect to %q over BLE: %w", serverName, ErrBluetoothDisabled)
+}
+
+// WatchBeacons always fails in builds without bluetooth.
+func WatchBeacons(ctx context.Context, serverName string, fn func(Beacon), opts ...Option) error {
+	return fmt.Errorf("watch beacons from %q: %w", serverName, ErrBluetoothDisabled)
+}
+
+// WriteRaw always fails in builds without bluetooth.
+func (c *BLEClient) WriteRaw(ctx context.Context, data []byte) error {
+	return ErrBluetoothDisabled
+}
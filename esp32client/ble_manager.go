@@ -0,0 +1,188 @@
+//go:build !nobluetooth
+
+package esp32client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// scanResultTTL is how long an advertisement seen by a shared scan is replayed to scans
+// that join later, so a device that advertised just before is found at once.
+const scanResultTTL = 10 * time.Second
+
+// errScanEnded is reported to watchers when the adapter stops scanning on its own.
+var errScanEnded = errors.New("scan ended unexpectedly")
+
+// managers holds the bleManager of every adapter in use. Adapters are compared with ==,
+// so an Adapter implementation must be comparable, e.g. a pointer.
+var managers = struct {
+	mu sync.Mutex
+	m  map[Adapter]*bleManager
+}{m: map[Adapter]*bleManager{}}
+
+// bleManager shares one adapter between every BLE client and beacon watcher in the
+// process. An adapter runs one scan at a time, so the manager runs a single scan for all
+// of them, fans every advertisement out to each, and stops it when the last one leaves.
+// Connections are opened one at a time, since most stacks cannot connect to two devices
+// at once.
+type bleManager struct {
+	adapter Adapter
+
+	// scanMu serializes starting and stopping the scan.
+	scanMu sync.Mutex
+	// scanDone is closed when the current scan has ended, and nil before the first.
+	scanDone chan struct{}
+
+	mu       sync.Mutex
+	watchers map[*scanWatcher]struct{}
+	recent   map[bluetooth.Address]seenResult
+
+	connectMu sync.Mutex
+}
+
+type seenResult struct {
+	result ScanResult
+	at     time.Time
+}
+
+// scanWatcher receives the advertisements of a shared scan until stopped.
+type scanWatcher struct {
+	manager *bleManager
+	fn      func(ScanResult)
+	logger  Logger
+	// ended receives the error of a scan that ended while the watcher was running.
+	ended chan error
+}
+
+// managerFor returns the manager of adapter, creating it on first use.
+func managerFor(adapter Adapter) *bleManager {
+	managers.mu.Lock()
+	defer managers.mu.Unlock()
+	m, ok := managers.m[adapter]
+	if !ok {
+		m = &bleManager{
+			adapter:  adapter,
+			watchers: map[*scanWatcher]struct{}{},
+			recent:   map[bluetooth.Address]seenResult{},
+		}
+		managers.m[adapter] = m
+	}
+	return m
+}
+
+// watch calls fn for every advertisement seen by the shared scan, starting it if no one
+// else is scanning. Advertisements seen in the last scanResultTTL are replayed to fn
+// first. fn may be called from several goroutines until the watcher is stopped.
+func (m *bleManager) watch(fn func(ScanResult), logger Logger) *scanWatcher {
+	w := &scanWatcher{manager: m, fn: fn, logger: logger, ended: make(chan error, 1)}
+
+	m.scanMu.Lock()
+	defer m.scanMu.Unlock()
+	m.mu.Lock()
+	m.watchers[w] = struct{}{}
+	var replay []ScanResult
+	for address, seen := range m.recent {
+		if time.Since(seen.at) > scanResultTTL {
+			delete(m.recent, address)
+			continue
+		}
+		replay = append(replay, seen.result)
+	}
+	m.mu.Unlock()
+
+	for _, result := range replay {
+		fn(result)
+	}
+	if !m.scanning() {
+		m.startScan(logger)
+	}
+	return w
+}
+
+// scanning reports whether a scan is running. m.scanMu must be held.
+func (m *bleManager) scanning() bool {
+	if m.scanDone == nil {
+		return false
+	}
+	select {
+	case <-m.scanDone:
+		return false
+	default:
+		return true
+	}
+}
+
+// startScan starts the shared scan. m.scanMu must be held.
+func (m *bleManager) startScan(logger Logger) {
+	done := make(chan struct{})
+	m.scanDone = done
+	go func() {
+		defer close(done)
+		err := m.adapter.Scan(m.dispatch)
+		if err == nil {
+			err = errScanEnded
+		}
+		// Watchers still running lost their scan; stopped ones no longer listen.
+		m.mu.Lock()
+		watchers := m.watchers
+		m.watchers = map[*scanWatcher]struct{}{}
+		m.mu.Unlock()
+		if len(watchers) > 0 {
+			logger.Debugf("shared scan ended: %v", err)
+		}
+		for w := range watchers {
+			w.ended <- err
+		}
+	}()
+}
+
+// dispatch passes an advertisement to every watcher.
+func (m *bleManager) dispatch(result ScanResult) {
+	m.mu.Lock()
+	m.recent[result.Address] = seenResult{result: result, at: time.Now()}
+	watchers := make([]*scanWatcher, 0, len(m.watchers))
+	for w := range m.watchers {
+		watchers = append(watchers, w)
+	}
+	m.mu.Unlock()
+	for _, w := range watchers {
+		w.fn(result)
+	}
+}
+
+// stop removes the watcher. The last watcher to stop ends the scan and waits for it to
+// end, so the adapter is idle once stop returns. stop must not be called from fn.
+func (w *scanWatcher) stop() {
+	m := w.manager
+	m.scanMu.Lock()
+	defer m.scanMu.Unlock()
+	m.mu.Lock()
+	delete(m.watchers, w)
+	last := len(m.watchers) == 0
+	m.mu.Unlock()
+	if !last || !m.scanning() {
+		return
+	}
+	for {
+		if err := m.adapter.StopScan(); err != nil {
+			w.logger.Debugf("failed to stop scan: %v", err)
+		}
+		select {
+		case <-m.scanDone:
+			return
+		case <-time.After(stopScanRetryInterval):
+		}
+	}
+}
+
+// connect connects to address, waiting for any other connection attempt on the adapter
+// to finish first.
+func (m *bleManager) connect(address bluetooth.Address) (Peripheral, error) {
+	m.connectMu.Lock()
+	defer m.connectMu.Unlock()
+	return m.adapter.Connect(address)
+}
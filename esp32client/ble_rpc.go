@@ -0,0 +1,159 @@
+//go:build !nobluetooth
+
+package esp32client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// bleResponseTimeout bounds how long a correlated request waits for its response when
+// neither the caller's context nor WithRequestTimeout set a deadline. A request that
+// times out was most likely dropped.
+const bleResponseTimeout = 5 * time.Second
+
+// bleResponse is the envelope of every response notified on the read characteristic.
+// Payload fields, such as pin_reads, sit next to these.
+type bleResponse struct {
+	ID     uint32 `json:"id"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bleNotification is a notification on the read characteristic: a response, or samples
+// of streamed analog pins, which answer no request.
+type bleNotification struct {
+	bleResponse
+	AnalogSamples []AnalogSample `json:"analog_samples,omitempty"`
+}
+
+// enableResponses subscribes to notifications on the read characteristic. Once enabled,
+// every request carries an "id" that the firmware echoes in its response, so several
+// requests can be in flight and each caller gets its own response or error.
+func (c *BLEClient) enableResponses() error {
+	c.pending = map[uint32]chan []byte{}
+	return c.readChar.EnableNotifications(c.handleNotification)
+}
+
+func (c *BLEClient) handleNotification(buf []byte) {
+	var response bleNotification
+	if err := json.Unmarshal(buf, &response); err != nil {
+		c.opts.logger.Debugf("ignoring malformed notification %q: %v", buf, err)
+		return
+	}
+	if response.ID == 0 && len(response.AnalogSamples) > 0 {
+		c.dispatchSamples(response.AnalogSamples)
+		return
+	}
+	c.pendingMu.Lock()
+	ch, ok := c.pending[response.ID]
+	delete(c.pending, response.ID)
+	c.pendingMu.Unlock()
+	if !ok {
+		c.opts.logger.Debugf("ignoring response to unknown or abandoned request %d", response.ID)
+		return
+	}
+	// The buffer may be reused once the callback returns.
+	ch <- append([]byte(nil), buf...)
+}
+
+// request sends body with a new request ID and decodes the matching response into out
+// (if non-nil). It requires notifications to be enabled.
+func (c *BLEClient) request(ctx context.Context, body map[string]interface{}, out interface{}) error {
+	if c.opts.requestTimeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, c.opts.requestTimeout)
+		defer cancel()
+	} else if _, ok := ctx.Deadline(); !ok {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, bleResponseTimeout)
+		defer cancel()
+	}
+
+	ch := make(chan []byte, 1)
+	c.pendingMu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
+
+	body["id"] = id
+	c.mu.Lock()
+	err := c.write(ctx, body)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	select {
+	case buf = <-ch:
+	case <-ctx.Done():
+		return fmt.Errorf("no response to request %d, it may have been dropped: %w", id, transportError(ctx.Err()))
+	}
+	c.opts.logger.Debugf("response: %s", buf)
+
+	var response bleResponse
+	if err := json.Unmarshal(buf, &response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	switch {
+	case response.Status == http.StatusNotFound:
+		return fmt.Errorf("request %d: %s: %w", id, response.Error, ErrFirmwareTooOld)
+	case response.Status == http.StatusLocked:
+		return fmt.Errorf("request %d: the device is leased to another host: %w", id, ErrBoardBusy)
+	case response.Error != "":
+		return fmt.Errorf("request %d failed on the device: %s", id, response.Error)
+	case response.Status != 0 && response.Status != http.StatusOK && response.Status != http.StatusMultiStatus:
+		return fmt.Errorf("request %d: unexpected status: %d", id, response.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(buf, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// Call sends a request for the endpoint at path, the same endpoints the HTTP API serves.
+// It needs firmware that answers requests on the read characteristic.
+func (c *BLEClient) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	if c.pending == nil {
+		return fmt.Errorf("%s %s over BLE: %w", method, path, ErrFirmwareTooOld)
+	}
+	request := map[string]interface{}{"method": method, "path": path}
+	if body = addCallExtra(ctx, method, body); body != nil {
+		request["body"] = body
+	}
+	var response struct {
+		Body json.RawMessage `json:"body"`
+	}
+	if err := c.request(ctx, request, &response); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if out == nil || len(response.Body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Body, out); err != nil {
+		return fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+// Info returns identifying information about the device.
+func (c *BLEClient) Info(ctx context.Context) (Info, error) {
+	var info Info
+	if err := c.Call(ctx, http.MethodGet, "/info", nil, &info); err != nil {
+		return Info{}, fmt.Errorf("failed to get info: %w", err)
+	}
+	return info, nil
+}
@@ -0,0 +1,56 @@
+//go:build !nobluetooth
+
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// StreamAnalog calls fn with every sample of pin the firmware notifies, asking it to
+// sample the pin rateHz times a second, so readings do not each cost a round trip over
+// GATT. Calling it again for the same pin replaces fn and resends the request, e.g. after
+// the device rebooted and forgot it. fn is called from the notification handler and must
+// not block. It needs firmware that notifies responses.
+func (c *BLEClient) StreamAnalog(ctx context.Context, pin int, rateHz float64, fn func(AnalogSample)) error {
+	c.streamMu.Lock()
+	if c.streams == nil {
+		c.streams = map[int]func(AnalogSample){}
+	}
+	c.streams[pin] = fn
+	c.streamMu.Unlock()
+	if err := c.Call(ctx, http.MethodPost, "/analogs/stream", AnalogStream{PinNum: pin, RateHz: rateHz}, nil); err != nil {
+		c.streamMu.Lock()
+		delete(c.streams, pin)
+		c.streamMu.Unlock()
+		return fmt.Errorf("failed to stream analog pin %d: %w", pin, err)
+	}
+	return nil
+}
+
+// StopAnalogStream asks the firmware to stop notifying samples of pin. Samples already
+// in flight are dropped.
+func (c *BLEClient) StopAnalogStream(ctx context.Context, pin int) error {
+	c.streamMu.Lock()
+	delete(c.streams, pin)
+	c.streamMu.Unlock()
+	if err := c.Call(ctx, http.MethodPost, "/analogs/stream", AnalogStream{PinNum: pin}, nil); err != nil {
+		return fmt.Errorf("failed to stop streaming analog pin %d: %w", pin, err)
+	}
+	return nil
+}
+
+// dispatchSamples hands notified samples to their streams.
+func (c *BLEClient) dispatchSamples(samples []AnalogSample) {
+	for _, sample := range samples {
+		c.streamMu.Lock()
+		fn := c.streams[sample.PinNum]
+		c.streamMu.Unlock()
+		if fn == nil {
+			c.opts.logger.Debugf("ignoring sample of analog pin %d, which is not streamed", sample.PinNum)
+			continue
+		}
+		fn(sample)
+	}
+}
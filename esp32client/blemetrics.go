@@ -0,0 +1,164 @@
+package esp32client
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BLEMetrics are the health counters of the BLE link to one device. They are kept for
+// the life of the process, across connections, so a device whose link degrades shows it
+// before it stops answering.
+type BLEMetrics struct {
+	// Device is the name the device advertises.
+	Device string
+	// Dials counts the connections made and DialFailures the attempts that failed.
+	Dials        int64
+	DialFailures int64
+	// LastScan, LastConnect and LastDiscovery are how long the steps of the last
+	// connection took: finding the advertisement, connecting and discovering the
+	// characteristics. AvgDial is the average of their sum over every connection.
+	LastScan      time.Duration
+	LastConnect   time.Duration
+	LastDiscovery time.Duration
+	AvgDial       time.Duration
+	// Disconnects counts the times the link was lost, and LastReconnect how long it took
+	// to come back the last time.
+	Disconnects   int64
+	LastReconnect time.Duration
+	// Writes counts characteristic writes and WriteFailures the ones that failed.
+	Writes        int64
+	WriteFailures int64
+}
+
+// WriteFailureRate is the fraction of writes that failed, 0 before the first write.
+func (m BLEMetrics) WriteFailureRate() float64 {
+	if m.Writes == 0 {
+		return 0
+	}
+	return float64(m.WriteFailures) / float64(m.Writes)
+}
+
+// bleMetrics accumulates the BLEMetrics of a device.
+type bleMetrics struct {
+	mu        sync.Mutex
+	m         BLEMetrics
+	totalDial time.Duration
+	// up is set while the link works, and lostAt is when it was last lost.
+	up     bool
+	lostAt time.Time
+}
+
+// bleDeviceMetrics holds the metrics of every device dialed, by lower-case name, since
+// DialBLE matches names case-insensitively.
+var bleDeviceMetrics = struct {
+	mu sync.Mutex
+	m  map[string]*bleMetrics
+}{m: map[string]*bleMetrics{}}
+
+// bleMetricsFor returns the metrics of the device advertising serverName, creating them
+// on first use.
+func bleMetricsFor(serverName string) *bleMetrics {
+	key := strings.ToLower(serverName)
+	bleDeviceMetrics.mu.Lock()
+	defer bleDeviceMetrics.mu.Unlock()
+	m, ok := bleDeviceMetrics.m[key]
+	if !ok {
+		m = &bleMetrics{m: BLEMetrics{Device: serverName}}
+		bleDeviceMetrics.m[key] = m
+	}
+	return m
+}
+
+// BLEDeviceMetrics returns the metrics of the device advertising serverName, and false if
+// it was never dialed.
+func BLEDeviceMetrics(serverName string) (BLEMetrics, bool) {
+	bleDeviceMetrics.mu.Lock()
+	m, ok := bleDeviceMetrics.m[strings.ToLower(serverName)]
+	bleDeviceMetrics.mu.Unlock()
+	if !ok {
+		return BLEMetrics{}, false
+	}
+	return m.snapshot(), true
+}
+
+// AllBLEMetrics returns the metrics of every device dialed, sorted by name.
+func AllBLEMetrics() []BLEMetrics {
+	bleDeviceMetrics.mu.Lock()
+	all := make([]*bleMetrics, 0, len(bleDeviceMetrics.m))
+	for _, m := range bleDeviceMetrics.m {
+		all = append(all, m)
+	}
+	bleDeviceMetrics.mu.Unlock()
+	metrics := make([]BLEMetrics, len(all))
+	for i, m := range all {
+		metrics[i] = m.snapshot()
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Device < metrics[j].Device })
+	return metrics
+}
+
+func (m *bleMetrics) snapshot() BLEMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m
+}
+
+// dialed records a connection whose steps took scan, connect and discovery.
+func (m *bleMetrics) dialed(scan, connect, discovery time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Dials++
+	m.m.LastScan, m.m.LastConnect, m.m.LastDiscovery = scan, connect, discovery
+	m.totalDial += scan + connect + discovery
+	m.m.AvgDial = m.totalDial / time.Duration(m.m.Dials)
+	m.restored()
+}
+
+func (m *bleMetrics) dialFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.DialFailures++
+}
+
+// wrote records a characteristic write that failed with err, if not nil.
+func (m *bleMetrics) wrote(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Writes++
+	if err != nil {
+		m.m.WriteFailures++
+	}
+	m.linkResult(err)
+}
+
+// read records a characteristic read that failed with err, if not nil.
+func (m *bleMetrics) read(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.linkResult(err)
+}
+
+// linkResult tracks the link from the outcome of a characteristic operation. m.mu must
+// be held.
+func (m *bleMetrics) linkResult(err error) {
+	if err == nil {
+		m.restored()
+		return
+	}
+	if m.up {
+		m.up = false
+		m.m.Disconnects++
+		m.lostAt = time.Now()
+	}
+}
+
+// restored marks the link as working. m.mu must be held.
+func (m *bleMetrics) restored() {
+	if !m.up && !m.lostAt.IsZero() {
+		m.m.LastReconnect = time.Since(m.lostAt)
+		m.lostAt = time.Time{}
+	}
+	m.up = true
+}
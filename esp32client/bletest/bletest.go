@@ -0,0 +1,275 @@
+//go:build !nobluetooth
+
+// Package bletest provides an in-memory esp32client.Adapter so BLE code can be exercised
+// without bluetooth hardware:
+//
+//	device := &bletest.Device{Name: "esp32", Handle: func(req []byte) []byte { ... }}
+//	client, err := esp32client.DialBLE(ctx, "esp32", esp32client.WithAdapter(bletest.NewAdapter(device)))
+package bletest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"tinygo.org/x/bluetooth"
+
+	"esp32wifi/esp32client"
+)
+
+// Adapter advertises its devices once per scan and then blocks, like a real scan, until
+// StopScan is called.
+type Adapter struct {
+	// EnableErr, if set, is returned by Enable.
+	EnableErr error
+
+	mu      sync.Mutex
+	devices []*Device
+	stop    chan struct{}
+	scans   int
+}
+
+// NewAdapter returns an adapter that can see devices.
+func NewAdapter(devices ...*Device) *Adapter {
+	return &Adapter{devices: devices}
+}
+
+// Enable returns EnableErr.
+func (a *Adapter) Enable() error {
+	return a.EnableErr
+}
+
+// Scan advertises every device to fn, then waits for StopScan.
+func (a *Adapter) Scan(fn func(esp32client.ScanResult)) error {
+	a.mu.Lock()
+	if a.stop != nil {
+		a.mu.Unlock()
+		return errors.New("already scanning")
+	}
+	stop := make(chan struct{})
+	a.stop = stop
+	a.scans++
+	devices := append([]*Device(nil), a.devices...)
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.stop = nil
+		a.mu.Unlock()
+	}()
+	for _, d := range devices {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		fn(esp32client.ScanResult{Address: d.Address, LocalName: d.Name, RSSI: d.RSSI, ManufacturerData: d.ManufacturerData})
+	}
+	<-stop
+	return nil
+}
+
+// StopScan ends the running scan.
+func (a *Adapter) StopScan() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stop == nil {
+		return errors.New("not scanning")
+	}
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+	return nil
+}
+
+// Scanning reports whether a Scan call has not returned yet, to check none leaked.
+func (a *Adapter) Scanning() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stop != nil
+}
+
+// Scans returns how many times Scan was called.
+func (a *Adapter) Scans() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.scans
+}
+
+// Connect connects to the device with the given address.
+func (a *Adapter) Connect(address bluetooth.Address) (esp32client.Peripheral, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, d := range a.devices {
+		if d.Address == address {
+			return d.connect()
+		}
+	}
+	return nil, fmt.Errorf("no device with address %s", address.String())
+}
+
+// Device is a simulated firmware.
+type Device struct {
+	Name    string
+	Address bluetooth.Address
+	RSSI    int16
+	// ManufacturerData is advertised with the device's name, e.g. a beacon payload under
+	// esp32client.EspressifCompanyID.
+	ManufacturerData map[uint16][]byte
+
+	// Handle answers each write to the write characteristic. A non-nil answer becomes
+	// the read characteristic's value and is notified if notifications are enabled.
+	Handle func(request []byte) []byte
+	// NoReadCharacteristic simulates the oldest firmware, which only accepts writes.
+	NoReadCharacteristic bool
+	// ConnectErr, if set, is returned by Connect.
+	ConnectErr error
+	// WriteResponseErr, if set, is returned by writes with response, as for a device that
+	// rejects them. Writes without response do not see it.
+	WriteResponseErr error
+
+	mu        sync.Mutex
+	connected bool
+	writes    [][]byte
+	acked     int
+	value     []byte
+	notify    func([]byte)
+}
+
+// Notify notifies value on the read characteristic without a request, as firmware
+// streaming samples does. It returns false if notifications are not enabled.
+func (d *Device) Notify(value []byte) bool {
+	d.mu.Lock()
+	notify := d.notify
+	d.mu.Unlock()
+	if notify == nil {
+		return false
+	}
+	notify(value)
+	return true
+}
+
+// WritesWithResponse returns how many writes the device has acknowledged or rejected.
+func (d *Device) WritesWithResponse() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.acked
+}
+
+// Connected reports whether a client is connected to the device.
+func (d *Device) Connected() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.connected
+}
+
+// Writes returns every write the device has received.
+func (d *Device) Writes() [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([][]byte(nil), d.writes...)
+}
+
+func (d *Device) connect() (esp32client.Peripheral, error) {
+	if d.ConnectErr != nil {
+		return nil, d.ConnectErr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.connected = true
+	return (*peripheral)(d), nil
+}
+
+type peripheral Device
+
+func (p *peripheral) Characteristic(uuid string) (esp32client.Characteristic, error) {
+	switch {
+	case uuid == esp32client.WriteCharacteristicUUID:
+		return (*writeCharacteristic)(p), nil
+	case uuid == esp32client.ReadCharacteristicUUID && !p.NoReadCharacteristic:
+		return (*readCharacteristic)(p), nil
+	}
+	return nil, fmt.Errorf("failed to find characteristic %s", uuid)
+}
+
+func (p *peripheral) Disconnect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.connected = false
+	p.notify = nil
+	return nil
+}
+
+type writeCharacteristic Device
+
+func (c *writeCharacteristic) Read(p []byte) (int, error) {
+	return 0, errors.New("write characteristic is not readable")
+}
+
+func (c *writeCharacteristic) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return 0, errors.New("not connected")
+	}
+	c.writes = append(c.writes, append([]byte(nil), p...))
+	handle := c.Handle
+	c.mu.Unlock()
+
+	if handle == nil {
+		return len(p), nil
+	}
+	response := handle(p)
+	if response == nil {
+		return len(p), nil
+	}
+	c.mu.Lock()
+	c.value = response
+	notify := c.notify
+	c.mu.Unlock()
+	if notify != nil {
+		// Notifications arrive on another goroutine, after the write returns.
+		go notify(response)
+	}
+	return len(p), nil
+}
+
+func (c *writeCharacteristic) WriteWithResponse(p []byte) (int, error) {
+	c.mu.Lock()
+	c.acked++
+	err := c.WriteResponseErr
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return c.Write(p)
+}
+
+func (c *writeCharacteristic) EnableNotifications(fn func(buf []byte)) error {
+	return errors.New("write characteristic does not notify")
+}
+
+type readCharacteristic Device
+
+func (c *readCharacteristic) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copy(p, c.value), nil
+}
+
+func (c *readCharacteristic) Write(p []byte) (int, error) {
+	return 0, errors.New("read characteristic is not writable")
+}
+
+func (c *readCharacteristic) WriteWithResponse(p []byte) (int, error) {
+	return 0, errors.New("read characteristic is not writable")
+}
+
+func (c *readCharacteristic) EnableNotifications(fn func(buf []byte)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = fn
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build linux && !nobluetooth
+
+package esp32client
+
+import (
+	"fmt"
+	"os"
+)
+
+// systemBusSockets are where the D-Bus system bus listens unless DBUS_SYSTEM_BUS_ADDRESS
+// says otherwise.
+var systemBusSockets = []string{"/run/dbus/system_bus_socket", "/var/run/dbus/system_bus_socket"}
+
+// checkBluetoothStack fails if BlueZ cannot be reached, because the D-Bus system bus it
+// is served on is missing, e.g. in a minimal container.
+func checkBluetoothStack() error {
+	if os.Getenv("DBUS_SYSTEM_BUS_ADDRESS") != "" {
+		return nil
+	}
+	for _, socket := range systemBusSockets {
+		if _, err := os.Stat(socket); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no D-Bus system bus at %s, BlueZ needs dbus and bluetoothd running: %w",
+		systemBusSockets[0], ErrBluetoothUnavailable)
+}
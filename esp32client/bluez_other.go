@@ -0,0 +1,9 @@
+//go:build !linux && !nobluetooth
+
+package esp32client
+
+// checkBluetoothStack has nothing to check outside Linux, where the bluetooth stack is
+// part of the OS.
+func checkBluetoothStack() error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package esp32client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the device while the HTTP client's
+// circuit breaker is open. It is also ErrDeviceUnreachable.
+var ErrCircuitOpen = fmt.Errorf("%w, failing fast until it responds again", ErrDeviceUnreachable)
+
+const (
+	// defaultBreakerThreshold is how many consecutive failed requests open the circuit.
+	defaultBreakerThreshold = 5
+	minProbeBackoff         = time.Second
+	maxProbeBackoff         = 30 * time.Second
+	probeTimeout            = 5 * time.Second
+)
+
+// WithCircuitBreaker sets how many consecutive requests must fail to reach the device
+// before the HTTP client fails fast with ErrCircuitOpen. While the circuit is open a
+// background probe retries the device with exponential backoff and closes the circuit as
+// soon as it answers. A threshold of 0 disables the breaker.
+func WithCircuitBreaker(threshold int) Option {
+	return func(o *options) {
+		o.breakerThreshold = threshold
+	}
+}
+
+// breaker is a circuit breaker in front of one of an HTTPClient's URLs.
+type breaker struct {
+	client    *HTTPClient
+	url       string
+	threshold int
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+}
+
+// allow returns ErrCircuitOpen if requests should fail fast.
+func (b *breaker) allow() error {
+	if b.threshold == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record counts the outcome of a request, opening the circuit after too many failures.
+// Only failures to get any response count, a device answering with an error is up.
+func (b *breaker) record(err error) {
+	if b.threshold == 0 || errors.Is(err, context.Canceled) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures < b.threshold || b.open {
+		return
+	}
+	b.open = true
+	b.client.opts.logger.Errorf("%d consecutive requests to %s failed, failing fast until it responds: %v",
+		b.failures, b.url, err)
+	b.client.goProbe(b.probe)
+}
+
+// probe retries the device until it answers or the client is closed, then closes the
+// circuit.
+func (b *breaker) probe() {
+	backoff := minProbeBackoff
+	for {
+		select {
+		case <-b.client.cancelCtx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if err := b.ping(b.client.cancelCtx); err != nil {
+			if b.client.cancelCtx.Err() != nil {
+				return
+			}
+			b.client.opts.logger.Debugf("device still unreachable, probing again in %s: %v", backoff, err)
+			backoff *= 2
+			if backoff > maxProbeBackoff {
+				backoff = maxProbeBackoff
+			}
+			continue
+		}
+
+		b.mu.Lock()
+		b.open, b.failures = false, 0
+		b.mu.Unlock()
+		b.client.opts.logger.Infof("device at %s is reachable again", b.url)
+		return
+	}
+}
+
+// ping checks whether anything answers at the breaker's URL.
+func (b *breaker) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"/info", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.client.doer().Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
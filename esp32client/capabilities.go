@@ -0,0 +1,69 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// PinCapabilities lists the modes the firmware supports on a pin, using the same names as
+// the board config: input, output, pwm, analog and dac.
+type PinCapabilities struct {
+	PinNum int      `json:"pin_num"`
+	Modes  []string `json:"modes"`
+}
+
+// ProtocolH2C is listed in Capabilities.Protocols by firmware that serves HTTP/2 without
+// TLS, e.g. with the esp-idf http2 component.
+const ProtocolH2C = "h2c"
+
+// State formats listed in Capabilities.StateFormat.
+const (
+	// StateFormatPercent reports pin states as 0-100 percent of full scale.
+	StateFormatPercent = "percent"
+	// StateFormatRaw reports pin states as 12-bit ADC counts, 0-4095.
+	StateFormatRaw = "raw"
+)
+
+// Capabilities is what the firmware reports it can do.
+type Capabilities struct {
+	Pins []PinCapabilities `json:"pins"`
+	// Protocols lists the HTTP protocols the firmware serves besides HTTP/1.1, such as
+	// ProtocolH2C. It is empty on older firmware.
+	Protocols []string `json:"protocols,omitempty"`
+	// StateFormat is the scale of the states pins are read as, StateFormatPercent or
+	// StateFormatRaw. It is empty on older firmware.
+	StateFormat string `json:"state_format,omitempty"`
+	// Features lists the optional features the firmware supports, named after their
+	// endpoints, e.g. "macros" for /macros or "pulse_count" for /pulse-count. It is nil
+	// on older firmware, which does not list them.
+	Features []string `json:"features,omitempty"`
+}
+
+// ReadCapabilities returns the pins the firmware exposes and what each can do.
+func ReadCapabilities(ctx context.Context, c Client) ([]PinCapabilities, error) {
+	capabilities, err := ReadDeviceCapabilities(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return capabilities.Pins, nil
+}
+
+// ReadDeviceCapabilities returns the pins and protocols the firmware supports.
+func ReadDeviceCapabilities(ctx context.Context, c Client) (Capabilities, error) {
+	var capabilities Capabilities
+	if err := c.Call(ctx, http.MethodGet, "/capabilities", nil, &capabilities); err != nil {
+		return Capabilities{}, fmt.Errorf("failed to read pin capabilities: %w", err)
+	}
+	return capabilities, nil
+}
+
+// supportsProtocol reports whether the firmware listed protocol.
+func (c Capabilities) supportsProtocol(protocol string) bool {
+	for _, p := range c.Protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
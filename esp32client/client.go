@@ -0,0 +1,255 @@
+// Package esp32client speaks the esp32_interfaces firmware protocol over HTTP or BLE.
+// It has no dependency on the Viam RDK so the same firmware can be driven from any Go
+// program; the board models in the parent package are thin adapters on top of it.
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultSubscribeInterval is how often a subscribed pin is read to detect state changes.
+const defaultSubscribeInterval = 100 * time.Millisecond
+
+// PinRead is the state of a single pin as reported by the firmware. Freq is the PWM
+// frequency in Hz and is zero when the firmware does not report one.
+type PinRead struct {
+	PinNum int     `json:"pin_num"`
+	State  float64 `json:"state"`
+	Freq   uint    `json:"freq,omitempty"`
+}
+
+// PinWrite is a state to apply to a single pin. State is 0-100; 0 is low and 100 is high.
+type PinWrite struct {
+	PinNum int `json:"pin_num"`
+	State  int `json:"state"`
+	// Duty, if set, is the exact duty cycle as a fraction of 0-1 for PWM outputs that need
+	// more resolution than State, e.g. servos. Firmware that does not know it uses State.
+	Duty *float64 `json:"duty,omitempty"`
+}
+
+// PinFreq is a PWM frequency in Hz to apply to a single pin.
+type PinFreq struct {
+	PinNum int  `json:"pin_num"`
+	Freq   uint `json:"freq"`
+	// LEDCTimer and LEDCChannel, if set, are the LEDC timer and channel the firmware must
+	// drive the pin with, numbered across speed modes. Firmware that assigns them itself
+	// ignores them.
+	LEDCTimer   *int `json:"ledc_timer,omitempty"`
+	LEDCChannel *int `json:"ledc_channel,omitempty"`
+}
+
+// Info describes the device as reported by the firmware's /info endpoint.
+type Info struct {
+	FirmwareVersion string `json:"firmware_version"`
+	ChipModel       string `json:"chip_model"`
+	MAC             string `json:"mac"`
+	// ChipID is a unique ID burned into the chip, reported by firmware that has one.
+	ChipID string `json:"chip_id,omitempty"`
+	// ResetReason is why the chip last reset, e.g. "brownout" or "power_on", BootCount
+	// how many times it booted, and UptimeMs how long ago, on firmware that reports them.
+	ResetReason string `json:"reset_reason,omitempty"`
+	BootCount   int    `json:"boot_count,omitempty"`
+	UptimeMs    uint64 `json:"uptime_ms,omitempty"`
+}
+
+// Client is implemented by every transport in this package.
+type Client interface {
+	// ReadPins returns the current state of each pin, in the order requested.
+	ReadPins(ctx context.Context, pins []int) ([]PinRead, error)
+	// WritePins applies all writes in a single request.
+	WritePins(ctx context.Context, writes []PinWrite) error
+	// Transaction applies all writes atomically in one GPIO write cycle: either every
+	// write is applied or none is.
+	Transaction(ctx context.Context, writes []PinWrite) error
+	// SetPWMFreqs applies all PWM frequencies in a single request.
+	SetPWMFreqs(ctx context.Context, freqs []PinFreq) error
+	// Info returns identifying information about the device.
+	Info(ctx context.Context) (Info, error)
+	// Subscribe calls fn every time the state of pin changes, until ctx is done or the
+	// returned func is called. The returned func waits for the subscription to stop, so it
+	// must not be called from fn.
+	Subscribe(ctx context.Context, pin int, fn func(PinRead)) (func(), error)
+	// Call sends body (if non-nil) to a firmware endpoint and decodes the response into
+	// out (if non-nil). It backs the extended firmware API in this package. A body other
+	// than a GET's carries the extra and lease holder of ctx, like a pin request.
+	Call(ctx context.Context, method, path string, body, out interface{}) error
+	// Events calls fn for every event the device pushes until ctx is done.
+	Events(ctx context.Context, fn func(Event)) error
+	// Close releases the underlying connection.
+	Close() error
+}
+
+// Logger is the subset of a leveled logger used by the clients. The RDK's logging.Logger
+// satisfies it.
+type Logger interface {
+	Debugf(template string, args ...interface{})
+	Infof(template string, args ...interface{})
+	Errorf(template string, args ...interface{})
+}
+
+// redactor is implemented by request bodies that carry secrets and must not be logged
+// verbatim.
+type redactor interface {
+	redacted() string
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+type options struct {
+	logger            Logger
+	subscribeInterval time.Duration
+	security          SecurityLevel
+	passkey           uint32
+	breakerThreshold  int
+	proxy             *url.URL
+	adapter           Adapter
+	doer              Doer
+	requestTimeout    time.Duration
+	adaptive          *adaptiveTimeout
+	protocolVersion   int
+	fallbackURLs      []string
+	writeMode         WriteMode
+}
+
+// Option configures a client.
+type Option func(*options)
+
+// WithLogger sets the logger used for request and connection logging.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithSubscribeInterval sets how often subscribed pins are polled.
+func WithSubscribeInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.subscribeInterval = interval
+	}
+}
+
+// WithSecurity sets the BLE security level and, for SecurityPasskey, the static passkey
+// the firmware expects.
+func WithSecurity(level SecurityLevel, passkey uint32) Option {
+	return func(o *options) {
+		o.security = level
+		o.passkey = passkey
+	}
+}
+
+// WithProxy sends HTTP requests through proxy instead of any proxy set in the
+// environment. http, https, socks5 and socks5h proxies are supported, with credentials
+// given as the URL's user info.
+func WithProxy(proxy *url.URL) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// Doer sends HTTP requests. *http.Client implements it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// WithDoer sends HTTP requests through d, e.g. an existing client or middleware that
+// signs or logs requests. WithProxy has no effect on it.
+func WithDoer(d Doer) Option {
+	return func(o *options) {
+		o.doer = d
+	}
+}
+
+// WithRoundTripper sends HTTP requests through rt. WithProxy has no effect on it.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.doer = &http.Client{Transport: rt}
+	}
+}
+
+// WithRequestTimeout bounds each HTTP request, and how long BLE requests wait for their
+// response, to timeout. The caller's deadline still applies if it is sooner.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.requestTimeout = timeout
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		logger:            nopLogger{},
+		subscribeInterval: defaultSubscribeInterval,
+		security:          SecurityNone,
+		breakerThreshold:  defaultBreakerThreshold,
+		adapter:           DefaultAdapter,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// readPin reads a single pin through c.
+func readPin(ctx context.Context, c Client, pin int) (PinRead, error) {
+	reads, err := c.ReadPins(ctx, []int{pin})
+	if err != nil {
+		return PinRead{}, err
+	}
+	if len(reads) == 0 {
+		return PinRead{}, fmt.Errorf("no state returned for pin %d", pin)
+	}
+	return reads[0], nil
+}
+
+// pollSubscribe implements Subscribe for transports without push notifications by
+// reading the pin every interval and calling fn when its state differs from the last read.
+// The returned func stops polling and waits for the polling goroutine to exit.
+func pollSubscribe(ctx context.Context, c Client, logger Logger, interval time.Duration, pin int, fn func(PinRead)) (func(), error) {
+	if fn == nil {
+		return nil, fmt.Errorf("subscribe to pin %d: callback must not be nil", pin)
+	}
+	last, err := readPin(ctx, c, pin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read initial state of pin %d: %w", pin, err)
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-subCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := readPin(subCtx, c, pin)
+			if err != nil {
+				if subCtx.Err() == nil {
+					logger.Debugf("failed to poll pin %d: %v", pin, err)
+				}
+				continue
+			}
+			if current.State == last.State {
+				continue
+			}
+			last = current
+			fn(current)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
@@ -0,0 +1,19 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ReadDeviceTime returns the device's monotonic clock in microseconds since boot, the
+// same clock interrupt events are timestamped with.
+func ReadDeviceTime(ctx context.Context, c Client) (uint64, error) {
+	var response struct {
+		TimeUs uint64 `json:"time_us"`
+	}
+	if err := c.Call(ctx, http.MethodGet, "/time", nil, &response); err != nil {
+		return 0, fmt.Errorf("failed to read device time: %w", err)
+	}
+	return response.TimeUs, nil
+}
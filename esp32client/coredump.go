@@ -0,0 +1,69 @@
+package esp32client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// coreDumpChunkSize is how much of a core dump is read per request, small enough for a
+// BLE response.
+const coreDumpChunkSize = 2048
+
+// ErrNoCoreDump is returned by ReadCoreDump when the device has no core dump stored.
+var ErrNoCoreDump = errors.New("no core dump stored")
+
+// CoreDumpInfo describes the core dump the firmware saved to flash after its last panic.
+type CoreDumpInfo struct {
+	Present bool `json:"present"`
+	// Size is the length of the dump in bytes.
+	Size int `json:"size"`
+	// PanicReason and Task are taken from the dump's summary by firmware that reads it.
+	PanicReason string `json:"panic_reason,omitempty"`
+	Task        string `json:"task,omitempty"`
+}
+
+// ReadCoreDumpInfo reports whether the device has a core dump stored.
+func ReadCoreDumpInfo(ctx context.Context, c Client) (CoreDumpInfo, error) {
+	var info CoreDumpInfo
+	if err := c.Call(ctx, http.MethodGet, "/coredump/info", nil, &info); err != nil {
+		return CoreDumpInfo{}, fmt.Errorf("failed to read core dump info: %w", err)
+	}
+	return info, nil
+}
+
+// ReadCoreDump downloads the stored core dump, an ELF file ESP-IDF's espcoredump.py can
+// decode, in chunks.
+func ReadCoreDump(ctx context.Context, c Client) ([]byte, error) {
+	info, err := ReadCoreDumpInfo(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Present {
+		return nil, ErrNoCoreDump
+	}
+	dump := make([]byte, 0, info.Size)
+	for len(dump) < info.Size {
+		var chunk struct {
+			Data []byte `json:"data"`
+		}
+		path := fmt.Sprintf("/coredump?offset=%d&length=%d", len(dump), coreDumpChunkSize)
+		if err := c.Call(ctx, http.MethodGet, path, nil, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to read core dump at offset %d: %w", len(dump), err)
+		}
+		if len(chunk.Data) == 0 {
+			return nil, fmt.Errorf("core dump ended at %d of %d bytes", len(dump), info.Size)
+		}
+		dump = append(dump, chunk.Data...)
+	}
+	return dump, nil
+}
+
+// EraseCoreDump clears the stored core dump, so the next panic's can be told apart.
+func EraseCoreDump(ctx context.Context, c Client) error {
+	if err := c.Call(ctx, http.MethodDelete, "/coredump", nil, nil); err != nil {
+		return fmt.Errorf("failed to erase core dump: %w", err)
+	}
+	return nil
+}
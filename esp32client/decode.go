@@ -0,0 +1,127 @@
+package esp32client
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Payload is a message exchanged with the firmware over either transport, decoded: the
+// pin operations it carries, their results, and the BLE envelope around them. Fields
+// the message does not carry are left zero.
+type Payload struct {
+	// ID is the request ID of a BLE request or response.
+	ID uint32
+	// Method, Path and Body are a Call over BLE. Body is left for its endpoint to make
+	// sense of.
+	Method string
+	Path   string
+	Body   json.RawMessage
+	Ping   bool
+	// Status and Error are the outcome a BLE response reports.
+	Status int
+	Error  string
+
+	// ReadPins are the pins a read requests.
+	ReadPins []int
+	Reads    []PinRead
+	// Values are the states of a legacy read in the order requested, either bare states
+	// or objects like PinRead. DecodeExchange turns them into Reads.
+	Values []json.RawMessage
+	// Legacy is set if the read is in the legacy protocol.
+	Legacy      bool
+	Writes      []PinWrite
+	Transaction []PinWrite
+	Freqs       []PinFreq
+	Results     []PinResult
+	Samples     []AnalogSample
+	Events      []Event
+
+	// Other are the fields this package does not know, e.g. from newer firmware.
+	Other map[string]json.RawMessage
+}
+
+// DecodePayload decodes a JSON message sent to or by the firmware, such as an HTTP body,
+// a BLE characteristic value or notification, or an event. A field that does not decode
+// as expected is left in Other.
+func DecodePayload(data []byte) (Payload, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Payload{}, fmt.Errorf("not a JSON object: %w", err)
+	}
+	if _, ok := fields["type"]; ok {
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return Payload{}, fmt.Errorf("invalid event: %w", err)
+		}
+		return Payload{Events: []Event{event}}, nil
+	}
+
+	var p Payload
+	decodeField(fields, "id", &p.ID)
+	decodeField(fields, "method", &p.Method)
+	decodeField(fields, "path", &p.Path)
+	decodeField(fields, "body", &p.Body)
+	decodeField(fields, "ping", &p.Ping)
+	decodeField(fields, "status", &p.Status)
+	decodeField(fields, "error", &p.Error)
+	p.Legacy = decodeField(fields, "pins", &p.ReadPins)
+	if decodeField(fields, "values", &p.Values) {
+		p.Legacy = true
+	}
+	// pin_reads are the pins requested in a read, and their states in its response.
+	if !decodeField(fields, "pin_reads", &p.ReadPins) {
+		decodeField(fields, "pin_reads", &p.Reads)
+	}
+	decodeField(fields, "pin_writes", &p.Writes)
+	decodeField(fields, "transaction", &p.Transaction)
+	decodeField(fields, "pin_freqs", &p.Freqs)
+	decodeField(fields, "results", &p.Results)
+	decodeField(fields, "analog_samples", &p.Samples)
+	decodeField(fields, "events", &p.Events)
+	if len(fields) > 0 {
+		p.Other = fields
+	}
+	return p, nil
+}
+
+// decodeField decodes the named field into v and removes it from fields, reporting
+// whether it did. v is left alone if the field does not decode.
+func decodeField[T any](fields map[string]json.RawMessage, name string, v *T) bool {
+	data, ok := fields[name]
+	if !ok {
+		return false
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return false
+	}
+	*v = value
+	delete(fields, name)
+	return true
+}
+
+// DecodeExchange decodes the request and response of a recorded exchange. The states of
+// a legacy read are matched to the pins requested, and the writes of a transaction,
+// recorded as pin_writes, are returned as Transaction.
+func DecodeExchange(e Exchange) (request, response Payload, err error) {
+	if len(e.Request) > 0 {
+		if request, err = DecodePayload(e.Request); err != nil {
+			return Payload{}, Payload{}, fmt.Errorf("%s %s: request: %w", e.Method, e.Path, err)
+		}
+	}
+	if len(e.Response) > 0 {
+		if response, err = DecodePayload(e.Response); err != nil {
+			return Payload{}, Payload{}, fmt.Errorf("%s %s: response: %w", e.Method, e.Path, err)
+		}
+	}
+	if e.Path == "/transaction" && request.Transaction == nil {
+		request.Transaction, request.Writes = request.Writes, nil
+	}
+	if response.Values != nil && request.ReadPins != nil {
+		legacy := readsResponse{Values: response.Values}
+		if reads, err := legacy.reads(request.ReadPins); err == nil {
+			response.Reads, response.Values = reads, nil
+		}
+	}
+	return request, response, nil
+}
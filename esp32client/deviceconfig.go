@@ -0,0 +1,48 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// DeviceConfig is the setup the firmware keeps in its storage and applies on boot, so a
+// commissioned board's setup can be copied onto replacement hardware.
+type DeviceConfig struct {
+	Pins []DevicePinConfig `json:"pins"`
+}
+
+// DevicePinConfig is the stored setup of one pin. Unset optional fields are not stored.
+type DevicePinConfig struct {
+	PinNum int `json:"pin_num"`
+	// Mode is input, output, pwm, analog or dac.
+	Mode string `json:"mode"`
+	// SafeState is the level driven on boot and when the firmware loses its client.
+	SafeState *bool `json:"safe_state,omitempty"`
+	PWMFreqHz int   `json:"pwm_freq_hz,omitempty"`
+	// Calibration converts raw readings of an analog pin: value = raw*scale + offset.
+	Calibration *DeviceCalibration `json:"calibration,omitempty"`
+}
+
+// DeviceCalibration is a linear calibration stored on the device.
+type DeviceCalibration struct {
+	Offset float64 `json:"offset"`
+	Scale  float64 `json:"scale"`
+}
+
+// ReadDeviceConfig returns the setup stored on the device.
+func ReadDeviceConfig(ctx context.Context, c Client) (DeviceConfig, error) {
+	var config DeviceConfig
+	if err := c.Call(ctx, http.MethodGet, "/config", nil, &config); err != nil {
+		return DeviceConfig{}, fmt.Errorf("failed to read device config: %w", err)
+	}
+	return config, nil
+}
+
+// WriteDeviceConfig replaces the setup stored on the device and applies it.
+func WriteDeviceConfig(ctx context.Context, c Client, config DeviceConfig) error {
+	if err := c.Call(ctx, http.MethodPut, "/config", config, nil); err != nil {
+		return fmt.Errorf("failed to write device config: %w", err)
+	}
+	return nil
+}
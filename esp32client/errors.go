@@ -0,0 +1,64 @@
+package esp32client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Errors the clients wrap their failures with, so callers can tell them apart with
+// errors.Is. The board models in the parent package return them too.
+var (
+	// ErrNotSupported is returned when an operation is not available over a transport or
+	// on the firmware.
+	ErrNotSupported = errors.New("not supported")
+	// ErrFirmwareTooOld is returned when the firmware lacks an endpoint or characteristic,
+	// typically because it predates the feature. It is also ErrNotSupported.
+	ErrFirmwareTooOld = fmt.Errorf("%w by this firmware version", ErrNotSupported)
+	// ErrNotFound is returned when the firmware has no such key or id, e.g. an NVS key
+	// that was never written or a schedule that already finished. Unlike
+	// ErrFirmwareTooOld it says nothing of what the firmware supports.
+	ErrNotFound = errors.New("not found")
+	// ErrDeviceUnreachable is returned when a request got no response at all, e.g. the
+	// connection was refused or dropped.
+	ErrDeviceUnreachable = errors.New("device unreachable")
+	// ErrTimeout is returned when the device did not answer in time. The error is also
+	// context.DeadlineExceeded if the deadline came from the context.
+	ErrTimeout = errors.New("timed out")
+	// ErrInvalidPin is returned when a pin does not exist or cannot be used the way it was
+	// asked to, whether the module or the firmware rejected it.
+	ErrInvalidPin = errors.New("invalid pin")
+	// ErrBoardBusy is returned when another host holds the device's control lease, so the
+	// device refused a write or the board did not send it.
+	ErrBoardBusy = errors.New("board busy")
+	// ErrBluetoothUnavailable is returned when the host's bluetooth stack cannot be used,
+	// e.g. BlueZ is not running or there is no adapter.
+	ErrBluetoothUnavailable = errors.New("bluetooth unavailable")
+	// ErrWriteNotVerified is returned when a pin read back after a write does not hold the
+	// state written.
+	ErrWriteNotVerified = errors.New("write not verified")
+)
+
+// notFound turns the 404 of a request for a key or id, which the clients return as
+// ErrFirmwareTooOld, into ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, ErrFirmwareTooOld) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// transportError marks err, a failure to get any response, as ErrTimeout or
+// ErrDeviceUnreachable. Cancellation is left as is, it is up to the caller.
+func transportError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) ||
+		errors.Is(err, ErrTimeout) || errors.Is(err, ErrDeviceUnreachable) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrDeviceUnreachable, err)
+}
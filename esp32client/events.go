@@ -0,0 +1,138 @@
+package esp32client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Event types pushed by the firmware.
+const (
+	EventInterrupt = "interrupt"
+	EventReboot    = "reboot"
+	EventWiFi      = "wifi"
+)
+
+const (
+	// eventsPollTimeout is how long the firmware may hold an /events request open.
+	eventsPollTimeout = 25 * time.Second
+	minEventsBackoff  = time.Second
+	maxEventsBackoff  = 30 * time.Second
+)
+
+// Event is a single notification pushed by the firmware.
+type Event struct {
+	Type string `json:"type"`
+
+	// Set for interrupt events.
+	PinNum      int    `json:"pin_num,omitempty"`
+	High        bool   `json:"high,omitempty"`
+	TimestampUs uint64 `json:"timestamp_us,omitempty"`
+
+	// Set for reboot events.
+	Reason string `json:"reason,omitempty"`
+
+	// Set for wifi events.
+	WiFi *WiFiStatus `json:"wifi,omitempty"`
+}
+
+// WiFiStatus is the state of the device's WiFi connection.
+type WiFiStatus struct {
+	Connected bool   `json:"connected"`
+	SSID      string `json:"ssid,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	RSSI      int    `json:"rssi,omitempty"`
+	Channel   int    `json:"channel,omitempty"`
+}
+
+// Events long-polls the firmware's /events endpoint and calls fn for every event until
+// ctx is done. If the firmware answers with a server-sent event stream instead of a JSON
+// batch, the stream is read until it closes. Transient failures are retried with
+// exponential backoff; ErrNotSupported is returned if the firmware has no /events.
+func (c *HTTPClient) Events(ctx context.Context, fn func(Event)) error {
+	backoff := minEventsBackoff
+	for ctx.Err() == nil {
+		e := c.endpoint()
+		err := c.pollEvents(ctx, e, fn)
+		if errors.Is(err, ErrNotSupported) {
+			return err
+		}
+		if err == nil {
+			backoff = minEventsBackoff
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if unreachable(err) && c.failover(ctx, e, err) != nil {
+			backoff = minEventsBackoff
+			continue
+		}
+
+		c.opts.logger.Debugf("failed to poll events, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxEventsBackoff {
+			backoff = maxEventsBackoff
+		}
+	}
+	return ctx.Err()
+}
+
+func (c *HTTPClient) pollEvents(ctx context.Context, b *breaker, fn func(Event)) error {
+	endpoint := fmt.Sprintf("%s/events?timeout_ms=%d", b.url, eventsPollTimeout.Milliseconds())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := c.send(b, req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("events: %w", ErrFirmwareTooOld)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+				c.opts.logger.Debugf("failed to decode event %q: %v", data, err)
+				continue
+			}
+			fn(event)
+		}
+		return scanner.Err()
+	}
+
+	var response struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	for _, event := range response.Events {
+		fn(event)
+	}
+	return nil
+}
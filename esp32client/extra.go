@@ -0,0 +1,57 @@
+package esp32client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type extraKey struct{}
+
+// WithExtra returns a context whose pin requests, and Calls that drive pins, carry extra to
+// the firmware as an "extra" object next to the request's own fields. Firmware can use it
+// for per-call options that have no field of their own; firmware that does not know a key
+// ignores it.
+func WithExtra(ctx context.Context, extra map[string]interface{}) context.Context {
+	if len(extra) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, extraKey{}, extra)
+}
+
+// addExtra adds the extra carried by ctx, if any, to body, with the lease holder set by
+// WithLeaseHolder under "lease".
+func addExtra(ctx context.Context, body map[string]interface{}) map[string]interface{} {
+	extra, _ := ctx.Value(extraKey{}).(map[string]interface{})
+	if holder, ok := ctx.Value(leaseKey{}).(string); ok {
+		merged := make(map[string]interface{}, len(extra)+1)
+		for key, value := range extra {
+			merged[key] = value
+		}
+		merged["lease"] = holder
+		extra = merged
+	}
+	if len(extra) > 0 {
+		body["extra"] = extra
+	}
+	return body
+}
+
+// addCallExtra adds the extra carried by ctx, if any, to the body of a Call other than a
+// GET, as addExtra does for pin requests, so Calls that drive pins carry the lease holder.
+// body is returned unchanged if it is not a JSON object, whose error Call then reports.
+func addCallExtra(ctx context.Context, method string, body interface{}) interface{} {
+	_, hasExtra := ctx.Value(extraKey{}).(map[string]interface{})
+	_, hasHolder := ctx.Value(leaseKey{}).(string)
+	if method == http.MethodGet || !hasExtra && !hasHolder {
+		return body
+	}
+	object := map[string]interface{}{}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil || json.Unmarshal(data, &object) != nil {
+			return body
+		}
+	}
+	return addExtra(ctx, object)
+}
@@ -0,0 +1,288 @@
+package esp32client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// failoverProbeInterval is how often a FailoverClient on its fallback checks whether the
+// primary is reachable again.
+const failoverProbeInterval = 10 * time.Second
+
+// errFailoverClosed fails requests that would switch to the fallback after Close.
+var errFailoverClosed = errors.New("failover client is closed")
+
+// FailoverClient sends requests over an HTTP client and, when the device stops answering
+// over HTTP, over a fallback client instead, typically BLE for devices that lose WiFi but
+// stay in range of the gateway. It moves back to HTTP once the device answers there again.
+type FailoverClient struct {
+	primary      *HTTPClient
+	dialFallback func(ctx context.Context) (Client, error)
+	opts         options
+
+	mu         sync.Mutex
+	fallback   Client
+	onFallback bool
+	// dial is the fallback being dialed, which requests failing meanwhile wait for.
+	dial *fallbackDial
+
+	closeOnce sync.Once
+	done      chan struct{}
+	probing   sync.WaitGroup
+}
+
+// NewFailoverClient returns a client that prefers primary. dialFallback is called the
+// first time primary is unreachable, and again after each move back to primary.
+func NewFailoverClient(primary *HTTPClient, dialFallback func(ctx context.Context) (Client, error), opts ...Option) *FailoverClient {
+	return &FailoverClient{
+		primary:      primary,
+		dialFallback: dialFallback,
+		opts:         newOptions(opts),
+		done:         make(chan struct{}),
+	}
+}
+
+// fallbackDial is a dial of the fallback, done once it returned err.
+type fallbackDial struct {
+	done chan struct{}
+	err  error
+}
+
+// UsingFallback reports whether requests are currently sent over the fallback.
+func (c *FailoverClient) UsingFallback() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.onFallback
+}
+
+// unreachable reports whether err means the request never got an answer, as opposed to
+// the device answering with an error.
+func unreachable(err error) bool {
+	return errors.Is(err, ErrDeviceUnreachable) || errors.Is(err, ErrTimeout)
+}
+
+// do runs fn on the active client, failing over if the primary is unreachable. fn is run
+// again on the fallback if it never reached the primary, or after it timed out if replay
+// is set: a request that timed out may have been applied, so only idempotent ones are
+// sent twice.
+func (c *FailoverClient) do(ctx context.Context, replay bool, fn func(Client) error) error {
+	c.mu.Lock()
+	client := Client(c.primary)
+	if c.onFallback {
+		client = c.fallback
+	}
+	onFallback := c.onFallback
+	c.mu.Unlock()
+
+	err := fn(client)
+	if onFallback || ctx.Err() != nil || !unreachable(err) {
+		return err
+	}
+	fallback, fallbackErr := c.failover(ctx, err)
+	if fallbackErr != nil {
+		return fmt.Errorf("%w (fallback unavailable: %v)", err, fallbackErr)
+	}
+	if !replay && errors.Is(err, ErrTimeout) {
+		return err
+	}
+	return fn(fallback)
+}
+
+// failover switches to the fallback, dialing it if needed, and starts probing the primary.
+// The fallback is dialed once however many requests fail together, and without holding
+// c.mu, so requests already on the fallback are not held up by it.
+func (c *FailoverClient) failover(ctx context.Context, cause error) (Client, error) {
+	for {
+		c.mu.Lock()
+		if c.onFallback {
+			fallback := c.fallback
+			c.mu.Unlock()
+			return fallback, nil
+		}
+		if c.fallback != nil {
+			fallback, err := c.switchToFallback(cause)
+			c.mu.Unlock()
+			return fallback, err
+		}
+		if dial := c.dial; dial != nil {
+			c.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-dial.done:
+			}
+			if dial.err != nil {
+				return nil, dial.err
+			}
+			continue
+		}
+		dial := &fallbackDial{done: make(chan struct{})}
+		c.dial = dial
+		c.mu.Unlock()
+
+		fallback, err := c.dialFallback(ctx)
+		c.mu.Lock()
+		c.dial = nil
+		stale := err == nil && c.closed()
+		if stale {
+			err = errFailoverClosed
+		} else if err == nil {
+			c.fallback = fallback
+		}
+		dial.err = err
+		close(dial.done)
+		c.mu.Unlock()
+		if stale {
+			// Close already ran, so the fallback is closed here.
+			return nil, errors.Join(err, fallback.Close())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// closed reports whether Close was called.
+func (c *FailoverClient) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// switchToFallback moves requests to the dialed fallback and starts probing the primary,
+// unless the client was closed. c.mu must be held.
+func (c *FailoverClient) switchToFallback(cause error) (Client, error) {
+	if c.closed() {
+		return nil, errFailoverClosed
+	}
+	c.onFallback = true
+	c.opts.logger.Errorf("device unreachable at %s, switching to fallback: %v", c.primary.URL(), cause)
+
+	c.probing.Add(1)
+	go func() {
+		defer c.probing.Done()
+		c.probePrimary()
+	}()
+	return c.fallback, nil
+}
+
+// probePrimary waits for the primary to answer again, then moves requests back to it and
+// disconnects the fallback.
+func (c *FailoverClient) probePrimary() {
+	ticker := time.NewTicker(failoverProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		_, err := c.primary.Info(ctx)
+		cancel()
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			c.opts.logger.Debugf("primary still unreachable: %v", err)
+			continue
+		}
+
+		c.mu.Lock()
+		fallback := c.fallback
+		c.fallback, c.onFallback = nil, false
+		c.mu.Unlock()
+		c.opts.logger.Infof("device reachable at %s again, switching back", c.primary.URL())
+		if err := fallback.Close(); err != nil {
+			c.opts.logger.Errorf("failed to close fallback: %v", err)
+		}
+		return
+	}
+}
+
+// ReadPins returns the current state of each pin, in the order requested.
+func (c *FailoverClient) ReadPins(ctx context.Context, pins []int) ([]PinRead, error) {
+	var reads []PinRead
+	err := c.do(ctx, true, func(client Client) error {
+		var err error
+		reads, err = client.ReadPins(ctx, pins)
+		return err
+	})
+	return reads, err
+}
+
+// WritePins applies all writes in a single request.
+func (c *FailoverClient) WritePins(ctx context.Context, writes []PinWrite) error {
+	return c.do(ctx, true, func(client Client) error {
+		return client.WritePins(ctx, writes)
+	})
+}
+
+// Transaction applies all writes atomically in one GPIO write cycle.
+func (c *FailoverClient) Transaction(ctx context.Context, writes []PinWrite) error {
+	return c.do(ctx, true, func(client Client) error {
+		return client.Transaction(ctx, writes)
+	})
+}
+
+// SetPWMFreqs applies all PWM frequencies in a single request.
+func (c *FailoverClient) SetPWMFreqs(ctx context.Context, freqs []PinFreq) error {
+	return c.do(ctx, true, func(client Client) error {
+		return client.SetPWMFreqs(ctx, freqs)
+	})
+}
+
+// Info returns identifying information about the device.
+func (c *FailoverClient) Info(ctx context.Context) (Info, error) {
+	var info Info
+	err := c.do(ctx, true, func(client Client) error {
+		var err error
+		info, err = client.Info(ctx)
+		return err
+	})
+	return info, err
+}
+
+// Call sends body to the endpoint at path over the active client. Only a GET is sent
+// again over the fallback after timing out over the primary.
+func (c *FailoverClient) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.do(ctx, method == http.MethodGet, func(client Client) error {
+		return client.Call(ctx, method, path, body, out)
+	})
+}
+
+// Subscribe calls fn every time the state of pin changes, over whichever client is
+// active, until ctx is done or the returned func is called.
+func (c *FailoverClient) Subscribe(ctx context.Context, pin int, fn func(PinRead)) (func(), error) {
+	return pollSubscribe(ctx, c, c.opts.logger, c.opts.subscribeInterval, pin, fn)
+}
+
+// Events streams events from the primary, which keeps retrying while the device is
+// only reachable over the fallback.
+func (c *FailoverClient) Events(ctx context.Context, fn func(Event)) error {
+	return c.primary.Events(ctx, fn)
+}
+
+// Close stops probing and closes both clients.
+func (c *FailoverClient) Close() error {
+	// Closed under c.mu, so no probe starts after the wait.
+	c.mu.Lock()
+	c.closeOnce.Do(func() { close(c.done) })
+	c.mu.Unlock()
+	c.probing.Wait()
+
+	c.mu.Lock()
+	fallback := c.fallback
+	c.fallback, c.onFallback = nil, false
+	c.mu.Unlock()
+
+	err := c.primary.Close()
+	if fallback != nil {
+		err = errors.Join(err, fallback.Close())
+	}
+	return err
+}
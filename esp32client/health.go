@@ -0,0 +1,24 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Health is the firmware's readiness as reported by /health.
+type Health struct {
+	// Ready is set once the ADC and WiFi have settled after power-on.
+	Ready bool `json:"ready"`
+	// Reason says what the firmware is waiting for while it is not ready.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReadHealth returns whether the device is ready to be used.
+func ReadHealth(ctx context.Context, c Client) (Health, error) {
+	var health Health
+	if err := c.Call(ctx, http.MethodGet, "/health", nil, &health); err != nil {
+		return Health{}, fmt.Errorf("failed to read health: %w", err)
+	}
+	return health, nil
+}
@@ -0,0 +1,415 @@
+package esp32client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPClient talks to the firmware's HTTP API.
+type HTTPClient struct {
+	// endpoints are the URLs the firmware is served at, in order of preference, each
+	// behind its own circuit breaker, and active the one requests are sent to. failoverMu
+	// guards endpoints and serializes failovers, so requests failing together switch URLs
+	// once.
+	failoverMu sync.Mutex
+	endpoints  []*breaker
+	active     atomic.Pointer[breaker]
+	opts       options
+	protocol   *protocol
+
+	// doerMu guards httpClient, which NegotiateHTTP2 may replace.
+	doerMu     sync.Mutex
+	httpClient Doer
+	// ownDoer is set if httpClient was built from the options rather than given.
+	ownDoer bool
+	http2   bool
+
+	// cancelCtx is cancelled by Close, which then waits for probes, the breakers'
+	// background probes. probesMu keeps a probe from starting once Close has begun.
+	cancelCtx  context.Context
+	cancelFunc context.CancelFunc
+	probesMu   sync.Mutex
+	probes     sync.WaitGroup
+}
+
+// NewHTTPClient returns a client for the firmware served at url, e.g. "http://192.168.1.50".
+func NewHTTPClient(url string, opts ...Option) *HTTPClient {
+	o := newOptions(opts)
+	httpClient, ownDoer := o.doer, false
+	if httpClient == nil {
+		client := &http.Client{}
+		if o.proxy != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.Proxy = http.ProxyURL(o.proxy)
+			client.Transport = transport
+		}
+		httpClient, ownDoer = client, true
+	}
+	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	c := &HTTPClient{
+		httpClient: httpClient,
+		ownDoer:    ownDoer,
+		opts:       o,
+		protocol:   newProtocol(o),
+		cancelCtx:  cancelCtx,
+		cancelFunc: cancelFunc,
+	}
+	for _, u := range append([]string{url}, o.fallbackURLs...) {
+		c.endpoints = append(c.endpoints, c.newBreaker(u))
+	}
+	c.active.Store(c.endpoints[0])
+	return c
+}
+
+func (c *HTTPClient) newBreaker(url string) *breaker {
+	return &breaker{client: c, url: strings.TrimSuffix(url, "/"), threshold: c.opts.breakerThreshold}
+}
+
+// WithFallbackURLs sets other addresses an HTTP client's firmware is served at, e.g. the
+// AP-mode address of a device that switches between station and AP mode. When the device
+// stops answering at its current address, requests move to the first of the addresses,
+// in order, that answers, and stick to it until it stops answering in turn.
+func WithFallbackURLs(urls ...string) Option {
+	return func(o *options) {
+		o.fallbackURLs = urls
+	}
+}
+
+// URL returns the base URL requests are sent to.
+func (c *HTTPClient) URL() string {
+	return c.endpoint().url
+}
+
+// URLs returns the URLs the firmware is served at, in order of preference.
+func (c *HTTPClient) URLs() []string {
+	c.failoverMu.Lock()
+	defer c.failoverMu.Unlock()
+	urls := make([]string, len(c.endpoints))
+	for i, e := range c.endpoints {
+		urls[i] = e.url
+	}
+	return urls
+}
+
+// SetURL sends requests to url from now on, ahead of the URLs the client fails over to,
+// e.g. once a device provisioned in AP mode has joined the LAN.
+func (c *HTTPClient) SetURL(url string) {
+	url = strings.TrimSuffix(url, "/")
+	c.failoverMu.Lock()
+	defer c.failoverMu.Unlock()
+	endpoints := []*breaker{nil}
+	for _, e := range c.endpoints {
+		if e.url == url {
+			endpoints[0] = e
+		} else {
+			endpoints = append(endpoints, e)
+		}
+	}
+	if endpoints[0] == nil {
+		endpoints[0] = c.newBreaker(url)
+	}
+	c.endpoints = endpoints
+	c.active.Store(endpoints[0])
+	c.opts.logger.Infof("sending requests to %s", url)
+}
+
+// endpoint returns the breaker of the URL requests are sent to.
+func (c *HTTPClient) endpoint() *breaker {
+	return c.active.Load()
+}
+
+// failover moves requests off from, whose URL stopped answering with cause, to the first
+// other URL that answers GET /info, and returns its breaker. It returns nil if no other
+// URL answers. A URL whose circuit is open is skipped until its own probe closes it.
+func (c *HTTPClient) failover(ctx context.Context, from *breaker, cause error) *breaker {
+	c.failoverMu.Lock()
+	defer c.failoverMu.Unlock()
+	if current := c.endpoint(); current != from {
+		// Another request already moved.
+		return current
+	}
+	for _, e := range c.endpoints {
+		if e == from || e.allow() != nil {
+			continue
+		}
+		err := e.ping(ctx)
+		e.record(transportError(err))
+		if err != nil {
+			c.opts.logger.Debugf("device not reachable at %s either: %v", e.url, err)
+			continue
+		}
+		c.active.Store(e)
+		c.opts.logger.Infof("device unreachable at %s, switching to %s: %v", from.url, e.url, cause)
+		return e
+	}
+	return nil
+}
+
+// NegotiateHTTP2 switches to HTTP/2 without TLS if the firmware lists ProtocolH2C in
+// capabilities, so concurrent requests such as reads of several analog pins share one
+// connection instead of queueing for the few sockets the firmware serves. The switch is
+// checked with an /info request and the client stays on HTTP/1.1 if that fails. HTTPS
+// URLs negotiate HTTP/2 on their own, and clients given a Doer or a proxy are left as is;
+// for those NegotiateHTTP2 returns ErrNotSupported.
+func (c *HTTPClient) NegotiateHTTP2(ctx context.Context, capabilities Capabilities) error {
+	if !capabilities.supportsProtocol(ProtocolH2C) {
+		return fmt.Errorf("firmware does not serve %s: %w", ProtocolH2C, ErrNotSupported)
+	}
+	c.doerMu.Lock()
+	previous, ownDoer, upgraded := c.httpClient, c.ownDoer, c.http2
+	c.doerMu.Unlock()
+	switch {
+	case upgraded:
+		return nil
+	case !ownDoer || c.opts.proxy != nil || !strings.HasPrefix(c.URL(), "http://"):
+		return fmt.Errorf("%s with a custom client, a proxy or TLS: %w", ProtocolH2C, ErrNotSupported)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	transport.Protocols = protocols
+	h2c := &http.Client{Transport: transport}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL()+"/info", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := h2c.Do(req)
+	if err != nil {
+		transport.CloseIdleConnections()
+		return fmt.Errorf("firmware listed %s but the request failed: %w", ProtocolH2C, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		transport.CloseIdleConnections()
+		return fmt.Errorf("firmware listed %s but answered %s", ProtocolH2C, resp.Status)
+	}
+
+	c.doerMu.Lock()
+	c.httpClient, c.http2 = h2c, true
+	c.doerMu.Unlock()
+	if closer, ok := previous.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+	c.opts.logger.Infof("using %s with %s", ProtocolH2C, c.URL())
+	return nil
+}
+
+// doer returns the Doer requests are currently sent through.
+func (c *HTTPClient) doer() Doer {
+	c.doerMu.Lock()
+	defer c.doerMu.Unlock()
+	return c.httpClient
+}
+
+// ReadPins returns the current state of each pin, in the order requested.
+func (c *HTTPClient) ReadPins(ctx context.Context, pins []int) ([]PinRead, error) {
+	reads, err := c.protocol.readPins(pins, func(body map[string]interface{}, response *readsResponse) error {
+		return c.do(ctx, http.MethodPost, "/read-pins", addExtra(ctx, body), response)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pins: %w", err)
+	}
+	return reads, nil
+}
+
+// WritePins applies all writes in a single request. If the firmware rejects some of the
+// pins, the others are still written and the error is a *PartialWriteError.
+func (c *HTTPClient) WritePins(ctx context.Context, writes []PinWrite) error {
+	body := addExtra(ctx, map[string]interface{}{
+		"pin_writes": writes,
+	})
+	var response batchResponse
+	if err := c.do(ctx, http.MethodPost, "/write-pins", body, &response); err != nil {
+		return fmt.Errorf("failed to write pins: %w", err)
+	}
+	if err := response.err(); err != nil {
+		return fmt.Errorf("failed to write pins: %w", err)
+	}
+	return nil
+}
+
+// Transaction applies all writes atomically in one GPIO write cycle. Firmware without
+// transaction support returns ErrFirmwareTooOld rather than applying writes one by one.
+func (c *HTTPClient) Transaction(ctx context.Context, writes []PinWrite) error {
+	body := addExtra(ctx, map[string]interface{}{
+		"pin_writes": writes,
+	})
+	if err := c.do(ctx, http.MethodPost, "/transaction", body, nil); err != nil {
+		return fmt.Errorf("failed to apply transaction: %w", err)
+	}
+	return nil
+}
+
+// SetPWMFreqs applies all PWM frequencies in a single request. Like WritePins, a
+// partial failure returns a *PartialWriteError.
+func (c *HTTPClient) SetPWMFreqs(ctx context.Context, freqs []PinFreq) error {
+	body := addExtra(ctx, map[string]interface{}{
+		"pin_freqs": freqs,
+	})
+	var response batchResponse
+	if err := c.do(ctx, http.MethodPost, "/write-pins", body, &response); err != nil {
+		return fmt.Errorf("failed to set pwm frequencies: %w", err)
+	}
+	if err := response.err(); err != nil {
+		return fmt.Errorf("failed to set pwm frequencies: %w", err)
+	}
+	return nil
+}
+
+// Info returns identifying information about the device.
+func (c *HTTPClient) Info(ctx context.Context) (Info, error) {
+	var info Info
+	if err := c.do(ctx, http.MethodGet, "/info", nil, &info); err != nil {
+		return Info{}, fmt.Errorf("failed to get info: %w", err)
+	}
+	return info, nil
+}
+
+// Subscribe calls fn every time the state of pin changes, until ctx is done or the
+// returned func is called.
+func (c *HTTPClient) Subscribe(ctx context.Context, pin int, fn func(PinRead)) (func(), error) {
+	return pollSubscribe(ctx, c, c.opts.logger, c.opts.subscribeInterval, pin, fn)
+}
+
+// Call sends body (if non-nil) to the endpoint at path and decodes the response into out
+// (if non-nil).
+func (c *HTTPClient) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.do(ctx, method, path, addCallExtra(ctx, method, body), out)
+}
+
+// goProbe runs fn in the background until it returns, unless the client is closed.
+func (c *HTTPClient) goProbe(fn func()) {
+	c.probesMu.Lock()
+	defer c.probesMu.Unlock()
+	if c.cancelCtx.Err() != nil {
+		return
+	}
+	c.probes.Add(1)
+	go func() {
+		defer c.probes.Done()
+		fn()
+	}()
+}
+
+// Close stops any background probing, waiting for a probe in flight, and releases idle
+// connections, if the Doer keeps any.
+func (c *HTTPClient) Close() error {
+	c.probesMu.Lock()
+	c.cancelFunc()
+	c.probesMu.Unlock()
+	c.probes.Wait()
+	if closer, ok := c.doer().(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+	return nil
+}
+
+// send sends req through the circuit breaker of its URL. Failures to get a response are
+// ErrDeviceUnreachable or ErrTimeout.
+func (c *HTTPClient) send(b *breaker, req *http.Request) (*http.Response, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := c.doer().Do(req)
+	b.record(err)
+	return resp, transportError(err)
+}
+
+// do sends body (if non-nil) as JSON to path and decodes the response into out (if
+// non-nil), failing over to another URL if the device does not answer at this one.
+func (c *HTTPClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	e := c.endpoint()
+	err := c.doAt(ctx, e, method, path, body, out)
+	if !unreachable(err) || ctx.Err() != nil {
+		return err
+	}
+	if next := c.failover(ctx, e, err); next != nil {
+		return c.doAt(ctx, next, method, path, body, out)
+	}
+	return err
+}
+
+// doAt sends a request to the URL of b.
+func (c *HTTPClient) doAt(ctx context.Context, b *breaker, method, path string, body, out interface{}) error {
+	parent, timeout, key := ctx, c.opts.requestTimeout, endpointKey(method, path)
+	if c.opts.adaptive != nil {
+		timeout = c.opts.adaptive.timeout(key)
+	}
+	if timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	endpoint := b.url + path
+	c.opts.logger.Debugf("using url: %s", endpoint)
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal body: %w", err)
+		}
+		if r, ok := body.(redactor); ok {
+			c.opts.logger.Debugf("jsonBody: %s", r.redacted())
+		} else {
+			c.opts.logger.Debugf("jsonBody: %s", string(jsonBody))
+		}
+		reqBody.Write(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, &reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	sent := time.Now()
+	resp, err := c.send(b, req)
+	if c.opts.adaptive != nil {
+		switch {
+		case err == nil:
+			c.opts.adaptive.observe(key, time.Since(sent))
+		case ctx.Err() != nil && parent.Err() == nil:
+			// The adaptive deadline ran out, not the caller's.
+			c.opts.adaptive.observe(key, timeout)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, path, ErrFirmwareTooOld)
+	}
+	if resp.StatusCode == http.StatusLocked {
+		return fmt.Errorf("%s %s: the device is leased to another host: %w", method, path, ErrBoardBusy)
+	}
+	// Multi-Status carries per-pin results for the caller to check.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	// An empty body leaves out unchanged.
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	c.opts.logger.Debugf("response: %+v", out)
+	return nil
+}
@@ -0,0 +1,49 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MaxI2CTransfer is the most bytes the firmware writes or reads in one I2C transfer.
+const MaxI2CTransfer = 128
+
+// I2CTransfer has the firmware write data to the 7-bit address on its I2C bus, then, if
+// readLen is positive, read readLen bytes back after a repeated start, so a register
+// address can be written and the register read in one transfer other requests cannot
+// come between.
+func I2CTransfer(ctx context.Context, c Client, bus, address int, data []byte, readLen int) ([]byte, error) {
+	if address < 0x08 || address > 0x77 {
+		return nil, fmt.Errorf("i2c address 0x%02x must be a 7-bit address between 0x08 and 0x77", address)
+	}
+	if len(data) > MaxI2CTransfer || readLen < 0 || readLen > MaxI2CTransfer {
+		return nil, fmt.Errorf("i2c transfers write and read at most %d bytes", MaxI2CTransfer)
+	}
+	if len(data) == 0 && readLen == 0 {
+		return nil, fmt.Errorf("i2c transfer to 0x%02x has nothing to write or read", address)
+	}
+	// Bytes are sent as numbers, not the base64 encoding/json gives a []byte.
+	write := make([]int, len(data))
+	for i, b := range data {
+		write[i] = int(b)
+	}
+	body := map[string]interface{}{"bus": bus, "address": address, "write": write, "read": readLen}
+	var response struct {
+		Data []int `json:"data"`
+	}
+	if err := c.Call(ctx, http.MethodPost, "/i2c", body, &response); err != nil {
+		return nil, fmt.Errorf("i2c transfer to 0x%02x failed: %w", address, err)
+	}
+	if len(response.Data) != readLen {
+		return nil, fmt.Errorf("i2c transfer to 0x%02x read %d bytes, expected %d", address, len(response.Data), readLen)
+	}
+	read := make([]byte, readLen)
+	for i, b := range response.Data {
+		if b < 0 || b > 0xff {
+			return nil, fmt.Errorf("i2c transfer to 0x%02x read %d, not a byte", address, b)
+		}
+		read[i] = byte(b)
+	}
+	return read, nil
+}
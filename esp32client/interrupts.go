@@ -0,0 +1,57 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Interrupt edges and pulls accepted by ConfigureInterrupt.
+const (
+	EdgeRising  = "rising"
+	EdgeFalling = "falling"
+	EdgeBoth    = "both"
+
+	PullNone = "none"
+	PullUp   = "up"
+	PullDown = "down"
+)
+
+// InterruptConfig sets up the firmware's interrupt handler for a pin.
+type InterruptConfig struct {
+	PinNum int    `json:"pin_num"`
+	Edge   string `json:"edge"`
+	Pull   string `json:"pull"`
+	// Counter makes the firmware count the edges itself, to be read with
+	// ReadInterruptCounts, instead of pushing an event for each.
+	Counter bool `json:"counter,omitempty"`
+}
+
+// InterruptCount is the firmware's count of a counter interrupt since it booted.
+type InterruptCount struct {
+	PinNum int   `json:"pin_num"`
+	Count  int64 `json:"count"`
+	// TimestampUs is the device clock when the count was taken, zero on firmware without
+	// one.
+	TimestampUs uint64 `json:"timestamp_us,omitempty"`
+}
+
+// ConfigureInterrupt attaches an interrupt handler to a pin, replacing any the pin had.
+// The firmware pushes an interrupt event every time it fires.
+func ConfigureInterrupt(ctx context.Context, c Client, cfg InterruptConfig) error {
+	if err := c.Call(ctx, http.MethodPost, "/interrupts", cfg, nil); err != nil {
+		return fmt.Errorf("failed to configure interrupt on pin %d: %w", cfg.PinNum, err)
+	}
+	return nil
+}
+
+// ReadInterruptCounts reads the counts of the counter interrupts on pins in one request.
+func ReadInterruptCounts(ctx context.Context, c Client, pins []int) ([]InterruptCount, error) {
+	var response struct {
+		Counts []InterruptCount `json:"counts"`
+	}
+	if err := c.Call(ctx, http.MethodPost, "/interrupts/counts", map[string]interface{}{"pin_nums": pins}, &response); err != nil {
+		return nil, fmt.Errorf("failed to read interrupt counts: %w", err)
+	}
+	return response.Counts, nil
+}
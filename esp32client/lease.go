@@ -0,0 +1,49 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Lease asks the firmware for exclusive control of its outputs. While a host holds the
+// lease, the firmware answers pin writes that do not carry its holder with 423 Locked.
+type Lease struct {
+	// Holder identifies the host. Asking again with the same holder renews the lease.
+	Holder     string `json:"holder"`
+	DurationMs int    `json:"duration_ms"`
+}
+
+// LeaseStatus is the firmware's answer to a lease request.
+type LeaseStatus struct {
+	Granted bool `json:"granted"`
+	// Holder is the host holding the lease, the one that asked if it was granted.
+	Holder      string `json:"holder"`
+	RemainingMs int    `json:"remaining_ms"`
+}
+
+type leaseKey struct{}
+
+// WithLeaseHolder returns a context whose pin requests carry holder to the firmware, under
+// "lease" in their extra, so a device leased to holder accepts them.
+func WithLeaseHolder(ctx context.Context, holder string) context.Context {
+	return context.WithValue(ctx, leaseKey{}, holder)
+}
+
+// AcquireLease asks for or renews the lease. A lease held by another host is not an error:
+// the status is not granted and names the holder.
+func AcquireLease(ctx context.Context, c Client, lease Lease) (LeaseStatus, error) {
+	var status LeaseStatus
+	if err := c.Call(ctx, http.MethodPost, "/lease", lease, &status); err != nil {
+		return LeaseStatus{}, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return status, nil
+}
+
+// ReleaseLease gives up the lease holder holds, so another host can take it at once.
+func ReleaseLease(ctx context.Context, c Client, holder string) error {
+	if err := c.Call(ctx, http.MethodDelete, "/lease", map[string]interface{}{"holder": holder}, nil); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// LogEntry is a line of the firmware's log.
+type LogEntry struct {
+	// Seq numbers the entries since the device booted.
+	Seq uint64 `json:"seq"`
+	// TimestampUs is the device's uptime when the entry was logged.
+	TimestampUs int64 `json:"timestamp_us"`
+	// Level is the ESP-IDF log level, "E", "W", "I", "D" or "V".
+	Level   string `json:"level"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+}
+
+// Logs is a page of the firmware's log ring buffer.
+type Logs struct {
+	Entries []LogEntry `json:"entries"`
+	// NextSeq is the since to pass to read the entries logged after these.
+	NextSeq uint64 `json:"next_seq"`
+	// Dropped counts the entries after since that the ring buffer overwrote before they
+	// were read.
+	Dropped int `json:"dropped"`
+}
+
+// ReadLogs returns the entries of the firmware's log ring buffer numbered since or later.
+// Pass 0 for all the entries it still holds. Sequence numbers restart when the device
+// reboots.
+func ReadLogs(ctx context.Context, c Client, since uint64) (Logs, error) {
+	var logs Logs
+	path := "/logs?since=" + strconv.FormatUint(since, 10)
+	if err := c.Call(ctx, http.MethodGet, path, nil, &logs); err != nil {
+		return Logs{}, fmt.Errorf("failed to read logs: %w", err)
+	}
+	return logs, nil
+}
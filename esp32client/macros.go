@@ -0,0 +1,51 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MacroStep is one write of a macro. The firmware waits DelayMs after the write before
+// moving on to the next step.
+type MacroStep struct {
+	PinNum  int `json:"pin_num"`
+	State   int `json:"state"`
+	DelayMs int `json:"delay_ms,omitempty"`
+}
+
+// MacroProgress reports how far the device has got through a macro.
+type MacroProgress struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Step  int    `json:"step"`
+	Steps int    `json:"steps"`
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+// RunMacro starts a sequence of writes that the firmware executes on its own clock and
+// returns the run's ID.
+func RunMacro(ctx context.Context, c Client, name string, steps []MacroStep) (int, error) {
+	body := struct {
+		Name  string      `json:"name"`
+		Steps []MacroStep `json:"steps"`
+	}{name, steps}
+	var response struct {
+		ID int `json:"id"`
+	}
+	if err := c.Call(ctx, http.MethodPost, "/macros", body, &response); err != nil {
+		return 0, fmt.Errorf("failed to run macro %s: %w", name, err)
+	}
+	return response.ID, nil
+}
+
+// MacroStatus returns the progress of the macro run with the given ID, or ErrNotFound if
+// the device does not know it.
+func MacroStatus(ctx context.Context, c Client, id int) (MacroProgress, error) {
+	var progress MacroProgress
+	if err := c.Call(ctx, http.MethodGet, fmt.Sprintf("/macros/%d", id), nil, &progress); err != nil {
+		return MacroProgress{}, fmt.Errorf("failed to get status of macro run %d: %w", id, notFound(err))
+	}
+	return progress, nil
+}
@@ -0,0 +1,22 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// GPIOBankSize is the number of GPIOs in each output register of the chip. Bit n of a
+// mask is GPIO n, and a masked write only updates pins in one bank atomically.
+const GPIOBankSize = 32
+
+// WriteMask sets every GPIO whose bit is set in mask to the matching bit of values, in
+// one write of the bank's output register, e.g. to drive an 8-bit parallel bus in one
+// request. Pins outside mask keep their state.
+func WriteMask(ctx context.Context, c Client, mask, values uint64) error {
+	body := map[string]uint64{"mask": mask, "values": values}
+	if err := c.Call(ctx, http.MethodPost, "/write-mask", body, nil); err != nil {
+		return fmt.Errorf("failed to write mask %#x: %w", mask, err)
+	}
+	return nil
+}
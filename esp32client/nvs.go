@@ -0,0 +1,66 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// MaxNVSKeyLength is the longest key ESP-IDF's NVS library accepts.
+const MaxNVSKeyLength = 15
+
+func nvsPath(namespace, key string) (string, error) {
+	if key == "" || len(key) > MaxNVSKeyLength {
+		return "", fmt.Errorf("nvs key %q must be between 1 and %d characters", key, MaxNVSKeyLength)
+	}
+	if len(namespace) > MaxNVSKeyLength {
+		return "", fmt.Errorf("nvs namespace %q must be at most %d characters", namespace, MaxNVSKeyLength)
+	}
+	path := "/nvs/" + url.PathEscape(key)
+	if namespace != "" {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+	return path, nil
+}
+
+// NVSGet returns the value stored under key in the device's non-volatile storage, or
+// ErrNotFound if nothing is. An empty namespace uses the firmware's default namespace.
+func NVSGet(ctx context.Context, c Client, namespace, key string) (interface{}, error) {
+	path, err := nvsPath(namespace, key)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Value interface{} `json:"value"`
+	}
+	if err := c.Call(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get nvs key %q: %w", key, notFound(err))
+	}
+	return response.Value, nil
+}
+
+// NVSSet stores value under key. Strings, numbers, booleans and JSON objects are accepted.
+func NVSSet(ctx context.Context, c Client, namespace, key string, value interface{}) error {
+	path, err := nvsPath(namespace, key)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"value": value}
+	if err := c.Call(ctx, http.MethodPut, path, body, nil); err != nil {
+		return fmt.Errorf("failed to set nvs key %q: %w", key, err)
+	}
+	return nil
+}
+
+// NVSErase removes key, returning ErrNotFound if it is not stored.
+func NVSErase(ctx context.Context, c Client, namespace, key string) error {
+	path, err := nvsPath(namespace, key)
+	if err != nil {
+		return err
+	}
+	if err := c.Call(ctx, http.MethodDelete, path, nil, nil); err != nil {
+		return fmt.Errorf("failed to erase nvs key %q: %w", key, notFound(err))
+	}
+	return nil
+}
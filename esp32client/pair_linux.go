@@ -0,0 +1,109 @@
+//go:build linux && !nobluetooth
+
+package esp32client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"tinygo.org/x/bluetooth"
+)
+
+const (
+	bluezAdapterPath = "/org/bluez/hci0"
+	agentPath        = dbus.ObjectPath("/esp32client/agent")
+)
+
+// pairDevice bonds with the connected device through BlueZ. BlueZ stores the bond under
+// /var/lib/bluetooth, so once a device reports Paired it is reused on every reconnect
+// without asking for the passkey again.
+func pairDevice(address bluetooth.Address, security SecurityLevel, passkey uint32, logger Logger) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+
+	devicePath := dbus.ObjectPath(bluezAdapterPath + "/dev_" + strings.ReplaceAll(address.MAC.String(), ":", "_"))
+	device := conn.Object("org.bluez", devicePath)
+
+	paired, err := device.GetProperty("org.bluez.Device1.Paired")
+	if err != nil {
+		return fmt.Errorf("failed to read paired state: %w", err)
+	}
+	if isPaired, ok := paired.Value().(bool); ok && isPaired {
+		logger.Infof("Reusing existing bond with %s", address.String())
+		return nil
+	}
+
+	capability := "NoInputNoOutput"
+	if security == SecurityPasskey {
+		capability = "KeyboardOnly"
+		if err := conn.Export(&passkeyAgent{passkey: passkey}, agentPath, "org.bluez.Agent1"); err != nil {
+			return fmt.Errorf("failed to export pairing agent: %w", err)
+		}
+		defer conn.Export(nil, agentPath, "org.bluez.Agent1")
+
+		manager := conn.Object("org.bluez", "/org/bluez")
+		if err := manager.Call("org.bluez.AgentManager1.RegisterAgent", 0, agentPath, capability).Err; err != nil {
+			return fmt.Errorf("failed to register pairing agent: %w", err)
+		}
+		defer manager.Call("org.bluez.AgentManager1.UnregisterAgent", 0, agentPath)
+		if err := manager.Call("org.bluez.AgentManager1.RequestDefaultAgent", 0, agentPath).Err; err != nil {
+			return fmt.Errorf("failed to make pairing agent the default: %w", err)
+		}
+	}
+
+	logger.Infof("Pairing with %s (%s)", address.String(), capability)
+	if err := device.Call("org.bluez.Device1.Pair", 0).Err; err != nil {
+		return fmt.Errorf("failed to pair: %w", err)
+	}
+	if err := device.SetProperty("org.bluez.Device1.Trusted", dbus.MakeVariant(true)); err != nil {
+		return fmt.Errorf("failed to mark device trusted: %w", err)
+	}
+	return nil
+}
+
+// passkeyAgent answers BlueZ's org.bluez.Agent1 callbacks with a static passkey.
+type passkeyAgent struct {
+	passkey uint32
+}
+
+func (a *passkeyAgent) Release() *dbus.Error {
+	return nil
+}
+
+func (a *passkeyAgent) RequestPinCode(device dbus.ObjectPath) (string, *dbus.Error) {
+	return fmt.Sprintf("%06d", a.passkey), nil
+}
+
+func (a *passkeyAgent) DisplayPinCode(device dbus.ObjectPath, pincode string) *dbus.Error {
+	return nil
+}
+
+func (a *passkeyAgent) RequestPasskey(device dbus.ObjectPath) (uint32, *dbus.Error) {
+	return a.passkey, nil
+}
+
+func (a *passkeyAgent) DisplayPasskey(device dbus.ObjectPath, passkey uint32, entered uint16) *dbus.Error {
+	return nil
+}
+
+func (a *passkeyAgent) RequestConfirmation(device dbus.ObjectPath, passkey uint32) *dbus.Error {
+	if passkey != a.passkey {
+		return dbus.NewError("org.bluez.Error.Rejected", []interface{}{"passkey mismatch"})
+	}
+	return nil
+}
+
+func (a *passkeyAgent) RequestAuthorization(device dbus.ObjectPath) *dbus.Error {
+	return nil
+}
+
+func (a *passkeyAgent) AuthorizeService(device dbus.ObjectPath, uuid string) *dbus.Error {
+	return nil
+}
+
+func (a *passkeyAgent) Cancel() *dbus.Error {
+	return nil
+}
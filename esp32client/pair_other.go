@@ -0,0 +1,12 @@
+//go:build !linux && !nobluetooth
+
+package esp32client
+
+import "tinygo.org/x/bluetooth"
+
+// pairDevice is a no-op outside Linux: macOS and Windows pair on first access to an
+// encrypted characteristic and prompt for the passkey themselves.
+func pairDevice(address bluetooth.Address, security SecurityLevel, passkey uint32, logger Logger) error {
+	logger.Infof("Pairing with %s is handled by the operating system", address.String())
+	return nil
+}
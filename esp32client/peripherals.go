@@ -0,0 +1,28 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Peripheral drivers ResetPeripherals can reinitialize.
+const (
+	// PeripheralLEDC drives PWM outputs. Resetting it stops every PWM pin.
+	PeripheralLEDC = "ledc"
+	// PeripheralPCNT counts pulses. Resetting it clears the counters.
+	PeripheralPCNT = "pcnt"
+	// PeripheralRMT generates and captures pulse trains.
+	PeripheralRMT = "rmt"
+)
+
+// ResetPeripherals has the firmware tear down and reinitialize the drivers of
+// peripherals, e.g. after runtime frequency and interrupt changes left one in a bad state.
+// Anything configured on them, such as PWM outputs and interrupt handlers, is lost.
+func ResetPeripherals(ctx context.Context, c Client, peripherals []string) error {
+	body := map[string]interface{}{"peripherals": peripherals}
+	if err := c.Call(ctx, http.MethodPost, "/reset-peripherals", body, nil); err != nil {
+		return fmt.Errorf("failed to reset peripherals: %w", err)
+	}
+	return nil
+}
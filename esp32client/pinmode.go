@@ -0,0 +1,38 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Modes a pin can be switched to at runtime.
+const (
+	// PinModeInput releases a pin to a high-impedance input, so it no longer drives the
+	// line.
+	PinModeInput = "input"
+	// PinModeOutput drives the pin both high and low.
+	PinModeOutput = "output"
+	// PinModeOpenDrain drives the pin low and releases it for high, leaving a pull-up to
+	// raise the line, as on open-collector buses such as I2C.
+	PinModeOpenDrain = "open_drain"
+)
+
+// PinMode switches a pin's direction at runtime. The next write to a released pin drives
+// it again.
+type PinMode struct {
+	PinNum int    `json:"pin_num"`
+	Mode   string `json:"mode"`
+	// PullUp enables the chip's weak internal pull-up, for open-drain pins without a
+	// resistor on the line.
+	PullUp bool `json:"pull_up,omitempty"`
+}
+
+// SetPinModes switches the pins' modes in a single request.
+func SetPinModes(ctx context.Context, c Client, modes []PinMode) error {
+	body := map[string]interface{}{"pin_modes": modes}
+	if err := c.Call(ctx, http.MethodPost, "/pin-modes", body, nil); err != nil {
+		return fmt.Errorf("failed to set pin modes: %w", err)
+	}
+	return nil
+}
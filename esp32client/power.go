@@ -0,0 +1,25 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Power is the supply telemetry the firmware reports.
+type Power struct {
+	// SupplyVoltage is the chip's supply voltage in volts.
+	SupplyVoltage float64 `json:"supply_voltage"`
+	// Brownouts counts brownout resets since the counter was last erased. The firmware
+	// keeps it in NVS, so it survives reboots.
+	Brownouts int `json:"brownouts"`
+}
+
+// ReadPower returns the device's supply voltage and brownout count.
+func ReadPower(ctx context.Context, c Client) (Power, error) {
+	var power Power
+	if err := c.Call(ctx, http.MethodGet, "/power", nil, &power); err != nil {
+		return Power{}, fmt.Errorf("failed to read power: %w", err)
+	}
+	return power, nil
+}
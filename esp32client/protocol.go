@@ -0,0 +1,122 @@
+package esp32client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Protocol versions accepted by WithProtocolVersion.
+const (
+	// ProtocolAuto detects the version from the first pin read.
+	ProtocolAuto = 0
+	// ProtocolLegacy is spoken by older firmware, which reads pins with {"pins": [...]}
+	// and answers {"values": [...]}.
+	ProtocolLegacy = 1
+	// ProtocolCurrent reads pins with {"pin_reads": [...]} and answers in kind.
+	ProtocolCurrent = 2
+)
+
+// WithProtocolVersion sets the payload format of pin reads. The default, ProtocolAuto,
+// tries the current format first and falls back to the legacy one if the firmware does
+// not answer it, remembering whichever worked.
+func WithProtocolVersion(version int) Option {
+	return func(o *options) {
+		o.protocolVersion = version
+	}
+}
+
+// protocol tracks the payload format of a client's pin reads.
+type protocol struct {
+	configured int
+	detected   atomic.Int32
+	logger     Logger
+}
+
+func newProtocol(o options) *protocol {
+	return &protocol{configured: o.protocolVersion, logger: o.logger}
+}
+
+// version returns the format to use for the next read.
+func (p *protocol) version() int {
+	if p.configured != ProtocolAuto {
+		return p.configured
+	}
+	if detected := int(p.detected.Load()); detected != ProtocolAuto {
+		return detected
+	}
+	return ProtocolCurrent
+}
+
+// readBody returns the body of a read of pins in version's format.
+func readBody(version int, pins []int) map[string]interface{} {
+	if version == ProtocolLegacy {
+		return map[string]interface{}{"pins": pins}
+	}
+	return map[string]interface{}{"pin_reads": pins}
+}
+
+// readPins sends a read of pins through send, which fills in the response to the body
+// it is given, and detects the protocol version on the first read if it is not set.
+func (p *protocol) readPins(pins []int, send func(body map[string]interface{}, response *readsResponse) error) ([]PinRead, error) {
+	version := p.version()
+	reads, err := p.tryRead(version, pins, send)
+	if p.configured != ProtocolAuto || p.detected.Load() != ProtocolAuto {
+		return reads, err
+	}
+	if err == nil {
+		p.detected.Store(int32(version))
+		return reads, nil
+	}
+	// A device that did not answer says nothing about its protocol.
+	if errors.Is(err, ErrDeviceUnreachable) || errors.Is(err, ErrTimeout) {
+		return nil, err
+	}
+	legacyReads, legacyErr := p.tryRead(ProtocolLegacy, pins, send)
+	if legacyErr != nil {
+		return nil, err
+	}
+	p.detected.Store(ProtocolLegacy)
+	p.logger.Infof("firmware speaks the legacy pin read protocol, using it from now on")
+	return legacyReads, nil
+}
+
+func (p *protocol) tryRead(version int, pins []int, send func(body map[string]interface{}, response *readsResponse) error) ([]PinRead, error) {
+	var response readsResponse
+	if err := send(readBody(version, pins), &response); err != nil {
+		return nil, err
+	}
+	return response.reads(pins)
+}
+
+// readsResponse is a response to a pin read in either protocol version.
+type readsResponse struct {
+	PinReads []PinRead `json:"pin_reads"`
+	// Values are legacy reads, in the order requested: either bare states or objects
+	// like PinRead.
+	Values []json.RawMessage `json:"values"`
+}
+
+// reads returns the reads of pins the response holds.
+func (r *readsResponse) reads(pins []int) ([]PinRead, error) {
+	if r.PinReads == nil && r.Values != nil {
+		reads := make([]PinRead, len(r.Values))
+		for i, raw := range r.Values {
+			if i < len(pins) {
+				reads[i].PinNum = pins[i]
+			}
+			if err := json.Unmarshal(raw, &reads[i].State); err == nil {
+				continue
+			}
+			if err := json.Unmarshal(raw, &reads[i]); err != nil {
+				return nil, fmt.Errorf("failed to decode value %d: %w", i, err)
+			}
+		}
+		r.PinReads = reads
+	}
+	if len(r.PinReads) != len(pins) {
+		return nil, fmt.Errorf("requested %d pins but got %d", len(pins), len(r.PinReads))
+	}
+	return r.PinReads, nil
+}
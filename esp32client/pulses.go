@@ -0,0 +1,21 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CountPulses counts rising edges on pin with the device's pulse counter for window. The
+// request returns once the window has passed.
+func CountPulses(ctx context.Context, c Client, pin int, window time.Duration) (uint64, error) {
+	body := map[string]interface{}{"pin_num": pin, "window_ms": window.Milliseconds()}
+	var response struct {
+		Count uint64 `json:"count"`
+	}
+	if err := c.Call(ctx, http.MethodPost, "/pulse-count", body, &response); err != nil {
+		return 0, fmt.Errorf("failed to count pulses on pin %d: %w", pin, err)
+	}
+	return response.Count, nil
+}
@@ -0,0 +1,180 @@
+package esp32client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// eventMethod is the method of recorded events, which are pushed rather than requested.
+const eventMethod = "EVENT"
+
+// Error kinds of a recorded exchange, so a replay fails the same way.
+const (
+	errorKindUnreachable  = "unreachable"
+	errorKindTimeout      = "timeout"
+	errorKindNotSupported = "not_supported"
+	errorKindDevice       = "device"
+)
+
+// Exchange is one request to the device and its outcome, or one event it pushed, as
+// written to a recording. Requests over every transport are recorded as the equivalent
+// HTTP request, e.g. a BLE pin read as POST /read-pins.
+type Exchange struct {
+	Time       time.Time       `json:"time"`
+	DurationMs float64         `json:"duration_ms"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	// ErrorKind is unreachable, timeout, not_supported or device.
+	ErrorKind string `json:"error_kind,omitempty"`
+}
+
+// Recorder is a Client that writes every exchange with the client it wraps to a JSONL
+// recording, for reproducing firmware interaction bugs offline with a ReplayClient.
+// Secrets such as WiFi passwords are redacted.
+type Recorder struct {
+	client Client
+	w      io.Writer
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRecorder records c's exchanges to w. Close closes c, and w if it is an io.Closer.
+func NewRecorder(c Client, w io.Writer) *Recorder {
+	return &Recorder{client: c, w: w, enc: json.NewEncoder(w)}
+}
+
+// Unwrap returns the recorded client.
+func (r *Recorder) Unwrap() Client {
+	return r.client
+}
+
+// record writes an exchange that started at start. Failures to write are dropped, a
+// recording must not break the requests it records.
+func (r *Recorder) record(start time.Time, method, path string, request, response interface{}, err error) {
+	exchange := Exchange{
+		Time:       start,
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+		Method:     method,
+		Path:       path,
+		Request:    recordedJSON(request),
+	}
+	if err != nil {
+		exchange.Error, exchange.ErrorKind = err.Error(), errorKind(err)
+	} else {
+		exchange.Response = recordedJSON(response)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(exchange)
+}
+
+// recordedJSON marshals v for a recording, redacting it if it carries secrets.
+func recordedJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	if red, ok := v.(redactor); ok {
+		return json.RawMessage(red.redacted())
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded):
+		return errorKindTimeout
+	case errors.Is(err, ErrDeviceUnreachable):
+		return errorKindUnreachable
+	case errors.Is(err, ErrNotSupported):
+		return errorKindNotSupported
+	default:
+		return errorKindDevice
+	}
+}
+
+// ReadPins records a read as POST /read-pins.
+func (r *Recorder) ReadPins(ctx context.Context, pins []int) ([]PinRead, error) {
+	start := time.Now()
+	reads, err := r.client.ReadPins(ctx, pins)
+	r.record(start, http.MethodPost, "/read-pins",
+		map[string]interface{}{"pin_reads": pins}, map[string]interface{}{"pin_reads": reads}, err)
+	return reads, err
+}
+
+// WritePins records the writes as POST /write-pins.
+func (r *Recorder) WritePins(ctx context.Context, writes []PinWrite) error {
+	start := time.Now()
+	err := r.client.WritePins(ctx, writes)
+	r.record(start, http.MethodPost, "/write-pins", map[string]interface{}{"pin_writes": writes}, struct{}{}, err)
+	return err
+}
+
+// Transaction records the writes as POST /transaction.
+func (r *Recorder) Transaction(ctx context.Context, writes []PinWrite) error {
+	start := time.Now()
+	err := r.client.Transaction(ctx, writes)
+	r.record(start, http.MethodPost, "/transaction", map[string]interface{}{"pin_writes": writes}, struct{}{}, err)
+	return err
+}
+
+// SetPWMFreqs records the frequencies as POST /write-pins.
+func (r *Recorder) SetPWMFreqs(ctx context.Context, freqs []PinFreq) error {
+	start := time.Now()
+	err := r.client.SetPWMFreqs(ctx, freqs)
+	r.record(start, http.MethodPost, "/write-pins", map[string]interface{}{"pin_freqs": freqs}, struct{}{}, err)
+	return err
+}
+
+// Info records GET /info.
+func (r *Recorder) Info(ctx context.Context) (Info, error) {
+	start := time.Now()
+	info, err := r.client.Info(ctx)
+	r.record(start, http.MethodGet, "/info", nil, info, err)
+	return info, err
+}
+
+// Subscribe polls through the recorder so the reads are recorded.
+func (r *Recorder) Subscribe(ctx context.Context, pin int, fn func(PinRead)) (func(), error) {
+	return pollSubscribe(ctx, r, nopLogger{}, defaultSubscribeInterval, pin, fn)
+}
+
+// Call records the request as is.
+func (r *Recorder) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	start := time.Now()
+	err := r.client.Call(ctx, method, path, body, out)
+	r.record(start, method, path, body, out, err)
+	return err
+}
+
+// Events records every event the device pushes.
+func (r *Recorder) Events(ctx context.Context, fn func(Event)) error {
+	err := r.client.Events(ctx, func(event Event) {
+		r.record(time.Now(), eventMethod, "/events", nil, event, nil)
+		fn(event)
+	})
+	return err
+}
+
+// Close closes the recorded client and the recording.
+func (r *Recorder) Close() error {
+	err := r.client.Close()
+	if closer, ok := r.w.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,240 @@
+package esp32client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxExchangeSize bounds a single line of a recording.
+const maxExchangeSize = 1 << 20
+
+// ReplayClient is a Client that answers from a recording made by a Recorder, so a bug
+// report's firmware interaction can be reproduced without the device. Each request is
+// answered by the next unused exchange with the same method and path, failing the same
+// way it failed when recorded. It is also an http.Handler that serves the recording as
+// firmware, so the board models can be pointed at it.
+type ReplayClient struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+	events    []Event
+	// eventsServed is set once ServeHTTP has answered /events with the recorded events.
+	eventsServed bool
+}
+
+// NewReplayClient reads a recording.
+func NewReplayClient(r io.Reader) (*ReplayClient, error) {
+	c := &ReplayClient{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxExchangeSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		if exchange.Method == eventMethod {
+			var event Event
+			if err := json.Unmarshal(exchange.Response, &event); err != nil {
+				return nil, fmt.Errorf("recording line %d: invalid event: %w", line, err)
+			}
+			c.events = append(c.events, event)
+			continue
+		}
+		c.exchanges = append(c.exchanges, exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	c.used = make([]bool, len(c.exchanges))
+	return c, nil
+}
+
+// Remaining returns how many recorded requests have not been replayed.
+func (c *ReplayClient) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	remaining := 0
+	for _, used := range c.used {
+		if !used {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// next returns the next unused exchange for method and path.
+func (c *ReplayClient) next(method, path string) (Exchange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, exchange := range c.exchanges {
+		if !c.used[i] && exchange.Method == method && exchange.Path == path {
+			c.used[i] = true
+			return exchange, nil
+		}
+	}
+	return Exchange{}, fmt.Errorf("%s %s: no more recorded exchanges, the replay diverged from the recording", method, path)
+}
+
+// replay answers a request from the recording, decoding the response into out (if
+// non-nil).
+func (c *ReplayClient) replay(method, path string, out interface{}) error {
+	exchange, err := c.next(method, path)
+	if err != nil {
+		return err
+	}
+	if exchange.Error != "" {
+		return exchange.err()
+	}
+	if out == nil || len(exchange.Response) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(exchange.Response, out); err != nil {
+		return fmt.Errorf("%s %s: failed to decode recorded response: %w", method, path, err)
+	}
+	return nil
+}
+
+// err returns the recorded error, wrapping the sentinel of its kind.
+func (e Exchange) err() error {
+	switch e.ErrorKind {
+	case errorKindUnreachable:
+		return fmt.Errorf("recorded: %s: %w", e.Error, ErrDeviceUnreachable)
+	case errorKindTimeout:
+		return fmt.Errorf("recorded: %s: %w", e.Error, ErrTimeout)
+	case errorKindNotSupported:
+		return fmt.Errorf("recorded: %s: %w", e.Error, ErrNotSupported)
+	default:
+		return fmt.Errorf("recorded: %s", e.Error)
+	}
+}
+
+// ReadPins replays POST /read-pins.
+func (c *ReplayClient) ReadPins(ctx context.Context, pins []int) ([]PinRead, error) {
+	var response struct {
+		PinReads []PinRead `json:"pin_reads"`
+	}
+	if err := c.replay(http.MethodPost, "/read-pins", &response); err != nil {
+		return nil, err
+	}
+	return response.PinReads, nil
+}
+
+// WritePins replays POST /write-pins.
+func (c *ReplayClient) WritePins(ctx context.Context, writes []PinWrite) error {
+	return c.replay(http.MethodPost, "/write-pins", nil)
+}
+
+// Transaction replays POST /transaction.
+func (c *ReplayClient) Transaction(ctx context.Context, writes []PinWrite) error {
+	return c.replay(http.MethodPost, "/transaction", nil)
+}
+
+// SetPWMFreqs replays POST /write-pins.
+func (c *ReplayClient) SetPWMFreqs(ctx context.Context, freqs []PinFreq) error {
+	return c.replay(http.MethodPost, "/write-pins", nil)
+}
+
+// Info replays GET /info.
+func (c *ReplayClient) Info(ctx context.Context) (Info, error) {
+	var info Info
+	if err := c.replay(http.MethodGet, "/info", &info); err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// Subscribe polls the replayed reads.
+func (c *ReplayClient) Subscribe(ctx context.Context, pin int, fn func(PinRead)) (func(), error) {
+	return pollSubscribe(ctx, c, nopLogger{}, defaultSubscribeInterval, pin, fn)
+}
+
+// Call replays the request at method and path.
+func (c *ReplayClient) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.replay(method, path, out)
+}
+
+// Events delivers every recorded event in order, then waits for ctx to be done.
+func (c *ReplayClient) Events(ctx context.Context, fn func(Event)) error {
+	c.mu.Lock()
+	events := c.events
+	c.mu.Unlock()
+	if len(events) == 0 {
+		return fmt.Errorf("events: none recorded: %w", ErrNotSupported)
+	}
+	for _, event := range events {
+		if ctx.Err() != nil {
+			break
+		}
+		fn(event)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Close does nothing.
+func (c *ReplayClient) Close() error {
+	return nil
+}
+
+// ServeHTTP answers a request as the recorded firmware did. Requests that failed to reach
+// the device when recorded get their connection dropped.
+func (c *ReplayClient) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/events" {
+		c.serveEvents(w, r)
+		return
+	}
+	exchange, err := c.next(r.Method, r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	switch exchange.ErrorKind {
+	case "":
+	case errorKindUnreachable, errorKindTimeout:
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		http.Error(w, exchange.Error, http.StatusBadGateway)
+		return
+	case errorKindNotSupported:
+		http.Error(w, exchange.Error, http.StatusNotFound)
+		return
+	default:
+		http.Error(w, exchange.Error, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(exchange.Response) != 0 {
+		w.Write(exchange.Response)
+	}
+}
+
+// serveEvents answers the first /events poll with every recorded event and holds later
+// polls open until the client gives up, like firmware with nothing to report.
+func (c *ReplayClient) serveEvents(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	events, served := c.events, c.eventsServed
+	c.eventsServed = true
+	c.mu.Unlock()
+	if len(events) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if served {
+		<-r.Context().Done()
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+}
@@ -0,0 +1,16 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Restart makes the firmware reboot, as esp_restart does. The device answers before it
+// restarts, so the next requests fail until it is back.
+func Restart(ctx context.Context, c Client) error {
+	if err := c.Call(ctx, http.MethodPost, "/restart", nil, nil); err != nil {
+		return fmt.Errorf("failed to restart: %w", err)
+	}
+	return nil
+}
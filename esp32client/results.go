@@ -0,0 +1,80 @@
+package esp32client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PinResult is the firmware's outcome for one pin of a batched request. Firmware that
+// reports them answers a partially failed request with 207 Multi-Status.
+type PinResult struct {
+	PinNum int    `json:"pin_num"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchResponse is the response to a batched request.
+type batchResponse struct {
+	Results []PinResult `json:"results"`
+}
+
+// PinError is why the firmware rejected one pin of a batched request.
+type PinError struct {
+	PinNum int
+	Reason string
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("pin %d: %s", e.PinNum, e.Reason)
+}
+
+// Unwrap makes every rejected pin an ErrInvalidPin.
+func (e *PinError) Unwrap() error {
+	return ErrInvalidPin
+}
+
+// PartialWriteError is returned when the firmware applied some pins of a batched write
+// and rejected others. Use errors.As to find which.
+type PartialWriteError struct {
+	// Applied are the pins that were written.
+	Applied []int
+	Failed  []*PinError
+}
+
+func (e *PartialWriteError) Error() string {
+	reasons := make([]string, len(e.Failed))
+	for i, failed := range e.Failed {
+		reasons[i] = failed.Error()
+	}
+	return fmt.Sprintf("%d of %d pins failed (%s)", len(e.Failed), len(e.Failed)+len(e.Applied), strings.Join(reasons, "; "))
+}
+
+// Unwrap returns the PinError of every failed pin.
+func (e *PartialWriteError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, failed := range e.Failed {
+		errs[i] = failed
+	}
+	return errs
+}
+
+// err returns a PartialWriteError if any pin failed. Firmware without per-pin results
+// sends none, which counts as success.
+func (r batchResponse) err() error {
+	partial := &PartialWriteError{}
+	for _, result := range r.Results {
+		if result.OK {
+			partial.Applied = append(partial.Applied, result.PinNum)
+			continue
+		}
+		reason := result.Error
+		if reason == "" {
+			reason = "rejected by the device"
+		}
+		partial.Failed = append(partial.Failed, &PinError{PinNum: result.PinNum, Reason: reason})
+	}
+	if len(partial.Failed) == 0 {
+		return nil
+	}
+	return partial
+}
@@ -0,0 +1,51 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Schedule is a pin write the firmware executes on its own clock. When the schedule fires
+// the pin is set to State; if DurationMs is set it is set to RestoreState that long
+// afterwards. A non-zero IntervalMs repeats the schedule until it is cancelled.
+type Schedule struct {
+	ID           int `json:"id,omitempty"`
+	PinNum       int `json:"pin_num"`
+	State        int `json:"state"`
+	DurationMs   int `json:"duration_ms,omitempty"`
+	RestoreState int `json:"restore_state"`
+	DelayMs      int `json:"delay_ms,omitempty"`
+	IntervalMs   int `json:"interval_ms,omitempty"`
+}
+
+// CreateSchedule starts a schedule on the device and returns its ID.
+func CreateSchedule(ctx context.Context, c Client, schedule Schedule) (int, error) {
+	var response struct {
+		ID int `json:"id"`
+	}
+	if err := c.Call(ctx, http.MethodPost, "/schedules", schedule, &response); err != nil {
+		return 0, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return response.ID, nil
+}
+
+// CancelSchedule stops the schedule with the given ID, returning ErrNotFound if the
+// device has no such schedule, e.g. because it already finished.
+func CancelSchedule(ctx context.Context, c Client, id int) error {
+	if err := c.Call(ctx, http.MethodDelete, fmt.Sprintf("/schedules/%d", id), nil, nil); err != nil {
+		return fmt.Errorf("failed to cancel schedule %d: %w", id, notFound(err))
+	}
+	return nil
+}
+
+// Schedules returns every schedule active on the device.
+func Schedules(ctx context.Context, c Client) ([]Schedule, error) {
+	var response struct {
+		Schedules []Schedule `json:"schedules"`
+	}
+	if err := c.Call(ctx, http.MethodGet, "/schedules", nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	return response.Schedules, nil
+}
@@ -0,0 +1,16 @@
+package esp32client
+
+// SecurityLevel is how a BLE connection is secured.
+type SecurityLevel string
+
+const (
+	// SecurityNone connects without pairing.
+	SecurityNone SecurityLevel = "none"
+	// SecurityBond pairs without a passkey ("just works") and keeps the bond.
+	SecurityBond SecurityLevel = "bond"
+	// SecurityPasskey pairs using a static six digit passkey and keeps the bond.
+	SecurityPasskey SecurityLevel = "passkey"
+)
+
+// MaxPasskey is the largest passkey BLE pairing accepts.
+const MaxPasskey = 999999
@@ -0,0 +1,27 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// WakeSchedule tells firmware that light sleeps between requests when to stay awake.
+type WakeSchedule struct {
+	// IntervalMs is the time between the starts of two wake windows.
+	IntervalMs int `json:"wake_interval_ms"`
+	// WindowMs is how long the device stays awake in each window.
+	WindowMs int `json:"window_ms"`
+	// NextWakeMs is how long from now the next window starts, so the device's windows
+	// line up with the host's.
+	NextWakeMs int `json:"next_wake_ms"`
+}
+
+// SetWakeSchedule makes the device sleep outside the windows of schedule. It still wakes
+// for requests that arrive while it sleeps, only later.
+func SetWakeSchedule(ctx context.Context, c Client, schedule WakeSchedule) error {
+	if err := c.Call(ctx, http.MethodPost, "/wake-schedule", schedule, nil); err != nil {
+		return fmt.Errorf("failed to set wake schedule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package esp32client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// WiFiNetwork is a network visible to the device.
+type WiFiNetwork struct {
+	SSID    string `json:"ssid"`
+	RSSI    int    `json:"rssi"`
+	Channel int    `json:"channel"`
+	Auth    string `json:"auth,omitempty"`
+}
+
+type wifiCredentials struct {
+	SSID     string `json:"ssid"`
+	Password string `json:"password,omitempty"`
+}
+
+func (c wifiCredentials) redacted() string {
+	return fmt.Sprintf(`{"ssid":%q,"password":"<redacted>"}`, c.SSID)
+}
+
+// ScanWiFi returns the networks the device can see.
+func ScanWiFi(ctx context.Context, c Client) ([]WiFiNetwork, error) {
+	var response struct {
+		Networks []WiFiNetwork `json:"networks"`
+	}
+	if err := c.Call(ctx, http.MethodGet, "/wifi/scan", nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to scan wifi: %w", err)
+	}
+	return response.Networks, nil
+}
+
+// ConnectWiFi asks the device to join another network. The device drops its current
+// connection, so it may come back at a different address.
+func ConnectWiFi(ctx context.Context, c Client, ssid, password string) error {
+	body := wifiCredentials{SSID: ssid, Password: password}
+	if err := c.Call(ctx, http.MethodPost, "/wifi/connect", body, nil); err != nil {
+		return fmt.Errorf("failed to connect wifi: %w", err)
+	}
+	return nil
+}
+
+// ReadWiFiStatus returns the state of the device's WiFi connection.
+func ReadWiFiStatus(ctx context.Context, c Client) (WiFiStatus, error) {
+	var status WiFiStatus
+	if err := c.Call(ctx, http.MethodGet, "/wifi/status", nil, &status); err != nil {
+		return WiFiStatus{}, fmt.Errorf("failed to read wifi status: %w", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,18 @@
+//go:build darwin && !nobluetooth
+
+package esp32client
+
+import "tinygo.org/x/bluetooth"
+
+// writeCharacteristic writes with response on macOS. CoreBluetooth silently drops writes
+// without response once its send buffer is full, which loses pin writes sent in quick
+// succession.
+func writeCharacteristic(char bluetooth.DeviceCharacteristic, data []byte) (int, error) {
+	return char.Write(data)
+}
+
+// writeCharacteristicWithResponse is the same write, which on macOS is always
+// acknowledged.
+func writeCharacteristicWithResponse(char bluetooth.DeviceCharacteristic, address bluetooth.Address, data []byte) (int, error) {
+	return char.Write(data)
+}
@@ -0,0 +1,69 @@
+//go:build linux && !nobluetooth
+
+package esp32client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"tinygo.org/x/bluetooth"
+)
+
+// writeCharacteristic writes without waiting for an acknowledgement. BlueZ queues the
+// writes itself, so none are dropped here, but one the device does not take is not
+// reported; writeCharacteristicWithResponse is the reliable path.
+func writeCharacteristic(char bluetooth.DeviceCharacteristic, data []byte) (int, error) {
+	return char.WriteWithoutResponse(data)
+}
+
+// bluezCharacteristics caches the D-Bus paths of characteristics, by device path and
+// UUID.
+var bluezCharacteristics sync.Map
+
+// writeCharacteristicWithResponse asks BlueZ for a write request, which the device
+// acknowledges. The bluetooth package only writes without asking for a type, so the
+// characteristic is written through its D-Bus object directly.
+func writeCharacteristicWithResponse(char bluetooth.DeviceCharacteristic, address bluetooth.Address, data []byte) (int, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	devicePath := bluezAdapterPath + "/dev_" + strings.ReplaceAll(address.MAC.String(), ":", "_")
+	key := devicePath + " " + char.UUID().String()
+	path, ok := bluezCharacteristics.Load(key)
+	if !ok {
+		if path, err = findCharacteristic(conn, devicePath, char.UUID().String()); err != nil {
+			return 0, err
+		}
+		bluezCharacteristics.Store(key, path)
+	}
+	options := map[string]dbus.Variant{"type": dbus.MakeVariant("request")}
+	err = conn.Object("org.bluez", path.(dbus.ObjectPath)).Call("org.bluez.GattCharacteristic1.WriteValue", 0, data, options).Err
+	if err != nil {
+		// BlueZ may have dropped the object with the connection.
+		bluezCharacteristics.Delete(key)
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// findCharacteristic returns the path of the characteristic with uuid on the device at
+// devicePath.
+func findCharacteristic(conn *dbus.Conn, devicePath, uuid string) (dbus.ObjectPath, error) {
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	if err := conn.Object("org.bluez", "/").Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		return "", fmt.Errorf("failed to list BlueZ objects: %w", err)
+	}
+	for path, interfaces := range objects {
+		props, ok := interfaces["org.bluez.GattCharacteristic1"]
+		if !ok || !strings.HasPrefix(string(path), devicePath+"/") {
+			continue
+		}
+		if value, ok := props["UUID"].Value().(string); ok && strings.EqualFold(value, uuid) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("BlueZ has no characteristic %s on %s", uuid, devicePath)
+}
@@ -0,0 +1,17 @@
+//go:build windows && !nobluetooth
+
+package esp32client
+
+import "tinygo.org/x/bluetooth"
+
+// writeCharacteristic writes without waiting for an acknowledgement. WinRT waits for the
+// write to be queued before returning, so back to back writes are not dropped.
+func writeCharacteristic(char bluetooth.DeviceCharacteristic, data []byte) (int, error) {
+	return char.WriteWithoutResponse(data)
+}
+
+// writeCharacteristicWithResponse writes with response, returning once the device
+// acknowledged the write.
+func writeCharacteristicWithResponse(char bluetooth.DeviceCharacteristic, address bluetooth.Address, data []byte) (int, error) {
+	return char.Write(data)
+}
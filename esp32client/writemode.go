@@ -0,0 +1,39 @@
+package esp32client
+
+import "context"
+
+// WriteMode is how a BLE client writes requests to the write characteristic.
+type WriteMode int
+
+const (
+	// WriteFast writes without response where the platform supports it: the write returns
+	// once it is queued, so a write the device never received goes unnoticed.
+	WriteFast WriteMode = iota
+	// WriteReliable writes with response, so the write returns once the device
+	// acknowledged it, and fails if it did not.
+	WriteReliable
+)
+
+// WithWriteMode sets how BLE clients write requests that no WithRequestWriteMode context
+// says otherwise for, WriteFast by default.
+func WithWriteMode(mode WriteMode) Option {
+	return func(o *options) {
+		o.writeMode = mode
+	}
+}
+
+type writeModeKey struct{}
+
+// WithRequestWriteMode returns a context whose BLE requests are written in mode,
+// overriding the client's WithWriteMode. HTTP clients ignore it.
+func WithRequestWriteMode(ctx context.Context, mode WriteMode) context.Context {
+	return context.WithValue(ctx, writeModeKey{}, mode)
+}
+
+// writeModeFor returns the write mode ctx asks for, or def.
+func writeModeFor(ctx context.Context, def WriteMode) WriteMode {
+	if mode, ok := ctx.Value(writeModeKey{}).(WriteMode); ok {
+		return mode
+	}
+	return def
+}
@@ -0,0 +1,105 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	board "go.viam.com/rdk/components/board"
+
+	"esp32wifi/esp32client"
+)
+
+// tickStream is a StreamTicks caller waiting for ticks on a set of pins.
+type tickStream struct {
+	ctx  context.Context
+	ch   chan board.Tick
+	pins map[int]bool
+}
+
+// watchEvents dispatches events pushed by the firmware until the board is closed.
+func (s *esp32Board) watchEvents() {
+	err := s.client.Events(s.cancelCtx, func(event esp32client.Event) {
+		s.handleEvent(event)
+	})
+	if errors.Is(err, esp32client.ErrNotSupported) {
+		s.logger.Debugf("device does not push events, interrupts will not be streamed: %v", err)
+		for _, pinNum := range s.polls.fallbacks() {
+			s.fallBackToPolling(pinNum)
+		}
+	}
+}
+
+func (s *esp32Board) handleEvent(event esp32client.Event) {
+	s.health.recordEvent(event)
+
+	switch event.Type {
+	case esp32client.EventInterrupt:
+		s.dispatchTick(event.PinNum, event.High, s.tickTime(event.TimestampUs))
+	case esp32client.EventReboot:
+		s.logger.Warnf("device rebooted: %s", event.Reason)
+		s.rebooted()
+	case esp32client.EventWiFi:
+		if event.WiFi != nil {
+			s.logger.Infof("device wifi status changed: connected=%t ssid=%s ip=%s rssi=%d",
+				event.WiFi.Connected, event.WiFi.SSID, event.WiFi.IP, event.WiFi.RSSI)
+		}
+	default:
+		s.logger.Debugf("ignoring unknown event type %q", event.Type)
+	}
+}
+
+// rebooted drops what the board cached about the device and has its clock sync and setups
+// redone, after the device rebooted.
+func (s *esp32Board) rebooted() {
+	// The device clock, pin states, LEDC assignments and log restarted, so none of the
+	// caches apply.
+	s.clock.reset()
+	s.writes.reset()
+	s.relays.forget()
+	s.ledc.reset()
+	s.deviceLogs.resetCursor()
+	s.counters.rebooted()
+	// Schedules do not survive a reboot, and their ids may be used again.
+	s.forgetSchedules()
+	// The firmware may have been updated.
+	s.features.forget()
+	// A panic reboots the device, so it may have left a core dump.
+	s.checkCoreDump()
+	s.checkBoot()
+	for _, resync := range []chan struct{}{s.clockResync, s.reconfigure} {
+		select {
+		case resync <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// dispatchTick counts an interrupt on pin at host time at and delivers it to every
+// stream watching the pin.
+func (s *esp32Board) dispatchTick(pin int, high bool, at time.Time) {
+	tick := board.Tick{
+		Name:             s.interruptName(pin),
+		High:             high,
+		TimestampNanosec: uint64(at.UnixNano()),
+	}
+
+	s.interruptMu.Lock()
+	s.interruptCounts[pin]++
+	var streams []*tickStream
+	for stream := range s.tickStreams {
+		if stream.pins[pin] {
+			streams = append(streams, stream)
+		}
+	}
+	s.interruptMu.Unlock()
+	s.tickWebhooks(pin, tick.Name, high, at)
+
+	for _, stream := range streams {
+		select {
+		case stream.ch <- tick:
+		case <-stream.ctx.Done():
+		case <-s.cancelCtx.Done():
+		}
+	}
+}
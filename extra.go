@@ -0,0 +1,140 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"esp32wifi/esp32client"
+)
+
+// Keys the pin methods understand in their extra parameters. Any other key is forwarded
+// to the firmware with the request.
+const (
+	// extraRaw makes analog Read return the unfiltered, uncalibrated reading.
+	extraRaw = "raw"
+	// extraTimeoutMs fails the call if the device has not answered in time.
+	extraTimeoutMs = "timeout_ms"
+	// extraNoRetry fails the call on the first error instead of retrying once.
+	extraNoRetry = "no_retry"
+	// extraSamples makes analog Read average this many readings.
+	extraSamples = "samples"
+	// extraFresh makes analog Read ask the device in battery mode, instead of answering
+	// from the last wake window.
+	extraFresh = "fresh"
+	// extraPulseWidthUs makes SetPWM drive a pulse this many microseconds long at the
+	// pin's current frequency instead of the duty cycle it is given, e.g. for servos.
+	extraPulseWidthUs = "pulse_width_us"
+	// extraMode "input" makes Set release the pin to high impedance instead of driving it,
+	// and Get release it before reading the level something else drives.
+	extraMode = "mode"
+	// extraNoAck makes Set and SetPWM return without waiting for the device to answer,
+	// overriding the board's no_ack.
+	extraNoAck = "no_ack"
+	// extraWriteResponse makes Set and SetPWM write over BLE with response, or without,
+	// overriding the board's write_mode.
+	extraWriteResponse = "write_response"
+)
+
+const retryDelay = 100 * time.Millisecond
+
+// callOptions are the per-call options parsed from a method's extra parameters.
+type callOptions struct {
+	raw     bool
+	timeout time.Duration
+	noRetry bool
+	samples int
+	fresh   bool
+	// pulseWidthUs is 0 unless a pulse width was asked for.
+	pulseWidthUs float64
+	// mode is empty unless a pin mode was asked for.
+	mode string
+	// noAck is nil unless extra says whether to wait for the device.
+	noAck *bool
+	// writeResponse is nil unless extra says whether to write with response.
+	writeResponse *bool
+	forward       map[string]interface{}
+}
+
+func parseExtra(extra map[string]interface{}) (callOptions, error) {
+	opts := callOptions{samples: 1}
+	for key, value := range extra {
+		var err error
+		switch key {
+		case extraRaw:
+			opts.raw, err = boolArg(extra, key)
+		case extraNoRetry:
+			opts.noRetry, err = boolArg(extra, key)
+		case extraFresh:
+			opts.fresh, err = boolArg(extra, key)
+		case extraTimeoutMs:
+			var ms float64
+			if ms, err = numberArg(extra, key); err == nil && ms <= 0 {
+				err = fmt.Errorf("%q must be positive, got %v", key, ms)
+			}
+			opts.timeout = time.Duration(ms * float64(time.Millisecond))
+		case extraSamples:
+			var samples float64
+			if samples, err = numberArg(extra, key); err == nil && samples < 1 {
+				err = fmt.Errorf("%q must be at least 1, got %v", key, samples)
+			}
+			opts.samples = int(samples)
+		case extraMode:
+			if opts.mode, err = stringArg(extra, key); err == nil && opts.mode != pinModeInput {
+				err = fmt.Errorf("%q must be %q, got %q", key, pinModeInput, opts.mode)
+			}
+		case extraNoAck:
+			var noAck bool
+			noAck, err = boolArg(extra, key)
+			opts.noAck = &noAck
+		case extraWriteResponse:
+			var writeResponse bool
+			writeResponse, err = boolArg(extra, key)
+			opts.writeResponse = &writeResponse
+		case extraPulseWidthUs:
+			if opts.pulseWidthUs, err = numberArg(extra, key); err == nil && opts.pulseWidthUs <= 0 {
+				err = fmt.Errorf("%q must be positive, got %v", key, opts.pulseWidthUs)
+			}
+		default:
+			if opts.forward == nil {
+				opts.forward = map[string]interface{}{}
+			}
+			opts.forward[key] = value
+		}
+		if err != nil {
+			return callOptions{}, fmt.Errorf("invalid extra: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// call runs fn with the timeout and forwarded keys of opts applied, retrying once after
+// an error unless opts.noRetry is set. Only idempotent requests go through call.
+func (s *esp32Board) call(ctx context.Context, opts callOptions, fn func(ctx context.Context) error) error {
+	if opts.timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	ctx = esp32client.WithExtra(ctx, opts.forward)
+
+	err := fn(ctx)
+	if err == nil || opts.noRetry || ctx.Err() != nil ||
+		errors.Is(err, esp32client.ErrNotSupported) || errors.Is(err, esp32client.ErrCircuitOpen) ||
+		errors.Is(err, esp32client.ErrBoardBusy) ||
+		errors.As(err, new(*esp32client.PartialWriteError)) {
+		return err
+	}
+	s.logger.Debugf("retrying after error: %v", err)
+	trace.SpanFromContext(ctx).AddEvent("esp32.retry", trace.WithAttributes(attribute.String("error", err.Error())))
+	select {
+	case <-time.After(retryDelay):
+	case <-ctx.Done():
+		return err
+	}
+	return fn(ctx)
+}
@@ -0,0 +1,230 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.viam.com/rdk/logging"
+
+	"esp32wifi/esp32client"
+)
+
+// Features that are not named after the first segment of their endpoint's path, and the
+// requests that are not Calls.
+const (
+	featureTransaction     = "transaction"
+	featurePWMFreqs        = "pwm_freqs"
+	featureEvents          = "events"
+	featureAnalogStream    = "analog_stream"
+	featureInterruptCounts = "interrupt_counts"
+)
+
+// featureSubpaths are the endpoints whose feature is not their first segment's.
+var featureSubpaths = map[string]string{
+	"/analogs/stream":    featureAnalogStream,
+	"/interrupts/counts": featureInterruptCounts,
+}
+
+// coreEndpoints are served by every firmware version, so they are never gated.
+var coreEndpoints = map[string]bool{"info": true, "capabilities": true, "read_pins": true, "write_pins": true}
+
+// featureOf returns the feature an endpoint belongs to, e.g. "macros" for "/macros/3",
+// or "" for the endpoints every firmware serves.
+func featureOf(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	if feature, ok := featureSubpaths[path]; ok {
+		return feature
+	}
+	first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	feature := strings.ReplaceAll(first, "-", "_")
+	if coreEndpoints[feature] {
+		return ""
+	}
+	return feature
+}
+
+// isFeatureEndpoint reports whether path is a feature's base endpoint, e.g. "/macros" but
+// not "/macros/3". Only a 404 on the base endpoint says the firmware lacks the feature;
+// one on a key or id only says there is no such macro, schedule or NVS key.
+func isFeatureEndpoint(path string) bool {
+	path, _, _ = strings.Cut(path, "?")
+	if _, ok := featureSubpaths[path]; ok {
+		return true
+	}
+	return !strings.Contains(strings.TrimPrefix(path, "/"), "/")
+}
+
+type passthroughKey struct{}
+
+// withPassthrough returns a context whose Calls bypass the feature map, for requests to
+// paths the module does not know, e.g. the http command's.
+func withPassthrough(ctx context.Context) context.Context {
+	return context.WithValue(ctx, passthroughKey{}, true)
+}
+
+// featureMap tracks what the firmware can do, from the features its capabilities list
+// and from the endpoints it turned out not to serve, so requests for a missing feature
+// fail with ErrNotSupported before reaching the device. Each missing feature is logged
+// once.
+type featureMap struct {
+	logger logging.Logger
+
+	mu sync.Mutex
+	// listed are the features the capabilities list, nil if the firmware does not list
+	// them, in which case every feature is tried.
+	listed map[string]bool
+	// missing are the features whose endpoints the firmware did not serve.
+	missing map[string]bool
+	logged  map[string]bool
+}
+
+func newFeatureMap(logger logging.Logger) *featureMap {
+	return &featureMap{logger: logger, missing: map[string]bool{}, logged: map[string]bool{}}
+}
+
+// list records the features the firmware's capabilities list.
+func (f *featureMap) list(features []string) {
+	if features == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listed = make(map[string]bool, len(features))
+	for _, feature := range features {
+		f.listed[feature] = true
+	}
+}
+
+// check returns ErrFirmwareTooOld if the firmware lacks feature.
+func (f *featureMap) check(feature string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.missing[feature] || (f.listed != nil && !f.listed[feature]) {
+		f.logMissing(feature)
+		return fmt.Errorf("%s: %w", feature, esp32client.ErrFirmwareTooOld)
+	}
+	return nil
+}
+
+// observe marks feature missing if err says the firmware does not serve it.
+func (f *featureMap) observe(feature string, err error) {
+	if !errors.Is(err, esp32client.ErrFirmwareTooOld) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.missing[feature] = true
+	f.logMissing(feature)
+}
+
+// logMissing logs that feature is missing the first time. f.mu must be held.
+func (f *featureMap) logMissing(feature string) {
+	if f.logged[feature] {
+		return
+	}
+	f.logged[feature] = true
+	f.logger.Infof("firmware does not support %s, requests for it fail with ErrNotSupported", feature)
+}
+
+// forget drops the features learned from requests, since the firmware may have been
+// updated, e.g. when the device rebooted.
+func (f *featureMap) forget() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.missing = map[string]bool{}
+	f.logged = map[string]bool{}
+}
+
+// status lists the features the firmware lists, if it does, and the ones it lacks.
+func (f *featureMap) status() map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	missing := map[string]bool{}
+	for feature := range f.missing {
+		missing[feature] = true
+	}
+	for feature := range f.logged {
+		missing[feature] = true
+	}
+	if f.listed == nil && len(missing) == 0 {
+		return nil
+	}
+	status := map[string]interface{}{"unsupported": sortedFeatures(missing)}
+	if f.listed != nil {
+		status["listed"] = sortedFeatures(f.listed)
+	}
+	return status
+}
+
+func sortedFeatures(features map[string]bool) []interface{} {
+	names := make([]string, 0, len(features))
+	for feature := range features {
+		names = append(names, feature)
+	}
+	sort.Strings(names)
+	list := make([]interface{}, len(names))
+	for i, name := range names {
+		list[i] = name
+	}
+	return list
+}
+
+// featureClient gates the requests of every feature on the feature map. learn is unset
+// on transports that switch links, whose missing endpoint may be the link's and not the
+// firmware's, e.g. BLE without responses.
+type featureClient struct {
+	esp32client.Client
+	features *featureMap
+	learn    bool
+}
+
+func (c *featureClient) unwrap() esp32client.Client {
+	return c.Client
+}
+
+// gated runs fn unless the firmware lacks feature, and, if learn is set, learns from
+// its error whether it does.
+func (c *featureClient) gated(feature string, learn bool, fn func() error) error {
+	if feature == "" {
+		return fn()
+	}
+	if err := c.features.check(feature); err != nil {
+		return err
+	}
+	err := fn()
+	if learn && c.learn {
+		c.features.observe(feature, err)
+	}
+	return err
+}
+
+func (c *featureClient) Transaction(ctx context.Context, writes []esp32client.PinWrite) error {
+	return c.gated(featureTransaction, true, func() error {
+		return c.Client.Transaction(ctx, writes)
+	})
+}
+
+func (c *featureClient) SetPWMFreqs(ctx context.Context, freqs []esp32client.PinFreq) error {
+	return c.gated(featurePWMFreqs, true, func() error {
+		return c.Client.SetPWMFreqs(ctx, freqs)
+	})
+}
+
+func (c *featureClient) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	if ctx.Value(passthroughKey{}) != nil {
+		return c.Client.Call(ctx, method, path, body, out)
+	}
+	return c.gated(featureOf(path), isFeatureEndpoint(path), func() error {
+		return c.Client.Call(ctx, method, path, body, out)
+	})
+}
+
+func (c *featureClient) Events(ctx context.Context, fn func(esp32client.Event)) error {
+	return c.gated(featureEvents, true, func() error {
+		return c.Client.Events(ctx, fn)
+	})
+}
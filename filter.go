@@ -0,0 +1,112 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Filter types accepted in FilterConfig.Type.
+const (
+	filterMean   = "mean"
+	filterMedian = "median"
+	filterEMA    = "ema"
+)
+
+// FilterConfig smooths analog readings in the module so they are equally stable over
+// every transport.
+type FilterConfig struct {
+	// Type is mean, median or ema.
+	Type string `json:"type"`
+	// Window is the number of samples averaged by mean and median, and sets the default
+	// ema alpha of 2/(window+1).
+	Window int `json:"window,omitempty"`
+	// Alpha is the ema smoothing factor between 0 (exclusive) and 1.
+	Alpha float64 `json:"alpha,omitempty"`
+}
+
+func (cfg *FilterConfig) validate(path string) error {
+	switch cfg.Type {
+	case filterMean, filterMedian:
+		if cfg.Window < 1 {
+			return fmt.Errorf("%s: 'window' must be at least 1 for the %s filter", path, cfg.Type)
+		}
+		if cfg.Alpha != 0 {
+			return fmt.Errorf("%s: 'alpha' only applies to the %s filter", path, filterEMA)
+		}
+	case filterEMA:
+		if cfg.Window < 0 {
+			return fmt.Errorf("%s: 'window' must not be negative", path)
+		}
+		if cfg.Window == 0 && cfg.Alpha == 0 {
+			return fmt.Errorf("%s: the %s filter needs 'alpha' or 'window'", path, filterEMA)
+		}
+		if cfg.Alpha < 0 || cfg.Alpha > 1 {
+			return fmt.Errorf("%s: 'alpha' must be between 0 and 1", path)
+		}
+	default:
+		return fmt.Errorf("%s: invalid filter 'type' %q, must be one of %s, %s or %s",
+			path, cfg.Type, filterMean, filterMedian, filterEMA)
+	}
+	return nil
+}
+
+// sampleFilter holds the recent samples of one analog pin.
+type sampleFilter struct {
+	kind    string
+	window  int
+	alpha   float64
+	samples []float64
+	ema     float64
+	primed  bool
+}
+
+func newSampleFilter(cfg *FilterConfig) *sampleFilter {
+	f := &sampleFilter{kind: cfg.Type, window: cfg.Window, alpha: cfg.Alpha}
+	if f.kind == filterEMA && f.alpha == 0 {
+		f.alpha = 2 / float64(cfg.Window+1)
+	}
+	return f
+}
+
+// add records a sample and returns the filtered value.
+func (f *sampleFilter) add(sample float64) float64 {
+	if f.kind == filterEMA {
+		if !f.primed {
+			f.ema, f.primed = sample, true
+		} else {
+			f.ema += f.alpha * (sample - f.ema)
+		}
+		return f.ema
+	}
+
+	f.samples = append(f.samples, sample)
+	if len(f.samples) > f.window {
+		f.samples = f.samples[len(f.samples)-f.window:]
+	}
+	if f.kind == filterMedian {
+		sorted := append([]float64(nil), f.samples...)
+		sort.Float64s(sorted)
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	}
+	var sum float64
+	for _, s := range f.samples {
+		sum += s
+	}
+	return sum / float64(len(f.samples))
+}
+
+// filtered records a raw reading of pinNum and returns it filtered, or unchanged if the
+// pin has no filter.
+func (s *esp32Board) filtered(pinNum int, raw float64) float64 {
+	s.filterMu.Lock()
+	defer s.filterMu.Unlock()
+	f, ok := s.filters[pinNum]
+	if !ok {
+		return raw
+	}
+	return f.add(raw)
+}
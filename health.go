@@ -0,0 +1,39 @@
+package esp32wifi
+
+import (
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+// deviceHealth is what the module has learned about the device outside of direct calls,
+// such as from events the firmware pushes.
+type deviceHealth struct {
+	mu sync.Mutex
+
+	lastEvent        time.Time
+	reboots          int
+	brownouts        int
+	lastRebootReason string
+	wifi             *esp32client.WiFiStatus
+	// boot is what /info last reported about boots.
+	boot bootInfo
+}
+
+func (h *deviceHealth) recordEvent(event esp32client.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastEvent = time.Now()
+	switch event.Type {
+	case esp32client.EventReboot:
+		h.reboots++
+		h.lastRebootReason = event.Reason
+		if event.Reason == rebootReasonBrownout {
+			h.brownouts++
+		}
+	case esp32client.EventWiFi:
+		h.wifi = event.WiFi
+	}
+}
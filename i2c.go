@@ -0,0 +1,136 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+
+	board "go.viam.com/rdk/components/board"
+
+	"esp32wifi/esp32client"
+)
+
+// I2CBus is implemented by the boards in this package to pass I2C transfers through to
+// the ESP32's bus. The sensor models for chips wired to it read boards through it.
+type I2CBus interface {
+	// I2CTransfer writes data to the 7-bit address on bus, then reads readLen bytes
+	// after a repeated start.
+	I2CTransfer(ctx context.Context, bus, address int, data []byte, readLen int) ([]byte, error)
+}
+
+func (s *esp32Board) I2CTransfer(ctx context.Context, bus, address int, data []byte, readLen int) ([]byte, error) {
+	return esp32client.I2CTransfer(ctx, s.client, bus, address, data, readLen)
+}
+
+// doI2C writes bytes to a device on the ESP32's I2C bus and reads bytes back, e.g. to
+// read a register of a chip no model in this module drives.
+//
+//	{"command": "i2c", "address": 104, "write": [117], "read": 1}
+func (s *esp32Board) doI2C(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	address, err := numberArg(cmd, "address")
+	if err != nil {
+		return nil, err
+	}
+	bus, err := optionalNumberArg(cmd, "bus", 0)
+	if err != nil {
+		return nil, err
+	}
+	readLen, err := optionalNumberArg(cmd, "read", 0)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if _, ok := cmd["write"]; ok {
+		if data, err = bytesArg(cmd, "write"); err != nil {
+			return nil, err
+		}
+	}
+	read, err := s.I2CTransfer(ctx, int(bus), int(address), data, int(readLen))
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(read))
+	for i, b := range read {
+		values[i] = int(b)
+	}
+	return map[string]interface{}{"data": values}, nil
+}
+
+// bytesArg returns the list of bytes at key.
+func bytesArg(cmd map[string]interface{}, key string) ([]byte, error) {
+	items, ok := cmd[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument %q must be a list of bytes, got %T", key, cmd[key])
+	}
+	data := make([]byte, len(items))
+	for i, item := range items {
+		value, ok := item.(float64)
+		if !ok || value < 0 || value > 0xff || value != float64(int(value)) {
+			return nil, fmt.Errorf("argument %q: item %d must be a byte, got %v", key, i, item)
+		}
+		data[i] = byte(value)
+	}
+	return data, nil
+}
+
+// i2cDevice is a chip at an address on a board's I2C bus.
+type i2cDevice struct {
+	bus     I2CBus
+	busNum  int
+	address int
+}
+
+// write sends each write, a register address followed by the bytes written from it, in
+// order.
+func (d i2cDevice) write(ctx context.Context, writes ...[]byte) error {
+	for _, write := range writes {
+		if _, err := d.bus.I2CTransfer(ctx, d.busNum, d.address, write, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read returns n bytes from register on.
+func (d i2cDevice) read(ctx context.Context, register byte, n int) ([]byte, error) {
+	return d.bus.I2CTransfer(ctx, d.busNum, d.address, []byte{register}, n)
+}
+
+// i2cBusOf returns the I2C bus of b, through its i2c command if it is a client of a board
+// in another process, as modules usually get their dependencies.
+func i2cBusOf(b board.Board) I2CBus {
+	if bus, ok := b.(I2CBus); ok {
+		return bus
+	}
+	return commandI2CBus{b}
+}
+
+// commandI2CBus passes transfers through the i2c command of a board it cannot type
+// assert.
+type commandI2CBus struct {
+	board board.Board
+}
+
+func (c commandI2CBus) I2CTransfer(ctx context.Context, bus, address int, data []byte, readLen int) ([]byte, error) {
+	write := make([]interface{}, len(data))
+	for i, b := range data {
+		write[i] = int(b)
+	}
+	resp, err := c.board.DoCommand(ctx, map[string]interface{}{
+		"command": "i2c",
+		"bus":     bus,
+		"address": address,
+		"write":   write,
+		"read":    readLen,
+	})
+	if err != nil {
+		return nil, err
+	}
+	read, err := bytesArg(resp, "data")
+	if err != nil {
+		return nil, fmt.Errorf("board %q answered the i2c command unexpectedly, is it an esp32 board from this module? %w", c.board.Name().ShortName(), err)
+	}
+	if len(read) != readLen {
+		return nil, fmt.Errorf("i2c transfer to 0x%02x read %d bytes, expected %d", address, len(read), readLen)
+	}
+	return read, nil
+}
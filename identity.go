@@ -0,0 +1,33 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"esp32wifi/esp32client"
+)
+
+// normalizeDeviceID lowercases id and drops MAC separators, so "AA:BB:CC:DD:EE:FF",
+// "aa-bb-cc-dd-ee-ff" and "aabbccddeeff" compare equal. It returns "" if id has nothing
+// but separators.
+func normalizeDeviceID(id string) string {
+	return strings.NewReplacer(":", "", "-", "", ".", "", " ", "").Replace(strings.ToLower(id))
+}
+
+// verifyDeviceID checks that the device reports expected as its MAC address or chip ID.
+func verifyDeviceID(ctx context.Context, client esp32client.Client, expected string) error {
+	info, err := client.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify device id: %w", err)
+	}
+	want := normalizeDeviceID(expected)
+	if want == normalizeDeviceID(info.MAC) || (info.ChipID != "" && want == normalizeDeviceID(info.ChipID)) {
+		return nil
+	}
+	reported := info.MAC
+	if info.ChipID != "" {
+		reported += " (chip id " + info.ChipID + ")"
+	}
+	return fmt.Errorf("expected device %s but the device reports %s", expected, reported)
+}
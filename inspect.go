@@ -0,0 +1,247 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+// pinHistorySize is how many events are kept per pin.
+const pinHistorySize = 64
+
+// Kinds of PinEvent.
+const (
+	PinCommanded = "commanded"
+	PinObserved  = "observed"
+	PinFrequency = "frequency"
+	PinFailed    = "error"
+)
+
+// PinEvent is something the board did with a pin or learned about it.
+type PinEvent struct {
+	At time.Time
+	// Kind is PinCommanded for a state written to the pin, PinFrequency for a PWM
+	// frequency, PinObserved for a state read from it, and PinFailed for a request that
+	// failed.
+	Kind string
+	// State is the duty cycle commanded (0-100), the frequency in Hz, or the state read.
+	State float64
+	// Err is why the request failed, for PinFailed.
+	Err string
+}
+
+// PinActivity is what the board last commanded, observed and failed to do with a pin.
+type PinActivity struct {
+	PinNum int
+	// Commanded, Frequency, Observed and Failed are the latest event of each kind, nil if
+	// there was none.
+	Commanded *PinEvent
+	Frequency *PinEvent
+	Observed  *PinEvent
+	Failed    *PinEvent
+	// History is the pin's most recent events, oldest first. Reads are only added when the
+	// state read changed.
+	History []PinEvent
+}
+
+// PinInspector is implemented by the boards in this package. Go programs that embed a board
+// can type assert to it to see what the board last did with a pin.
+type PinInspector interface {
+	// InspectPin returns the activity of the pin, named as for GPIOPinByName.
+	InspectPin(name string) (PinActivity, error)
+}
+
+// InspectPin returns the activity of the pin.
+func (s *esp32Board) InspectPin(name string) (PinActivity, error) {
+	pinNum, err := s.pins.lookup(name)
+	if err != nil {
+		return PinActivity{}, err
+	}
+	return s.pinActivity.get(pinNum), nil
+}
+
+// pinTracker records the pin events of every request to the device.
+type pinTracker struct {
+	mu   sync.Mutex
+	pins map[int]*PinActivity
+}
+
+func (t *pinTracker) get(pinNum int) PinActivity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	activity, ok := t.pins[pinNum]
+	if !ok {
+		return PinActivity{PinNum: pinNum}
+	}
+	copied := *activity
+	copied.History = append([]PinEvent(nil), activity.History...)
+	return copied
+}
+
+func (t *pinTracker) add(pinNum int, event PinEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pins == nil {
+		t.pins = map[int]*PinActivity{}
+	}
+	activity, ok := t.pins[pinNum]
+	if !ok {
+		activity = &PinActivity{PinNum: pinNum}
+		t.pins[pinNum] = activity
+	}
+	var latest **PinEvent
+	switch event.Kind {
+	case PinCommanded:
+		latest = &activity.Commanded
+	case PinFrequency:
+		latest = &activity.Frequency
+	case PinObserved:
+		latest = &activity.Observed
+	default:
+		latest = &activity.Failed
+	}
+	unchanged := event.Kind == PinObserved && *latest != nil && (*latest).State == event.State
+	*latest = &event
+	if unchanged {
+		return
+	}
+	if len(activity.History) == pinHistorySize {
+		activity.History = append(activity.History[:0], activity.History[1:]...)
+	}
+	activity.History = append(activity.History, event)
+}
+
+func (t *pinTracker) reads(pins []int, reads []esp32client.PinRead, err error) {
+	now := time.Now()
+	if err != nil {
+		for _, pinNum := range pins {
+			t.add(pinNum, PinEvent{At: now, Kind: PinFailed, Err: "read: " + err.Error()})
+		}
+		return
+	}
+	// Older firmware does not echo pin_num back, so reads are matched by position.
+	for i, read := range reads {
+		if i < len(pins) {
+			t.add(pins[i], PinEvent{At: now, Kind: PinObserved, State: read.State})
+		}
+	}
+}
+
+func (t *pinTracker) writes(writes []esp32client.PinWrite, err error) {
+	now := time.Now()
+	failed := map[int]string{}
+	var partial *esp32client.PartialWriteError
+	switch {
+	case errors.As(err, &partial):
+		for _, pinErr := range partial.Failed {
+			failed[pinErr.PinNum] = pinErr.Reason
+		}
+	case err != nil:
+		for _, w := range writes {
+			failed[w.PinNum] = err.Error()
+		}
+	}
+	for _, w := range writes {
+		if reason, ok := failed[w.PinNum]; ok {
+			t.add(w.PinNum, PinEvent{At: now, Kind: PinFailed, State: float64(w.State), Err: "write: " + reason})
+		} else {
+			t.add(w.PinNum, PinEvent{At: now, Kind: PinCommanded, State: float64(w.State)})
+		}
+	}
+}
+
+func (t *pinTracker) freqs(freqs []esp32client.PinFreq, err error) {
+	now := time.Now()
+	for _, f := range freqs {
+		if err != nil {
+			t.add(f.PinNum, PinEvent{At: now, Kind: PinFailed, State: float64(f.Freq), Err: "set frequency: " + err.Error()})
+		} else {
+			t.add(f.PinNum, PinEvent{At: now, Kind: PinFrequency, State: float64(f.Freq)})
+		}
+	}
+}
+
+// asOf returns the activity as the board knew it at t, from the pin's history. Events that
+// were dropped from the history are not known.
+func (a PinActivity) asOf(t time.Time) PinActivity {
+	past := PinActivity{PinNum: a.PinNum}
+	for i := range a.History {
+		event := a.History[i]
+		if event.At.After(t) {
+			break
+		}
+		switch event.Kind {
+		case PinCommanded:
+			past.Commanded = &event
+		case PinFrequency:
+			past.Frequency = &event
+		case PinObserved:
+			past.Observed = &event
+		default:
+			past.Failed = &event
+		}
+		past.History = append(past.History, event)
+	}
+	return past
+}
+
+// doInspectPin returns what the board last commanded, observed and failed to do with a
+// pin, optionally as of a past time.
+//
+//	{"command": "inspect_pin", "pin": "26", "at": "2026-10-14T14:02:00Z"}
+func (s *esp32Board) doInspectPin(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, err := stringArg(cmd, "pin")
+	if err != nil {
+		return nil, err
+	}
+	activity, err := s.InspectPin(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := cmd["at"]; ok {
+		at, err := stringArg(cmd, "at")
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q must be an RFC 3339 time: %w", "at", err)
+		}
+		activity = activity.asOf(t)
+	}
+
+	result := map[string]interface{}{"pin_num": activity.PinNum}
+	latest := map[string]*PinEvent{
+		PinCommanded: activity.Commanded,
+		PinFrequency: activity.Frequency,
+		PinObserved:  activity.Observed,
+		PinFailed:    activity.Failed,
+	}
+	for kind, event := range latest {
+		if event != nil {
+			result[kind] = pinEventValue(*event)
+		}
+	}
+	history := make([]interface{}, len(activity.History))
+	for i, event := range activity.History {
+		history[i] = pinEventValue(event)
+	}
+	result["history"] = history
+	return result, nil
+}
+
+func pinEventValue(event PinEvent) map[string]interface{} {
+	value := map[string]interface{}{
+		"at":    event.At.Format(time.RFC3339Nano),
+		"kind":  event.Kind,
+		"state": event.State,
+	}
+	if event.Err != "" {
+		value["error"] = event.Err
+	}
+	return value
+}
@@ -0,0 +1,185 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	interruptTypeBasic   = "basic"
+	interruptTypeCounter = "counter"
+	interruptTypePoll    = "poll"
+
+	defaultCounterSyncMs = 1000
+	minCounterSyncMs     = 100
+)
+
+// DigitalInterruptConfig declares a digital interrupt, following the RDK board config.
+type DigitalInterruptConfig struct {
+	// Name is what DigitalInterruptByName and ticks use for the interrupt.
+	Name string `json:"name"`
+	// Pin is a pin name or a GPIO number.
+	Pin string `json:"pin"`
+	// Type is basic (the default), which counts every edge the firmware pushes, counter,
+	// which the firmware counts itself, or poll, whose pin the module reads at PollHz for
+	// firmware without interrupt support.
+	Type string `json:"type,omitempty"`
+	// SyncMs is how often a counter's count is read, 1000 by default.
+	SyncMs int `json:"sync_ms,omitempty"`
+	// PollHz is how often a poll interrupt's pin is read, 20 by default. On a basic
+	// interrupt it has the module poll the pin if the firmware turns out not to push
+	// interrupt events.
+	PollHz int `json:"poll_hz,omitempty"`
+	// Edge is rising, falling or both (the default).
+	Edge string `json:"edge,omitempty"`
+	// Pull is none (the default), up or down.
+	Pull string `json:"pull,omitempty"`
+}
+
+func (cfg *DigitalInterruptConfig) validate(path string, pins *pinTable) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if _, err := strconv.Atoi(cfg.Name); err == nil {
+		return fmt.Errorf("%s: 'name' %q must not be a number", path, cfg.Name)
+	}
+	if cfg.Pin == "" {
+		return fmt.Errorf("%s: missing required field 'pin'", path)
+	}
+	pinNum, err := pins.lookup(cfg.Pin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := pins.checkRead(pinNum); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if pin, ok := pins.pins[pinNum]; ok && pin.Mode != pinModeInput {
+		return fmt.Errorf("%s: GPIO%d is configured as %s, interrupts need an input pin", path, pinNum, pin.Mode)
+	}
+	switch cfg.Type {
+	case "", interruptTypeBasic, interruptTypePoll:
+		if cfg.SyncMs != 0 {
+			return fmt.Errorf("%s: 'sync_ms' can only be set on %s interrupts", path, interruptTypeCounter)
+		}
+		if cfg.PollHz < 0 || cfg.PollHz > maxPollHz {
+			return fmt.Errorf("%s: 'poll_hz' must be between 0 (default) and %d, got %d", path, maxPollHz, cfg.PollHz)
+		}
+	case interruptTypeCounter:
+		if cfg.SyncMs != 0 && cfg.SyncMs < minCounterSyncMs {
+			return fmt.Errorf("%s: 'sync_ms' must be at least %d, got %d", path, minCounterSyncMs, cfg.SyncMs)
+		}
+		if cfg.PollHz != 0 {
+			return fmt.Errorf("%s: 'poll_hz' cannot be set on %s interrupts", path, interruptTypeCounter)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported interrupt 'type' %q, must be %q, %q or %q",
+			path, cfg.Type, interruptTypeBasic, interruptTypeCounter, interruptTypePoll)
+	}
+	switch cfg.Edge {
+	case "", esp32client.EdgeRising, esp32client.EdgeFalling, esp32client.EdgeBoth:
+	default:
+		return fmt.Errorf("%s: invalid 'edge' %q, must be one of %s, %s or %s",
+			path, cfg.Edge, esp32client.EdgeRising, esp32client.EdgeFalling, esp32client.EdgeBoth)
+	}
+	switch cfg.Pull {
+	case "", esp32client.PullNone:
+	case esp32client.PullUp, esp32client.PullDown:
+		if cfg.Type == interruptTypePoll {
+			return fmt.Errorf("%s: the firmware sets no pulls on %s interrupts, use an external pull resistor", path, interruptTypePoll)
+		}
+		if pins.inputOnly[pinNum] {
+			return fmt.Errorf("%s: GPIO%d has no internal pull resistors on the %s, use an external one",
+				path, pinNum, pins.variantName)
+		}
+	default:
+		return fmt.Errorf("%s: invalid 'pull' %q, must be one of %s, %s or %s",
+			path, cfg.Pull, esp32client.PullNone, esp32client.PullUp, esp32client.PullDown)
+	}
+	return nil
+}
+
+// firmwareConfig returns the handler settings sent to the firmware.
+func (cfg *DigitalInterruptConfig) firmwareConfig(pins *pinTable) esp32client.InterruptConfig {
+	// Checked by validate.
+	pinNum, _ := pins.lookup(cfg.Pin)
+	interrupt := esp32client.InterruptConfig{PinNum: pinNum, Edge: cfg.Edge, Pull: cfg.Pull, Counter: cfg.Type == interruptTypeCounter}
+	if interrupt.Edge == "" {
+		interrupt.Edge = esp32client.EdgeBoth
+	}
+	if interrupt.Pull == "" {
+		interrupt.Pull = esp32client.PullNone
+	}
+	return interrupt
+}
+
+// syncInterval returns how often a counter is read.
+func (cfg *DigitalInterruptConfig) syncInterval() time.Duration {
+	if cfg.SyncMs == 0 {
+		return defaultCounterSyncMs * time.Millisecond
+	}
+	return time.Duration(cfg.SyncMs) * time.Millisecond
+}
+
+// pollHz returns how often a poll interrupt, or a basic interrupt that falls back to
+// polling, is read, and 0 for one that is never polled.
+func (cfg *DigitalInterruptConfig) pollHz() int {
+	switch {
+	case cfg.Type == interruptTypePoll && cfg.PollHz == 0:
+		return defaultPollHz
+	case cfg.Type == interruptTypeCounter:
+		return 0
+	}
+	return cfg.PollHz
+}
+
+// interruptPin resolves an interrupt name, falling back to pin names and numbers for
+// interrupts that are not configured.
+func (s *esp32Board) interruptPin(name string) (int, error) {
+	if interrupt, ok := s.interrupts[name]; ok {
+		return interrupt.PinNum, nil
+	}
+	if tickPin, ok := s.alertTicks[name]; ok {
+		return tickPin, nil
+	}
+	return s.pins.lookup(name)
+}
+
+// interruptName returns the configured name of the interrupt on pinNum, or the pin's
+// name if it has none.
+func (s *esp32Board) interruptName(pinNum int) string {
+	for name, interrupt := range s.interrupts {
+		if interrupt.PinNum == pinNum {
+			return name
+		}
+	}
+	for name, tickPin := range s.alertTicks {
+		if tickPin == pinNum {
+			return name
+		}
+	}
+	return s.pins.name(pinNum)
+}
+
+// interruptSetup configures an interrupt on the firmware.
+func (s *esp32Board) interruptSetup(name string, interrupt esp32client.InterruptConfig) deviceSetup {
+	return deviceSetup{
+		what: fmt.Sprintf("interrupt %q", name),
+		apply: func(ctx context.Context) error {
+			err := esp32client.ConfigureInterrupt(ctx, s.client, interrupt)
+			switch {
+			case err == nil:
+				s.polls.native(interrupt.PinNum)
+			case errors.Is(err, esp32client.ErrNotSupported) && s.fallBackToPolling(interrupt.PinNum):
+				// Polled instead; applied again after reboots in case the firmware was
+				// updated.
+				return nil
+			}
+			return err
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultKeepAliveMs is well under the ~30s some ESP32 BLE stacks drop idle
+	// connections after.
+	defaultKeepAliveMs = 10000
+	minKeepAliveMs     = 1000
+	keepAliveTimeout   = 5 * time.Second
+)
+
+// pinger is implemented by transports whose connection can idle out, such as BLE.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// validateKeepAlive checks a keep_alive_ms attribute, where nil is the default and 0
+// disables the keep-alive.
+func validateKeepAlive(keepAliveMs *int) error {
+	if keepAliveMs == nil || *keepAliveMs == 0 {
+		return nil
+	}
+	if *keepAliveMs < minKeepAliveMs {
+		return fmt.Errorf("'keep_alive_ms' must be 0 to disable the keep-alive or at least %d, got %d",
+			minKeepAliveMs, *keepAliveMs)
+	}
+	return nil
+}
+
+// keepAliveInterval returns the interval of a valid keep_alive_ms attribute, or zero if
+// the keep-alive is disabled.
+func keepAliveInterval(keepAliveMs *int) time.Duration {
+	if keepAliveMs == nil {
+		return defaultKeepAliveMs * time.Millisecond
+	}
+	return time.Duration(*keepAliveMs) * time.Millisecond
+}
+
+// keepAlive pings the device whenever no request reached it for interval, until the
+// board is closed. Pings are recorded like any other request, so a dropped connection
+// runs the disconnect hooks right away rather than on the next call. It stops if the
+// transport cannot be pinged.
+func (s *esp32Board) keepAlive(interval time.Duration) {
+	p, ok := transport(s.client).(pinger)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s.conn.idleFor() < interval {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(s.cancelCtx, keepAliveTimeout)
+		start := time.Now()
+		err := p.Ping(ctx)
+		cancel()
+		if s.cancelCtx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.logger.Debugf("keep-alive failed: %v", err)
+		}
+		s.conn.record(err, time.Since(start))
+	}
+}
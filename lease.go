@@ -0,0 +1,231 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultLeaseDurationMs = 10000
+	minLeaseDurationMs     = 1000
+	leaseTimeout           = 5 * time.Second
+)
+
+// LeaseConfig makes the board take exclusive control of the device's outputs, so two
+// hosts pointed at the same ESP32 cannot fight over them.
+type LeaseConfig struct {
+	// DurationMs is how long the lease lasts unless renewed, 10000 by default. The board
+	// renews it every third of that.
+	DurationMs int `json:"duration_ms,omitempty"`
+	// Holder identifies this host to the firmware and to other hosts, the hostname by
+	// default. Boards with the same holder share the lease.
+	Holder string `json:"holder,omitempty"`
+}
+
+func (cfg *LeaseConfig) validate(path string) error {
+	if cfg.DurationMs != 0 && cfg.DurationMs < minLeaseDurationMs {
+		return fmt.Errorf("%s: 'duration_ms' must be at least %d, got %d", path, minLeaseDurationMs, cfg.DurationMs)
+	}
+	return nil
+}
+
+func (cfg *LeaseConfig) duration() time.Duration {
+	if cfg.DurationMs == 0 {
+		return defaultLeaseDurationMs * time.Millisecond
+	}
+	return time.Duration(cfg.DurationMs) * time.Millisecond
+}
+
+// lease is the board's view of the device's lease.
+type lease struct {
+	holder   string
+	duration time.Duration
+
+	mu sync.Mutex
+	// expires is when the lease the board holds runs out, zero while it holds none.
+	expires time.Time
+	// other is the host holding the lease when it was last refused.
+	other string
+	// unsupported is set once the firmware turned out not to lease, so writes go through
+	// unguarded.
+	unsupported bool
+}
+
+func newLease(cfg *LeaseConfig) *lease {
+	holder := cfg.Holder
+	if holder == "" {
+		// A host without a name still gets a holder unique to the process.
+		var err error
+		if holder, err = os.Hostname(); err != nil {
+			holder = fmt.Sprintf("pid-%d", os.Getpid())
+		}
+	}
+	return &lease{holder: holder, duration: cfg.duration()}
+}
+
+// check returns ErrBoardBusy unless the board holds the lease.
+func (l *lease) check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.unsupported || time.Now().Before(l.expires):
+		return nil
+	case l.other != "":
+		return fmt.Errorf("the device is leased to %q: %w", l.other, ErrBoardBusy)
+	default:
+		return fmt.Errorf("the device's lease has not been acquired: %w", ErrBoardBusy)
+	}
+}
+
+// acquireLease asks for or renews the lease, returning an error only if the device did
+// not answer.
+func (s *esp32Board) acquireLease(ctx context.Context) error {
+	l := s.lease
+	ctx, cancel := context.WithTimeout(ctx, leaseTimeout)
+	defer cancel()
+	asked := time.Now()
+	status, err := esp32client.AcquireLease(ctx, s.client, esp32client.Lease{Holder: l.holder, DurationMs: int(l.duration.Milliseconds())})
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case errors.Is(err, esp32client.ErrNotSupported):
+		if !l.unsupported {
+			s.logger.Warnf("firmware cannot lease its outputs, writes are not guarded against other hosts: %v", err)
+		}
+		l.unsupported = true
+		return nil
+	case err != nil:
+		// The lease the board held lasts until it expires.
+		return err
+	case status.Granted:
+		if l.other != "" || l.expires.IsZero() {
+			s.logger.Infof("acquired the device's lease as %q", l.holder)
+		}
+		remaining := l.duration
+		if status.RemainingMs > 0 {
+			remaining = time.Duration(status.RemainingMs) * time.Millisecond
+		}
+		l.expires, l.other = asked.Add(remaining), ""
+	default:
+		if l.other != status.Holder {
+			s.logger.Warnf("the device is leased to %q, writes will fail until it is released", status.Holder)
+		}
+		l.expires, l.other = time.Time{}, status.Holder
+	}
+	return nil
+}
+
+// renewLease keeps the lease until the board is closed, and keeps asking for it while
+// another host holds it.
+func (s *esp32Board) renewLease() {
+	interval := s.lease.duration / 3
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if err := s.acquireLease(s.cancelCtx); err != nil && s.cancelCtx.Err() == nil {
+			s.logger.Debugf("failed to renew the lease: %v", err)
+		}
+	}
+}
+
+// releaseLease gives the lease up on close, so another host can take it without waiting
+// for it to expire.
+func (s *esp32Board) releaseLease(ctx context.Context) {
+	l := s.lease
+	l.mu.Lock()
+	held := !l.unsupported && time.Now().Before(l.expires)
+	l.expires = time.Time{}
+	l.mu.Unlock()
+	if !held {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, leaseTimeout)
+	defer cancel()
+	if err := esp32client.ReleaseLease(ctx, s.client, l.holder); err != nil {
+		s.logger.Debugf("failed to release the lease, it expires on its own: %v", err)
+	}
+}
+
+// status describes the lease, for status.
+func (l *lease) status() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	status := map[string]interface{}{"holder": l.holder, "held": time.Now().Before(l.expires)}
+	if l.unsupported {
+		status["supported"] = false
+	}
+	if l.other != "" {
+		status["leased_to"] = l.other
+	}
+	return status
+}
+
+// leasedPaths are the endpoints whose requests other than GET drive pins, and are leased
+// like pin writes.
+var leasedPaths = []string{"/write-mask", "/macros", "/schedules", "/pin-modes", "/reset-peripherals"}
+
+// leased reports whether a Call to path drives pins.
+func leased(method, path string) bool {
+	if method == http.MethodGet {
+		return false
+	}
+	for _, prefix := range leasedPaths {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// leasedClient refuses pin writes while the board does not hold the lease, and sends the
+// holder with the ones it lets through so the firmware accepts them.
+type leasedClient struct {
+	esp32client.Client
+	lease *lease
+}
+
+func (c *leasedClient) unwrap() esp32client.Client {
+	return c.Client
+}
+
+func (c *leasedClient) WritePins(ctx context.Context, writes []esp32client.PinWrite) error {
+	if err := c.lease.check(); err != nil {
+		return err
+	}
+	return c.Client.WritePins(esp32client.WithLeaseHolder(ctx, c.lease.holder), writes)
+}
+
+func (c *leasedClient) Transaction(ctx context.Context, writes []esp32client.PinWrite) error {
+	if err := c.lease.check(); err != nil {
+		return err
+	}
+	return c.Client.Transaction(esp32client.WithLeaseHolder(ctx, c.lease.holder), writes)
+}
+
+func (c *leasedClient) SetPWMFreqs(ctx context.Context, freqs []esp32client.PinFreq) error {
+	if err := c.lease.check(); err != nil {
+		return err
+	}
+	return c.Client.SetPWMFreqs(esp32client.WithLeaseHolder(ctx, c.lease.holder), freqs)
+}
+
+func (c *leasedClient) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	if !leased(method, path) {
+		return c.Client.Call(ctx, method, path, body, out)
+	}
+	if err := c.lease.check(); err != nil {
+		return err
+	}
+	return c.Client.Call(esp32client.WithLeaseHolder(ctx, c.lease.holder), method, path, body, out)
+}
@@ -0,0 +1,259 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"esp32wifi/esp32client"
+)
+
+// ledcAllocator models the chip's LEDC peripheral, which drives PWM pins from channels
+// that each take their frequency from a timer. Pins on the same timer share a frequency,
+// so setting one pin's frequency must not retune the others. The allocator assigns every
+// pin whose frequency the board sets a channel, and its channel a timer at that frequency,
+// and tells the firmware which to use.
+type ledcAllocator struct {
+	mu sync.Mutex
+	// groups, timers and channels are per speed mode, from the chip variant.
+	groups, timers, channels int
+	// timerFreqs is the frequency of each timer, 0 while it is free. Timers and channels
+	// are numbered across speed modes.
+	timerFreqs []uint
+	pinTimer   map[int]int
+	pinChannel map[int]int
+}
+
+func newLEDCAllocator(variant chipVariant) *ledcAllocator {
+	a := &ledcAllocator{groups: variant.ledcGroups, timers: variant.ledcTimers, channels: variant.ledcChannels}
+	a.reset()
+	return a
+}
+
+// reset forgets every assignment, after the device rebooted.
+func (a *ledcAllocator) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.timerFreqs = make([]uint, a.groups*a.timers)
+	a.pinTimer = map[int]int{}
+	a.pinChannel = map[int]int{}
+}
+
+// ledcState is an assignment being planned.
+type ledcState struct {
+	a          *ledcAllocator
+	timerFreqs []uint
+	pinTimer   map[int]int
+	pinChannel map[int]int
+}
+
+func (a *ledcAllocator) state() *ledcState {
+	st := &ledcState{
+		a:          a,
+		timerFreqs: append([]uint(nil), a.timerFreqs...),
+		pinTimer:   make(map[int]int, len(a.pinTimer)),
+		pinChannel: make(map[int]int, len(a.pinChannel)),
+	}
+	for pin, timer := range a.pinTimer {
+		st.pinTimer[pin] = timer
+	}
+	for pin, channel := range a.pinChannel {
+		st.pinChannel[pin] = channel
+	}
+	return st
+}
+
+// assign plans freqs and returns them with their timers and channels set, without
+// changing the allocator; commit applies the plan once the firmware accepted it. A
+// frequency no timer can be found for is rejected as ErrInvalidPin. Chips the allocator
+// knows no LEDC layout for get freqs back as they are.
+func (a *ledcAllocator) assign(freqs []esp32client.PinFreq) ([]esp32client.PinFreq, *ledcState, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.groups == 0 {
+		return freqs, nil, nil
+	}
+	st := a.state()
+	assigned := make([]esp32client.PinFreq, len(freqs))
+	for i, f := range freqs {
+		assigned[i] = f
+		// 0 asks for the firmware's default, which the firmware places itself.
+		if f.Freq == 0 {
+			continue
+		}
+		timer, channel, err := st.place(f.PinNum, f.Freq)
+		if err != nil {
+			return nil, nil, err
+		}
+		assigned[i].LEDCTimer, assigned[i].LEDCChannel = &timer, &channel
+	}
+	return assigned, st, nil
+}
+
+// commit makes a plan returned by assign the allocator's state.
+func (a *ledcAllocator) commit(st *ledcState) {
+	if st == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.timerFreqs, a.pinTimer, a.pinChannel = st.timerFreqs, st.pinTimer, st.pinChannel
+}
+
+// place puts pin on a timer running at freq, preferring its current channel's speed mode
+// and a timer that already runs at freq over a free one.
+func (st *ledcState) place(pin int, freq uint) (int, int, error) {
+	a := st.a
+	channel, hasChannel := st.pinChannel[pin]
+	if hasChannel {
+		timer := st.pinTimer[pin]
+		if st.timerFreqs[timer] == freq {
+			return timer, channel, nil
+		}
+		// Leave the old timer first, so the pin can retune it if it was the only user.
+		delete(st.pinTimer, pin)
+		st.releaseIfUnused(timer)
+		group := channel / a.channels
+		if timer, ok := st.timerFor(group, freq); ok {
+			st.take(pin, timer, channel, freq)
+			return timer, channel, nil
+		}
+		// The speed mode has no timer to spare, try a channel in another one.
+		delete(st.pinChannel, pin)
+	}
+	for group := 0; group < a.groups; group++ {
+		channel, ok := st.freeChannel(group)
+		if !ok {
+			continue
+		}
+		if timer, ok := st.timerFor(group, freq); ok {
+			st.take(pin, timer, channel, freq)
+			return timer, channel, nil
+		}
+	}
+	return 0, 0, st.conflict(pin, freq)
+}
+
+// timerFor returns a timer of group at freq, or a free one.
+func (st *ledcState) timerFor(group int, freq uint) (int, bool) {
+	a := st.a
+	free := -1
+	for timer := group * a.timers; timer < (group+1)*a.timers; timer++ {
+		switch st.timerFreqs[timer] {
+		case freq:
+			return timer, true
+		case 0:
+			if free < 0 {
+				free = timer
+			}
+		}
+	}
+	return free, free >= 0
+}
+
+func (st *ledcState) freeChannel(group int) (int, bool) {
+	used := map[int]bool{}
+	for _, channel := range st.pinChannel {
+		used[channel] = true
+	}
+	for channel := group * st.a.channels; channel < (group+1)*st.a.channels; channel++ {
+		if !used[channel] {
+			return channel, true
+		}
+	}
+	return 0, false
+}
+
+func (st *ledcState) take(pin, timer, channel int, freq uint) {
+	st.timerFreqs[timer] = freq
+	st.pinTimer[pin] = timer
+	st.pinChannel[pin] = channel
+}
+
+func (st *ledcState) releaseIfUnused(timer int) {
+	for _, other := range st.pinTimer {
+		if other == timer {
+			return
+		}
+	}
+	st.timerFreqs[timer] = 0
+}
+
+// conflict explains why pin cannot run at freq.
+func (st *ledcState) conflict(pin int, freq uint) error {
+	a := st.a
+	if len(st.pinChannel) >= a.groups*a.channels {
+		return fmt.Errorf("cannot drive GPIO%d with PWM: all %d LEDC channels are in use: %w", pin, a.groups*a.channels, ErrInvalidPin)
+	}
+	users := map[int][]int{}
+	for other, timer := range st.pinTimer {
+		users[timer] = append(users[timer], other)
+	}
+	var timers []string
+	for timer, timerFreq := range st.timerFreqs {
+		if timerFreq == 0 {
+			continue
+		}
+		sort.Ints(users[timer])
+		names := make([]string, len(users[timer]))
+		for i, other := range users[timer] {
+			names[i] = fmt.Sprintf("GPIO%d", other)
+		}
+		timers = append(timers, fmt.Sprintf("%dHz (%s)", timerFreq, strings.Join(names, ", ")))
+	}
+	return fmt.Errorf("cannot set GPIO%d to %dHz: no LEDC timer with a free channel is left, timers run at %s; "+
+		"use one of these frequencies or move a pin to another: %w",
+		pin, freq, strings.Join(timers, ", "), ErrInvalidPin)
+}
+
+// freq returns the frequency the allocator set pin to, if it set one.
+func (a *ledcAllocator) freq(pin int) (uint, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	timer, ok := a.pinTimer[pin]
+	if !ok {
+		return 0, false
+	}
+	return a.timerFreqs[timer], true
+}
+
+// timerUsage describes every timer in use, for status.
+func (a *ledcAllocator) timerUsage() []interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pins := map[int][]int{}
+	for pin, timer := range a.pinTimer {
+		pins[timer] = append(pins[timer], pin)
+	}
+	usage := []interface{}{}
+	for timer, freq := range a.timerFreqs {
+		if freq == 0 {
+			continue
+		}
+		sort.Ints(pins[timer])
+		pinNums := make([]interface{}, len(pins[timer]))
+		for i, pin := range pins[timer] {
+			pinNums[i] = pin
+		}
+		usage = append(usage, map[string]interface{}{"timer": timer, "freq_hz": freq, "pins": pinNums})
+	}
+	return usage
+}
+
+// setPWMFreqs sets the frequencies with the timers and channels the allocator assigns
+// them.
+func (s *esp32Board) setPWMFreqs(ctx context.Context, opts callOptions, freqs []esp32client.PinFreq) error {
+	assigned, plan, err := s.ledc.assign(freqs)
+	if err != nil {
+		return err
+	}
+	if err := s.call(ctx, opts, func(ctx context.Context) error {
+		return s.client.SetPWMFreqs(ctx, assigned)
+	}); err != nil {
+		return err
+	}
+	s.ledc.commit(plan)
+	return nil
+}
@@ -0,0 +1,145 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const macroPollInterval = 100 * time.Millisecond
+
+// MacroConfig is a named sequence of pin writes run by the firmware, so its timing does
+// not depend on the network.
+type MacroConfig struct {
+	Name  string            `json:"name"`
+	Steps []MacroStepConfig `json:"steps"`
+}
+
+// MacroStepConfig sets a pin either to a level or to a duty cycle, then waits DelayMs
+// before the next step.
+type MacroStepConfig struct {
+	// Pin is a pin name or a GPIO number, e.g. "relay1" or "26".
+	Pin       string   `json:"pin"`
+	High      *bool    `json:"high,omitempty"`
+	DutyCycle *float64 `json:"duty_cycle,omitempty"`
+	DelayMs   int      `json:"delay_ms,omitempty"`
+}
+
+// resolve converts the macro into firmware steps, looking its pins up in t.
+func (cfg *MacroConfig) resolve(t *pinTable) ([]esp32client.MacroStep, error) {
+	if len(cfg.Steps) == 0 {
+		return nil, fmt.Errorf("macro %q has no steps", cfg.Name)
+	}
+	steps := make([]esp32client.MacroStep, 0, len(cfg.Steps))
+	for i, step := range cfg.Steps {
+		pinNum, err := t.lookup(step.Pin)
+		if err != nil {
+			return nil, fmt.Errorf("steps.%d: %w", i, err)
+		}
+		if err := t.checkWrite(pinNum); err != nil {
+			return nil, fmt.Errorf("steps.%d: %w", i, err)
+		}
+		if step.DelayMs < 0 {
+			return nil, fmt.Errorf("steps.%d: 'delay_ms' must not be negative", i)
+		}
+		var state int
+		switch {
+		case step.High != nil && step.DutyCycle != nil:
+			return nil, fmt.Errorf("steps.%d: set either 'high' or 'duty_cycle', not both", i)
+		case step.High != nil:
+			if *step.High {
+				state = 100
+			}
+		case step.DutyCycle != nil:
+			if *step.DutyCycle < 0 || *step.DutyCycle > 1 {
+				return nil, fmt.Errorf("steps.%d: 'duty_cycle' must be between 0 and 1, got %v", i, *step.DutyCycle)
+			}
+			if err := t.checkPWM(pinNum); err != nil {
+				return nil, fmt.Errorf("steps.%d: %w", i, err)
+			}
+			state = int(*step.DutyCycle * 100)
+		default:
+			return nil, fmt.Errorf("steps.%d: needs either 'high' or 'duty_cycle'", i)
+		}
+		steps = append(steps, esp32client.MacroStep{PinNum: pinNum, State: state, DelayMs: step.DelayMs})
+	}
+	return steps, nil
+}
+
+// doRunMacro starts a configured macro. With "wait" it blocks until the firmware reports
+// the macro finished and returns the final progress.
+//
+//	{"command": "run_macro", "name": "startup", "wait": true}
+func (s *esp32Board) doRunMacro(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, err := stringArg(cmd, "name")
+	if err != nil {
+		return nil, err
+	}
+	steps, ok := s.macros[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown macro %q", name)
+	}
+	wait, _ := cmd["wait"].(bool)
+	return s.runMacro(ctx, name, steps, wait)
+}
+
+// runMacro starts steps on the firmware and, if wait is set, polls until they finished.
+func (s *esp32Board) runMacro(ctx context.Context, name string, steps []esp32client.MacroStep, wait bool) (map[string]interface{}, error) {
+	// The firmware drives the macro's pins from now on.
+	pinNums := make([]int, len(steps))
+	for i, step := range steps {
+		s.writes.forget(step.PinNum)
+		pinNums[i] = step.PinNum
+	}
+	s.dropNoAck(pinNums...)
+	id, err := esp32client.RunMacro(ctx, s.client, name, steps)
+	if err != nil {
+		return nil, err
+	}
+	if !wait {
+		return map[string]interface{}{"id": id, "steps": len(steps)}, nil
+	}
+
+	ticker := time.NewTicker(macroPollInterval)
+	defer ticker.Stop()
+	for {
+		progress, err := esp32client.MacroStatus(ctx, s.client, id)
+		if err != nil {
+			return nil, err
+		}
+		if progress.Done {
+			return macroProgressResult(progress)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("macro %q (run %d) still running at step %d of %d: %w",
+				name, id, progress.Step, progress.Steps, ctx.Err())
+		}
+	}
+}
+
+// doMacroStatus reports the progress of a macro run.
+//
+//	{"command": "macro_status", "id": 4}
+func (s *esp32Board) doMacroStatus(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	id, err := numberArg(cmd, "id")
+	if err != nil {
+		return nil, err
+	}
+	progress, err := esp32client.MacroStatus(ctx, s.client, int(id))
+	if err != nil {
+		return nil, err
+	}
+	return macroProgressResult(progress)
+}
+
+func macroProgressResult(progress esp32client.MacroProgress) (map[string]interface{}, error) {
+	result, err := toJSONValue(progress)
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]interface{}), nil
+}
@@ -0,0 +1,112 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+
+	"esp32wifi/esp32client"
+)
+
+// doWriteMask sets the pins whose bits are set in mask to the matching bits of values,
+// atomically in one write of a GPIO bank. Bit n is GPIO n; both numbers may also be
+// given as strings such as "0xff000".
+//
+//	{"command": "write_mask", "mask": "0xff000", "values": "0x5a000"}
+func (s *esp32Board) doWriteMask(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	mask, err := maskArg(cmd, "mask")
+	if err != nil {
+		return nil, err
+	}
+	values, err := maskArg(cmd, "values")
+	if err != nil {
+		return nil, err
+	}
+	if mask == 0 {
+		return nil, fmt.Errorf("argument \"mask\" must select at least one pin")
+	}
+	if values&^mask != 0 {
+		return nil, fmt.Errorf("argument \"values\" %#x sets bits outside the mask %#x", values, mask)
+	}
+
+	writes, err := s.maskWrites(mask, values)
+	if err != nil {
+		return nil, err
+	}
+	s.dropNoAckWrites(writes)
+	if err := esp32client.WriteMask(ctx, s.client, mask, values); err != nil {
+		for _, w := range writes {
+			s.writes.forget(w.PinNum)
+		}
+		return nil, err
+	}
+	s.writes.record(writes...)
+	return map[string]interface{}{"written": len(writes)}, nil
+}
+
+// maskWrites checks that every pin in mask can be written and sits in one bank, and
+// returns the writes the mask amounts to.
+func (s *esp32Board) maskWrites(mask, values uint64) ([]esp32client.PinWrite, error) {
+	variant, err := lookupChipVariant(s.pins.variantName)
+	if err != nil {
+		return nil, err
+	}
+	lowest := bits.TrailingZeros64(mask)
+	highest := 63 - bits.LeadingZeros64(mask)
+	if lowest/esp32client.GPIOBankSize != highest/esp32client.GPIOBankSize {
+		return nil, fmt.Errorf("mask %#x spans GPIO%d to GPIO%d, but only pins within one bank of %d can be written together",
+			mask, lowest, highest, esp32client.GPIOBankSize)
+	}
+
+	var writes []esp32client.PinWrite
+	for pinNum := lowest; pinNum <= highest; pinNum++ {
+		if mask&(1<<pinNum) == 0 {
+			continue
+		}
+		if !variant.gpios[pinNum] {
+			return nil, fmt.Errorf("GPIO%d is not a usable pin on the %s: %w", pinNum, s.pins.variantName, ErrInvalidPin)
+		}
+		if err := s.pins.checkAvailable(pinNum); err != nil {
+			return nil, err
+		}
+		if err := s.pins.checkWrite(pinNum); err != nil {
+			return nil, err
+		}
+		if pin, ok := s.pins.pins[pinNum]; ok && pin.Mode != pinModeOutput {
+			return nil, fmt.Errorf("pin %s is configured as %s, masked writes need output pins: %w",
+				s.pins.name(pinNum), pin.Mode, ErrInvalidPin)
+		}
+		state := 0
+		if values&(1<<pinNum) != 0 {
+			state = 100
+		}
+		writes = append(writes, esp32client.PinWrite{PinNum: pinNum, State: state})
+	}
+	return writes, nil
+}
+
+// maskArg parses a bit mask given as a non-negative integer or a string in any base
+// strconv accepts, e.g. "0b1010" or "0xff".
+func maskArg(cmd map[string]interface{}, key string) (uint64, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return 0, fmt.Errorf("missing required argument %q", key)
+	}
+	switch value := raw.(type) {
+	case float64:
+		if value < 0 || value != math.Trunc(value) || value > 1<<53 {
+			return 0, fmt.Errorf("argument %q must be a non-negative integer, got %v", key, value)
+		}
+		return uint64(value), nil
+	case string:
+		mask, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("argument %q: invalid mask %q: %w", key, value, err)
+		}
+		return mask, nil
+	default:
+		return 0, fmt.Errorf("argument %q must be a number or a string, got %T", key, raw)
+	}
+}
@@ -0,0 +1,167 @@
+package esp32wifi
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"esp32wifi/esp32client"
+)
+
+// PinLister is implemented by the boards in this package. It lists the pins that can be
+// passed to AnalogByName and GPIOPinByName, so callers can offer a choice of pins.
+type PinLister interface {
+	// AnalogNames returns the names of the analog pins.
+	AnalogNames() []string
+	// GPIOPinNames returns the names of the input, output and pwm pins.
+	GPIOPinNames() []string
+}
+
+// pinCapabilities is what the firmware reported it can do with each pin.
+type pinCapabilities struct {
+	mu    sync.Mutex
+	modes map[int][]string
+}
+
+// loadCapabilities asks the firmware which pins it exposes, and switches to HTTP/2 if it
+// serves it. Firmware that cannot say leaves the pin lists to the config.
+func (s *esp32Board) loadCapabilities() {
+	ctx, cancel := context.WithTimeout(s.cancelCtx, infoTimeout)
+	defer cancel()
+	capabilities, err := esp32client.ReadDeviceCapabilities(ctx, s.client)
+	if err != nil {
+		s.logger.Debugf("pins will be listed from the config only: %v", err)
+		return
+	}
+	if httpClient, ok := transport(s.client).(*esp32client.HTTPClient); ok {
+		if err := httpClient.NegotiateHTTP2(ctx, capabilities); err != nil {
+			s.logger.Debugf("staying on HTTP/1.1: %v", err)
+		}
+	}
+	if s.states.detect(capabilities.StateFormat) && s.states.configured == "" {
+		s.logger.Infof("firmware reads pins in the %s state format", capabilities.StateFormat)
+	}
+	s.features.list(capabilities.Features)
+	modes := map[int][]string{}
+	for _, pin := range capabilities.Pins {
+		modes[pin.PinNum] = pin.Modes
+	}
+	s.capabilities.mu.Lock()
+	s.capabilities.modes = modes
+	s.capabilities.mu.Unlock()
+}
+
+// AnalogNames returns the configured analog readers and differential analogs, then the
+// other configured analog pins, then any other pins the firmware reports as analog
+// capable.
+func (s *esp32Board) AnalogNames() []string {
+	readers := make([]string, 0, len(s.analogs)+len(s.differentials))
+	read := map[string]bool{}
+	for name, reader := range s.analogs {
+		readers = append(readers, name)
+		read[s.pins.name(reader.pinNum)] = true
+	}
+	for name := range s.differentials {
+		readers = append(readers, name)
+	}
+	sort.Strings(readers)
+	for _, name := range s.pinNames(func(mode string) bool { return mode == pinModeAnalog }) {
+		if !read[name] {
+			readers = append(readers, name)
+		}
+	}
+	return readers
+}
+
+// GPIOPinNames returns the configured input, output and pwm pins, then any other pins the
+// firmware reports can be used that way.
+func (s *esp32Board) GPIOPinNames() []string {
+	return s.pinNames(func(mode string) bool {
+		return mode == pinModeInput || mode == pinModeOutput || mode == pinModePWM
+	})
+}
+
+// pinNames returns the names of configured pins whose mode matches, in pin order,
+// followed by the unconfigured, unreserved pins where the firmware supports a matching
+// mode.
+func (s *esp32Board) pinNames(matches func(mode string) bool) []string {
+	var configured, reported []int
+	for pinNum, pin := range s.pins.pins {
+		if matches(pin.Mode) {
+			configured = append(configured, pinNum)
+		}
+	}
+
+	s.capabilities.mu.Lock()
+	for pinNum, modes := range s.capabilities.modes {
+		if _, ok := s.pins.pins[pinNum]; ok || s.pins.checkAvailable(pinNum) != nil {
+			continue
+		}
+		for _, mode := range modes {
+			if matches(mode) {
+				reported = append(reported, pinNum)
+				break
+			}
+		}
+	}
+	s.capabilities.mu.Unlock()
+
+	sort.Ints(configured)
+	sort.Ints(reported)
+	names := make([]string, 0, len(configured)+len(reported))
+	for _, pinNum := range append(configured, reported...) {
+		names = append(names, s.pins.name(pinNum))
+	}
+	return names
+}
+
+// doDescribe lists the board's pins: every configured pin with its mode, the firmware's
+// capabilities for it when known, and the names accepted by AnalogByName and
+// GPIOPinByName.
+//
+//	{"command": "describe"}
+func (s *esp32Board) doDescribe(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	pinNums := make([]int, 0, len(s.pins.pins))
+	for pinNum := range s.pins.pins {
+		pinNums = append(pinNums, pinNum)
+	}
+	sort.Ints(pinNums)
+
+	s.capabilities.mu.Lock()
+	pins := make([]interface{}, 0, len(pinNums))
+	for _, pinNum := range pinNums {
+		pin := s.pins.pins[pinNum]
+		entry := map[string]interface{}{"name": s.pins.name(pinNum), "pin": pinNum, "mode": pin.Mode}
+		if pin.SafeState != nil {
+			entry["safe_state"] = *pin.SafeState
+		}
+		if modes, ok := s.capabilities.modes[pinNum]; ok {
+			entry["capabilities"] = stringsToList(modes)
+		}
+		pins = append(pins, entry)
+	}
+	s.capabilities.mu.Unlock()
+
+	macros := make([]string, 0, len(s.macros))
+	for name := range s.macros {
+		macros = append(macros, name)
+	}
+	sort.Strings(macros)
+
+	return map[string]interface{}{
+		"profile":   s.pins.profileName,
+		"pins":      pins,
+		"analogs":   stringsToList(s.AnalogNames()),
+		"gpio_pins": stringsToList(s.GPIOPinNames()),
+		"macros":    stringsToList(macros),
+	}, nil
+}
+
+// stringsToList converts strs to the []interface{} DoCommand results are made of.
+func stringsToList(strs []string) []interface{} {
+	list := make([]interface{}, len(strs))
+	for i, str := range strs {
+		list[i] = str
+	}
+	return list
+}
@@ -0,0 +1,173 @@
+package esp32wifi
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+// noAckTimeout bounds a write sent without acknowledgement that no timeout_ms bounds, so
+// a device that stopped answering does not hold up the writes queued behind it.
+const noAckTimeout = 5 * time.Second
+
+// noAckWriter sends pin writes in the background, so Set and SetPWM return without
+// waiting for the device. Writes to a pin that are still queued when the next one arrives
+// are replaced by it, so the device always ends at the last state asked for.
+type noAckWriter struct {
+	// sendMu is held while a write is sent, so an acknowledged write cannot be overtaken
+	// by an older one in flight.
+	sendMu sync.Mutex
+
+	mu      sync.Mutex
+	started bool
+	pending map[int]pendingWrite
+	wake    chan struct{}
+
+	sent          int
+	failed        int
+	lastError     error
+	lastErrorTime time.Time
+}
+
+type pendingWrite struct {
+	write esp32client.PinWrite
+	opts  callOptions
+}
+
+func newNoAckWriter() *noAckWriter {
+	return &noAckWriter{pending: map[int]pendingWrite{}, wake: make(chan struct{}, 1)}
+}
+
+// noAck reports whether a write with opts is sent without waiting for the device: as
+// extra's no_ack says, or the board's no_ack if extra does not say.
+func (s *esp32Board) noAck(opts callOptions) bool {
+	if opts.noAck != nil {
+		return *opts.noAck
+	}
+	return s.noAckDefault
+}
+
+// writeNoAck queues write for the "no_ack" worker, starting it on first use.
+func (s *esp32Board) writeNoAck(write esp32client.PinWrite, opts callOptions) error {
+	w := s.noAckWrites
+	w.mu.Lock()
+	if !w.started {
+		if !s.goBackground("no_ack", s.sendNoAckWrites) {
+			w.mu.Unlock()
+			return errBoardClosed
+		}
+		w.started = true
+	}
+	w.pending[write.PinNum] = pendingWrite{write: write, opts: opts}
+	w.mu.Unlock()
+
+	s.writes.record(write)
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// dropNoAck discards the queued writes to pinNums and waits for any write in flight, before
+// any other write to them is sent. Every write path calls it, so a queued write never lands
+// after a later one.
+func (s *esp32Board) dropNoAck(pinNums ...int) {
+	if len(pinNums) == 0 {
+		return
+	}
+	w := s.noAckWrites
+	w.mu.Lock()
+	started := w.started
+	for _, pinNum := range pinNums {
+		delete(w.pending, pinNum)
+	}
+	w.mu.Unlock()
+	if started {
+		// Only waits for the write in flight.
+		w.sendMu.Lock()
+		w.sendMu.Unlock()
+	}
+}
+
+// dropNoAckWrites calls dropNoAck for the pins of writes.
+func (s *esp32Board) dropNoAckWrites(writes []esp32client.PinWrite) {
+	pinNums := make([]int, len(writes))
+	for i, write := range writes {
+		pinNums[i] = write.PinNum
+	}
+	s.dropNoAck(pinNums...)
+}
+
+// sendNoAckWrites sends queued writes until the board is closed. Writes still queued then
+// are dropped.
+func (s *esp32Board) sendNoAckWrites() {
+	w := s.noAckWrites
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-w.wake:
+		}
+		w.sendMu.Lock()
+		w.mu.Lock()
+		batch := w.pending
+		w.pending = map[int]pendingWrite{}
+		w.mu.Unlock()
+
+		pinNums := make([]int, 0, len(batch))
+		for pinNum := range batch {
+			pinNums = append(pinNums, pinNum)
+		}
+		sort.Ints(pinNums)
+		for _, pinNum := range pinNums {
+			s.sendNoAck(batch[pinNum])
+		}
+		w.sendMu.Unlock()
+	}
+}
+
+// sendNoAck sends one queued write, counting it as sent or failed for status.
+func (s *esp32Board) sendNoAck(p pendingWrite) {
+	opts := p.opts
+	if opts.timeout == 0 {
+		opts.timeout = noAckTimeout
+	}
+	ctx := s.withWriteMode(s.cancelCtx, s.pins.pins[p.write.PinNum].SafeState != nil, opts)
+	err := s.call(ctx, opts, func(ctx context.Context) error {
+		return s.client.WritePins(ctx, []esp32client.PinWrite{p.write})
+	})
+	w := s.noAckWrites
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		if s.cancelCtx.Err() != nil {
+			return
+		}
+		s.writes.forget(p.write.PinNum)
+		w.failed++
+		w.lastError, w.lastErrorTime = err, time.Now()
+		s.logger.Debugf("failed to write GPIO%d without acknowledgement: %v", p.write.PinNum, err)
+		return
+	}
+	w.sent++
+}
+
+// status describes the writes sent without acknowledgement, for status. It returns nil
+// if there were none.
+func (w *noAckWriter) status() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		return nil
+	}
+	status := map[string]interface{}{"pending": len(w.pending), "sent": w.sent, "failed": w.failed}
+	if w.lastError != nil {
+		status["last_error"] = w.lastError.Error()
+		status["last_error_time"] = w.lastErrorTime.Format(time.RFC3339)
+	}
+	return status
+}
@@ -0,0 +1,61 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+
+	"esp32wifi/esp32client"
+)
+
+// nvsArgs returns the optional namespace and required key of an nvs command.
+func nvsArgs(cmd map[string]interface{}) (string, string, error) {
+	key, err := stringArg(cmd, "key")
+	if err != nil {
+		return "", "", err
+	}
+	var namespace string
+	if _, ok := cmd["namespace"]; ok {
+		if namespace, err = stringArg(cmd, "namespace"); err != nil {
+			return "", "", err
+		}
+	}
+	return namespace, key, nil
+}
+
+func (s *esp32Board) doNVSGet(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	namespace, key, err := nvsArgs(cmd)
+	if err != nil {
+		return nil, err
+	}
+	value, err := esp32client.NVSGet(ctx, s.client, namespace, key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"key": key, "value": value}, nil
+}
+
+func (s *esp32Board) doNVSSet(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	namespace, key, err := nvsArgs(cmd)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := cmd["value"]
+	if !ok {
+		return nil, fmt.Errorf("missing required argument %q", "value")
+	}
+	if err := esp32client.NVSSet(ctx, s.client, namespace, key, value); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"key": key}, nil
+}
+
+func (s *esp32Board) doNVSErase(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	namespace, key, err := nvsArgs(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := esp32client.NVSErase(ctx, s.client, namespace, key); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"key": key}, nil
+}
@@ -0,0 +1,85 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"esp32wifi/esp32client"
+)
+
+// openDrainModes returns the mode of every configured open-drain pin, in pin order.
+func (t *pinTable) openDrainModes() []esp32client.PinMode {
+	var modes []esp32client.PinMode
+	for pinNum, pin := range t.pins {
+		if pin.OpenDrain {
+			modes = append(modes, esp32client.PinMode{
+				PinNum: pinNum,
+				Mode:   esp32client.PinModeOpenDrain,
+				PullUp: pin.PullUp == pullUpInternal,
+			})
+		}
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i].PinNum < modes[j].PinNum })
+	return modes
+}
+
+// pinModeSetup switches pins to their modes, which the firmware forgets when it reboots.
+func (s *esp32Board) pinModeSetup(what string, modes []esp32client.PinMode) deviceSetup {
+	return deviceSetup{
+		what: what,
+		apply: func(ctx context.Context) error {
+			return esp32client.SetPinModes(ctx, s.client, modes)
+		},
+	}
+}
+
+// doSetOpenDrain switches an output pin between open-drain and push-pull until the device
+// reboots. pull_up is external (default) or internal, as for the pin attribute.
+//
+//	{"command": "set_open_drain", "pin": "21", "enabled": true, "pull_up": "internal"}
+func (s *esp32Board) doSetOpenDrain(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	pinNum, err := s.pinArg(cmd, "pin")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pins.checkWrite(pinNum); err != nil {
+		return nil, err
+	}
+	enabled := true
+	if _, ok := cmd["enabled"]; ok {
+		if enabled, err = boolArg(cmd, "enabled"); err != nil {
+			return nil, err
+		}
+	}
+	pullUp := pullUpExternal
+	if _, ok := cmd["pull_up"]; ok {
+		if pullUp, err = stringArg(cmd, "pull_up"); err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, errors.New(`"pull_up" can only be given with "enabled": true`)
+		}
+		if pullUp != pullUpExternal && pullUp != pullUpInternal {
+			return nil, fmt.Errorf(`"pull_up" must be %s or %s, got %q`, pullUpExternal, pullUpInternal, pullUp)
+		}
+	}
+	mode := esp32client.PinMode{PinNum: pinNum, Mode: esp32client.PinModeOutput}
+	if enabled {
+		mode = esp32client.PinMode{PinNum: pinNum, Mode: esp32client.PinModeOpenDrain, PullUp: pullUp == pullUpInternal}
+	}
+	err = s.call(ctx, callOptions{}, func(ctx context.Context) error {
+		return esp32client.SetPinModes(ctx, s.client, []esp32client.PinMode{mode})
+	})
+	// A high written before now drove the line; written again it only releases it.
+	s.writes.forget(pinNum)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"pin_num": pinNum, "mode": mode.Mode}
+	if enabled {
+		result["pull_up"] = pullUp
+	}
+	return result, nil
+}
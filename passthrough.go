@@ -0,0 +1,89 @@
+package esp32wifi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"esp32wifi/esp32client"
+)
+
+// doHTTP sends a request to any firmware endpoint, for customized firmware. Over BLE it
+// needs firmware that answers BLE requests. Requests forwarded by a board configured with
+// a "resource://" url set "forwarded", and get answers the firmware refused with, e.g. 404
+// for an unknown endpoint, as a "status" instead of an error.
+//
+//	{"command": "http", "method": "POST", "path": "/custom", "body": {"speed": 3}}
+func (s *esp32Board) doHTTP(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	method, err := stringArg(cmd, "method")
+	if err != nil {
+		return nil, err
+	}
+	method = strings.ToUpper(method)
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return nil, fmt.Errorf("unsupported method %q", method)
+	}
+	path, err := stringArg(cmd, "path")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path must start with \"/\", got %q", path)
+	}
+
+	if method != http.MethodGet {
+		// The request may have changed any pin.
+		s.writes.reset()
+		s.relays.forget()
+	}
+	forwarded := false
+	if _, ok := cmd["forwarded"]; ok {
+		if forwarded, err = boolArg(cmd, "forwarded"); err != nil {
+			return nil, err
+		}
+	}
+	var response interface{}
+	// The path may be the firmware's own, which says nothing of the module's features.
+	if err := s.client.Call(withPassthrough(ctx), method, path, cmd["body"], &response); err != nil {
+		if status := forwardedStatus(err); forwarded && status != 0 {
+			return map[string]interface{}{"status": status, "response": map[string]interface{}{"error": err.Error()}}, nil
+		}
+		return nil, err
+	}
+	return map[string]interface{}{"response": response}, nil
+}
+
+// doBLEWrite writes data to the BLE write characteristic without waiting for a response.
+// A string is written as is, anything else as JSON.
+//
+//	{"command": "ble_write", "data": {"custom": true}}
+func (s *esp32Board) doBLEWrite(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	data, ok := cmd["data"]
+	if !ok {
+		return nil, fmt.Errorf("missing required argument %q", "data")
+	}
+	ble, ok := transport(s.client).(*esp32client.BLEClient)
+	if !ok {
+		return nil, fmt.Errorf("ble_write is only available on BLE boards: %w", esp32client.ErrNotSupported)
+	}
+	var raw []byte
+	if str, ok := data.(string); ok {
+		raw = []byte(str)
+	} else {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+	}
+	// The write may have changed any pin.
+	s.writes.reset()
+	s.relays.forget()
+	if err := ble.WriteRaw(ctx, raw); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"written": len(raw)}, nil
+}
@@ -0,0 +1,130 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"esp32wifi/esp32client"
+)
+
+var allPeripherals = []string{esp32client.PeripheralLEDC, esp32client.PeripheralPCNT, esp32client.PeripheralRMT}
+
+// doResetPeripherals has the firmware reinitialize its LEDC, PCNT and RMT drivers, or
+// only those listed, and then restores what the module configured on them: the duty
+// cycle and frequency of every readable pwm pin when LEDC is reset, and the interrupts
+// and analog readers from the config.
+//
+//	{"command": "reset_peripherals"}
+//	{"command": "reset_peripherals", "peripherals": ["ledc"]}
+func (s *esp32Board) doResetPeripherals(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	peripherals, err := peripheralsArg(cmd, "peripherals")
+	if err != nil {
+		return nil, err
+	}
+	resetLEDC := false
+	for _, peripheral := range peripherals {
+		resetLEDC = resetLEDC || peripheral == esp32client.PeripheralLEDC
+	}
+
+	var pwm []esp32client.PinRead
+	if resetLEDC {
+		if pwm, err = s.readPWMPins(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read pwm pins before the reset: %w", err)
+		}
+	}
+	if err := esp32client.ResetPeripherals(ctx, s.client, peripherals); err != nil {
+		return nil, err
+	}
+	// Like a reboot, the reset may have changed any pin the firmware drives.
+	s.writes.reset()
+	s.relays.forget()
+
+	if err := s.restorePWMPins(ctx, pwm); err != nil {
+		return nil, fmt.Errorf("peripherals were reset but restoring pwm pins failed: %w", err)
+	}
+	reapplied := make([]interface{}, 0, len(s.setups))
+	for _, setup := range s.setups {
+		if err := setup.apply(ctx); err != nil {
+			return nil, fmt.Errorf("peripherals were reset but configuring %s failed: %w", setup.what, err)
+		}
+		reapplied = append(reapplied, setup.what)
+	}
+
+	reset := make([]interface{}, len(peripherals))
+	for i, peripheral := range peripherals {
+		reset[i] = peripheral
+	}
+	return map[string]interface{}{
+		"reset":         reset,
+		"restored_pins": len(pwm),
+		"reapplied":     reapplied,
+	}, nil
+}
+
+// peripheralsArg returns the peripherals listed at key, or every peripheral if the key
+// is not set.
+func peripheralsArg(cmd map[string]interface{}, key string) ([]string, error) {
+	raw, ok := cmd[key]
+	if !ok {
+		return allPeripherals, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("argument %q must be a non-empty list of peripherals", key)
+	}
+	peripherals := make([]string, 0, len(items))
+	for i, item := range items {
+		name, _ := item.(string)
+		known := false
+		for _, peripheral := range allPeripherals {
+			known = known || name == peripheral
+		}
+		if !known {
+			return nil, fmt.Errorf("%s[%d]: unknown peripheral %v, must be one of %v", key, i, item, allPeripherals)
+		}
+		peripherals = append(peripherals, name)
+	}
+	return peripherals, nil
+}
+
+// readPWMPins reads the configured pwm pins that are not write_only.
+func (s *esp32Board) readPWMPins(ctx context.Context) ([]esp32client.PinRead, error) {
+	var pinNums []int
+	for pinNum, pin := range s.pins.pins {
+		if pin.Mode == pinModePWM && !pin.WriteOnly {
+			pinNums = append(pinNums, pinNum)
+		}
+	}
+	if len(pinNums) == 0 {
+		return nil, nil
+	}
+	sort.Ints(pinNums)
+	return s.client.ReadPins(ctx, pinNums)
+}
+
+// restorePWMPins writes back the frequencies and duty cycles read by readPWMPins.
+func (s *esp32Board) restorePWMPins(ctx context.Context, reads []esp32client.PinRead) error {
+	if len(reads) == 0 {
+		return nil
+	}
+	var freqs []esp32client.PinFreq
+	writes := make([]esp32client.PinWrite, 0, len(reads))
+	for _, read := range reads {
+		if read.Freq != 0 {
+			freqs = append(freqs, esp32client.PinFreq{PinNum: read.PinNum, Freq: read.Freq})
+		}
+		writes = append(writes, esp32client.PinWrite{PinNum: read.PinNum, State: int(math.Round(read.State))})
+	}
+	if len(freqs) > 0 {
+		if err := s.setPWMFreqs(ctx, callOptions{noRetry: true}, freqs); err != nil {
+			return err
+		}
+	}
+	if err := s.client.WritePins(ctx, writes); err != nil {
+		return err
+	}
+	s.writes.record(writes...)
+	return nil
+}
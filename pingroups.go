@@ -0,0 +1,115 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"esp32wifi/esp32client"
+)
+
+// PinGroupConfig is a set of output pins switched together between named states, e.g. a
+// traffic light whose "stop" state drives red high and the others low.
+type PinGroupConfig struct {
+	Name string `json:"name"`
+	// Pins maps member names to pin names or GPIO numbers, e.g. {"red": "25"}.
+	Pins map[string]string `json:"pins"`
+	// States maps each state to the members it drives high. The other members are
+	// driven low.
+	States map[string][]string `json:"states"`
+}
+
+// resolve converts the group into the writes of each state, looking its pins up in t.
+func (cfg *PinGroupConfig) resolve(t *pinTable) (map[string][]esp32client.PinWrite, error) {
+	if len(cfg.Pins) == 0 {
+		return nil, fmt.Errorf("group %q has no pins", cfg.Name)
+	}
+	if len(cfg.States) == 0 {
+		return nil, fmt.Errorf("group %q has no states", cfg.Name)
+	}
+	members := make(map[string]int, len(cfg.Pins))
+	owners := map[int]string{}
+	// Sorted so validation reports the same error every time.
+	for _, member := range sortedKeys(cfg.Pins) {
+		pin := cfg.Pins[member]
+		pinNum, err := t.lookup(pin)
+		if err != nil {
+			return nil, fmt.Errorf("pins.%s: %w", member, err)
+		}
+		if err := t.checkWrite(pinNum); err != nil {
+			return nil, fmt.Errorf("pins.%s: %w", member, err)
+		}
+		if configured, ok := t.pins[pinNum]; ok && configured.Mode != pinModeOutput {
+			return nil, fmt.Errorf("pins.%s: GPIO%d is configured as %s, not output", member, pinNum, configured.Mode)
+		}
+		if other, ok := owners[pinNum]; ok {
+			return nil, fmt.Errorf("pins.%s: GPIO%d is also member %q", member, pinNum, other)
+		}
+		owners[pinNum] = member
+		members[member] = pinNum
+	}
+
+	states := make(map[string][]esp32client.PinWrite, len(cfg.States))
+	for _, state := range sortedKeys(cfg.States) {
+		high := cfg.States[state]
+		levels := make(map[int]int, len(members))
+		for _, pinNum := range members {
+			levels[pinNum] = 0
+		}
+		for _, member := range high {
+			pinNum, ok := members[member]
+			if !ok {
+				return nil, fmt.Errorf("states.%s: %q is not a member of group %q", state, member, cfg.Name)
+			}
+			levels[pinNum] = 100
+		}
+		writes := make([]esp32client.PinWrite, 0, len(levels))
+		for pinNum, level := range levels {
+			writes = append(writes, esp32client.PinWrite{PinNum: pinNum, State: level})
+		}
+		sort.Slice(writes, func(i, j int) bool { return writes[i].PinNum < writes[j].PinNum })
+		states[state] = writes
+	}
+	return states, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// doSetGroupState drives a pin group to one of its states in a single transaction, so
+// the pins never show a mix of two states.
+//
+//	{"command": "set_group_state", "group": "traffic_light", "state": "stop"}
+func (s *esp32Board) doSetGroupState(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	group, err := stringArg(cmd, "group")
+	if err != nil {
+		return nil, err
+	}
+	state, err := stringArg(cmd, "state")
+	if err != nil {
+		return nil, err
+	}
+	states, ok := s.groups[group]
+	if !ok {
+		return nil, fmt.Errorf("unknown pin group %q", group)
+	}
+	writes, ok := states[state]
+	if !ok {
+		return nil, fmt.Errorf("pin group %q has no state %q", group, state)
+	}
+	s.dropNoAckWrites(writes)
+	if err := s.client.Transaction(ctx, writes); err != nil {
+		for _, w := range writes {
+			s.writes.forget(w.PinNum)
+		}
+		return nil, err
+	}
+	s.writes.record(writes...)
+	return map[string]interface{}{"group": group, "state": state, "written": len(writes)}, nil
+}
@@ -0,0 +1,167 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"esp32wifi/esp32client"
+)
+
+// pinTable resolves pin names for a board, combining the profile's pins with the
+// configured ones.
+type pinTable struct {
+	profileName string
+	byName      map[string]int
+	pins        map[int]PinConfig
+	reserved    map[int]string
+	pwmPins     map[int]bool
+	// variantName and inputOnly describe the chip, whose input-only pins are never
+	// written.
+	variantName string
+	inputOnly   map[int]bool
+	// relays are the relays driven by pins, which are only written through them.
+	relays map[int]string
+}
+
+// newPinTable merges cfg's pins over its profile's pins. Configured pins replace profile
+// pins with the same number.
+func newPinTable(cfg *BoardConfig) (*pinTable, error) {
+	profile, err := lookupBoardProfile(cfg.Profile)
+	if err != nil {
+		return nil, err
+	}
+	variantName := cfg.ChipVariant
+	if profile.chipVariant != "" {
+		variantName = profile.chipVariant
+	}
+	if variantName == "" {
+		variantName = defaultChipVariant
+	}
+	variant, err := lookupChipVariant(variantName)
+	if err != nil {
+		return nil, err
+	}
+	t := &pinTable{
+		profileName: cfg.Profile,
+		byName:      map[string]int{},
+		pins:        map[int]PinConfig{},
+		reserved:    profile.reserved,
+		pwmPins:     profile.pwmPins,
+		variantName: variantName,
+		inputOnly:   variant.inputOnly,
+	}
+	for _, pin := range profile.pins {
+		t.pins[pin.Pin] = pin
+	}
+	for _, pin := range cfg.Pins {
+		t.pins[pin.Pin] = pin
+	}
+	for _, pin := range t.pins {
+		if pin.Name == "" {
+			continue
+		}
+		if other, ok := t.byName[pin.Name]; ok {
+			return nil, fmt.Errorf("pin name %q is used by both GPIO%d and GPIO%d", pin.Name, other, pin.Pin)
+		}
+		t.byName[pin.Name] = pin.Pin
+	}
+	t.relays = map[int]string{}
+	for _, relay := range cfg.Relays {
+		// Bad relay pins are reported by validate.
+		if pinNum, err := t.lookup(relay.Pin); err == nil {
+			t.relays[pinNum] = relay.Name
+		}
+	}
+	return t, nil
+}
+
+// lookup converts a pin name such as "relay1" or "26" into the GPIO number the firmware
+// expects, rejecting pins reserved by the board profile.
+func (t *pinTable) lookup(name string) (int, error) {
+	pinNum, ok := t.byName[name]
+	if !ok {
+		var err error
+		pinNum, err = strconv.Atoi(name)
+		if err != nil {
+			return 0, fmt.Errorf("unknown pin %q: not a configured pin name or a GPIO number: %w", name, ErrInvalidPin)
+		}
+	}
+	if err := t.checkAvailable(pinNum); err != nil {
+		return 0, err
+	}
+	return pinNum, nil
+}
+
+// checkAvailable returns an error if the profile reserves pinNum for on-board hardware.
+func (t *pinTable) checkAvailable(pinNum int) error {
+	if use, ok := t.reserved[pinNum]; ok {
+		return fmt.Errorf("GPIO%d is reserved for %s on the %s profile: %w", pinNum, use, t.profileName, ErrInvalidPin)
+	}
+	return nil
+}
+
+// checkPWM returns an error if the profile does not allow PWM on pinNum.
+func (t *pinTable) checkPWM(pinNum int) error {
+	if t.pwmPins != nil && !t.pwmPins[pinNum] {
+		return fmt.Errorf("GPIO%d does not support PWM on the %s profile: %w", pinNum, t.profileName, ErrInvalidPin)
+	}
+	return nil
+}
+
+// checkRead returns an error if pinNum is configured write_only.
+func (t *pinTable) checkRead(pinNum int) error {
+	if t.pins[pinNum].WriteOnly {
+		return fmt.Errorf("pin %s is write_only and cannot be read: %w", t.name(pinNum), ErrInvalidPin)
+	}
+	return nil
+}
+
+// checkWrite returns an error if pinNum cannot be driven or drives a relay.
+func (t *pinTable) checkWrite(pinNum int) error {
+	if err := t.checkDrivable(pinNum); err != nil {
+		return err
+	}
+	if relay, ok := t.relays[pinNum]; ok {
+		return fmt.Errorf("pin %s drives relay %q and is only switched through it: %w", t.name(pinNum), relay, ErrInvalidPin)
+	}
+	return nil
+}
+
+// checkDrivable returns an error if pinNum is configured read_only or is input-only on
+// the chip.
+func (t *pinTable) checkDrivable(pinNum int) error {
+	if t.pins[pinNum].ReadOnly {
+		return fmt.Errorf("pin %s is read_only and cannot be written: %w", t.name(pinNum), ErrInvalidPin)
+	}
+	if t.inputOnly[pinNum] {
+		return fmt.Errorf("GPIO%d is input-only on the %s and cannot be written: %w", pinNum, t.variantName, ErrInvalidPin)
+	}
+	return nil
+}
+
+// name returns the configured name of pinNum, or its number if it has none.
+func (t *pinTable) name(pinNum int) string {
+	if pin, ok := t.pins[pinNum]; ok && pin.Name != "" {
+		return pin.Name
+	}
+	return strconv.Itoa(pinNum)
+}
+
+// safeStates returns a write for every pin with a safe state, fully on or off for pwm
+// pins, in pin order.
+func (t *pinTable) safeStates() []esp32client.PinWrite {
+	var writes []esp32client.PinWrite
+	for pinNum, pin := range t.pins {
+		if pin.SafeState == nil {
+			continue
+		}
+		state := 0
+		if *pin.SafeState {
+			state = 100
+		}
+		writes = append(writes, esp32client.PinWrite{PinNum: pinNum, State: state})
+	}
+	sort.Slice(writes, func(i, j int) bool { return writes[i].PinNum < writes[j].PinNum })
+	return writes
+}
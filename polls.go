@@ -0,0 +1,224 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultPollHz = 20
+	maxPollHz     = 100
+	// pollTimeout bounds a read of the polled pins.
+	pollTimeout = 2 * time.Second
+)
+
+// interruptPolls emulate interrupts for firmware that cannot push them, reading the pins
+// at their poll rate and ticking on the edges between reads. Edges shorter than the poll
+// interval are missed, and ticks are stamped up to an interval late.
+type interruptPolls struct {
+	mu    sync.Mutex
+	polls map[int]*interruptPoll
+}
+
+type interruptPoll struct {
+	name     string
+	hz       int
+	interval time.Duration
+	edge     string
+	// fallback is set for basic interrupts, which are only polled once the firmware
+	// turned out not to push their events.
+	fallback bool
+	active   bool
+	due      time.Time
+
+	// known is set once the pin was read, and high is the level it was read at.
+	known bool
+	high  bool
+}
+
+func newInterruptPolls() *interruptPolls {
+	return &interruptPolls{polls: map[int]*interruptPoll{}}
+}
+
+func (p *interruptPolls) add(pinNum int, name string, hz int, edge string, fallback bool) *interruptPoll {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	poll := &interruptPoll{
+		name:     name,
+		hz:       hz,
+		interval: time.Second / time.Duration(hz),
+		edge:     edge,
+		fallback: fallback,
+		active:   !fallback,
+	}
+	p.polls[pinNum] = poll
+	return poll
+}
+
+// limits says what polling misses, for the warning logged when polling starts.
+func (poll *interruptPoll) limits() string {
+	return fmt.Sprintf("pulses shorter than %s are missed and ticks are up to %s late", poll.interval, poll.interval)
+}
+
+// fallBack starts polling the fallback interrupt on pinNum, returning false if it has no
+// fallback or is already polled.
+func (p *interruptPolls) fallBack(pinNum int) (*interruptPoll, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	poll, ok := p.polls[pinNum]
+	if !ok || poll.active {
+		return nil, false
+	}
+	poll.active, poll.known = true, false
+	return poll, true
+}
+
+// native stops polling the fallback interrupt on pinNum, once the firmware configured it.
+func (p *interruptPolls) native(pinNum int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if poll, ok := p.polls[pinNum]; ok && poll.fallback {
+		poll.active = false
+	}
+}
+
+// fallbacks returns the pins of the fallback interrupts.
+func (p *interruptPolls) fallbacks() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var pinNums []int
+	for pinNum, poll := range p.polls {
+		if poll.fallback {
+			pinNums = append(pinNums, pinNum)
+		}
+	}
+	sort.Ints(pinNums)
+	return pinNums
+}
+
+// due returns the polled pins due for a read at now, and moves them to their next read.
+func (p *interruptPolls) due(now time.Time) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var pinNums []int
+	for pinNum, poll := range p.polls {
+		if poll.active && !now.Before(poll.due) {
+			pinNums = append(pinNums, pinNum)
+			poll.due = now.Add(poll.interval)
+		}
+	}
+	sort.Ints(pinNums)
+	return pinNums
+}
+
+// tick returns the shortest poll interval.
+func (p *interruptPolls) tick() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var tick time.Duration
+	for _, poll := range p.polls {
+		if tick == 0 || poll.interval < tick {
+			tick = poll.interval
+		}
+	}
+	return tick
+}
+
+// record applies a read of pinNum, returning whether it is an edge the interrupt ticks
+// on. The first read only learns the level.
+func (p *interruptPolls) record(pinNum int, high bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	poll, ok := p.polls[pinNum]
+	if !ok || !poll.active {
+		return false
+	}
+	changed := poll.known && high != poll.high
+	poll.known, poll.high = true, high
+	if !changed {
+		return false
+	}
+	switch poll.edge {
+	case esp32client.EdgeRising:
+		return high
+	case esp32client.EdgeFalling:
+		return !high
+	default:
+		return true
+	}
+}
+
+// status lists the names of the polled interrupts, nil if none are.
+func (p *interruptPolls) status() []interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var names []string
+	for _, poll := range p.polls {
+		if poll.active {
+			names = append(names, poll.name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	polled := make([]interface{}, len(names))
+	for i, name := range names {
+		polled[i] = name
+	}
+	return polled
+}
+
+// fallBackToPolling starts polling the fallback interrupt on pinNum, warning of what
+// polling misses. It returns false if the interrupt has no fallback.
+func (s *esp32Board) fallBackToPolling(pinNum int) bool {
+	poll, ok := s.polls.fallBack(pinNum)
+	if !ok {
+		return false
+	}
+	s.logger.Warnf("firmware does not push interrupt %q, polling it at %d Hz instead: %s", poll.name, poll.hz, poll.limits())
+	return true
+}
+
+// pollInterrupts reads the polled pins at their poll rates until the board is closed,
+// ticking on the edges between reads. A failed read is retried at the next poll.
+func (s *esp32Board) pollInterrupts() {
+	ticker := time.NewTicker(s.polls.tick())
+	defer ticker.Stop()
+	for {
+		if pinNums := s.polls.due(time.Now()); len(pinNums) > 0 {
+			ctx, cancel := context.WithTimeout(s.cancelCtx, pollTimeout)
+			start := time.Now()
+			reads, err := s.client.ReadPins(ctx, pinNums)
+			cancel()
+			// The pins were read some time during the request.
+			at := start.Add(time.Since(start) / 2)
+			switch {
+			case err != nil:
+				if s.cancelCtx.Err() == nil {
+					s.logger.Debugf("failed to poll interrupts: %v", err)
+				}
+			case len(reads) != len(pinNums):
+				s.logger.Debugf("firmware returned %d readings for %d polled interrupts", len(reads), len(pinNums))
+			default:
+				for i, read := range reads {
+					high := s.states.high(pinNums[i], read.State)
+					if s.polls.record(pinNums[i], high) {
+						s.dispatchTick(pinNums[i], high, at)
+					}
+				}
+			}
+		}
+
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	powerPollInterval   = 30 * time.Second
+	powerReadTimeout    = 5 * time.Second
+	defaultWarnBelowV   = 3.0
+	defaultDividerRatio = 1.0
+	// adcFullScale and adcRefVolts convert raw 12-bit readings of an uncalibrated divider
+	// pin into volts.
+	adcFullScale = 4095
+	adcRefVolts  = 3.3
+	// rebootReasonBrownout is the reboot event reason the firmware sends after a
+	// brownout reset.
+	rebootReasonBrownout = "brownout"
+)
+
+// PowerConfig measures the supply voltage through a resistor divider on an analog pin.
+// Without it the voltage comes from the firmware, if it reports one.
+type PowerConfig struct {
+	// DividerPin is the analog pin the divider is connected to. Its calibration, if set,
+	// must convert readings to volts at the pin.
+	DividerPin string `json:"divider_pin,omitempty"`
+	// DividerRatio is the supply voltage divided by the voltage at the pin, e.g. 2 for two
+	// equal resistors. Defaults to 1.
+	DividerRatio float64 `json:"divider_ratio,omitempty"`
+	// WarnBelowV logs a warning when the supply drops below it. Defaults to 3.0.
+	WarnBelowV float64 `json:"warn_below_v,omitempty"`
+}
+
+func (cfg *PowerConfig) validate(path string, pins *pinTable) error {
+	if cfg.DividerPin != "" {
+		pinNum, err := pins.lookup(cfg.DividerPin)
+		if err != nil {
+			return fmt.Errorf("%s.divider_pin: %w", path, err)
+		}
+		if mode := pins.pins[pinNum].Mode; mode != pinModeAnalog {
+			return fmt.Errorf("%s.divider_pin: pin %s must be configured as an analog pin", path, cfg.DividerPin)
+		}
+	}
+	if cfg.DividerRatio < 0 {
+		return fmt.Errorf("%s: 'divider_ratio' must not be negative", path)
+	}
+	if cfg.WarnBelowV < 0 {
+		return fmt.Errorf("%s: 'warn_below_v' must not be negative", path)
+	}
+	return nil
+}
+
+// PowerStatus is the board's supply telemetry.
+type PowerStatus struct {
+	// SupplyVoltage is zero if neither a divider pin nor the firmware measures it.
+	SupplyVoltage float64
+	// Brownouts is the firmware's brownout reset count, or the brownout reboots seen
+	// since the board started on firmware that does not count them.
+	Brownouts int
+	// Sagging is true while SupplyVoltage is below the warning threshold.
+	Sagging bool
+	// BootCount, ResetReason and Uptime are what the firmware last reported in /info, so
+	// a supply that keeps resetting the chip shows; BootCount is zero if it does not
+	// report them.
+	BootCount   int
+	ResetReason string
+	Uptime      time.Duration
+}
+
+// PowerMonitor is implemented by the boards in this package to report their supply
+// voltage and brownout resets. The esp32-power sensor reads boards through it.
+type PowerMonitor interface {
+	Power(ctx context.Context) (PowerStatus, error)
+}
+
+// powerMonitor holds the settings and last readings of the supply.
+type powerMonitor struct {
+	dividerPin   int
+	hasDivider   bool
+	dividerRatio float64
+	warnBelowV   float64
+
+	mu        sync.Mutex
+	primed    bool
+	sagging   bool
+	brownouts int
+}
+
+func newPowerMonitor(cfg *PowerConfig, pins *pinTable) *powerMonitor {
+	m := &powerMonitor{dividerRatio: defaultDividerRatio, warnBelowV: defaultWarnBelowV}
+	if cfg == nil {
+		return m
+	}
+	if cfg.DividerPin != "" {
+		// Checked by validate.
+		m.dividerPin, _ = pins.lookup(cfg.DividerPin)
+		m.hasDivider = true
+	}
+	if cfg.DividerRatio != 0 {
+		m.dividerRatio = cfg.DividerRatio
+	}
+	if cfg.WarnBelowV != 0 {
+		m.warnBelowV = cfg.WarnBelowV
+	}
+	return m
+}
+
+// Power reads the supply voltage and brownout count from the device, and adds the boot
+// info /info last reported.
+func (s *esp32Board) Power(ctx context.Context) (PowerStatus, error) {
+	var status PowerStatus
+	power, err := esp32client.ReadPower(ctx, s.client)
+	switch {
+	case err == nil:
+		status.SupplyVoltage, status.Brownouts = power.SupplyVoltage, power.Brownouts
+	case errors.Is(err, esp32client.ErrNotSupported):
+		s.health.mu.Lock()
+		status.Brownouts = s.health.brownouts
+		s.health.mu.Unlock()
+	default:
+		return PowerStatus{}, err
+	}
+
+	if s.power.hasDivider {
+		reads, err := s.client.ReadPins(ctx, []int{s.power.dividerPin})
+		if err != nil {
+			return PowerStatus{}, fmt.Errorf("failed to read divider pin %s: %w", s.pins.name(s.power.dividerPin), err)
+		}
+		status.SupplyVoltage = s.dividerVolts(s.states.counts(reads[0].State)) * s.power.dividerRatio
+	}
+	status.Sagging = status.SupplyVoltage != 0 && status.SupplyVoltage < s.power.warnBelowV
+	s.health.mu.Lock()
+	if boot := s.health.boot; boot.known {
+		status.BootCount, status.ResetReason, status.Uptime = boot.bootCount, boot.resetReason, boot.uptimeNow()
+	}
+	s.health.mu.Unlock()
+	return status, nil
+}
+
+// dividerVolts converts a raw reading of the divider pin into volts at the pin.
+func (s *esp32Board) dividerVolts(raw float64) float64 {
+	s.calibrationMu.Lock()
+	_, calibrated := s.calibrations[s.power.dividerPin]
+	s.calibrationMu.Unlock()
+	if calibrated {
+		return s.calibrated(s.power.dividerPin, raw)
+	}
+	return raw * adcRefVolts / adcFullScale
+}
+
+// monitorPower polls the supply until the board is closed, warning when the voltage sags
+// below the threshold and when the brownout count goes up. It stops if there is nothing
+// to poll.
+func (s *esp32Board) monitorPower() {
+	ticker := time.NewTicker(powerPollInterval)
+	defer ticker.Stop()
+	for {
+		if !s.pollPower() {
+			return
+		}
+
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollPower reads the supply once and logs changes. It returns false if there is nothing
+// to poll.
+func (s *esp32Board) pollPower() bool {
+	ctx, cancel := context.WithTimeout(s.cancelCtx, powerReadTimeout)
+	defer cancel()
+	status, err := s.Power(ctx)
+	if err == nil {
+		s.notePower(status)
+	} else if s.cancelCtx.Err() == nil {
+		s.logger.Debugf("failed to read power telemetry: %v", err)
+	}
+	if err == nil && status.SupplyVoltage == 0 && !s.power.hasDivider {
+		s.logger.Debug("device reports no supply voltage and no divider pin is configured, not monitoring power")
+		return false
+	}
+	return true
+}
+
+// notePower logs changes in the supply since the last reading.
+func (s *esp32Board) notePower(status PowerStatus) {
+	s.power.mu.Lock()
+	primed, wasSagging, oldBrownouts := s.power.primed, s.power.sagging, s.power.brownouts
+	s.power.primed, s.power.sagging, s.power.brownouts = true, status.Sagging, status.Brownouts
+	s.power.mu.Unlock()
+
+	switch {
+	case status.Sagging && !wasSagging:
+		s.logger.Warnf("supply voltage sagged to %.2fV, below %.2fV", status.SupplyVoltage, s.power.warnBelowV)
+	case !status.Sagging && wasSagging:
+		s.logger.Infof("supply voltage recovered to %.2fV", status.SupplyVoltage)
+	}
+	if primed && status.Brownouts > oldBrownouts {
+		s.logger.Warnf("device had %d brownout resets since the last check, %d in total",
+			status.Brownouts-oldBrownouts, status.Brownouts)
+	}
+}
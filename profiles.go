@@ -0,0 +1,87 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// boardProfile is a preset for a popular ESP32 board so users can refer to its pins by
+// name without learning its GPIO matrix.
+type boardProfile struct {
+	chipVariant string
+	// pins are named pins with their modes and safe states.
+	pins []PinConfig
+	// reserved maps pins the board wires to on-board hardware to what uses them.
+	reserved map[int]string
+	// pwmPins, if set, are the only pins that may be driven with PWM.
+	pwmPins map[int]bool
+}
+
+func safeLow() *bool {
+	low := false
+	return &low
+}
+
+func safeHigh() *bool {
+	high := true
+	return &high
+}
+
+var boardProfiles = map[string]boardProfile{
+	// ESP32 DevKit V1 (DOIT, 30 pin).
+	"devkit-v1": {
+		chipVariant: "esp32",
+		pins: []PinConfig{
+			{Name: "led", Pin: 2, Mode: pinModeOutput, SafeState: safeLow()},
+			{Name: "boot", Pin: 0, Mode: pinModeInput},
+		},
+		reserved: map[int]string{1: "UART0 TX", 3: "UART0 RX"},
+	},
+	// ESP32 4 channel relay board (LC-Relay-ESP32-4R and clones).
+	"relay-4ch": {
+		chipVariant: "esp32",
+		pins: []PinConfig{
+			{Name: "relay1", Pin: 32, Mode: pinModeOutput, SafeState: safeLow()},
+			{Name: "relay2", Pin: 33, Mode: pinModeOutput, SafeState: safeLow()},
+			{Name: "relay3", Pin: 25, Mode: pinModeOutput, SafeState: safeLow()},
+			{Name: "relay4", Pin: 26, Mode: pinModeOutput, SafeState: safeLow()},
+			{Name: "led", Pin: 23, Mode: pinModeOutput, SafeState: safeLow()},
+		},
+		reserved: map[int]string{1: "UART0 TX", 3: "UART0 RX"},
+	},
+	// AI-Thinker ESP32-CAM. Most pins are taken by the camera and PSRAM; the rest are
+	// shared with the SD card slot.
+	"esp32-cam": {
+		chipVariant: "esp32",
+		pins: []PinConfig{
+			{Name: "flash", Pin: 4, Mode: pinModePWM, SafeState: safeLow()},
+			// The red status LED is active low.
+			{Name: "led", Pin: 33, Mode: pinModeOutput, SafeState: safeHigh()},
+		},
+		reserved: map[int]string{
+			0: "camera XCLK", 5: "camera D0", 18: "camera D1", 19: "camera D2", 21: "camera D3",
+			36: "camera D4", 39: "camera D5", 34: "camera D6", 35: "camera D7", 25: "camera VSYNC",
+			23: "camera HREF", 22: "camera PCLK", 26: "camera SIOD", 27: "camera SIOC", 32: "camera PWDN",
+			16: "PSRAM", 1: "UART0 TX", 3: "UART0 RX",
+		},
+		pwmPins: pinSet([]int{2, 4, 12, 13, 14, 15}),
+	},
+}
+
+// lookupBoardProfile returns the named profile. An empty name is the empty profile.
+func lookupBoardProfile(name string) (boardProfile, error) {
+	if name == "" {
+		return boardProfile{}, nil
+	}
+	profile, ok := boardProfiles[name]
+	if !ok {
+		names := make([]string, 0, len(boardProfiles))
+		for name := range boardProfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return boardProfile{}, fmt.Errorf("unknown 'profile' %q, must be one of %s", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
@@ -0,0 +1,82 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"esp32wifi/esp32client"
+)
+
+// maxRampSteps is the number of distinct duty cycles a pin can be set to.
+const maxRampSteps = 100
+
+// doRampPWM fades a pwm pin from one duty cycle (0-1) to another over duration_ms. The
+// ramp runs on the firmware as a macro, so it is smooth regardless of the network; it
+// takes "steps" equal increments (default and at most one per duty cycle percent). With
+// "wait" it blocks until the ramp finished, like run_macro.
+//
+//	{"command": "ramp_pwm", "pin": "27", "from": 0, "to": 0.8, "duration_ms": 2000}
+func (s *esp32Board) doRampPWM(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	pinNum, err := s.pinArg(cmd, "pin")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pins.checkWrite(pinNum); err != nil {
+		return nil, err
+	}
+	if err := s.pins.checkPWM(pinNum); err != nil {
+		return nil, err
+	}
+	from, err := numberArg(cmd, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := numberArg(cmd, "to")
+	if err != nil {
+		return nil, err
+	}
+	if from < 0 || from > 1 || to < 0 || to > 1 {
+		return nil, fmt.Errorf("from and to must be duty cycles between 0 and 1, got %v and %v", from, to)
+	}
+	durationMs, err := numberArg(cmd, "duration_ms")
+	if err != nil {
+		return nil, err
+	}
+	if durationMs < 0 {
+		return nil, fmt.Errorf("duration_ms must not be negative, got %v", durationMs)
+	}
+
+	fromState, toState := int(math.Round(from*100)), int(math.Round(to*100))
+	distinct := fromState - toState
+	if distinct < 0 {
+		distinct = -distinct
+	}
+	if distinct == 0 {
+		distinct = 1
+	}
+	steps, err := optionalNumberArg(cmd, "steps", float64(distinct))
+	if err != nil {
+		return nil, err
+	}
+	if steps < 1 || steps > maxRampSteps {
+		return nil, fmt.Errorf("steps must be between 1 and %d, got %v", maxRampSteps, steps)
+	}
+	n := int(math.Min(steps, float64(distinct)))
+
+	// The first step sets the starting duty cycle, each later one is a delay further.
+	macro := make([]esp32client.MacroStep, 0, n+1)
+	delayMs := int(durationMs) / n
+	for i := 0; i <= n; i++ {
+		step := esp32client.MacroStep{
+			PinNum: pinNum,
+			State:  fromState + int(math.Round(float64((toState-fromState)*i)/float64(n))),
+		}
+		if i < n {
+			step.DelayMs = delayMs
+		}
+		macro = append(macro, step)
+	}
+	wait, _ := cmd["wait"].(bool)
+	return s.runMacro(ctx, "ramp_pwm", macro, wait)
+}
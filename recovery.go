@@ -0,0 +1,231 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/resource"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultRecoveryCheckIntervalMs = 10000
+	minRecoveryCheckIntervalMs     = 1000
+	defaultPowerCycleAfterS        = 300
+	defaultPowerOffMs              = 2000
+	recoveryTimeout                = 5 * time.Second
+)
+
+// RecoveryConfig restarts a device that stopped working on its own.
+type RecoveryConfig struct {
+	// CheckIntervalMs is how often the device's /health is checked, 10000 by default.
+	CheckIntervalMs int `json:"check_interval_ms,omitempty"`
+	// RestartAfterS restarts the device once its health check has failed for this long
+	// while it still answers /info. 0 never restarts it.
+	RestartAfterS int `json:"restart_after_s,omitempty"`
+	// PowerCycle, if set, cuts the device's power once it has not answered at all for a
+	// while.
+	PowerCycle *PowerCycleConfig `json:"power_cycle,omitempty"`
+}
+
+// PowerCycleConfig is a GPIO on another board that switches the device's power, e.g.
+// through a relay.
+type PowerCycleConfig struct {
+	// Board is the board the power pin is on, a dependency of this one.
+	Board string `json:"board"`
+	Pin   string `json:"pin"`
+	// OffHigh drives the pin high to cut power. By default low cuts it.
+	OffHigh bool `json:"off_high,omitempty"`
+	// OffMs is how long the power stays off, 2000 by default.
+	OffMs int `json:"off_ms,omitempty"`
+	// AfterS is how long the device must be unreachable before its power is cycled, 300
+	// by default. It is also the least time between two power cycles.
+	AfterS int `json:"after_s,omitempty"`
+}
+
+func (cfg *RecoveryConfig) validate(path string) error {
+	if cfg.CheckIntervalMs != 0 && cfg.CheckIntervalMs < minRecoveryCheckIntervalMs {
+		return fmt.Errorf("%s: 'check_interval_ms' must be at least %d, got %d", path, minRecoveryCheckIntervalMs, cfg.CheckIntervalMs)
+	}
+	if cfg.RestartAfterS < 0 {
+		return fmt.Errorf("%s: 'restart_after_s' must not be negative", path)
+	}
+	if cfg.RestartAfterS == 0 && cfg.PowerCycle == nil {
+		return fmt.Errorf("%s: set 'restart_after_s', 'power_cycle' or both", path)
+	}
+	if pc := cfg.PowerCycle; pc != nil {
+		switch {
+		case pc.Board == "":
+			return fmt.Errorf("%s.power_cycle: missing required field 'board'", path)
+		case pc.Pin == "":
+			return fmt.Errorf("%s.power_cycle: missing required field 'pin'", path)
+		case pc.OffMs < 0:
+			return fmt.Errorf("%s.power_cycle: 'off_ms' must not be negative", path)
+		case pc.AfterS < 0:
+			return fmt.Errorf("%s.power_cycle: 'after_s' must not be negative", path)
+		}
+	}
+	return nil
+}
+
+func (cfg *RecoveryConfig) checkInterval() time.Duration {
+	if cfg.CheckIntervalMs == 0 {
+		return defaultRecoveryCheckIntervalMs * time.Millisecond
+	}
+	return time.Duration(cfg.CheckIntervalMs) * time.Millisecond
+}
+
+func (cfg *PowerCycleConfig) after() time.Duration {
+	if cfg.AfterS == 0 {
+		return defaultPowerCycleAfterS * time.Second
+	}
+	return time.Duration(cfg.AfterS) * time.Second
+}
+
+func (cfg *PowerCycleConfig) offTime() time.Duration {
+	if cfg.OffMs == 0 {
+		return defaultPowerOffMs * time.Millisecond
+	}
+	return time.Duration(cfg.OffMs) * time.Millisecond
+}
+
+// recovery runs the recovery policy and counts the actions it took.
+type recovery struct {
+	cfg *RecoveryConfig
+	// powerPin is the power_cycle pin, nil without one.
+	powerPin board.GPIOPin
+
+	mu          sync.Mutex
+	restarts    int
+	powerCycles int
+}
+
+// newRecovery looks the power_cycle pin up on its board in deps.
+func newRecovery(cfg *RecoveryConfig, deps resource.Dependencies) (*recovery, error) {
+	r := &recovery{cfg: cfg}
+	if cfg.PowerCycle == nil {
+		return r, nil
+	}
+	powerBoard, err := board.FromProvider(deps, cfg.PowerCycle.Board)
+	if err != nil {
+		return nil, fmt.Errorf("power_cycle: %w", err)
+	}
+	if r.powerPin, err = powerBoard.GPIOPinByName(cfg.PowerCycle.Pin); err != nil {
+		return nil, fmt.Errorf("power_cycle: %w", err)
+	}
+	return r, nil
+}
+
+// runRecovery checks the device's health every interval until the board is closed,
+// restarting a device that answers but is unhealthy and power cycling one that does not
+// answer at all.
+func (s *esp32Board) runRecovery() {
+	cfg := s.recovery.cfg
+	restartAfter := time.Duration(cfg.RestartAfterS) * time.Second
+	var unhealthySince, unreachableSince, lastPowerCycle time.Time
+	ticker := time.NewTicker(cfg.checkInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		healthy, answers := s.checkRecoveryHealth()
+		if s.cancelCtx.Err() != nil {
+			return
+		}
+		now := time.Now()
+		if healthy {
+			unhealthySince, unreachableSince = time.Time{}, time.Time{}
+			continue
+		}
+		if unhealthySince.IsZero() {
+			unhealthySince = now
+		}
+		if answers {
+			unreachableSince = time.Time{}
+			if restartAfter > 0 && now.Sub(unhealthySince) >= restartAfter {
+				s.restartUnhealthy(now.Sub(unhealthySince))
+				// Give the device as long again to come back before the next restart.
+				unhealthySince = time.Time{}
+			}
+			continue
+		}
+		if unreachableSince.IsZero() {
+			unreachableSince = now
+		}
+		pc := cfg.PowerCycle
+		if pc != nil && now.Sub(unreachableSince) >= pc.after() && now.Sub(lastPowerCycle) >= pc.after() {
+			s.powerCycle(now.Sub(unreachableSince))
+			lastPowerCycle = now
+			unreachableSince = time.Time{}
+		}
+	}
+}
+
+// checkRecoveryHealth reports whether the device is healthy and whether it answers at
+// all. On firmware without /health, answering /info counts as healthy.
+func (s *esp32Board) checkRecoveryHealth() (healthy, answers bool) {
+	ctx, cancel := context.WithTimeout(s.cancelCtx, recoveryTimeout)
+	defer cancel()
+	health, err := esp32client.ReadHealth(ctx, s.client)
+	if err == nil && health.Ready {
+		return true, true
+	}
+	if err != nil && !errors.Is(err, esp32client.ErrNotSupported) {
+		s.logger.Debugf("health check failed: %v", err)
+	}
+	_, infoErr := s.client.Info(ctx)
+	if errors.Is(err, esp32client.ErrNotSupported) {
+		return infoErr == nil, infoErr == nil
+	}
+	return false, infoErr == nil
+}
+
+func (s *esp32Board) restartUnhealthy(unhealthyFor time.Duration) {
+	s.logger.Warnf("device has been unhealthy for %s but still answers, restarting it", unhealthyFor.Round(time.Second))
+	ctx, cancel := context.WithTimeout(s.cancelCtx, recoveryTimeout)
+	defer cancel()
+	if err := esp32client.Restart(ctx, s.client); err != nil {
+		s.logger.Errorf("failed to restart the device: %v", err)
+		return
+	}
+	s.recovery.mu.Lock()
+	s.recovery.restarts++
+	s.recovery.mu.Unlock()
+}
+
+// powerCycle cuts the device's power for off_ms. Power is restored even if the board is
+// closed meanwhile, so a shutdown never leaves the device off.
+func (s *esp32Board) powerCycle(unreachableFor time.Duration) {
+	pc := s.recovery.cfg.PowerCycle
+	s.logger.Warnf("device has been unreachable for %s, cycling its power with pin %q of %q",
+		unreachableFor.Round(time.Second), pc.Pin, pc.Board)
+	ctx, cancel := context.WithTimeout(context.Background(), recoveryTimeout)
+	err := s.recovery.powerPin.Set(ctx, pc.OffHigh, nil)
+	cancel()
+	if err != nil {
+		s.logger.Errorf("failed to cut the device's power: %v", err)
+		return
+	}
+	select {
+	case <-s.cancelCtx.Done():
+	case <-time.After(pc.offTime()):
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), recoveryTimeout)
+	defer cancel()
+	if err := s.recovery.powerPin.Set(ctx, !pc.OffHigh, nil); err != nil {
+		s.logger.Errorf("failed to restore the device's power: %v", err)
+		return
+	}
+	s.recovery.mu.Lock()
+	s.recovery.powerCycles++
+	s.recovery.mu.Unlock()
+}
@@ -0,0 +1,278 @@
+package esp32wifi
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"esp32wifi/esp32client"
+)
+
+// devices is shared by every resource in the module so that components pointing at the
+// same ESP32 reuse one connection. The firmware only accepts a single BLE connection, so
+// opening a second one for another component would fail.
+var devices = &deviceRegistry{entries: map[string]*sharedDevice{}}
+
+type deviceRegistry struct {
+	mu      sync.Mutex
+	entries map[string]*sharedDevice
+}
+
+type sharedDevice struct {
+	// opened is closed once open has returned client or err.
+	opened chan struct{}
+	client esp32client.Client
+	err    error
+	refs   int
+	// events polls the device's events for all of its users.
+	events *eventHub
+}
+
+// httpDeviceKey returns the registry key for a device reached over HTTP, through proxy
+// if it is set. Boards using different proxies get their own connections.
+func httpDeviceKey(url, proxy string) string {
+	key := "http:" + strings.ToLower(strings.TrimSuffix(url, "/"))
+	if proxy != "" {
+		key += " via " + proxy
+	}
+	return key
+}
+
+// remoteDeviceKey returns the registry key for a device reached through the board name.
+func remoteDeviceKey(name string) string {
+	return "remote:" + name
+}
+
+// bleDeviceKey returns the registry key for a device reached over BLE.
+func bleDeviceKey(serverName string) string {
+	return "ble:" + strings.ToLower(serverName)
+}
+
+// acquire returns the client registered under key, calling open to create it if this is
+// the first user. The returned client must be closed once; the underlying connection is
+// closed when its last user closes. Users of the same key wait for the first to open it,
+// while other keys open in parallel, e.g. two BLE boards scanning for their devices.
+func (r *deviceRegistry) acquire(key string, open func() (esp32client.Client, error)) (esp32client.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		entry, ok := r.entries[key]
+		if !ok {
+			entry = &sharedDevice{opened: make(chan struct{})}
+			r.entries[key] = entry
+			r.mu.Unlock()
+			entry.client, entry.err = open()
+			if entry.err == nil {
+				entry.events = &eventHub{client: entry.client, subs: map[*eventSub]struct{}{}}
+			}
+			r.mu.Lock()
+			close(entry.opened)
+			if entry.err != nil {
+				delete(r.entries, key)
+				return nil, entry.err
+			}
+		} else {
+			r.mu.Unlock()
+			<-entry.opened
+			r.mu.Lock()
+			// The entry failed to open, or its last user closed it while we waited.
+			if r.entries[key] != entry {
+				continue
+			}
+		}
+		entry.refs++
+		return r.shared(key, entry), nil
+	}
+}
+
+// shared returns the handle of one user of entry.
+func (r *deviceRegistry) shared(key string, entry *sharedDevice) esp32client.Client {
+	return &sharedClient{
+		Client: entry.client,
+		events: entry.events,
+		release: func() error {
+			return r.release(key)
+		},
+	}
+}
+
+func (r *deviceRegistry) release(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[key]
+	if !ok {
+		return nil
+	}
+	entry.refs--
+	if entry.refs > 0 {
+		return nil
+	}
+	delete(r.entries, key)
+	return entry.client.Close()
+}
+
+// sharedClient is a registry handle on a shared client. Closing it releases the handle
+// rather than the connection.
+type sharedClient struct {
+	esp32client.Client
+	events *eventHub
+
+	once    sync.Once
+	release func() error
+}
+
+func (c *sharedClient) unwrap() esp32client.Client {
+	return c.Client
+}
+
+// Events calls fn for every event the device pushes until ctx is done. The firmware hands
+// each event to one request, so the users of a device share one poller.
+func (c *sharedClient) Events(ctx context.Context, fn func(esp32client.Event)) error {
+	return c.events.subscribe(ctx, fn)
+}
+
+func (c *sharedClient) Close() error {
+	var err error
+	c.once.Do(func() {
+		err = c.release()
+	})
+	return err
+}
+
+// eventHub runs one events poller for the users of a shared device while any of them is
+// subscribed, and hands every event to each of them.
+type eventHub struct {
+	client esp32client.Client
+
+	mu   sync.Mutex
+	subs map[*eventSub]struct{}
+	// poll is the running poller, nil while there is none.
+	poll *eventPoll
+}
+
+// eventPoll is a run of the poller, done once client.Events returned err.
+type eventPoll struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// eventSub is one user's subscription. mu is held while its fn runs, so once stopped is
+// set no event reaches it.
+type eventSub struct {
+	fn      func(esp32client.Event)
+	mu      sync.Mutex
+	stopped bool
+}
+
+// subscribe calls fn for every event until ctx is done, or returns the poller's error,
+// e.g. ErrNotSupported, if it stops first.
+func (h *eventHub) subscribe(ctx context.Context, fn func(esp32client.Event)) error {
+	sub := &eventSub{fn: fn}
+	h.mu.Lock()
+	if h.poll == nil {
+		pollCtx, cancel := context.WithCancel(context.Background())
+		h.poll = &eventPoll{cancel: cancel, done: make(chan struct{})}
+		go h.run(pollCtx, h.poll)
+	}
+	poll := h.poll
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	defer h.unsubscribe(sub)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-poll.done:
+		return poll.err
+	}
+}
+
+// unsubscribe stops sub, and the poller once no one is subscribed.
+func (h *eventHub) unsubscribe(sub *eventSub) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	if len(h.subs) == 0 && h.poll != nil {
+		h.poll.cancel()
+		h.poll = nil
+	}
+	h.mu.Unlock()
+
+	sub.mu.Lock()
+	sub.stopped = true
+	sub.mu.Unlock()
+}
+
+// run polls events until ctx is cancelled or the client stops, handing each one to every
+// subscriber in turn.
+func (h *eventHub) run(ctx context.Context, poll *eventPoll) {
+	poll.err = h.client.Events(ctx, func(event esp32client.Event) {
+		h.mu.Lock()
+		if h.poll != poll {
+			// Everyone left, and a later subscriber started another poller.
+			h.mu.Unlock()
+			return
+		}
+		subs := make([]*eventSub, 0, len(h.subs))
+		for sub := range h.subs {
+			subs = append(subs, sub)
+		}
+		h.mu.Unlock()
+		for _, sub := range subs {
+			sub.mu.Lock()
+			if !sub.stopped {
+				sub.fn(event)
+			}
+			sub.mu.Unlock()
+		}
+	})
+	h.mu.Lock()
+	if h.poll == poll {
+		h.poll = nil
+	}
+	h.mu.Unlock()
+	poll.cancel()
+	close(poll.done)
+}
+
+// wrapper is implemented by the clients in this package that wrap another client.
+type wrapper interface {
+	unwrap() esp32client.Client
+}
+
+// exportedWrapper is implemented by the esp32client clients that wrap another client,
+// such as the Recorder.
+type exportedWrapper interface {
+	Unwrap() esp32client.Client
+}
+
+// transport returns the transport client underneath c's wrappers, for features only one
+// transport has.
+func transport(c esp32client.Client) esp32client.Client {
+	for {
+		switch w := c.(type) {
+		case wrapper:
+			c = w.unwrap()
+		case exportedWrapper:
+			c = w.Unwrap()
+		default:
+			return c
+		}
+	}
+}
+
+// transportName names the transport underneath c: http, ble or hybrid.
+func transportName(c esp32client.Client) string {
+	switch transport(c).(type) {
+	case *esp32client.HTTPClient:
+		return "http"
+	case *esp32client.BLEClient:
+		return "ble"
+	case *esp32client.FailoverClient:
+		return "hybrid"
+	default:
+		return ""
+	}
+}
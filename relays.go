@@ -0,0 +1,276 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+var (
+	// ErrInterlocked is returned when energizing a relay would energize it together with
+	// a relay it is interlocked with.
+	ErrInterlocked = errors.New("relay is interlocked")
+	// ErrDwell is returned when a relay is switched before its minimum on or off time
+	// passed.
+	ErrDwell = errors.New("relay is within its minimum dwell time")
+)
+
+// RelayConfig is an output pin driving a relay or contactor, switched only through the
+// relay so its interlocks and dwell times hold.
+type RelayConfig struct {
+	Name string `json:"name"`
+	// Pin is the pin name or GPIO number driving the relay.
+	Pin string `json:"pin"`
+	// ActiveLow energizes the relay by driving the pin low, as on most relay modules.
+	ActiveLow bool `json:"active_low,omitempty"`
+	// MinOnMs and MinOffMs are how long the relay must stay energized, or de-energized,
+	// before it may be switched again.
+	MinOnMs  int `json:"min_on_ms,omitempty"`
+	MinOffMs int `json:"min_off_ms,omitempty"`
+}
+
+// InterlockConfig names relays that are never energized together, e.g. a motor's
+// forward and reverse contactors.
+type InterlockConfig struct {
+	Relays []string `json:"relays"`
+}
+
+func (cfg *RelayConfig) validate(path string, pins *pinTable) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if cfg.Pin == "" {
+		return fmt.Errorf("%s: missing required field 'pin'", path)
+	}
+	pinNum, err := pins.lookup(cfg.Pin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := pins.checkDrivable(pinNum); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if configured, ok := pins.pins[pinNum]; ok && configured.Mode != pinModeOutput {
+		return fmt.Errorf("%s: GPIO%d is configured as %s, not output", path, pinNum, configured.Mode)
+	}
+	if cfg.MinOnMs < 0 || cfg.MinOffMs < 0 {
+		return fmt.Errorf("%s: 'min_on_ms' and 'min_off_ms' must not be negative", path)
+	}
+	return nil
+}
+
+// validateRelays checks the relays and the interlocks between them.
+func validateRelays(path string, relays []RelayConfig, interlocks []InterlockConfig, pins *pinTable) error {
+	names := map[string]bool{}
+	owners := map[int]string{}
+	for i, relay := range relays {
+		relayPath := fmt.Sprintf("%s.relays.%d", path, i)
+		if err := relay.validate(relayPath, pins); err != nil {
+			return err
+		}
+		if names[relay.Name] {
+			return fmt.Errorf("%s: relay name %q is used more than once", relayPath, relay.Name)
+		}
+		names[relay.Name] = true
+		pinNum, _ := pins.lookup(relay.Pin)
+		if other, ok := owners[pinNum]; ok {
+			return fmt.Errorf("%s: GPIO%d also drives relay %q", relayPath, pinNum, other)
+		}
+		owners[pinNum] = relay.Name
+	}
+	for i, interlock := range interlocks {
+		interlockPath := fmt.Sprintf("%s.interlocks.%d", path, i)
+		seen := map[string]bool{}
+		for _, name := range interlock.Relays {
+			if !names[name] {
+				return fmt.Errorf("%s: unknown relay %q", interlockPath, name)
+			}
+			if seen[name] {
+				return fmt.Errorf("%s: relay %q is listed more than once", interlockPath, name)
+			}
+			seen[name] = true
+		}
+		if len(seen) < 2 {
+			return fmt.Errorf("%s: an interlock needs at least two relays", interlockPath)
+		}
+	}
+	return nil
+}
+
+// relay is a configured relay and what the board knows of its state.
+type relay struct {
+	cfg    RelayConfig
+	pinNum int
+	minOn  time.Duration
+	minOff time.Duration
+	// peers are the relays it is interlocked with.
+	peers []*relay
+
+	// Guarded by relaySet.mu.
+	energized bool
+	// changedAt is when the board last switched the relay, zero if it has not.
+	changedAt time.Time
+}
+
+// relaySet switches the board's relays. Its lock is held across each write, so two
+// interlocked relays cannot be energized by concurrent calls.
+type relaySet struct {
+	mu     sync.Mutex
+	relays map[string]*relay
+	// known is set once the relays' states were read from the device, and cleared when
+	// something else may have changed them, e.g. a reboot.
+	known bool
+}
+
+func newRelaySet(relays []RelayConfig, interlocks []InterlockConfig, pins *pinTable) *relaySet {
+	set := &relaySet{relays: make(map[string]*relay, len(relays))}
+	for _, cfg := range relays {
+		// Checked by validate.
+		pinNum, _ := pins.lookup(cfg.Pin)
+		set.relays[cfg.Name] = &relay{
+			cfg:    cfg,
+			pinNum: pinNum,
+			minOn:  time.Duration(cfg.MinOnMs) * time.Millisecond,
+			minOff: time.Duration(cfg.MinOffMs) * time.Millisecond,
+		}
+	}
+	for _, interlock := range interlocks {
+		for _, name := range interlock.Relays {
+			for _, peer := range interlock.Relays {
+				if peer != name {
+					set.relays[name].peers = append(set.relays[name].peers, set.relays[peer])
+				}
+			}
+		}
+	}
+	return set
+}
+
+// forget has the relays' states read again before the next switch, since something other
+// than the relays may have changed their pins.
+func (set *relaySet) forget() {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.known = false
+}
+
+// lookupRelay returns the named relay with set.mu held, having read the relays' states
+// from the device if they are not known. The caller unlocks set.mu.
+func (s *esp32Board) lookupRelay(ctx context.Context, name string) (*relay, error) {
+	r, ok := s.relays.relays[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown relay %q", name)
+	}
+	s.relays.mu.Lock()
+	if s.relays.known {
+		return r, nil
+	}
+	all := make([]*relay, 0, len(s.relays.relays))
+	pinNums := make([]int, 0, len(s.relays.relays))
+	for _, r := range s.relays.relays {
+		all = append(all, r)
+		pinNums = append(pinNums, r.pinNum)
+	}
+	reads, err := s.client.ReadPins(ctx, pinNums)
+	if err == nil && len(reads) != len(pinNums) {
+		err = fmt.Errorf("firmware returned %d readings for %d pins", len(reads), len(pinNums))
+	}
+	if err != nil {
+		s.relays.mu.Unlock()
+		return nil, fmt.Errorf("failed to read the relays' states: %w", err)
+	}
+	for i, read := range reads {
+		all[i].energized = s.states.high(all[i].pinNum, read.State) != all[i].cfg.ActiveLow
+	}
+	s.relays.known = true
+	return r, nil
+}
+
+// SetRelay energizes or releases the named relay, refusing with ErrDwell if it has not
+// been in its current state for its minimum time, and with ErrInterlocked if a relay it
+// is interlocked with is energized.
+func (s *esp32Board) SetRelay(ctx context.Context, name string, energize bool) error {
+	r, err := s.lookupRelay(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer s.relays.mu.Unlock()
+	if r.energized == energize {
+		return nil
+	}
+	if !r.changedAt.IsZero() {
+		minimum, state := r.minOff, "off"
+		if r.energized {
+			minimum, state = r.minOn, "on"
+		}
+		if left := minimum - time.Since(r.changedAt); left > 0 {
+			return fmt.Errorf("relay %q must stay %s for another %s: %w", name, state, left.Round(time.Millisecond), ErrDwell)
+		}
+	}
+	if energize {
+		for _, peer := range r.peers {
+			if peer.energized {
+				return fmt.Errorf("relay %q cannot be energized while %q is: %w", name, peer.cfg.Name, ErrInterlocked)
+			}
+		}
+	}
+	state := 0
+	if energize != r.cfg.ActiveLow {
+		state = 100
+	}
+	ctx = s.withWriteMode(ctx, true, callOptions{})
+	if err := s.client.WritePins(ctx, []esp32client.PinWrite{{PinNum: r.pinNum, State: state}}); err != nil {
+		// The write may have landed.
+		s.relays.known = false
+		return err
+	}
+	r.energized = energize
+	r.changedAt = time.Now()
+	return nil
+}
+
+// Relay reports whether the named relay is energized.
+func (s *esp32Board) Relay(ctx context.Context, name string) (bool, error) {
+	r, err := s.lookupRelay(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	defer s.relays.mu.Unlock()
+	return r.energized, nil
+}
+
+// doSetRelay energizes or releases a relay, subject to its interlocks and dwell times.
+//
+//	{"command": "set_relay", "relay": "pump", "on": true}
+func (s *esp32Board) doSetRelay(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, err := stringArg(cmd, "relay")
+	if err != nil {
+		return nil, err
+	}
+	on, err := boolArg(cmd, "on")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SetRelay(ctx, name, on); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"relay": name, "on": on}, nil
+}
+
+// doGetRelay reports whether a relay is energized.
+//
+//	{"command": "get_relay", "relay": "pump"}
+func (s *esp32Board) doGetRelay(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, err := stringArg(cmd, "relay")
+	if err != nil {
+		return nil, err
+	}
+	on, err := s.Relay(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"relay": name, "on": on}, nil
+}
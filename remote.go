@@ -0,0 +1,108 @@
+package esp32wifi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	board "go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/resource"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	// remoteScheme prefixes a 'url' naming a board the requests are forwarded through,
+	// e.g. "resource://rover:esp32" for the board "esp32" on the remote part "rover".
+	remoteScheme = "resource://"
+	// remoteClientURL is the URL the HTTP client of a forwarded board builds requests
+	// with. Only their method, path and body reach the remote board.
+	remoteClientURL = "http://remote"
+)
+
+// parseRemoteURL returns the board name in a 'url' using remoteScheme, and false for any
+// other url.
+func parseRemoteURL(raw string) (string, bool, error) {
+	if !strings.HasPrefix(raw, remoteScheme) {
+		return "", false, nil
+	}
+	name := strings.TrimPrefix(raw, remoteScheme)
+	if name == "" || strings.ContainsAny(name, "/?#") {
+		return "", true, fmt.Errorf("invalid 'url' %q: must name a board, e.g. \"%sremote:esp32\"", raw, remoteScheme)
+	}
+	return name, true, nil
+}
+
+// remoteDoer sends HTTP requests as "http" DoCommands to an esp32-wifi board on another
+// part that can reach the device, so a device only that part can reach is controlled
+// through the Viam connection.
+type remoteDoer struct {
+	board board.Board
+}
+
+// newRemoteDoer looks the board requests are forwarded through up in deps.
+func newRemoteDoer(deps resource.Dependencies, name string) (*remoteDoer, error) {
+	b, err := board.FromProvider(deps, name)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	return &remoteDoer{board: b}, nil
+}
+
+func (d *remoteDoer) Do(req *http.Request) (*http.Response, error) {
+	cmd := map[string]interface{}{"command": "http", "method": req.Method, "path": req.URL.RequestURI(), "forwarded": true}
+	if req.Body != nil {
+		raw, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		if len(raw) > 0 {
+			var body interface{}
+			if err := json.Unmarshal(raw, &body); err != nil {
+				return nil, fmt.Errorf("failed to decode request body: %w", err)
+			}
+			cmd["body"] = body
+		}
+	}
+	result, err := d.board.DoCommand(req.Context(), cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to forward through %s: %w", d.board.Name().ShortName(), err)
+	}
+
+	status := http.StatusOK
+	switch code := result["status"].(type) {
+	case float64:
+		status = int(code)
+	case int:
+		status = code
+	}
+	raw, err := json.Marshal(result["response"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode forwarded response: %w", err)
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(raw)),
+		Request:    req,
+	}, nil
+}
+
+// forwardedStatus returns the status the firmware answered a forwarded request with when
+// err is an answer the forwarding board must reproduce, e.g. 404 for an endpoint the
+// firmware does not know, and zero for any other error.
+func forwardedStatus(err error) int {
+	switch {
+	case errors.Is(err, esp32client.ErrFirmwareTooOld), errors.Is(err, esp32client.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, esp32client.ErrBoardBusy):
+		return http.StatusLocked
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/resource"
+)
+
+const (
+	defaultResetPulseMs = 100
+	maxResetPulseMs     = 10000
+)
+
+// ResetLineConfig is a GPIO on another board wired to the ESP32's EN pin, which holds the
+// chip in reset while it is low.
+type ResetLineConfig struct {
+	// Board is the board the pin is on, a dependency of this one.
+	Board string `json:"board"`
+	Pin   string `json:"pin"`
+	// ActiveHigh drives the pin high to hold the chip in reset, for lines through an
+	// inverting transistor. By default low resets it, as on EN itself.
+	ActiveHigh bool `json:"active_high,omitempty"`
+	// PulseMs is how long the chip is held in reset, 100 by default.
+	PulseMs int `json:"pulse_ms,omitempty"`
+}
+
+func (cfg *ResetLineConfig) validate(path string) error {
+	if cfg.Board == "" {
+		return fmt.Errorf("%s: missing required field 'board'", path)
+	}
+	if cfg.Pin == "" {
+		return fmt.Errorf("%s: missing required field 'pin'", path)
+	}
+	if cfg.PulseMs < 0 || cfg.PulseMs > maxResetPulseMs {
+		return fmt.Errorf("%s: 'pulse_ms' must be between 0 and %d", path, maxResetPulseMs)
+	}
+	return nil
+}
+
+func (cfg *ResetLineConfig) pulse() time.Duration {
+	if cfg.PulseMs == 0 {
+		return defaultResetPulseMs * time.Millisecond
+	}
+	return time.Duration(cfg.PulseMs) * time.Millisecond
+}
+
+// resetLine is a configured reset line with its pin looked up.
+type resetLine struct {
+	cfg *ResetLineConfig
+	pin board.GPIOPin
+}
+
+// newResetLine looks the reset pin up on its board in deps.
+func newResetLine(cfg *ResetLineConfig, deps resource.Dependencies) (*resetLine, error) {
+	resetBoard, err := board.FromProvider(deps, cfg.Board)
+	if err != nil {
+		return nil, fmt.Errorf("reset_line: %w", err)
+	}
+	pin, err := resetBoard.GPIOPinByName(cfg.Pin)
+	if err != nil {
+		return nil, fmt.Errorf("reset_line: %w", err)
+	}
+	return &resetLine{cfg: cfg, pin: pin}, nil
+}
+
+// doHardReset holds the chip in reset with the reset line, recovering firmware too hung to
+// answer a restart request.
+//
+//	{"command": "hard_reset"}
+func (s *esp32Board) doHardReset(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if s.resetLine == nil {
+		return nil, fmt.Errorf("no 'reset_line' is configured")
+	}
+	line := s.resetLine
+	s.logger.Warnf("resetting the device with pin %q of %q", line.cfg.Pin, line.cfg.Board)
+	if err := line.pin.Set(ctx, line.cfg.ActiveHigh, nil); err != nil {
+		return nil, fmt.Errorf("failed to hold the device in reset: %w", err)
+	}
+	time.Sleep(line.cfg.pulse())
+	// Released even if ctx is done by now, so the device is never left in reset.
+	releaseCtx, cancel := context.WithTimeout(context.Background(), recoveryTimeout)
+	defer cancel()
+	if err := line.pin.Set(releaseCtx, !line.cfg.ActiveHigh, nil); err != nil {
+		return nil, fmt.Errorf("failed to release the device from reset: %w", err)
+	}
+	// Firmware without events never reports this reboot.
+	s.rebooted()
+	return map[string]interface{}{"reset": true, "pulse_ms": line.cfg.pulse().Milliseconds()}, nil
+}
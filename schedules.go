@@ -0,0 +1,149 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"esp32wifi/esp32client"
+)
+
+// doSchedule starts a firmware-side schedule so pulse timing is not subject to network
+// jitter. The pin is restored to the opposite level (or low, for duty cycles) after
+// duration_ms, and the schedule repeats every interval_ms if set.
+func (s *esp32Board) doSchedule(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	write, err := s.pinWriteArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	durationMs, err := optionalNumberArg(cmd, "duration_ms", 0)
+	if err != nil {
+		return nil, err
+	}
+	delayMs, err := optionalNumberArg(cmd, "delay_ms", 0)
+	if err != nil {
+		return nil, err
+	}
+	intervalMs, err := optionalNumberArg(cmd, "interval_ms", 0)
+	if err != nil {
+		return nil, err
+	}
+	if durationMs < 0 || delayMs < 0 || intervalMs < 0 {
+		return nil, fmt.Errorf("duration_ms, delay_ms and interval_ms must not be negative")
+	}
+	if intervalMs > 0 && durationMs >= intervalMs {
+		return nil, fmt.Errorf("duration_ms (%v) must be shorter than interval_ms (%v)", durationMs, intervalMs)
+	}
+
+	restore := 0
+	if write.State == 0 {
+		restore = 100
+	}
+	schedule := esp32client.Schedule{
+		PinNum:       write.PinNum,
+		State:        write.State,
+		DurationMs:   int(durationMs),
+		RestoreState: restore,
+		DelayMs:      int(delayMs),
+		IntervalMs:   int(intervalMs),
+	}
+	// The firmware drives the pin from now on.
+	s.writes.forget(write.PinNum)
+	s.dropNoAck(write.PinNum)
+	id, err := esp32client.CreateSchedule(ctx, s.client, schedule)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = id
+
+	s.schedulesMu.Lock()
+	s.schedules[id] = schedule
+	s.schedulesMu.Unlock()
+
+	return map[string]interface{}{"id": id}, nil
+}
+
+// doCancelSchedule stops one schedule by id, or every schedule this module created that
+// is still on the device if "all" is true. A schedule the device no longer has counts as
+// cancelled.
+func (s *esp32Board) doCancelSchedule(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	var ids []int
+	if all, _ := cmd["all"].(bool); all {
+		active, err := esp32client.Schedules(ctx, s.client)
+		if err != nil {
+			return nil, err
+		}
+		ids = s.pruneSchedules(active)
+	} else {
+		id, err := numberArg(cmd, "id")
+		if err != nil {
+			return nil, err
+		}
+		ids = []int{int(id)}
+	}
+
+	cancelled := []interface{}{}
+	for _, id := range ids {
+		if err := esp32client.CancelSchedule(ctx, s.client, id); err != nil && !errors.Is(err, esp32client.ErrNotFound) {
+			return map[string]interface{}{"cancelled": cancelled}, err
+		}
+		s.schedulesMu.Lock()
+		delete(s.schedules, id)
+		s.schedulesMu.Unlock()
+		cancelled = append(cancelled, id)
+	}
+	return map[string]interface{}{"cancelled": cancelled}, nil
+}
+
+// doListSchedules returns every schedule active on the device, marking the ones created
+// through this module.
+func (s *esp32Board) doListSchedules(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	schedules, err := esp32client.Schedules(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+
+	ours := map[int]bool{}
+	for _, id := range s.pruneSchedules(schedules) {
+		ours[id] = true
+	}
+	list := make([]interface{}, 0, len(schedules))
+	for _, schedule := range schedules {
+		entry, err := toJSONValue(schedule)
+		if err != nil {
+			return nil, err
+		}
+		entry.(map[string]interface{})["created_by_module"] = ours[schedule.ID]
+		list = append(list, entry)
+	}
+	return map[string]interface{}{"schedules": list}, nil
+}
+
+// pruneSchedules forgets the schedules this module created that are not among active,
+// the device's, since they finished, and returns the ids of the ones that are.
+func (s *esp32Board) pruneSchedules(active []esp32client.Schedule) []int {
+	onDevice := make(map[int]bool, len(active))
+	for _, schedule := range active {
+		onDevice[schedule.ID] = true
+	}
+	s.schedulesMu.Lock()
+	defer s.schedulesMu.Unlock()
+	var ids []int
+	for id := range s.schedules {
+		if onDevice[id] {
+			ids = append(ids, id)
+		} else {
+			delete(s.schedules, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// forgetSchedules forgets the schedules this module created, which a reboot cleared.
+func (s *esp32Board) forgetSchedules() {
+	s.schedulesMu.Lock()
+	defer s.schedulesMu.Unlock()
+	s.schedules = map[int]esp32client.Schedule{}
+}
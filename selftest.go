@@ -0,0 +1,237 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultSelfTestPWMFreqHz = 1000
+	defaultLatencySamples    = 5
+	selfTestSettle           = 10 * time.Millisecond
+	pulseCountWindow         = 500 * time.Millisecond
+	// pwmFreqTolerance is how far the counted frequency may be from the set one.
+	pwmFreqTolerance = 0.05
+)
+
+// SelfTestConfig describes the loopback wiring the self_test command checks: OutputPin
+// is jumpered to InputPin on the test fixture.
+type SelfTestConfig struct {
+	OutputPin string `json:"output_pin"`
+	InputPin  string `json:"input_pin"`
+	// PWMFreqHz is the frequency driven on OutputPin and counted on InputPin. Defaults to
+	// 1000.
+	PWMFreqHz uint `json:"pwm_freq_hz,omitempty"`
+	// MaxLatencyMs, if set, fails the latency check when the average round trip is slower.
+	MaxLatencyMs float64 `json:"max_latency_ms,omitempty"`
+}
+
+func (cfg *SelfTestConfig) validate(path string, pins *pinTable) error {
+	if cfg.OutputPin == "" {
+		return fmt.Errorf("%s: missing required field 'output_pin'", path)
+	}
+	if cfg.InputPin == "" {
+		return fmt.Errorf("%s: missing required field 'input_pin'", path)
+	}
+	output, err := pins.lookup(cfg.OutputPin)
+	if err != nil {
+		return fmt.Errorf("%s.output_pin: %w", path, err)
+	}
+	input, err := pins.lookup(cfg.InputPin)
+	if err != nil {
+		return fmt.Errorf("%s.input_pin: %w", path, err)
+	}
+	if err := pins.checkWrite(output); err != nil {
+		return fmt.Errorf("%s.output_pin: %w", path, err)
+	}
+	if err := pins.checkRead(input); err != nil {
+		return fmt.Errorf("%s.input_pin: %w", path, err)
+	}
+	if output == input {
+		return fmt.Errorf("%s: 'output_pin' and 'input_pin' must be different pins", path)
+	}
+	if err := pins.checkPWM(output); err != nil {
+		return fmt.Errorf("%s.output_pin: %w", path, err)
+	}
+	if cfg.MaxLatencyMs < 0 {
+		return fmt.Errorf("%s: 'max_latency_ms' must not be negative", path)
+	}
+	return nil
+}
+
+// selfTestCheck is the outcome of one self_test check.
+type selfTestCheck struct {
+	name    string
+	passed  bool
+	skipped bool
+	detail  map[string]interface{}
+}
+
+func (c selfTestCheck) result() map[string]interface{} {
+	result := map[string]interface{}{"name": c.name, "passed": c.passed}
+	if c.skipped {
+		result["skipped"] = true
+	}
+	for k, v := range c.detail {
+		result[k] = v
+	}
+	return result
+}
+
+// doSelfTest commissions a board in the field: it drives the loopback output pin high and
+// low and reads each level back on the input pin, measures the round trip latency of the
+// transport, and counts the pulses of a PWM signal on the input pin. The pins can be given
+// in the command or in the 'self_test' attribute. The output pin is left low.
+//
+//	{"command": "self_test"}
+//	{"command": "self_test", "output_pin": "25", "input_pin": "26", "pwm_freq_hz": 500}
+func (s *esp32Board) doSelfTest(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	cfg := SelfTestConfig{PWMFreqHz: defaultSelfTestPWMFreqHz}
+	if s.selfTest != nil {
+		cfg = *s.selfTest
+		if cfg.PWMFreqHz == 0 {
+			cfg.PWMFreqHz = defaultSelfTestPWMFreqHz
+		}
+	}
+	for key, field := range map[string]*string{"output_pin": &cfg.OutputPin, "input_pin": &cfg.InputPin} {
+		if _, ok := cmd[key]; ok {
+			value, err := stringArg(cmd, key)
+			if err != nil {
+				return nil, err
+			}
+			*field = value
+		}
+	}
+	freq, err := optionalNumberArg(cmd, "pwm_freq_hz", float64(cfg.PWMFreqHz))
+	if err != nil {
+		return nil, err
+	}
+	if freq < 1 {
+		return nil, fmt.Errorf("pwm_freq_hz must be at least 1, got %v", freq)
+	}
+	cfg.PWMFreqHz = uint(freq)
+	if cfg.MaxLatencyMs, err = optionalNumberArg(cmd, "max_latency_ms", cfg.MaxLatencyMs); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate("self_test", s.pins); err != nil {
+		return nil, err
+	}
+	output, _ := s.pins.lookup(cfg.OutputPin)
+	input, _ := s.pins.lookup(cfg.InputPin)
+
+	s.writes.forget(output)
+	s.dropNoAck(output)
+	checks := []selfTestCheck{
+		s.checkLoopback(ctx, output, input),
+		s.checkLatency(ctx, cfg.MaxLatencyMs),
+		s.checkPWMFrequency(ctx, output, input, cfg.PWMFreqHz),
+	}
+	if err := s.client.WritePins(ctx, []esp32client.PinWrite{{PinNum: output, State: 0}}); err != nil {
+		s.logger.Warnf("self test: failed to drive pin %s low afterwards: %v", s.pins.name(output), err)
+	}
+
+	passed := true
+	results := make([]interface{}, 0, len(checks))
+	for _, check := range checks {
+		passed = passed && (check.passed || check.skipped)
+		results = append(results, check.result())
+	}
+	return map[string]interface{}{
+		"passed":    passed,
+		"transport": transportName(s.client),
+		"checks":    results,
+	}, nil
+}
+
+// checkLoopback writes both levels to output and expects to read each back on input.
+func (s *esp32Board) checkLoopback(ctx context.Context, output, input int) selfTestCheck {
+	check := selfTestCheck{name: "loopback", detail: map[string]interface{}{
+		"output_pin": s.pins.name(output),
+		"input_pin":  s.pins.name(input),
+	}}
+	for _, high := range []bool{true, false} {
+		state := 0
+		if high {
+			state = 100
+		}
+		if err := s.client.WritePins(ctx, []esp32client.PinWrite{{PinNum: output, State: state}}); err != nil {
+			check.detail["error"] = err.Error()
+			return check
+		}
+		time.Sleep(selfTestSettle)
+		reads, err := s.client.ReadPins(ctx, []int{input})
+		if err != nil {
+			check.detail["error"] = err.Error()
+			return check
+		}
+		if got := s.states.high(input, reads[0].State); got != high {
+			check.detail["error"] = fmt.Sprintf("wrote high=%t, read high=%t", high, got)
+			return check
+		}
+	}
+	check.passed = true
+	return check
+}
+
+// checkLatency times info requests, failing if the average exceeds maxMs (when set).
+func (s *esp32Board) checkLatency(ctx context.Context, maxMs float64) selfTestCheck {
+	check := selfTestCheck{name: "latency", detail: map[string]interface{}{}}
+	var total time.Duration
+	min, max := time.Duration(math.MaxInt64), time.Duration(0)
+	for i := 0; i < defaultLatencySamples; i++ {
+		start := time.Now()
+		if _, err := s.client.Info(ctx); err != nil {
+			check.detail["error"] = err.Error()
+			return check
+		}
+		elapsed := time.Since(start)
+		total += elapsed
+		if elapsed < min {
+			min = elapsed
+		}
+		if elapsed > max {
+			max = elapsed
+		}
+	}
+	avgMs := float64(total) / float64(time.Millisecond) / defaultLatencySamples
+	check.detail["min_ms"] = float64(min) / float64(time.Millisecond)
+	check.detail["avg_ms"] = avgMs
+	check.detail["max_ms"] = float64(max) / float64(time.Millisecond)
+	check.passed = maxMs == 0 || avgMs <= maxMs
+	if !check.passed {
+		check.detail["error"] = fmt.Sprintf("average latency %.1fms exceeds %vms", avgMs, maxMs)
+	}
+	return check
+}
+
+// checkPWMFrequency drives a 50% duty cycle at freqHz on output and counts it on input.
+// It is skipped if the firmware has no pulse counter.
+func (s *esp32Board) checkPWMFrequency(ctx context.Context, output, input int, freqHz uint) selfTestCheck {
+	check := selfTestCheck{name: "pwm_frequency", detail: map[string]interface{}{"expected_hz": float64(freqHz)}}
+	if err := s.setPWMFreqs(ctx, callOptions{noRetry: true}, []esp32client.PinFreq{{PinNum: output, Freq: freqHz}}); err != nil {
+		check.detail["error"] = err.Error()
+		return check
+	}
+	if err := s.client.WritePins(ctx, []esp32client.PinWrite{{PinNum: output, State: 50}}); err != nil {
+		check.detail["error"] = err.Error()
+		return check
+	}
+	count, err := esp32client.CountPulses(ctx, s.client, input, pulseCountWindow)
+	if err != nil {
+		check.skipped = errors.Is(err, esp32client.ErrNotSupported)
+		check.detail["error"] = err.Error()
+		return check
+	}
+	measured := float64(count) / pulseCountWindow.Seconds()
+	check.detail["measured_hz"] = measured
+	check.passed = math.Abs(measured-float64(freqHz)) <= pwmFreqTolerance*float64(freqHz)
+	if !check.passed {
+		check.detail["error"] = fmt.Sprintf("measured %.0fHz, expected %dHz within %.0f%%", measured, freqHz, pwmFreqTolerance*100)
+	}
+	return check
+}
@@ -0,0 +1,111 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"esp32wifi/esp32client"
+)
+
+const defaultServoFreqHz = 50
+
+// currentPWMFreq returns the frequency pinNum is driven at: the one the board set, or the
+// one the firmware reports.
+func (s *esp32Board) currentPWMFreq(ctx context.Context, name string, pinNum int, opts callOptions) (uint, error) {
+	if freq, ok := s.ledc.freq(pinNum); ok {
+		return freq, nil
+	}
+	read, err := s.readPin(ctx, name, opts)
+	if err != nil {
+		return 0, err
+	}
+	if read.Freq == 0 {
+		return 0, fmt.Errorf("the pwm frequency of pin %s is unknown, set it before a pulse width: %w", name, esp32client.ErrNotSupported)
+	}
+	return read.Freq, nil
+}
+
+// pulseDuty converts a pulse width to the duty cycle, 0-1, that drives it at freqHz.
+func pulseDuty(pulseWidthUs float64, freqHz uint) (float64, error) {
+	periodUs := 1e6 / float64(freqHz)
+	if pulseWidthUs > periodUs {
+		return 0, fmt.Errorf("a %vµs pulse does not fit the %.0fµs period of %dHz", pulseWidthUs, periodUs, freqHz)
+	}
+	return pulseWidthUs / periodUs, nil
+}
+
+// writePulseWidth drives a pulse pulseWidthUs long on pinNum at its current frequency.
+func (s *esp32Board) writePulseWidth(ctx context.Context, name string, pinNum int, pulseWidthUs float64, opts callOptions) error {
+	freq, err := s.currentPWMFreq(ctx, name, pinNum, opts)
+	if err != nil {
+		return err
+	}
+	_, err = s.writePulse(ctx, pinNum, pulseWidthUs, freq, opts)
+	return err
+}
+
+// writePulse drives a pulse pulseWidthUs long on pinNum running at freqHz and returns the
+// duty cycle written. The exact duty cycle is sent along with the nearest percent, since
+// a percent of a 50Hz period is 200µs, most of a servo's range.
+func (s *esp32Board) writePulse(ctx context.Context, pinNum int, pulseWidthUs float64, freqHz uint, opts callOptions) (float64, error) {
+	duty, err := pulseDuty(pulseWidthUs, freqHz)
+	if err != nil {
+		return 0, err
+	}
+	write := esp32client.PinWrite{PinNum: pinNum, State: int(math.Round(duty * 100)), Duty: &duty}
+	s.dropNoAck(pinNum)
+	err = s.call(ctx, opts, func(ctx context.Context) error {
+		return s.client.WritePins(ctx, []esp32client.PinWrite{write})
+	})
+	// The cache only knows whole percents, which would skip a pulse width within the same
+	// percent as the last write.
+	s.writes.forget(pinNum)
+	return duty, err
+}
+
+// doSetServoUs drives a servo pulse on a pin, first setting its frequency if freq_hz is
+// given. A pin without a known frequency is set to 50Hz, the usual servo frame rate.
+//
+//	{"command": "set_servo_us", "pin": "18", "pulse_width_us": 1500, "freq_hz": 50}
+func (s *esp32Board) doSetServoUs(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	pinNum, err := s.pinArg(cmd, "pin")
+	if err != nil {
+		return nil, err
+	}
+	pulseWidthUs, err := numberArg(cmd, "pulse_width_us")
+	if err != nil {
+		return nil, err
+	}
+	if pulseWidthUs <= 0 {
+		return nil, fmt.Errorf(`"pulse_width_us" must be positive, got %v`, pulseWidthUs)
+	}
+	if err := s.pins.checkPWM(pinNum); err != nil {
+		return nil, err
+	}
+	freq, known := s.ledc.freq(pinNum)
+	if _, ok := cmd["freq_hz"]; ok || !known {
+		hz, err := optionalNumberArg(cmd, "freq_hz", defaultServoFreqHz)
+		if err != nil {
+			return nil, err
+		}
+		if hz < 1 {
+			return nil, fmt.Errorf(`"freq_hz" must be at least 1, got %v`, hz)
+		}
+		freq = uint(hz)
+	}
+	// Checked before the frequency changes, so a pulse that does not fit changes nothing.
+	if _, err := pulseDuty(pulseWidthUs, freq); err != nil {
+		return nil, err
+	}
+	if current, _ := s.ledc.freq(pinNum); current != freq {
+		if err := s.setPWMFreqs(ctx, callOptions{}, []esp32client.PinFreq{{PinNum: pinNum, Freq: freq}}); err != nil {
+			return nil, err
+		}
+	}
+	duty, err := s.writePulse(ctx, pinNum, pulseWidthUs, freq, callOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"pin_num": pinNum, "pulse_width_us": pulseWidthUs, "freq_hz": int(freq), "duty_cycle": duty}, nil
+}
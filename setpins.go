@@ -0,0 +1,124 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"esp32wifi/esp32client"
+)
+
+// PinSetter is implemented by the boards in this package. Go programs that create a board
+// with NewEsp32Wifi or NewEsp32Ble can type assert to it to switch several pins, e.g. a
+// bank of relays, in one request instead of calling Set on each GPIOPin.
+type PinSetter interface {
+	// SetPins drives each pin high or low in a single firmware call. Pins are named as
+	// for GPIOPinByName. The pins are written together but not atomically; use the
+	// transaction command for outputs that must never be seen half switched.
+	SetPins(ctx context.Context, pins map[string]bool) error
+}
+
+// SetPins drives each pin high or low in a single firmware call.
+func (s *esp32Board) SetPins(ctx context.Context, pins map[string]bool) error {
+	writes, err := s.pinStates(pins)
+	if err != nil {
+		return err
+	}
+	return s.setPins(ctx, writes)
+}
+
+// doSetPins is SetPins for DoCommand.
+func (s *esp32Board) doSetPins(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	raw, ok := cmd["pins"]
+	if !ok {
+		return nil, errors.New(`missing required argument "pins"`)
+	}
+	entries, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`argument "pins" must be an object of pin names to booleans, got %T`, raw)
+	}
+	pins := make(map[string]bool, len(entries))
+	for name := range entries {
+		high, err := boolArg(entries, name)
+		if err != nil {
+			return nil, fmt.Errorf("pins: %w", err)
+		}
+		pins[name] = high
+	}
+	writes, err := s.pinStates(pins)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setPins(ctx, writes); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"written": len(writes)}, nil
+}
+
+// pinStates resolves pin names to writes, ordered by pin number. Two names for the same
+// pin are rejected, since only one state can be written to it.
+func (s *esp32Board) pinStates(pins map[string]bool) ([]esp32client.PinWrite, error) {
+	if len(pins) == 0 {
+		return nil, errors.New("no pins to set")
+	}
+	names := map[int]string{}
+	writes := make([]esp32client.PinWrite, 0, len(pins))
+	for name, high := range pins {
+		pinNum, err := s.pins.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.pins.checkWrite(pinNum); err != nil {
+			return nil, err
+		}
+		if other, ok := names[pinNum]; ok {
+			return nil, fmt.Errorf("pins %q and %q are both GPIO%d", other, name, pinNum)
+		}
+		names[pinNum] = name
+		state := 0
+		if high {
+			state = 100
+		}
+		writes = append(writes, esp32client.PinWrite{PinNum: pinNum, State: state})
+	}
+	sort.Slice(writes, func(i, j int) bool { return writes[i].PinNum < writes[j].PinNum })
+	return writes, nil
+}
+
+// setPins writes the pins whose state changed in one request. After a partial write the
+// applied pins are still remembered by the write cache.
+func (s *esp32Board) setPins(ctx context.Context, writes []esp32client.PinWrite) error {
+	changed := make([]esp32client.PinWrite, 0, len(writes))
+	for _, w := range writes {
+		if !s.writes.unchanged(w.PinNum, w.State) {
+			changed = append(changed, w)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	s.dropNoAckWrites(changed)
+	err := s.call(ctx, callOptions{}, func(ctx context.Context) error {
+		return s.client.WritePins(ctx, changed)
+	})
+	if err == nil {
+		s.writes.record(changed...)
+		return nil
+	}
+	var partial *esp32client.PartialWriteError
+	applied := map[int]bool{}
+	if errors.As(err, &partial) {
+		for _, pinNum := range partial.Applied {
+			applied[pinNum] = true
+		}
+	}
+	for _, w := range changed {
+		if applied[w.PinNum] {
+			s.writes.record(w)
+		} else {
+			s.writes.forget(w.PinNum)
+		}
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	setupRetryInterval = 5 * time.Second
+	setupTimeout       = 5 * time.Second
+)
+
+// deviceSetup is a piece of configuration applied to the firmware, such as an interrupt
+// handler. The firmware forgets it when it reboots.
+type deviceSetup struct {
+	// what describes the setup in logs, e.g. `interrupt "flow-meter"`.
+	what  string
+	apply func(ctx context.Context) error
+}
+
+// configureDevice applies s.setups to the firmware, retrying failed ones until all
+// succeeded and applying all of them again whenever the device reboots. Setups the
+// firmware does not support are dropped with a warning.
+func (s *esp32Board) configureDevice() {
+	setups := s.setups
+	for {
+		failed := false
+		supported := setups[:0:0]
+		for _, setup := range setups {
+			ctx, cancel := context.WithTimeout(s.cancelCtx, setupTimeout)
+			err := setup.apply(ctx)
+			cancel()
+			switch {
+			case errors.Is(err, esp32client.ErrNotSupported):
+				s.logger.Warnf("firmware cannot configure %s, set it up in the firmware instead: %v", setup.what, err)
+				continue
+			case err != nil:
+				failed = true
+				if s.cancelCtx.Err() == nil {
+					s.logger.Debugf("failed to configure %s, retrying: %v", setup.what, err)
+				}
+			}
+			supported = append(supported, setup)
+		}
+		setups = supported
+		if len(setups) == 0 {
+			return
+		}
+
+		var retry <-chan time.Time
+		if failed {
+			retry = time.After(setupRetryInterval)
+		}
+		select {
+		case <-retry:
+		case <-s.reconfigure:
+		case <-s.cancelCtx.Done():
+			return
+		}
+	}
+}
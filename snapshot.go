@@ -0,0 +1,54 @@
+package esp32wifi
+
+import (
+	"context"
+	"sort"
+)
+
+// doSnapshot reads every configured pin in one request and reports each in the units its
+// mode uses: a level for input and output pins, a duty cycle (0-1) for pwm pins, a
+// calibrated reading for analog pins and the raw value for dac pins. write_only pins are
+// left out.
+//
+//	{"command": "snapshot"}
+func (s *esp32Board) doSnapshot(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	pinNums := make([]int, 0, len(s.pins.pins))
+	for pinNum, pin := range s.pins.pins {
+		if !pin.WriteOnly {
+			pinNums = append(pinNums, pinNum)
+		}
+	}
+	sort.Ints(pinNums)
+
+	pins := map[string]interface{}{}
+	if len(pinNums) == 0 {
+		return map[string]interface{}{"pins": pins}, nil
+	}
+	reads, err := s.client.ReadPins(ctx, pinNums)
+	if err != nil {
+		return nil, err
+	}
+	for i, read := range reads {
+		pinNum := pinNums[i]
+		mode := s.pins.pins[pinNum].Mode
+		entry := map[string]interface{}{"pin": pinNum, "mode": mode}
+		switch mode {
+		case pinModePWM:
+			entry["duty_cycle"] = s.states.percent(read.State) / 100
+			if read.Freq != 0 {
+				entry["freq_hz"] = read.Freq
+			}
+		case pinModeAnalog:
+			// Filters are left alone, a snapshot is not one of the pin's regular reads.
+			raw := s.states.counts(read.State)
+			entry["value"] = s.calibrated(pinNum, raw)
+			entry["raw"] = raw
+		case pinModeDAC:
+			entry["value"] = read.State
+		default:
+			entry["high"] = s.states.high(pinNum, read.State)
+		}
+		pins[s.pins.name(pinNum)] = entry
+	}
+	return map[string]interface{}{"pins": pins}, nil
+}
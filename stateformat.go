@@ -0,0 +1,164 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	// percentFullScale is the state of a pin at full scale in the percent format.
+	percentFullScale = 100
+	// rawGetThreshold is where Get reads high by default in the raw format, since the ADC
+	// counts of a high line rarely reach full scale exactly.
+	rawGetThreshold = 0.5
+)
+
+// stateFormat tracks the scale of the states the firmware reads pins as: 0-100 percent of
+// full scale on older firmware, 12-bit ADC counts on newer firmware. Digital reads are
+// interpreted on the percent scale and analog reads as counts, whichever the firmware
+// sends, so boards in a fleet of mixed firmware read alike.
+type stateFormat struct {
+	configured string
+	// logic is how digital reads are interpreted, and pinLogic the pins that override it.
+	logic    logicLevel
+	pinLogic map[int]logicLevel
+
+	mu       sync.Mutex
+	detected string
+	// levels are the last level each pin read, which hysteresis holds it at.
+	levels map[int]bool
+}
+
+// logicLevel interprets a state as high or low: high at or above threshold, a fraction of
+// full scale, and low once back below threshold minus hysteresis. A zero threshold is
+// the format's default.
+type logicLevel struct {
+	threshold, hysteresis float64
+}
+
+func newStateFormat(cfg *BoardConfig, pins *pinTable) *stateFormat {
+	f := &stateFormat{
+		configured: cfg.StateFormat,
+		logic:      logicLevel{hysteresis: cfg.GetHysteresis},
+		pinLogic:   map[int]logicLevel{},
+		levels:     map[int]bool{},
+	}
+	if cfg.GetThreshold != nil {
+		f.logic.threshold = *cfg.GetThreshold
+	}
+	for pinNum, pin := range pins.pins {
+		if pin.GetThreshold == nil && pin.GetHysteresis == nil {
+			continue
+		}
+		logic := f.logic
+		if pin.GetThreshold != nil {
+			logic.threshold = *pin.GetThreshold
+		}
+		if pin.GetHysteresis != nil {
+			logic.hysteresis = *pin.GetHysteresis
+		}
+		f.pinLogic[pinNum] = logic
+	}
+	return f
+}
+
+// format returns the configured or detected format, or "" if neither is known, in which
+// case states are taken as they are sent: percent for digital reads, counts for analog.
+func (f *stateFormat) format() string {
+	if f.configured != "" {
+		return f.configured
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.detected
+}
+
+// detect records the format the firmware reports, reporting whether it changed.
+func (f *stateFormat) detect(format string) bool {
+	if format != esp32client.StateFormatPercent && format != esp32client.StateFormatRaw {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	changed := f.detected != format
+	f.detected = format
+	return changed
+}
+
+// percent returns state as a percent of full scale, the scale of writes.
+func (f *stateFormat) percent(state float64) float64 {
+	if f.format() == esp32client.StateFormatRaw {
+		return state * percentFullScale / adcFullScale
+	}
+	return state
+}
+
+// counts returns an analog read's state in ADC counts.
+func (f *stateFormat) counts(state float64) float64 {
+	if f.format() == esp32client.StateFormatPercent {
+		return state * adcFullScale / percentFullScale
+	}
+	return state
+}
+
+// high reports whether a digital read of pinNum is high. Only a state at full scale is
+// high by default, except in the raw format. With hysteresis, a pin that read high stays
+// high until its state drops below the band, so a state hovering at the threshold, e.g.
+// a noisy ADC count or a PWM pin near it, does not flap.
+func (f *stateFormat) high(pinNum int, state float64) bool {
+	logic, ok := f.pinLogic[pinNum]
+	if !ok {
+		logic = f.logic
+	}
+	threshold := logic.threshold
+	if threshold == 0 {
+		threshold = 1
+		if f.format() == esp32client.StateFormatRaw {
+			threshold = rawGetThreshold
+		}
+	}
+	level := f.percent(state) / percentFullScale
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	high := level >= threshold || (f.levels[pinNum] && level >= threshold-logic.hysteresis)
+	if logic.hysteresis != 0 {
+		f.levels[pinNum] = high
+	}
+	return high
+}
+
+// validateStateFormat checks the state_format attribute.
+func validateStateFormat(format string) error {
+	switch format {
+	case "", esp32client.StateFormatPercent, esp32client.StateFormatRaw:
+		return nil
+	default:
+		return fmt.Errorf("invalid 'state_format' %q, must be %q or %q, or unset to detect it",
+			format, esp32client.StateFormatPercent, esp32client.StateFormatRaw)
+	}
+}
+
+// validateLogicLevel checks a get_threshold and get_hysteresis.
+func validateLogicLevel(threshold, hysteresis *float64) error {
+	if threshold != nil && (math.IsNaN(*threshold) || *threshold <= 0 || *threshold > 1) {
+		return fmt.Errorf("'get_threshold' must be above 0 and at most 1, got %v", *threshold)
+	}
+	if hysteresis == nil {
+		return nil
+	}
+	if math.IsNaN(*hysteresis) || *hysteresis < 0 || *hysteresis >= 1 {
+		return fmt.Errorf("'get_hysteresis' must be at least 0 and below 1, got %v", *hysteresis)
+	}
+	if threshold != nil && *hysteresis >= *threshold {
+		return fmt.Errorf("'get_hysteresis' %v must be below 'get_threshold' %v", *hysteresis, *threshold)
+	}
+	if threshold == nil && *hysteresis >= rawGetThreshold {
+		// The default threshold is as low as rawGetThreshold.
+		return fmt.Errorf("'get_hysteresis' must be below %v unless 'get_threshold' is set, got %v", rawGetThreshold, *hysteresis)
+	}
+	return nil
+}
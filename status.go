@@ -0,0 +1,128 @@
+package esp32wifi
+
+import (
+	"context"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+// doStatus reports the connection, what the firmware has pushed about itself and the
+// supply telemetry. Power is left out if it cannot be read.
+//
+//	{"command": "status"}
+func (s *esp32Board) doStatus(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	conn := s.Status()
+	result := map[string]interface{}{
+		"connected":        conn.Connected,
+		"latency_ms":       float64(conn.Latency) / float64(time.Millisecond),
+		"firmware_version": conn.FirmwareVersion,
+		"workers":          s.workers.names(),
+	}
+	if conn.LastError != nil {
+		result["last_error"] = conn.LastError.Error()
+		result["last_error_time"] = conn.LastErrorTime.Format(time.RFC3339)
+	}
+
+	s.health.mu.Lock()
+	result["reboots"] = s.health.reboots
+	if s.health.lastRebootReason != "" {
+		result["last_reboot_reason"] = s.health.lastRebootReason
+	}
+	wifi, boot := s.health.wifi, s.health.boot
+	s.health.mu.Unlock()
+	if boot.known {
+		result["boot_count"] = boot.bootCount
+		result["reset_reason"] = boot.resetReason
+		result["uptime_s"] = boot.uptimeNow().Seconds()
+	}
+	if timers := s.ledc.timerUsage(); len(timers) > 0 {
+		result["pwm_timers"] = timers
+	}
+	if len(s.alerts) > 0 {
+		result["alerts"] = s.activeAlerts()
+	}
+	if s.lease != nil {
+		result["lease"] = s.lease.status()
+	}
+	if noAck := s.noAckWrites.status(); noAck != nil {
+		result["no_ack"] = noAck
+	}
+	if polled := s.polls.status(); polled != nil {
+		result["polled_interrupts"] = polled
+	}
+	if webhooks := s.webhookStatus(); webhooks != nil {
+		result["webhooks"] = webhooks
+	}
+	if features := s.features.status(); features != nil {
+		result["features"] = features
+	}
+	if streams := s.analogStreams.status(s.pins); streams != nil {
+		result["analog_streams"] = streams
+	}
+	if httpClient, ok := transport(s.client).(*esp32client.HTTPClient); ok {
+		if len(httpClient.URLs()) > 1 {
+			result["url"] = httpClient.URL()
+		}
+		if latencies := httpClient.AdaptiveTimeouts(); latencies != nil {
+			timeouts := make(map[string]interface{}, len(latencies))
+			for _, l := range latencies {
+				timeouts[l.Endpoint] = map[string]interface{}{
+					"average_ms": float64(l.Average) / float64(time.Millisecond),
+					"timeout_ms": float64(l.Timeout) / float64(time.Millisecond),
+				}
+			}
+			result["adaptive_timeouts"] = timeouts
+		}
+	}
+	if s.bleDevice != "" {
+		if ble, ok := esp32client.BLEDeviceMetrics(s.bleDevice); ok {
+			result["ble"] = bleMetricsStatus(ble)
+		}
+	}
+	if s.recovery != nil {
+		s.recovery.mu.Lock()
+		result["recovery_restarts"] = s.recovery.restarts
+		result["recovery_power_cycles"] = s.recovery.powerCycles
+		s.recovery.mu.Unlock()
+	}
+	if wifi != nil {
+		value, err := toJSONValue(wifi)
+		if err != nil {
+			return nil, err
+		}
+		result["wifi"] = value
+	}
+
+	power, err := s.Power(ctx)
+	if err != nil {
+		result["power_error"] = err.Error()
+		return result, nil
+	}
+	result["brownouts"] = power.Brownouts
+	if power.SupplyVoltage != 0 {
+		result["supply_voltage"] = power.SupplyVoltage
+		result["supply_sagging"] = power.Sagging
+	}
+	return result, nil
+}
+
+// bleMetricsStatus reports the health of a BLE link, with durations in milliseconds.
+func bleMetricsStatus(m esp32client.BLEMetrics) map[string]interface{} {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	return map[string]interface{}{
+		"dials":              m.Dials,
+		"dial_failures":      m.DialFailures,
+		"last_scan_ms":       ms(m.LastScan),
+		"last_connect_ms":    ms(m.LastConnect),
+		"last_discovery_ms":  ms(m.LastDiscovery),
+		"avg_dial_ms":        ms(m.AvgDial),
+		"disconnects":        m.Disconnects,
+		"last_reconnect_ms":  ms(m.LastReconnect),
+		"writes":             m.Writes,
+		"write_failures":     m.WriteFailures,
+		"write_failure_rate": m.WriteFailureRate(),
+	}
+}
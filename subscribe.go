@@ -0,0 +1,63 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	board "go.viam.com/rdk/components/board"
+
+	"esp32wifi/esp32client"
+)
+
+// Tick is a GPIO state change delivered to Subscribe callbacks.
+type Tick = board.Tick
+
+// Subscriber is implemented by the boards in this package. Go programs that create a board
+// with NewEsp32Wifi or NewEsp32Ble can type assert to it to register callbacks for pin
+// changes instead of managing StreamTicks channels themselves.
+type Subscriber interface {
+	// Subscribe calls fn every time the named pin changes level. The returned func
+	// stops the subscription and waits for it, so it must not be called from fn;
+	// subscriptions also stop when the board is closed.
+	Subscribe(pin string, fn func(Tick)) (func(), error)
+}
+
+// Subscribe calls fn every time the named pin changes level.
+func (s *esp32Board) Subscribe(pin string, fn func(Tick)) (func(), error) {
+	if fn == nil {
+		return nil, fmt.Errorf("subscribe to pin %s: callback must not be nil", pin)
+	}
+	pinNum, err := s.pins.lookup(pin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pins.checkRead(pinNum); err != nil {
+		return nil, err
+	}
+	stop, err := s.client.Subscribe(s.cancelCtx, pinNum, func(read esp32client.PinRead) {
+		fn(Tick{Name: pin, High: s.states.high(pinNum, read.State), TimestampNanosec: uint64(time.Now().UnixNano())})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The subscription is a worker so Close waits for its polling to stop.
+	unsubscribed, stopped := make(chan struct{}), make(chan struct{})
+	if !s.goBackground("subscription", func() {
+		defer close(stopped)
+		select {
+		case <-unsubscribed:
+		case <-s.cancelCtx.Done():
+		}
+		stop()
+	}) {
+		stop()
+		return nil, errBoardClosed
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(unsubscribed) })
+		<-stopped
+	}, nil
+}
@@ -0,0 +1,134 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"esp32wifi/esp32client"
+)
+
+const tracerName = "esp32wifi"
+
+// TracingConfig exports a span for every request to the device.
+type TracingConfig struct {
+	// OTLPEndpoint is the host:port of an OTLP gRPC collector. Without it spans go to the
+	// process's global tracer provider.
+	OTLPEndpoint string `json:"otlp_endpoint,omitempty"`
+	// Insecure sends spans to the collector without TLS.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// newTracerProvider returns the configured provider, and a func that flushes and stops it
+// if it belongs to the board.
+func newTracerProvider(cfg *TracingConfig, boardName string) (trace.TracerProvider, func(context.Context) error, error) {
+	if cfg == nil || cfg.OTLPEndpoint == "" {
+		return otel.GetTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	// The exporter connects lazily, so an unreachable collector does not fail the board.
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create otlp exporter for %s: %w", cfg.OTLPEndpoint, err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName("esp32-wifi"),
+			attribute.String("esp32.board", boardName),
+		)),
+	)
+	return provider, provider.Shutdown, nil
+}
+
+// tracedClient wraps every request to the device in a span.
+type tracedClient struct {
+	esp32client.Client
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+func newTracedClient(client esp32client.Client, provider trace.TracerProvider, boardName string) *tracedClient {
+	attrs := []attribute.KeyValue{attribute.String("esp32.board", boardName)}
+	if name := transportName(client); name != "" {
+		attrs = append(attrs, attribute.String("esp32.transport", name))
+	}
+	if c, ok := transport(client).(*esp32client.HTTPClient); ok {
+		attrs = append(attrs, attribute.String("url.full", c.URL()))
+	}
+	return &tracedClient{Client: client, tracer: provider.Tracer(tracerName), attrs: attrs}
+}
+
+func (c *tracedClient) unwrap() esp32client.Client {
+	return c.Client
+}
+
+// start starts a span for a request. end must be deferred with the request's error.
+func (c *tracedClient) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "esp32."+name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(c.attrs...), trace.WithAttributes(attrs...))
+}
+
+func end(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+func pinNums[T any](items []T, pinNum func(T) int) attribute.KeyValue {
+	pins := make([]int, len(items))
+	for i, item := range items {
+		pins[i] = pinNum(item)
+	}
+	return attribute.IntSlice("esp32.pins", pins)
+}
+
+func (c *tracedClient) ReadPins(ctx context.Context, pins []int) (reads []esp32client.PinRead, err error) {
+	ctx, span := c.start(ctx, "ReadPins", attribute.IntSlice("esp32.pins", pins))
+	defer end(span, &err)
+	return c.Client.ReadPins(ctx, pins)
+}
+
+func (c *tracedClient) WritePins(ctx context.Context, writes []esp32client.PinWrite) (err error) {
+	ctx, span := c.start(ctx, "WritePins", pinNums(writes, func(w esp32client.PinWrite) int { return w.PinNum }))
+	defer end(span, &err)
+	return c.Client.WritePins(ctx, writes)
+}
+
+func (c *tracedClient) Transaction(ctx context.Context, writes []esp32client.PinWrite) (err error) {
+	ctx, span := c.start(ctx, "Transaction", pinNums(writes, func(w esp32client.PinWrite) int { return w.PinNum }))
+	defer end(span, &err)
+	return c.Client.Transaction(ctx, writes)
+}
+
+func (c *tracedClient) SetPWMFreqs(ctx context.Context, freqs []esp32client.PinFreq) (err error) {
+	ctx, span := c.start(ctx, "SetPWMFreqs", pinNums(freqs, func(f esp32client.PinFreq) int { return f.PinNum }))
+	defer end(span, &err)
+	return c.Client.SetPWMFreqs(ctx, freqs)
+}
+
+func (c *tracedClient) Info(ctx context.Context) (info esp32client.Info, err error) {
+	ctx, span := c.start(ctx, "Info")
+	defer end(span, &err)
+	return c.Client.Info(ctx)
+}
+
+func (c *tracedClient) Call(ctx context.Context, method, path string, body, out interface{}) (err error) {
+	ctx, span := c.start(ctx, "Call",
+		attribute.String("http.request.method", method), attribute.String("url.path", path))
+	defer end(span, &err)
+	return c.Client.Call(ctx, method, path, body, out)
+}
@@ -0,0 +1,41 @@
+package esp32wifi
+
+import (
+	"context"
+
+	"esp32wifi/esp32client"
+)
+
+// releasePin stops driving pinNum, leaving it a high-impedance input another device can
+// drive, e.g. on a shared bus.
+func (s *esp32Board) releasePin(ctx context.Context, pinNum int, opts callOptions) error {
+	if err := s.pins.checkWrite(pinNum); err != nil {
+		return err
+	}
+	s.dropNoAck(pinNum)
+	err := s.call(ctx, opts, func(ctx context.Context) error {
+		return esp32client.SetPinModes(ctx, s.client, []esp32client.PinMode{{PinNum: pinNum, Mode: esp32client.PinModeInput}})
+	})
+	// The level last written is no longer driven, so writing it again must not be skipped.
+	s.writes.forget(pinNum)
+	return err
+}
+
+// doReleasePin releases a pin to high impedance and returns the level it reads once
+// released, i.e. the level something else drives the line to.
+//
+//	{"command": "release_pin", "pin": "21"}
+func (s *esp32Board) doReleasePin(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	pinNum, err := s.pinArg(cmd, "pin")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.releasePin(ctx, pinNum, callOptions{}); err != nil {
+		return nil, err
+	}
+	read, err := s.readPinNum(ctx, pinNum, callOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"pin_num": pinNum, "high": s.states.high(pinNum, read.State)}, nil
+}
@@ -0,0 +1,155 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.viam.com/rdk/components/board"
+)
+
+// AnalogUnitsConfig converts an analog reader's counts to an engineering unit: the count
+// times VoltsPerCount gives the volts at the pin, which becomes volts*scale + offset.
+type AnalogUnitsConfig struct {
+	// Name is the unit, e.g. "psi" or "°C", reported by read_analog.
+	Name string `json:"name"`
+	// VoltsPerCount is the volts one ADC count stands for, e.g. 3.3/4095.
+	VoltsPerCount float64 `json:"volts_per_count"`
+	// Scale is 1 by default.
+	Scale  *float64 `json:"scale,omitempty"`
+	Offset float64  `json:"offset,omitempty"`
+}
+
+func (cfg *AnalogUnitsConfig) validate(path string) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if cfg.VoltsPerCount <= 0 || math.IsInf(cfg.VoltsPerCount, 0) {
+		return fmt.Errorf("%s: 'volts_per_count' must be positive", path)
+	}
+	if cfg.Scale != nil && (*cfg.Scale == 0 || math.IsNaN(*cfg.Scale) || math.IsInf(*cfg.Scale, 0)) {
+		return fmt.Errorf("%s: 'scale' must be a nonzero number", path)
+	}
+	if math.IsNaN(cfg.Offset) || math.IsInf(cfg.Offset, 0) {
+		return fmt.Errorf("%s: 'offset' must be a finite number", path)
+	}
+	return nil
+}
+
+// analogUnits is the conversion of a configured reader.
+type analogUnits struct {
+	name          string
+	voltsPerCount float64
+	scale, offset float64
+}
+
+// newAnalogUnits resolves cfg, which must be valid, returning nil if it is nil.
+func newAnalogUnits(cfg *AnalogUnitsConfig) *analogUnits {
+	if cfg == nil {
+		return nil
+	}
+	u := &analogUnits{name: cfg.Name, voltsPerCount: cfg.VoltsPerCount, scale: 1, offset: cfg.Offset}
+	if cfg.Scale != nil {
+		u.scale = *cfg.Scale
+	}
+	return u
+}
+
+func (u *analogUnits) volts(counts float64) float64 {
+	return counts * u.voltsPerCount
+}
+
+func (u *analogUnits) convert(counts float64) float64 {
+	return u.volts(counts)*u.scale + u.offset
+}
+
+// analogValue returns counts, rounded, with the conversion to the unit: Min and Max are
+// the unit at zero and full scale, and StepSize the unit per count, so the value in the
+// unit is Min + Value*StepSize. Max is below Min for a negative scale. read_analog
+// returns the unrounded value in the unit.
+func (u *analogUnits) analogValue(counts float64) board.AnalogValue {
+	return board.AnalogValue{
+		Value:    int(math.Round(counts)),
+		Min:      float32(u.convert(0)),
+		Max:      float32(u.convert(adcFullScale)),
+		StepSize: float32(u.voltsPerCount * u.scale),
+	}
+}
+
+// analogValueReader reads an analog without rounding, in its units if it has them. The
+// boards in this package implement it; the esp32-rules service reads analogs through it.
+type analogValueReader interface {
+	readAnalogValue(ctx context.Context, name string) (float64, error)
+}
+
+// readAnalogValue reads an analog as read_analog's value, or a differential analog as
+// its Read does.
+func (s *esp32Board) readAnalogValue(ctx context.Context, name string) (float64, error) {
+	if diff, ok := s.differentials[name]; ok {
+		read, err := (&differentialAnalogClient{esp32Board: s, analogName: name, diff: diff}).Read(ctx, nil)
+		return float64(read.Value), err
+	}
+	opts, err := parseExtra(nil)
+	if err != nil {
+		return 0, err
+	}
+	_, value, err := s.readAnalog(ctx, name, opts)
+	if err != nil {
+		return 0, err
+	}
+	if reader, ok := s.analogs[name]; ok && reader.units != nil {
+		value = reader.units.convert(value)
+	}
+	return value, nil
+}
+
+// commandAnalogValueReader reads analogs through the read_analog command of a board it
+// cannot type assert.
+type commandAnalogValueReader struct {
+	board board.Board
+}
+
+func (c commandAnalogValueReader) readAnalogValue(ctx context.Context, name string) (float64, error) {
+	resp, err := c.board.DoCommand(ctx, map[string]interface{}{"command": "read_analog", "analog": name})
+	if err != nil {
+		return 0, err
+	}
+	value, ok := resp["value"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("board %q did not report analog %q, is it an esp32 board from this module?", c.board.Name().ShortName(), name)
+	}
+	return value, nil
+}
+
+// doReadAnalog reads an analog with the raw count alongside the value, and for readers
+// with units the unrounded value in the unit and the volts at the pin. It takes the same
+// options as Read's extra.
+//
+//	{"command": "read_analog", "analog": "pressure"}
+func (s *esp32Board) doReadAnalog(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	name, err := stringArg(cmd, "analog")
+	if err != nil {
+		return nil, err
+	}
+	extra := map[string]interface{}{}
+	for key, value := range cmd {
+		if key != "command" && key != "analog" {
+			extra[key] = value
+		}
+	}
+	opts, err := parseExtra(extra)
+	if err != nil {
+		return nil, err
+	}
+	raw, value, err := s.readAnalog(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"analog": name, "raw": raw, "value": value}
+	if reader, ok := s.analogs[name]; ok && reader.units != nil && !opts.raw {
+		result["value"] = reader.units.convert(value)
+		result["units"] = reader.units.name
+		result["volts"] = reader.units.volts(value)
+	}
+	return result, nil
+}
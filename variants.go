@@ -0,0 +1,133 @@
+package esp32wifi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// chipVariant describes the GPIO matrix of one ESP32 family member.
+type chipVariant struct {
+	// gpios are the pin numbers that exist and are safe to use. Pins wired to the
+	// SPI flash are left out.
+	gpios map[int]bool
+	// inputOnly pins have no output driver.
+	inputOnly map[int]bool
+	// adc maps analog-capable pins to their ADC unit (1 or 2).
+	adc map[int]int
+	// adc2WiFiConflict is set if WiFi holds ADC2 while it is active, so ADC2 pins return
+	// garbage or time out on a connected chip. Later chips arbitrate between the two.
+	adc2WiFiConflict bool
+	// dac pins can output a true analog voltage.
+	dac map[int]bool
+	// ledcGroups is the number of LEDC speed modes, each with ledcTimers timers and
+	// ledcChannels channels. A channel can only use the timers of its own speed mode.
+	ledcGroups   int
+	ledcTimers   int
+	ledcChannels int
+}
+
+const defaultChipVariant = "esp32"
+
+var chipVariants = map[string]chipVariant{
+	"esp32": {
+		gpios:     pinSet(pinRange(0, 5), pinRange(12, 19), pinRange(21, 23), pinRange(25, 27), pinRange(32, 39)),
+		inputOnly: pinSet(pinRange(34, 39)),
+		adc:       adcUnits(pinSet(pinRange(32, 39)), pinSet([]int{0, 2, 4}, pinRange(12, 15), pinRange(25, 27))),
+		dac:       pinSet([]int{25, 26}),
+
+		adc2WiFiConflict: true,
+		ledcGroups:       2,
+		ledcTimers:       4,
+		ledcChannels:     8,
+	},
+	"esp32s2": {
+		gpios:     pinSet(pinRange(0, 21), pinRange(33, 46)),
+		inputOnly: pinSet([]int{46}),
+		adc:       adcUnits(pinSet(pinRange(1, 10)), pinSet(pinRange(11, 20))),
+		dac:       pinSet([]int{17, 18}),
+
+		ledcGroups:   1,
+		ledcTimers:   4,
+		ledcChannels: 8,
+	},
+	"esp32s3": {
+		gpios:     pinSet(pinRange(0, 21), pinRange(33, 48)),
+		inputOnly: map[int]bool{},
+		adc:       adcUnits(pinSet(pinRange(1, 10)), pinSet(pinRange(11, 20))),
+		dac:       map[int]bool{},
+
+		ledcGroups:   1,
+		ledcTimers:   4,
+		ledcChannels: 8,
+	},
+	"esp32c3": {
+		gpios:     pinSet(pinRange(0, 11), pinRange(18, 21)),
+		inputOnly: map[int]bool{},
+		adc:       adcUnits(pinSet(pinRange(0, 4)), pinSet([]int{5})),
+		dac:       map[int]bool{},
+
+		adc2WiFiConflict: true,
+		ledcGroups:       1,
+		ledcTimers:       4,
+		ledcChannels:     6,
+	},
+}
+
+// lookupChipVariant returns the named variant, defaulting to the original ESP32.
+func lookupChipVariant(name string) (chipVariant, error) {
+	if name == "" {
+		name = defaultChipVariant
+	}
+	variant, ok := chipVariants[name]
+	if !ok {
+		return chipVariant{}, fmt.Errorf("unknown 'chip_variant' %q, must be one of esp32, esp32s2, esp32s3 or esp32c3", name)
+	}
+	return variant, nil
+}
+
+func pinRange(from, to int) []int {
+	pins := make([]int, 0, to-from+1)
+	for pin := from; pin <= to; pin++ {
+		pins = append(pins, pin)
+	}
+	return pins
+}
+
+func pinSet(groups ...[]int) map[int]bool {
+	set := map[int]bool{}
+	for _, group := range groups {
+		for _, pin := range group {
+			set[pin] = true
+		}
+	}
+	return set
+}
+
+func adcUnits(adc1, adc2 map[int]bool) map[int]int {
+	units := map[int]int{}
+	for pin := range adc1 {
+		units[pin] = 1
+	}
+	for pin := range adc2 {
+		units[pin] = 2
+	}
+	return units
+}
+
+// adc1Pins lists the pins on ADC1, e.g. "32, 33, 34".
+func (v chipVariant) adc1Pins() string {
+	var pins []int
+	for pin, unit := range v.adc {
+		if unit == 1 && v.gpios[pin] {
+			pins = append(pins, pin)
+		}
+	}
+	sort.Ints(pins)
+	names := make([]string, len(pins))
+	for i, pin := range pins {
+		names[i] = strconv.Itoa(pin)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,98 @@
+package esp32wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"esp32wifi/esp32client"
+)
+
+// verifyTolerance is how far, in percent of full scale, a PWM pin may read back from its
+// written duty cycle, since the LEDC timer's resolution rounds it.
+const verifyTolerance = 1
+
+// verifyingPins returns the pins configured verify_writes, or nil if there are none.
+func (t *pinTable) verifyingPins() map[int]bool {
+	var pins map[int]bool
+	for pinNum, pin := range t.pins {
+		if pin.VerifyWrites {
+			if pins == nil {
+				pins = map[int]bool{}
+			}
+			pins[pinNum] = true
+		}
+	}
+	return pins
+}
+
+// verifiedClient reads back the verify_writes pins of every acknowledged write, in one
+// request, and fails the write with ErrWriteNotVerified if the device does not hold the
+// state written, so a write the firmware dropped does not pass silently.
+type verifiedClient struct {
+	esp32client.Client
+	pins   *pinTable
+	verify map[int]bool
+	states *stateFormat
+}
+
+func (c *verifiedClient) unwrap() esp32client.Client {
+	return c.Client
+}
+
+func (c *verifiedClient) WritePins(ctx context.Context, writes []esp32client.PinWrite) error {
+	if err := c.Client.WritePins(ctx, writes); err != nil {
+		return err
+	}
+	return c.check(ctx, writes)
+}
+
+func (c *verifiedClient) Transaction(ctx context.Context, writes []esp32client.PinWrite) error {
+	if err := c.Client.Transaction(ctx, writes); err != nil {
+		return err
+	}
+	return c.check(ctx, writes)
+}
+
+// check reads back the verified pins among writes. The last write to a pin is the one
+// it must hold.
+func (c *verifiedClient) check(ctx context.Context, writes []esp32client.PinWrite) error {
+	want := map[int]float64{}
+	var pinNums []int
+	for _, write := range writes {
+		if !c.verify[write.PinNum] {
+			continue
+		}
+		if _, ok := want[write.PinNum]; !ok {
+			pinNums = append(pinNums, write.PinNum)
+		}
+		want[write.PinNum] = float64(write.State)
+		if write.Duty != nil {
+			want[write.PinNum] = *write.Duty * 100
+		}
+	}
+	if len(pinNums) == 0 {
+		return nil
+	}
+
+	reads, err := c.Client.ReadPins(ctx, pinNums)
+	if err != nil {
+		return fmt.Errorf("failed to read back written pins: %w", err)
+	}
+	if len(reads) != len(pinNums) {
+		return fmt.Errorf("firmware returned %d readings for %d pins", len(reads), len(pinNums))
+	}
+	var mismatches []string
+	for i, read := range reads {
+		pinNum := pinNums[i]
+		state := c.states.percent(read.State)
+		if math.Abs(state-want[pinNum]) > verifyTolerance {
+			mismatches = append(mismatches, fmt.Sprintf("pin %s reads %g after writing %g", c.pins.name(pinNum), state, want[pinNum]))
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", strings.Join(mismatches, "; "), ErrWriteNotVerified)
+}
@@ -0,0 +1,117 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"esp32wifi/esp32client"
+)
+
+const (
+	defaultWarmUpTimeoutMs = 10000
+	warmUpPollInterval     = 500 * time.Millisecond
+	maxDiscardSamples      = 100
+)
+
+// WarmUpConfig holds construction until the device is ready, so the components that use
+// the board start with reliable data.
+type WarmUpConfig struct {
+	// TimeoutMs is how long to wait for the device to report ready, 10000 by default. The
+	// board fails to start if it is not ready by then.
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// DiscardSamples is how many readings of every analog pin are dropped once the
+	// device is ready, while the ADC settles.
+	DiscardSamples int `json:"discard_samples,omitempty"`
+}
+
+func (cfg *WarmUpConfig) validate(path string) error {
+	if cfg.TimeoutMs < 0 {
+		return fmt.Errorf("%s: 'timeout_ms' must not be negative", path)
+	}
+	if cfg.DiscardSamples < 0 || cfg.DiscardSamples > maxDiscardSamples {
+		return fmt.Errorf("%s: 'discard_samples' must be between 0 and %d", path, maxDiscardSamples)
+	}
+	return nil
+}
+
+// warmUp waits for the device to report ready on /health, or to answer /info on firmware
+// without it, and then discards the configured number of analog samples.
+func (s *esp32Board) warmUp(ctx context.Context, cfg *WarmUpConfig) error {
+	timeoutMs := cfg.TimeoutMs
+	if timeoutMs == 0 {
+		timeoutMs = defaultWarmUpTimeoutMs
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := s.waitReady(ctx); err != nil {
+		return fmt.Errorf("device not ready after %dms: %w", timeoutMs, err)
+	}
+
+	pinNums := s.analogPinNums()
+	for i := 0; i < cfg.DiscardSamples && len(pinNums) > 0; i++ {
+		if _, err := s.client.ReadPins(ctx, pinNums); err != nil {
+			return fmt.Errorf("failed to discard warm-up sample %d: %w", i+1, err)
+		}
+	}
+	s.logger.Infof("device ready after %v", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// waitReady polls the device until it is ready or ctx is done, returning the last reason
+// it was not ready.
+func (s *esp32Board) waitReady(ctx context.Context) error {
+	ticker := time.NewTicker(warmUpPollInterval)
+	defer ticker.Stop()
+	for {
+		health, err := esp32client.ReadHealth(ctx, s.client)
+		if errors.Is(err, esp32client.ErrNotSupported) {
+			_, err = s.client.Info(ctx)
+			health.Ready = err == nil
+		}
+		switch {
+		case err == nil && health.Ready:
+			return nil
+		case err == nil:
+			err = fmt.Errorf("firmware is not ready: %s", health.Reason)
+		}
+		s.logger.Debugf("waiting for the device to warm up: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// analogPinNums returns every configured analog pin and the pins of analog readers and
+// differential analogs.
+func (s *esp32Board) analogPinNums() []int {
+	seen := map[int]bool{}
+	var pinNums []int
+	add := func(pinNum int) {
+		if !seen[pinNum] {
+			seen[pinNum] = true
+			pinNums = append(pinNums, pinNum)
+		}
+	}
+	for pinNum, pin := range s.pins.pins {
+		if pin.Mode == pinModeAnalog {
+			add(pinNum)
+		}
+	}
+	for _, reader := range s.analogs {
+		add(reader.pinNum)
+	}
+	for _, diff := range s.differentials {
+		add(diff.pinA)
+		add(diff.pinB)
+	}
+	sort.Ints(pinNums)
+	return pinNums
+}
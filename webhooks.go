@@ -0,0 +1,318 @@
+package esp32wifi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookRetries   = 3
+	defaultWebhookTimeoutMs = 5000
+	// webhookRetryBackoff is the wait before the first retry of a post, doubled for each
+	// retry after it.
+	webhookRetryBackoff = 500 * time.Millisecond
+	// webhookQueueSize bounds the posts waiting on a slow target. Changes past it are
+	// dropped and counted.
+	webhookQueueSize = 64
+)
+
+// Sources of a webhook payload.
+const (
+	webhookSourcePin       = "pin"
+	webhookSourceInterrupt = "interrupt"
+)
+
+// WebhookConfig posts a JSON payload to URL whenever a watched pin changes level or a
+// watched interrupt ticks, so the device's events can drive automations outside Viam
+// without polling.
+type WebhookConfig struct {
+	// Name identifies the webhook in logs, status and its payloads.
+	Name string `json:"name"`
+	// URL is the http or https address payloads are posted to.
+	URL string `json:"url"`
+	// Pins are pins, by name or number, whose level changes are posted.
+	Pins []string `json:"pins,omitempty"`
+	// Interrupts are digital interrupts, or alerts with tick, whose ticks are posted.
+	Interrupts []string `json:"interrupts,omitempty"`
+	// DebounceMs holds a change back this long and posts only the last level of a pin or
+	// interrupt that changed again meanwhile, e.g. for a bouncing switch. 0 posts every
+	// change.
+	DebounceMs int `json:"debounce_ms,omitempty"`
+	// Retries is how many times a failed post is retried, with backoff, 3 by default.
+	Retries *int `json:"retries,omitempty"`
+	// TimeoutMs bounds each post, 5000 by default.
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// Headers are added to every post, e.g. an Authorization header.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// validate checks the webhook. interrupts are the names Interrupts may hold.
+func (cfg *WebhookConfig) validate(path string, pins *pinTable, interrupts map[string]bool) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s: missing required field 'name'", path)
+	}
+	if cfg.URL == "" {
+		return fmt.Errorf("%s: missing required field 'url'", path)
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("%s: invalid 'url': %w", path, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: invalid 'url' %q: must be an http or https url", path, u.Redacted())
+	}
+	if len(cfg.Pins) == 0 && len(cfg.Interrupts) == 0 {
+		return fmt.Errorf("%s: set at least one of 'pins' or 'interrupts'", path)
+	}
+	for _, pin := range cfg.Pins {
+		pinNum, err := pins.lookup(pin)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := pins.checkRead(pinNum); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for _, name := range cfg.Interrupts {
+		if !interrupts[name] {
+			return fmt.Errorf("%s: unknown interrupt %q, must be a digital interrupt or an alert with 'tick'", path, name)
+		}
+	}
+	if cfg.DebounceMs < 0 {
+		return fmt.Errorf("%s: 'debounce_ms' must not be negative", path)
+	}
+	if cfg.Retries != nil && *cfg.Retries < 0 {
+		return fmt.Errorf("%s: 'retries' must not be negative", path)
+	}
+	if cfg.TimeoutMs < 0 {
+		return fmt.Errorf("%s: 'timeout_ms' must not be negative", path)
+	}
+	return nil
+}
+
+// webhookPayload is the body of a post.
+type webhookPayload struct {
+	Webhook string `json:"webhook"`
+	Board   string `json:"board"`
+	// Source is pin or interrupt, and Name the name of the pin or interrupt.
+	Source string    `json:"source"`
+	Name   string    `json:"name"`
+	High   bool      `json:"high"`
+	Time   time.Time `json:"time"`
+	// Changes counts the changes the payload stands for, more than one if debouncing
+	// held several back.
+	Changes int `json:"changes"`
+}
+
+// webhook delivers the payloads of one WebhookConfig from a queue, so a slow target does
+// not hold up the ticks it is posted from.
+type webhook struct {
+	cfg     WebhookConfig
+	retries int
+	client  *http.Client
+	// interruptPins are the pins the watched interrupts tick on.
+	interruptPins map[int]bool
+	queue         chan webhookPayload
+
+	mu sync.Mutex
+	// debouncing are the changes held back, by source and name.
+	debouncing map[string]*webhookPayload
+	sent       int64
+	failed     int64
+	dropped    int64
+	lastError  string
+}
+
+// newWebhooks returns the board's webhooks, once its interrupts are known.
+func (s *esp32Board) newWebhooks(cfgs []WebhookConfig) []*webhook {
+	hooks := make([]*webhook, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		timeoutMs := cfg.TimeoutMs
+		if timeoutMs == 0 {
+			timeoutMs = defaultWebhookTimeoutMs
+		}
+		hook := &webhook{
+			cfg:           cfg,
+			retries:       defaultWebhookRetries,
+			client:        &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond},
+			interruptPins: map[int]bool{},
+			queue:         make(chan webhookPayload, webhookQueueSize),
+			debouncing:    map[string]*webhookPayload{},
+		}
+		if cfg.Retries != nil {
+			hook.retries = *cfg.Retries
+		}
+		for _, name := range cfg.Interrupts {
+			pinNum, err := s.interruptPin(name)
+			if err != nil {
+				s.logger.Errorf("webhook %q cannot watch interrupt %s: %v", cfg.Name, name, err)
+				continue
+			}
+			hook.interruptPins[pinNum] = true
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// startWebhooks subscribes to the pins the webhooks watch and starts delivering.
+func (s *esp32Board) startWebhooks() {
+	for _, hook := range s.webhooks {
+		s.goBackground("webhook", func() { s.deliverWebhook(hook) })
+		for _, pin := range hook.cfg.Pins {
+			if _, err := s.Subscribe(pin, func(tick Tick) {
+				hook.change(s, webhookSourcePin, pin, tick.High, time.Unix(0, int64(tick.TimestampNanosec)))
+			}); err != nil {
+				s.logger.Errorf("webhook %q cannot watch pin %s: %v", hook.cfg.Name, pin, err)
+			}
+		}
+	}
+}
+
+// tickWebhooks posts an interrupt's tick to the webhooks watching it.
+func (s *esp32Board) tickWebhooks(pinNum int, name string, high bool, at time.Time) {
+	for _, hook := range s.webhooks {
+		if hook.interruptPins[pinNum] {
+			hook.change(s, webhookSourceInterrupt, name, high, at)
+		}
+	}
+}
+
+// change queues the post of a change, or holds it back while debouncing.
+func (h *webhook) change(s *esp32Board, source, name string, high bool, at time.Time) {
+	payload := webhookPayload{
+		Webhook: h.cfg.Name,
+		Board:   s.name.ShortName(),
+		Source:  source,
+		Name:    name,
+		High:    high,
+		Time:    at,
+		Changes: 1,
+	}
+	if h.cfg.DebounceMs == 0 {
+		h.enqueue(s, payload)
+		return
+	}
+	key := source + "/" + name
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if held, ok := h.debouncing[key]; ok {
+		held.High, held.Time = high, at
+		held.Changes++
+		return
+	}
+	h.debouncing[key] = &payload
+	time.AfterFunc(time.Duration(h.cfg.DebounceMs)*time.Millisecond, func() {
+		h.mu.Lock()
+		held := h.debouncing[key]
+		delete(h.debouncing, key)
+		h.mu.Unlock()
+		h.enqueue(s, *held)
+	})
+}
+
+func (h *webhook) enqueue(s *esp32Board, payload webhookPayload) {
+	select {
+	case h.queue <- payload:
+	default:
+		h.mu.Lock()
+		h.dropped++
+		h.mu.Unlock()
+		s.logger.Warnf("webhook %q is falling behind, dropped a change of %s %s", h.cfg.Name, payload.Source, payload.Name)
+	}
+}
+
+// deliverWebhook posts the webhook's queued payloads until the board is closed.
+func (s *esp32Board) deliverWebhook(h *webhook) {
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case payload := <-h.queue:
+			err := s.postWebhook(h, payload)
+			h.mu.Lock()
+			if err != nil {
+				h.failed++
+				h.lastError = err.Error()
+			} else {
+				h.sent++
+			}
+			h.mu.Unlock()
+			if err != nil && s.cancelCtx.Err() == nil {
+				s.logger.Warnf("webhook %q failed to post a change of %s %s: %v", h.cfg.Name, payload.Source, payload.Name, err)
+			}
+		}
+	}
+}
+
+// postWebhook posts payload, retrying with backoff.
+func (s *esp32Board) postWebhook(h *webhook, payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	backoff := webhookRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = h.post(s.cancelCtx, body)
+		if err == nil || attempt == h.retries {
+			return err
+		}
+		s.logger.Debugf("webhook %q post failed, retrying in %s: %v", h.cfg.Name, backoff, err)
+		select {
+		case <-s.cancelCtx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (h *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range h.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookStatus returns the delivery counts of each webhook by name, or nil if there are
+// none.
+func (s *esp32Board) webhookStatus() map[string]interface{} {
+	if len(s.webhooks) == 0 {
+		return nil
+	}
+	status := make(map[string]interface{}, len(s.webhooks))
+	for _, h := range s.webhooks {
+		h.mu.Lock()
+		entry := map[string]interface{}{
+			"sent":    h.sent,
+			"failed":  h.failed,
+			"dropped": h.dropped,
+			"queued":  len(h.queue),
+		}
+		if h.lastError != "" {
+			entry["last_error"] = h.lastError
+		}
+		h.mu.Unlock()
+		status[h.cfg.Name] = entry
+	}
+	return status
+}
@@ -0,0 +1,51 @@
+package esp32wifi
+
+import (
+	"context"
+
+	"esp32wifi/esp32client"
+)
+
+func (s *esp32Board) doWiFiScan(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	networks, err := esp32client.ScanWiFi(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+	list, err := toJSONValue(networks)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"networks": list}, nil
+}
+
+// doWiFiConnect moves the device to another network. The device is unreachable while it
+// joins, and if it gets a new address the board's url must be updated to match.
+func (s *esp32Board) doWiFiConnect(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	ssid, err := stringArg(cmd, "ssid")
+	if err != nil {
+		return nil, err
+	}
+	var password string
+	if _, ok := cmd["password"]; ok {
+		if password, err = stringArg(cmd, "password"); err != nil {
+			return nil, err
+		}
+	}
+	if err := esp32client.ConnectWiFi(ctx, s.client, ssid, password); err != nil {
+		return nil, err
+	}
+	s.logger.Warnf("device is switching to wifi network %q and may come back at a different address", ssid)
+	return map[string]interface{}{"ssid": ssid}, nil
+}
+
+func (s *esp32Board) doWiFiStatus(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	status, err := esp32client.ReadWiFiStatus(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+	result, err := toJSONValue(status)
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]interface{}), nil
+}
@@ -0,0 +1,74 @@
+package esp32wifi
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errBoardClosed is returned by calls that would start work on a closed board.
+var errBoardClosed = errors.New("board is closed")
+
+// workerGroup tracks the board's background goroutines. Every worker must return once
+// ctx is done; stop cancels ctx and waits for them all, so a closed board, or one whose
+// construction failed, leaves no goroutines behind.
+type workerGroup struct {
+	ctx    context.Context
+	cancel func()
+
+	mu      sync.Mutex
+	stopped bool
+	running map[string]int
+	wg      sync.WaitGroup
+}
+
+func newWorkerGroup() *workerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerGroup{ctx: ctx, cancel: cancel, running: map[string]int{}}
+}
+
+// start runs fn in a goroutine. name says what the worker does in the status command.
+// It returns false without running fn once the group is stopped.
+func (g *workerGroup) start(name string, fn func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return false
+	}
+	g.running[name]++
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.finished(name)
+		fn()
+	}()
+	return true
+}
+
+func (g *workerGroup) finished(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running[name]--; g.running[name] == 0 {
+		delete(g.running, name)
+	}
+}
+
+// stop cancels ctx and waits for every worker to return. It may be called more than once.
+func (g *workerGroup) stop() {
+	g.mu.Lock()
+	g.stopped = true
+	g.mu.Unlock()
+	g.cancel()
+	g.wg.Wait()
+}
+
+// names returns how many workers of each name are running.
+func (g *workerGroup) names() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make(map[string]interface{}, len(g.running))
+	for name, n := range g.running {
+		names[name] = n
+	}
+	return names
+}
@@ -0,0 +1,61 @@
+package esp32wifi
+
+import (
+	"sync"
+
+	"esp32wifi/esp32client"
+)
+
+// writeCache remembers the last state written to each pin so repeated identical writes
+// can skip the round trip to the device. It is only used when suppress_repeat_writes is
+// set; a disabled cache never skips.
+type writeCache struct {
+	enabled bool
+
+	mu   sync.Mutex
+	last map[int]int
+}
+
+func newWriteCache(enabled bool) *writeCache {
+	return &writeCache{enabled: enabled, last: map[int]int{}}
+}
+
+// unchanged reports whether state is what was last written to pinNum.
+func (c *writeCache) unchanged(pinNum, state int) bool {
+	if !c.enabled {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, ok := c.last[pinNum]
+	return ok && last == state
+}
+
+// record remembers successful writes.
+func (c *writeCache) record(writes ...esp32client.PinWrite) {
+	if !c.enabled {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, w := range writes {
+		c.last[w.PinNum] = w.State
+	}
+}
+
+// forget drops the pins whose state is no longer known, e.g. after a failed write or
+// while the firmware drives them itself.
+func (c *writeCache) forget(pinNums ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, pinNum := range pinNums {
+		delete(c.last, pinNum)
+	}
+}
+
+// reset drops every pin, e.g. when the device rebooted.
+func (c *writeCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last = map[int]int{}
+}